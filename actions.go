@@ -0,0 +1,80 @@
+package main
+
+import "errors"
+
+// hostActions is everything the action menu (`a`) offers for h: the key
+// bindings that act on the highlighted host, then programs sshpick can hand
+// the terminal to.
+func (m model) hostActions(h sshHost) []hostAction {
+	actions := []hostAction{
+		{label: "Connect", key: "enter"},
+		{label: "Browse files (sftp)", name: "sftp", args: sftpArgs(h)},
+		{label: "Copy to another host (scp -3)", key: "d"},
+	}
+	if hostSource(h) == "config" && m.configPath != "" && !m.readOnly {
+		actions = append(actions, hostAction{label: "Edit in $EDITOR", key: "e"})
+	}
+	detail := "Show details"
+	if m.showDetail {
+		detail = "Hide details"
+	}
+	actions = append(actions, hostAction{label: detail, key: "i"})
+	if !skipsBatchProbes(h) {
+		actions = append(actions, hostAction{label: "Who is logged in", key: "u"})
+	}
+	maint := "Mark as in maintenance"
+	if _, ok := m.inMaintenance(h); ok {
+		maint = "Clear maintenance mark"
+	}
+	if !m.readOnly {
+		actions = append(actions, hostAction{label: maint, key: "M"})
+	}
+	if !m.readOnly && len(hostPermProblems(h, defaultSSHDir())) > 0 {
+		actions = append(actions, hostAction{label: "Fix file permissions", key: "X"})
+	}
+	actions = append(actions, outOfBandActions(h)...)
+	actions = append(actions, desktopActions(h)...)
+	return actions
+}
+
+// transferHostArgs mirror hostArgs with the spelling scp and sftp use for
+// the port flag, without the destination.
+func transferHostArgs(h sshHost) []string {
+	return append(hostKeyArgs(true), transferRouteArgs(h)...)
+}
+
+// transferRouteArgs are the config file, port, jump and identity flags of
+// transferHostArgs, for callers choosing their own host key handling.
+func transferRouteArgs(h sshHost) []string {
+	args := configFileArgs()
+	if hostSource(h) != "config" {
+		if h.Port != "" {
+			args = append(args, "-P", h.Port)
+		}
+		if pc := h.option("proxycommand"); pc != "" {
+			args = append(args, "-o", "ProxyCommand="+pc)
+		} else if jump := h.option("proxyjump"); jump != "" {
+			args = append(args, "-J", jump)
+		}
+		for _, id := range h.IdentityFiles {
+			args = append(args, "-i", id)
+		}
+	}
+	return args
+}
+
+// sftpArgs opens an interactive sftp session on h.
+func sftpArgs(h sshHost) []string {
+	return append(transferHostArgs(h), "--", sshTarget(h))
+}
+
+func (m model) openActionMenu() model {
+	if len(m.hosts) == 0 {
+		m.err = errors.New("no hosts to act on")
+		return m
+	}
+	h := m.hosts[m.cursor]
+	m.err = nil
+	m.actions = &actionMenu{title: "Actions for " + h.Alias, items: m.hostActions(h)}
+	return m
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestActionMenu_RunsKeyBinding(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel([]sshHost{{Alias: "web1", Source: "config"}}, "", "/tmp/ssh_config")
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	m = next.(model)
+	if m.actions == nil {
+		t.Fatalf("expected the action menu to open")
+	}
+	labels := map[string]bool{}
+	for _, a := range m.actions.items {
+		labels[a.label] = true
+	}
+	for _, want := range []string{"Connect", "Browse files (sftp)", "Edit in $EDITOR", "Show details"} {
+		if !labels[want] {
+			t.Fatalf("missing %q in %v", want, m.actions.items)
+		}
+	}
+
+	for m.actions.items[m.actions.cursor].key != "i" {
+		next, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+		m = next.(model)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if m.actions != nil || !m.showDetail {
+		t.Fatalf("expected the menu to close and details to show")
+	}
+}
+
+func TestSftpArgs(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Alias: "db1", Hostname: "10.0.0.5", User: "postgres", Port: "2222", Source: "team"}
+	got := sftpArgs(h)
+	want := []string{"-P", "2222", "--", "postgres@10.0.0.5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sftpArgs = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// knownAgents name the agents people point IdentityAgent at, matched
+// case-insensitively against the socket path.
+var knownAgents = []struct{ pattern, name string }{
+	{"1password", "1Password"}, // ~/Library/Group Containers/2BUA8C4S2C.com.1password/t/agent.sock, ~/.1password/agent.sock
+	{"secretive", "Secretive"}, // ~/Library/Containers/com.maxgoedjen.Secretive.SecretAgent/Data/socket.ssh
+	{"gpg-agent", "gpg-agent"}, // $(gpgconf --list-dirs agent-ssh-socket)
+	{"bitwarden", "Bitwarden"},
+}
+
+// agentSocket is the agent socket ssh will use for h, and the IdentityAgent
+// value it came from ("" when ssh falls back to $SSH_AUTH_SOCK). sock is ""
+// when h uses no agent.
+func agentSocket(h sshHost) (sock, setting string) {
+	setting = strings.Trim(h.effectiveOption("identityagent"), `"`)
+	switch {
+	case setting == "":
+		return os.Getenv("SSH_AUTH_SOCK"), ""
+	case strings.EqualFold(setting, "none"):
+		return "", setting
+	case setting == "SSH_AUTH_SOCK":
+		return os.Getenv("SSH_AUTH_SOCK"), setting
+	case strings.HasPrefix(setting, "$") && !strings.HasPrefix(setting, "${"):
+		return os.Getenv(setting[1:]), setting
+	}
+	return expandHome(tokenReplacer(h).Replace(os.ExpandEnv(setting))), setting
+}
+
+// agentName is the agent behind sock, e.g. "1Password", or "ssh-agent".
+func agentName(sock string) string {
+	lower := strings.ToLower(sock)
+	for _, a := range knownAgents {
+		if strings.Contains(lower, a.pattern) {
+			return a.name
+		}
+	}
+	return "ssh-agent"
+}
+
+// socketExists reports whether path is a unix socket.
+func socketExists(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode()&os.ModeSocket != 0
+}
+
+// agentSummary is the detail pane's Agent line.
+func agentSummary(h sshHost) string {
+	sock, setting := agentSocket(h)
+	switch {
+	case strings.EqualFold(setting, "none"):
+		return "none (IdentityAgent none)"
+	case sock == "" && setting == "":
+		return ""
+	case sock == "":
+		return fmt.Sprintf("IdentityAgent %s is not set", setting)
+	}
+	s := agentName(sock) + " " + tildePath(sock)
+	if !socketExists(sock) {
+		s += " (missing)"
+	}
+	return s
+}
+
+// agentCheck warns before connecting when h names an agent in IdentityAgent
+// whose socket isn't there: the app isn't running, or the path is wrong, and
+// ssh would quietly fall back to keys on disk or a password.
+func agentCheck(_ model, h sshHost) *connectPrompt {
+	sock, setting := agentSocket(h)
+	if setting == "" || strings.EqualFold(setting, "none") {
+		return nil
+	}
+	if sock == "" {
+		return &connectPrompt{id: "agent", message: fmt.Sprintf("%s uses IdentityAgent %s, which is not set.", h.Alias, setting)}
+	}
+	if socketExists(sock) {
+		return nil
+	}
+	name := agentName(sock)
+	return &connectPrompt{
+		id:      "agent",
+		message: fmt.Sprintf("%s uses the %s agent at %s, but the socket doesn't exist. Is %s running?", h.Alias, name, tildePath(sock), name),
+	}
+}
+
+// checkIdentityAgents is doctor's check of every IdentityAgent socket the
+// config names; the default $SSH_AUTH_SOCK is checkAgent's.
+func checkIdentityAgents(hosts []sshHost) []finding {
+	usedBy := map[string][]string{}
+	var socks []string
+	for _, h := range hosts {
+		sock, setting := agentSocket(h)
+		if setting == "" || sock == "" {
+			continue
+		}
+		if usedBy[sock] == nil {
+			socks = append(socks, sock)
+		}
+		usedBy[sock] = append(usedBy[sock], h.Alias)
+	}
+	sort.Strings(socks)
+	var out []finding
+	for _, sock := range socks {
+		who := fmt.Sprintf("%s agent %s (used by %s)", agentName(sock), sock, strings.Join(usedBy[sock], ", "))
+		conn, err := net.DialTimeout("unix", sock, time.Second)
+		if err != nil {
+			out = append(out, finding{Level: levelWarn, Msg: fmt.Sprintf("%s is not reachable: %v", who, err), Fix: "start the agent or fix the IdentityAgent path"})
+			continue
+		}
+		conn.Close()
+		out = append(out, finding{Level: levelOK, Msg: who + " reachable"})
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAgentSocket(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("SSH_AUTH_SOCK", "/tmp/ssh-abc/agent.1")
+	t.Setenv("OP_SOCK", "/run/op.sock")
+
+	cases := []struct {
+		value, sock, name string
+	}{
+		{"", "/tmp/ssh-abc/agent.1", "ssh-agent"},
+		{"SSH_AUTH_SOCK", "/tmp/ssh-abc/agent.1", "ssh-agent"},
+		{"$OP_SOCK", "/run/op.sock", "ssh-agent"},
+		{`"~/Library/Group Containers/2BUA8C4S2C.com.1password/t/agent.sock"`, filepath.Join(dir, "Library/Group Containers/2BUA8C4S2C.com.1password/t/agent.sock"), "1Password"},
+		{"~/Library/Containers/com.maxgoedjen.Secretive.SecretAgent/Data/socket.ssh", filepath.Join(dir, "Library/Containers/com.maxgoedjen.Secretive.SecretAgent/Data/socket.ssh"), "Secretive"},
+		{"%d/.gnupg/S.gpg-agent.ssh", filepath.Join(dir, ".gnupg/S.gpg-agent.ssh"), "gpg-agent"},
+		{"none", "", ""},
+	}
+	for _, c := range cases {
+		h := sshHost{Alias: "db1", Origins: []optionOrigin{{Key: "identityagent", Value: c.value}}}
+		sock, _ := agentSocket(h)
+		if sock != c.sock {
+			t.Errorf("%q: socket %q, want %q", c.value, sock, c.sock)
+		}
+		if c.name != "" && agentName(sock) != c.name {
+			t.Errorf("%q: agent %q, want %q", c.value, agentName(sock), c.name)
+		}
+	}
+}
+
+func TestAgentCheck(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	sock := filepath.Join(dir, "agent.sock")
+	h := sshHost{Alias: "db1", Options: map[string]string{"identityagent": "~/agent.sock"}}
+
+	p := agentCheck(model{}, h)
+	if p == nil || !strings.Contains(p.message, "doesn't exist") {
+		t.Fatalf("missing socket: %+v", p)
+	}
+	if s := agentSummary(h); !strings.HasSuffix(s, "(missing)") {
+		t.Fatalf("summary %q", s)
+	}
+
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip("unix sockets unavailable:", err)
+	}
+	defer l.Close()
+	if p := agentCheck(model{}, h); p != nil {
+		t.Fatalf("socket exists: %s", p.message)
+	}
+	if got := checkIdentityAgents([]sshHost{h}); len(got) != 1 || got[0].Level != levelOK {
+		t.Fatalf("doctor: %+v", got)
+	}
+	// no IdentityAgent: $SSH_AUTH_SOCK is doctor's business, not a prompt
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(dir, "gone"))
+	if p := agentCheck(model{}, sshHost{Alias: "web"}); p != nil {
+		t.Fatalf("default agent prompted: %s", p.message)
+	}
+}
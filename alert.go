@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	alertTimeout = 10 * time.Second
+	// maxAlertLines caps the hosts listed in one alert; the rest are
+	// counted, so a network outage doesn't post a wall of text.
+	maxAlertLines = 20
+)
+
+// alertPayload is what an alert webhook receives. A lone "text" field is
+// what Slack incoming webhooks expect, and Mattermost, Rocket.Chat and
+// most chat bridges accept the same.
+type alertPayload struct {
+	Text string `json:"text"`
+}
+
+// postAlert posts text to the webhook at url.
+func postAlert(url, text string) error {
+	body, err := json.Marshal(alertPayload{Text: text})
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: alertTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// alertList is lines as a bulleted list, at most maxAlertLines of them.
+func alertList(lines []string) string {
+	var b strings.Builder
+	for i, l := range lines {
+		if i == maxAlertLines {
+			fmt.Fprintf(&b, "\n…and %d more", len(lines)-i)
+			break
+		}
+		b.WriteString("\n• " + l)
+	}
+	return b.String()
+}
+
+// checkAlert is the alert for a `sshpick check` report, or "" when every
+// host passed.
+func checkAlert(report checkReport) string {
+	if report.Failed == 0 {
+		return ""
+	}
+	var lines []string
+	for _, r := range report.Hosts {
+		if r.OK {
+			continue
+		}
+		for _, s := range []struct {
+			name string
+			step checkStep
+		}{{"resolve", r.Resolve}, {"tcp", r.TCP}, {"ssh", r.SSH}} {
+			if s.step.Status == checkFailed {
+				lines = append(lines, fmt.Sprintf("%s (%s): %s failed: %s", r.Alias, r.Address, s.name, s.step.Error))
+				break
+			}
+		}
+	}
+	return fmt.Sprintf("sshpick check: %d of %d hosts failing", report.Failed, len(report.Hosts)) + alertList(lines)
+}
+
+// daemonAlert is the alert for a refresh's status changes: hosts that went
+// down (or were down when first seen) and hosts back up after being down.
+// "" when there is nothing to report.
+func daemonAlert(prev []latencyResult, changes []statusChange) string {
+	known := map[string]bool{}
+	for _, c := range prev {
+		known[c.Host.Alias] = true
+	}
+	var down, up []string
+	for _, c := range changes {
+		switch {
+		case !c.Up:
+			down = append(down, c.Alias+": "+c.Error)
+		case known[c.Alias]:
+			up = append(up, c.Alias)
+		}
+	}
+	var parts []string
+	if len(down) > 0 {
+		parts = append(parts, fmt.Sprintf("sshpick: hosts unreachable (%d)", len(down))+alertList(down))
+	}
+	if len(up) > 0 {
+		parts = append(parts, fmt.Sprintf("sshpick: hosts reachable again (%d)", len(up))+alertList(up))
+	}
+	return strings.Join(parts, "\n")
+}
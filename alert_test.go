@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostAlert(t *testing.T) {
+	t.Parallel()
+
+	var got alertPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s with %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	report := checkReport{Failed: 1, OK: 1, Hosts: []hostCheck{
+		{Alias: "web", OK: true},
+		{Alias: "bastion", Address: "10.0.0.1:22", Resolve: checkStep{Status: checkOK}, TCP: checkStep{Status: checkFailed, Error: "connection refused"}},
+	}}
+	if err := postAlert(srv.URL, checkAlert(report)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got.Text, "sshpick check: 1 of 2 hosts failing\n• bastion (10.0.0.1:22): tcp failed: connection refused") {
+		t.Fatalf("payload %q", got.Text)
+	}
+	if checkAlert(checkReport{OK: 2}) != "" {
+		t.Fatal("a clean report should not alert")
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusForbidden)
+	}))
+	defer failing.Close()
+	if err := postAlert(failing.URL, "x"); err == nil {
+		t.Fatal("a rejected post should fail")
+	}
+}
+
+func TestDaemonAlert(t *testing.T) {
+	t.Parallel()
+
+	bastion, web, db := sshHost{Alias: "bastion"}, sshHost{Alias: "web"}, sshHost{Alias: "db"}
+	down := errors.New("i/o timeout")
+
+	first := []latencyResult{{Host: bastion, Err: down}, {Host: web}}
+	text := daemonAlert(nil, statusChanges(nil, first))
+	if text != "sshpick: hosts unreachable (1)\n• bastion: i/o timeout" {
+		t.Fatalf("first round: %q", text)
+	}
+
+	second := []latencyResult{{Host: bastion}, {Host: web}, {Host: db}}
+	if text := daemonAlert(first, statusChanges(first, second)); text != "sshpick: hosts reachable again (1)\n• bastion" {
+		t.Fatalf("recovery: %q (new hosts that are up are not news)", text)
+	}
+	if text := daemonAlert(second, statusChanges(second, second)); text != "" {
+		t.Fatalf("no change should not alert: %q", text)
+	}
+}
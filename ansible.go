@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+)
+
+// ansibleProvider lists the hosts of an Ansible inventory, read through
+// `ansible-inventory --list` so every inventory format and plugin works.
+// Each host is tagged with its groups, so @group on the command line and
+// tag: in a filter select them.
+type ansibleProvider struct {
+	name      string
+	inventory string // -i; empty uses ansible.cfg's default
+}
+
+func (p ansibleProvider) sourceName() string { return p.name }
+
+func (p ansibleProvider) hosts() ([]sshHost, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), providerCommandTimeout)
+	defer cancel()
+	args := []string{"--list"}
+	if p.inventory != "" {
+		args = append(args, "-i", expandHome(p.inventory))
+	}
+	cmd := exec.CommandContext(ctx, "ansible-inventory", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, "", fmt.Errorf("%s: %w", p.name, err)
+	}
+	inv, err := ansibleToInventory(out)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", p.name, err)
+	}
+	return inventoryToHosts(inv, p.name, p.inventory), "", nil
+}
+
+// ansibleGroup is a group in `ansible-inventory --list` output.
+type ansibleGroup struct {
+	Hosts    []string `json:"hosts"`
+	Children []string `json:"children"`
+}
+
+// ansibleToInventory converts `ansible-inventory --list` output. A host
+// belongs to its groups and their parents; "all" and "ungrouped" aren't
+// tags.
+func ansibleToInventory(data []byte) (inventoryFile, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return inventoryFile{}, err
+	}
+	var meta struct {
+		Hostvars map[string]map[string]any `json:"hostvars"`
+	}
+	if m, ok := raw["_meta"]; ok {
+		if err := json.Unmarshal(m, &meta); err != nil {
+			return inventoryFile{}, fmt.Errorf("_meta: %w", err)
+		}
+	}
+	groups := map[string]ansibleGroup{}
+	for name, g := range raw {
+		if name == "_meta" {
+			continue
+		}
+		var group ansibleGroup
+		if err := json.Unmarshal(g, &group); err != nil {
+			return inventoryFile{}, fmt.Errorf("group %s: %w", name, err)
+		}
+		groups[name] = group
+	}
+
+	tags := map[string][]string{}
+	var walk func(group, tag string, seen map[string]bool)
+	walk = func(group, tag string, seen map[string]bool) {
+		if seen[group] {
+			return
+		}
+		seen[group] = true
+		for _, h := range groups[group].Hosts {
+			if !containsString(tags[h], tag) {
+				tags[h] = append(tags[h], tag)
+			}
+		}
+		for _, child := range groups[group].Children {
+			walk(child, tag, seen)
+		}
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	aliases := map[string]bool{}
+	for _, name := range names {
+		for _, h := range groups[name].Hosts {
+			aliases[h] = true
+		}
+		if name != "all" && name != "ungrouped" {
+			walk(name, name, map[string]bool{})
+		}
+	}
+	for h := range meta.Hostvars {
+		aliases[h] = true
+	}
+
+	var inv inventoryFile
+	for alias := range aliases {
+		vars := meta.Hostvars[alias]
+		hostVar := func(keys ...string) string {
+			for _, k := range keys {
+				if v, ok := vars[k]; ok && v != nil {
+					return fmt.Sprint(v)
+				}
+			}
+			return ""
+		}
+		inv.Hosts = append(inv.Hosts, inventoryHost{
+			Alias:        alias,
+			Hostname:     hostVar("ansible_host", "ansible_ssh_host"),
+			User:         hostVar("ansible_user", "ansible_ssh_user"),
+			Port:         hostVar("ansible_port", "ansible_ssh_port"),
+			IdentityFile: hostVar("ansible_ssh_private_key_file"),
+			Tags:         tags[alias],
+		})
+	}
+	sort.Slice(inv.Hosts, func(i, j int) bool { return inv.Hosts[i].Alias < inv.Hosts[j].Alias })
+	return inv, nil
+}
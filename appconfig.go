@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// appConfig is sshpick's own settings file (not the ssh config), read from
+// $XDG_CONFIG_HOME/sshpick/config.json or ~/.config/sshpick/config.json.
+type appConfig struct {
+	// Networks maps a network name (referenced by network= annotations or
+	// by tag) to how sshpick detects and brings it up.
+	Networks map[string]networkConfig `json:"networks,omitempty"`
+
+	// Hooks are shell commands run around a connection, with the host's
+	// fields in SSHPICK_* environment variables.
+	Hooks hooksConfig `json:"hooks,omitempty"`
+
+	// Notify sends a notification when a subprocess session ends.
+	Notify notifyConfig `json:"notify,omitempty"`
+
+	// AlertWebhook is posted a Slack-style {"text": ...} message when
+	// `sshpick check` finds failures or the daemon sees hosts go down.
+	AlertWebhook string `json:"alert_webhook,omitempty"`
+
+	// MaintenanceFile is a (possibly shared) JSON file of hosts in
+	// maintenance; defaults to a local file in the state directory.
+	MaintenanceFile string `json:"maintenance_file,omitempty"`
+
+	// Inventories are shared host lists fetched over HTTP at startup.
+	Inventories []inventoryConfig `json:"inventories,omitempty"`
+
+	// Providers are more host sources merged after the inventories:
+	// known_hosts, or a command printing the inventory format.
+	Providers []providerConfig `json:"providers,omitempty"`
+
+	// Views are saved views shared through the settings file, by name: a
+	// filter, e.g. {"prod-fast": "tag:prod latency<50ms"}, or a viewSpec
+	// object with order, group and columns. The v menu and -view use them.
+	Views map[string]viewSpec `json:"views,omitempty"`
+
+	// ProviderProxy is the default "proxy" of inventories and command
+	// providers, e.g. "socks5://127.0.0.1:1080" or "ssh:bastion".
+	ProviderProxy string `json:"provider_proxy,omitempty"`
+
+	// Workspaces are named sets of hosts opened together in tmux by
+	// `sshpick workspace up <name>`.
+	Workspaces map[string]workspaceConfig `json:"workspaces,omitempty"`
+
+	// TagDefaults adds ssh options (and optionally a confirmation) to every
+	// host with the tag, e.g. {"prod": {"options": {"ForwardAgent": "no"}, "confirm": true}}.
+	TagDefaults map[string]tagDefaults `json:"tag_defaults,omitempty"`
+
+	// ReadOnly forces -read-only, e.g. in a system-wide settings file on a
+	// shared jump box.
+	ReadOnly bool `json:"read_only,omitempty"`
+
+	// Resolver overrides the DNS server used for the IP column.
+	Resolver resolverConfig `json:"resolver,omitempty"`
+
+	// BannerPreview fetches each host's version string and pre-auth banner
+	// for the detail pane, cached for a day.
+	BannerPreview bool `json:"banner_preview,omitempty"`
+
+	// HappyEyeballs races a dual-stack or multi-address host's addresses
+	// before connecting and hands ssh the first that answers (-happy-eyeballs).
+	HappyEyeballs bool `json:"happy_eyeballs,omitempty"`
+
+	// HostKeyPolicy decides how unknown host keys are handled by every ssh
+	// sshpick runs: "strict", "accept-new" or "ask" (fingerprints shown in
+	// the TUI). Empty leaves it to the ssh config.
+	HostKeyPolicy string `json:"host_key_policy,omitempty"`
+
+	// MetadataSync shares a team-wide hosts.yaml from a URL or git repo;
+	// the local hosts.yaml overrides it and keeps favorites personal.
+	MetadataSync metadataSyncConfig `json:"metadata_sync,omitempty"`
+
+	// Batch is how hosts tagged with Space open together: tmux panes or
+	// windows, and what to do outside tmux.
+	Batch batchConfig `json:"batch,omitempty"`
+
+	// Theme picks the status colors: "default" (green/yellow/red),
+	// "colorblind" (blue/orange/vermillion) or "mono" (no color).
+	Theme string `json:"theme,omitempty"`
+
+	// StatusShapes marks statuses with ✓ ▲ ✗ as well as color; always on
+	// with the colorblind and mono themes.
+	StatusShapes bool `json:"status_shapes,omitempty"`
+
+	// Language picks the UI translation, e.g. "de". Empty follows
+	// SSHPICK_LANG and the locale (LANG), falling back to English.
+	Language string `json:"language,omitempty"`
+}
+
+// errReadOnly is reported when a config-modifying feature is used in
+// read-only mode.
+var errReadOnly = errors.New("read-only mode: changing the config is disabled")
+
+// networkConfig describes a network hosts can require, e.g. a VPN.
+type networkConfig struct {
+	Interface string   `json:"interface,omitempty"` // must exist and be up, e.g. "tun0"
+	Probe     string   `json:"probe,omitempty"`     // host:port that must accept TCP
+	Up        string   `json:"up,omitempty"`        // shell command that brings the network up
+	Tags      []string `json:"tags,omitempty"`      // hosts with any of these tags require it
+}
+
+func configDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "sshpick")
+	}
+	home := homeDir()
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".config", "sshpick")
+}
+
+func defaultAppConfigPath() string {
+	dir := configDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// loadAppConfig reads the settings file. A missing file is not an error;
+// a malformed one is, so typos don't silently disable features.
+func loadAppConfig(path string) (appConfig, error) {
+	var cfg appConfig
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := validTagDefaults(cfg.TagDefaults); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
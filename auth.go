@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// keychainService is the service name sshpick stores credentials under.
+const keychainService = "sshpick"
+
+// keychainStoreCommand stores (or with remove, deletes) a credential in the
+// OS keychain. The tools prompt for the secret themselves, without echo, so
+// it never appears in argv or shell history.
+func keychainStoreCommand(account string, remove bool) (*exec.Cmd, error) {
+	switch keychainOS {
+	case "darwin":
+		if remove {
+			return exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", account), nil
+		}
+		// A trailing -w with no value makes security prompt for the password.
+		return exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", account, "-l", "sshpick: "+account, "-w"), nil
+	case "windows":
+		// A generic credential named like the keychain: reference. A /pass
+		// with no value makes cmdkey prompt for the password.
+		target := keychainService + "/" + account
+		if remove {
+			return exec.Command("cmdkey", "/delete:"+target), nil
+		}
+		return exec.Command("cmdkey", "/generic:"+target, "/user:"+account, "/pass"), nil
+	default:
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return nil, fmt.Errorf("secret-tool not found (install libsecret-tools): %w", err)
+		}
+		if remove {
+			return exec.Command("secret-tool", "clear", "service", keychainService, "account", account), nil
+		}
+		return exec.Command("secret-tool", "store", "--label=sshpick: "+account, "service", keychainService, "account", account), nil
+	}
+}
+
+// runAuth implements `sshpick auth [-delete] <provider>`, saving a provider's
+// token to the keychain where a keychain:sshpick/<provider> reference finds it.
+func runAuth(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("auth", flag.ContinueOnError)
+	settingsPath := fs.String("settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
+	remove := fs.Bool("delete", false, "Remove the stored token instead of setting it")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: sshpick auth [-delete] <provider>")
+		return 2
+	}
+	provider := fs.Arg(0)
+
+	settings, err := loadAppConfig(*settingsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+		return 2
+	}
+	var ic *inventoryConfig
+	var names []string
+	for i := range settings.Inventories {
+		name := settings.Inventories[i].sourceName()
+		names = append(names, name)
+		if name == provider {
+			ic = &settings.Inventories[i]
+		}
+	}
+	if ic == nil {
+		fmt.Fprintf(os.Stderr, "unknown provider %q (configured: %s)\n", provider, strings.Join(names, ", "))
+		return 2
+	}
+
+	cmd, err := keychainStoreCommand(provider, *remove)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "keychain:", err)
+		return 1
+	}
+	if *remove {
+		fmt.Fprintf(stdout, "Removed the stored token for %s.\n", provider)
+		return 0
+	}
+	ref := "keychain:" + keychainService + "/" + provider
+	fmt.Fprintf(stdout, "Stored the token for %s.\n", provider)
+	if ic.Token != ref {
+		fmt.Fprintf(stdout, "Set \"token\": %q for this provider in %s to use it.\n", ref, *settingsPath)
+	}
+	return 0
+}
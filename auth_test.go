@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunAuth_UnknownProvider(t *testing.T) {
+	t.Parallel()
+
+	settings := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(settings, []byte(`{"inventories": [{"name": "team", "url": "https://inv.example/hosts.json"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if code := runAuth([]string{"-settings", settings, "teem"}, &out); code != 2 {
+		t.Fatalf("expected exit 2 for an unknown provider, got %d", code)
+	}
+	if code := runAuth([]string{"-settings", settings}, &out); code != 2 {
+		t.Fatalf("expected exit 2 without a provider, got %d", code)
+	}
+}
+
+func TestKeychainStoreCommand(t *testing.T) {
+	orig := keychainOS
+	defer func() { keychainOS = orig }()
+
+	for _, tc := range []struct {
+		goos   string
+		remove bool
+		want   []string
+	}{
+		{"darwin", false, []string{"security", "add-generic-password", "-U", "-s", "sshpick", "-a", "team", "-l", "sshpick: team", "-w"}},
+		{"darwin", true, []string{"security", "delete-generic-password", "-s", "sshpick", "-a", "team"}},
+		{"windows", false, []string{"cmdkey", "/generic:sshpick/team", "/user:team", "/pass"}},
+		{"windows", true, []string{"cmdkey", "/delete:sshpick/team"}},
+	} {
+		keychainOS = tc.goos
+		cmd, err := keychainStoreCommand("team", tc.remove)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.goos, err)
+		}
+		if !reflect.DeepEqual(cmd.Args, tc.want) {
+			t.Errorf("%s remove=%v: %q, want %q", tc.goos, tc.remove, cmd.Args, tc.want)
+		}
+	}
+
+	// What sshpick auth stores on Windows is found again by the
+	// keychain:sshpick/team reference it suggests.
+	keychainOS = "windows"
+	origTool := runSecretTool
+	defer func() { runSecretTool = origTool }()
+	var name string
+	var args []string
+	runSecretTool = func(n string, a []string, stdin string) (string, error) {
+		name, args = n, a
+		return "s3cret", nil
+	}
+	if got, err := resolveSecret("keychain:sshpick/team"); err != nil || got != "s3cret" {
+		t.Fatalf("resolveSecret: %q %v", got, err)
+	}
+	if name != "powershell" || len(args) != 4 || !strings.HasSuffix(args[3], "} 'sshpick/team'") || !strings.Contains(args[3], "CredReadW") {
+		t.Fatalf("credential lookup: %s %q", name, args)
+	}
+	if got := powershellQuote("it's"); got != "'it''s'" {
+		t.Fatalf("powershellQuote = %s", got)
+	}
+}
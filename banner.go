@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	bannerTimeout = 10 * time.Second
+	bannerTTL     = 24 * time.Hour
+)
+
+// hostBanner is what a server shows before authentication: its version
+// string and the text configured with sshd's Banner option.
+type hostBanner struct {
+	Version string    `json:"version,omitempty"` // e.g. "OpenSSH_7.4"
+	Text    []string  `json:"text,omitempty"`
+	Err     string    `json:"error,omitempty"`
+	Fetched time.Time `json:"fetched"`
+}
+
+type bannerMsg struct {
+	alias  string
+	banner hostBanner
+}
+
+func bannerCachePath() string {
+	dir := cacheDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "banners.json")
+}
+
+// loadBanners reads the banner cache; a missing or corrupt file is empty.
+func loadBanners(path string) map[string]hostBanner {
+	banners := map[string]hostBanner{}
+	if path == "" {
+		return banners
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &banners)
+	}
+	return banners
+}
+
+func saveBanners(path string, banners map[string]hostBanner) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(banners, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fetchBanner asks ssh to authenticate with no methods at all, which goes
+// far enough to receive the version string and banner and then fails
+// without trying any credentials. Going through ssh keeps ProxyJump and
+// ProxyCommand working.
+func fetchBanner(h sshHost) hostBanner {
+	ctx, cancel := context.WithTimeout(context.Background(), bannerTimeout)
+	defer cancel()
+	args := append([]string{"-v", "-o", "BatchMode=yes", "-o", "ConnectTimeout=5", "-o", "PreferredAuthentications=none"}, hostKeyArgs(false)...)
+	args = append(args, hostArgs(h)...)
+	cmd := exec.CommandContext(ctx, "ssh", append(args, "true")...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	b := parseBanner(stderr.String())
+	b.Fetched = time.Now().Truncate(time.Second)
+	if b.Version == "" {
+		b.Err = "no version string received"
+		if runErr != nil {
+			if msg := lastLine(stderr.String()); msg != "" {
+				b.Err = msg
+			}
+		}
+	}
+	return b
+}
+
+// parseBanner reads ssh -v output. The banner is printed verbatim between
+// the userauth service being accepted and the next debug line.
+func parseBanner(out string) hostBanner {
+	var b hostBanner
+	inBanner := false
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if i := strings.Index(line, "remote software version "); i >= 0 && strings.HasPrefix(line, "debug") {
+			b.Version = strings.TrimSpace(line[i+len("remote software version "):])
+			continue
+		}
+		if strings.HasPrefix(line, "debug") {
+			if len(b.Text) > 0 {
+				inBanner = false
+			}
+			if strings.Contains(line, "SSH2_MSG_SERVICE_ACCEPT") {
+				inBanner = true
+			}
+			continue
+		}
+		if inBanner {
+			b.Text = append(b.Text, stripControl(line))
+		}
+	}
+	for len(b.Text) > 0 && strings.TrimSpace(b.Text[len(b.Text)-1]) == "" {
+		b.Text = b.Text[:len(b.Text)-1]
+	}
+	return b
+}
+
+// stripControl drops control characters so a hostile banner can't drive
+// the terminal.
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// outdatedOpenSSH reports whether version is an OpenSSH release older than
+// 7.4, the oldest still shipped by long-term-support distributions.
+func outdatedOpenSSH(version string) bool {
+	v, ok := strings.CutPrefix(version, "OpenSSH_")
+	if !ok {
+		return false
+	}
+	if i := strings.IndexFunc(v, func(r rune) bool { return r != '.' && (r < '0' || r > '9') }); i >= 0 {
+		v = v[:i]
+	}
+	major, minor, _ := strings.Cut(v, ".")
+	maj, err := strconv.Atoi(major)
+	if err != nil {
+		return false
+	}
+	mnr, _ := strconv.Atoi(minor)
+	return maj < 7 || (maj == 7 && mnr < 4)
+}
+
+// refreshBanner fetches h's banner when banner_preview is enabled and the
+// cached copy is missing or older than bannerTTL.
+func (m *model) refreshBanner(h sshHost) tea.Cmd {
+	if !m.appConfig.BannerPreview || skipsBatchProbes(h) || m.bannersPending[h.Alias] {
+		return nil
+	}
+	if b, ok := m.banners[h.Alias]; ok && time.Since(b.Fetched) < bannerTTL {
+		return nil
+	}
+	m.bannersPending[h.Alias] = true
+	return func() tea.Msg {
+		return bannerMsg{alias: h.Alias, banner: fetchBanner(h)}
+	}
+}
+
+// bannerLines renders the banner for the detail pane.
+func (m model) bannerLines(h sshHost) []string {
+	if !m.appConfig.BannerPreview {
+		return nil
+	}
+	b, ok := m.banners[h.Alias]
+	if !ok {
+		if m.bannersPending[h.Alias] {
+			return []string{fmt.Sprintf("%-14s fetching…", "Server:")}
+		}
+		return nil
+	}
+	if b.Version == "" {
+		return []string{fmt.Sprintf("%-14s unavailable (%s)", "Server:", b.Err)}
+	}
+	version := b.Version
+	if outdatedOpenSSH(version) {
+		version += " (outdated)"
+	}
+	lines := []string{fmt.Sprintf("%-14s %s", "Server:", version)}
+	for i, t := range b.Text {
+		if i == 5 {
+			lines = append(lines, fmt.Sprintf("%-14s … %d more lines", "", len(b.Text)-i))
+			break
+		}
+		label := ""
+		if i == 0 {
+			label = "Banner:"
+		}
+		lines = append(lines, fmt.Sprintf("%-14s %s", label, t))
+	}
+	return lines
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBanner(t *testing.T) {
+	t.Parallel()
+
+	out := strings.Join([]string{
+		"OpenSSH_9.6p1, OpenSSL 3.0.13 30 Jan 2024",
+		"debug1: Connecting to db1 [10.0.0.5] port 22.",
+		"debug1: Remote protocol version 2.0, remote software version OpenSSH_7.2p2 Ubuntu-4ubuntu2.10",
+		"debug1: SSH2_MSG_SERVICE_ACCEPT received",
+		"Authorized use only.",
+		"\x1b[31mAll activity is logged.\x1b[0m",
+		"",
+		"debug1: Authentications that can continue: publickey",
+		"db1: Permission denied (publickey).",
+	}, "\n")
+	b := parseBanner(out)
+	if b.Version != "OpenSSH_7.2p2 Ubuntu-4ubuntu2.10" {
+		t.Fatalf("version %q", b.Version)
+	}
+	if strings.Join(b.Text, "|") != "Authorized use only.|[31mAll activity is logged.[0m" {
+		t.Fatalf("text %q", b.Text)
+	}
+}
+
+func TestOutdatedOpenSSH(t *testing.T) {
+	t.Parallel()
+
+	for version, want := range map[string]bool{
+		"OpenSSH_5.3":                      true,
+		"OpenSSH_7.2p2 Ubuntu-4ubuntu2.10": true,
+		"OpenSSH_7.4":                      false,
+		"OpenSSH_9.6p1":                    false,
+		"dropbear_2022.83":                 false,
+	} {
+		if got := outdatedOpenSSH(version); got != want {
+			t.Errorf("outdatedOpenSSH(%q) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestBannerCacheRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "sub", "banners.json")
+	want := map[string]hostBanner{"db1": {Version: "OpenSSH_9.6", Text: []string{"hi"}, Fetched: time.Unix(1700000000, 0).UTC()}}
+	if err := saveBanners(path, want); err != nil {
+		t.Fatalf("saveBanners: %v", err)
+	}
+	got := loadBanners(path)
+	if got["db1"].Version != "OpenSSH_9.6" || !got["db1"].Fetched.Equal(want["db1"].Fetched) {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestBannerMsgUpdatesCache(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	m := initialModel([]sshHost{{Alias: "db1"}}, "", "")
+	next, _ := m.Update(bannerMsg{alias: "db1", banner: hostBanner{Version: "OpenSSH_9.6"}})
+	m = next.(model)
+	if m.err != nil || m.banners["db1"].Version != "OpenSSH_9.6" || loadBanners(bannerCachePath())["db1"].Version != "OpenSSH_9.6" {
+		t.Fatalf("err %v, banners %+v", m.err, m.banners)
+	}
+
+	// A cache that can't be written is reported, not dropped.
+	blocked := filepath.Join(cache, "file")
+	os.WriteFile(blocked, nil, 0o600)
+	t.Setenv("XDG_CACHE_HOME", blocked)
+	next, _ = m.Update(bannerMsg{alias: "db1", banner: hostBanner{Version: "OpenSSH_9.7"}})
+	m = next.(model)
+	if m.err == nil || !strings.Contains(m.err.Error(), "banner cache") || m.banners["db1"].Version != "OpenSSH_9.7" {
+		t.Fatalf("err %v, banners %+v", m.err, m.banners)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// bastionPersist is how long a shared bastion master outlives its last client.
+const bastionPersist = "10m"
+
+// sharedBastion returns the single-hop ProxyJump target of h, or "" when the
+// host doesn't jump or uses a multi-hop chain (which is left to ssh).
+func sharedBastion(h sshHost) string {
+	jump := strings.TrimSpace(h.option("ProxyJump"))
+	if jump == "" || strings.EqualFold(jump, "none") || strings.Contains(jump, ",") {
+		return ""
+	}
+	return jump
+}
+
+// bastionControlPath is the ControlMaster socket sshpick uses for a bastion.
+// The path is explicit (not %C) so the same socket can be named inside a
+// ProxyCommand, and kept short to stay under the unix socket path limit.
+func bastionControlPath(bastion string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, bastion)
+	return filepath.Join(os.TempDir(), fmt.Sprintf("sshpick-%d", os.Getuid()), "jump-"+safe)
+}
+
+// controlPathOption is path as a ControlPath option: quoted, since ssh
+// splits option values on spaces, and with % doubled, since ssh expands
+// % tokens in it.
+func controlPathOption(path string) string {
+	return `ControlPath="` + strings.ReplaceAll(path, "%", "%%") + `"`
+}
+
+// bastionProxyCommand is the ProxyCommand that reaches a host through the
+// master at path. Its words are shell-quoted and % escaped, because ssh
+// expands tokens in the ProxyCommand and then runs it with the shell.
+func bastionProxyCommand(path, bastion string) string {
+	words := []string{"ssh"}
+	for _, a := range append(configFileArgs(), "-o", controlPathOption(path), "-W") {
+		words = append(words, proxyValue(a))
+	}
+	return strings.Join(append(words, "%h:%p", proxyValue(bastion)), " ")
+}
+
+// ensureBastionMaster makes sure a ControlMaster to bastion is running,
+// starting one in the background (in the foreground terminal, so any auth
+// prompt for the bastion is shown) if the socket isn't alive. A variable
+// so tests don't start ssh.
+var ensureBastionMaster = func(bastion string) (string, error) {
+	path := bastionControlPath(bastion)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	check := exec.Command("ssh", append(configFileArgs(), "-o", controlPathOption(path), "-O", "check", bastion)...)
+	if check.Run() == nil {
+		return path, nil
+	}
+	args := append(hostKeyArgs(true), configFileArgs()...)
+	start := exec.Command("ssh", append(args,
+		"-o", "ControlMaster=yes",
+		"-o", controlPathOption(path),
+		"-o", "ControlPersist="+bastionPersist,
+		"-fN", bastion)...)
+	start.Stdin = os.Stdin
+	start.Stdout = os.Stdout
+	start.Stderr = os.Stderr
+	if err := start.Run(); err != nil {
+		return "", fmt.Errorf("bastion %s: %w", bastion, err)
+	}
+	return path, nil
+}
+
+// bastionArgs returns ssh options that route h through the shared master to
+// its bastion. ProxyCommand given on the command line takes precedence over
+// the config's ProxyJump. On failure ssh's own ProxyJump is used instead.
+func bastionArgs(h sshHost) []string {
+	bastion := sharedBastion(h)
+	if bastion == "" {
+		return nil
+	}
+	path, err := ensureBastionMaster(bastion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: not sharing bastion connection:", err)
+		return nil
+	}
+	return []string{"-o", "ProxyCommand=" + bastionProxyCommand(path, bastion)}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSharedBastion(t *testing.T) {
+	for jump, want := range map[string]string{
+		"":                  "",
+		"none":              "",
+		"bastion":           "bastion",
+		" bob@bastion:2222": "bob@bastion:2222",
+		"a,b":               "",
+	} {
+		h := sshHost{Alias: "web", Options: map[string]string{"proxyjump": jump}}
+		if got := sharedBastion(h); got != want {
+			t.Errorf("ProxyJump %q: got %q, want %q", jump, got, want)
+		}
+	}
+}
+
+func TestBastionArgs(t *testing.T) {
+	orig := ensureBastionMaster
+	defer func() { ensureBastionMaster = orig }()
+	path := "/tmp/my dir%/jump-bastion"
+	ensureBastionMaster = func(bastion string) (string, error) { return path, nil }
+
+	h := sshHost{Alias: "web", Options: map[string]string{"proxyjump": "bastion"}}
+	want := []string{"-o", `ProxyCommand=ssh -o 'ControlPath="/tmp/my dir%%%%/jump-bastion"' -W %h:%p bastion`}
+	if got := bastionArgs(h); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+	if got := controlPathOption(path); got != `ControlPath="/tmp/my dir%%/jump-bastion"` {
+		t.Fatalf("control path option %q", got)
+	}
+
+	ensureBastionMaster = func(bastion string) (string, error) { return "", errors.New("auth failed") }
+	if got := bastionArgs(h); got != nil {
+		t.Fatalf("a failed master should fall back to ProxyJump, got %q", got)
+	}
+	if got := bastionArgs(sshHost{Alias: "direct"}); got != nil {
+		t.Fatalf("direct host: %q", got)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// batchConfig is the "batch" settings block: how hosts tagged with Space
+// open together when Enter is pressed.
+type batchConfig struct {
+	// Mode is "panes" (one window split per host, the default) or
+	// "windows" (a tmux window per host).
+	Mode string `json:"mode,omitempty"`
+	// Layout is the tmux layout for panes, default "tiled".
+	Layout string `json:"layout,omitempty"`
+	// Fallback outside tmux: "print" the ssh commands (the default) or
+	// connect to each host in turn ("sequential").
+	Fallback string `json:"fallback,omitempty"`
+}
+
+func (c batchConfig) validate() error {
+	switch c.Mode {
+	case "", "panes", "windows":
+	default:
+		return fmt.Errorf("batch mode %q: want panes or windows", c.Mode)
+	}
+	switch c.Fallback {
+	case "", "print", "sequential":
+	default:
+		return fmt.Errorf("batch fallback %q: want print or sequential", c.Fallback)
+	}
+	return nil
+}
+
+func (c batchConfig) layout() string {
+	if c.Layout == "" {
+		return "tiled"
+	}
+	return c.Layout
+}
+
+// toggleTag is the Space key: tag or untag the highlighted host and move on
+// to the next one.
+func (m model) toggleTag() model {
+	if len(m.hosts) == 0 {
+		return m
+	}
+	alias := m.hosts[m.cursor].Alias
+	if m.tagged[alias] {
+		delete(m.tagged, alias)
+	} else {
+		if m.tagged == nil {
+			m.tagged = map[string]bool{}
+		}
+		m.tagged[alias] = true
+	}
+	m.cursor = (m.cursor + 1) % len(m.hosts)
+	return m
+}
+
+// taggedHosts are the tagged hosts in list order, including ones the
+// current filter hides.
+func (m model) taggedHosts() []sshHost {
+	var out []sshHost
+	for _, h := range m.allHosts {
+		if m.tagged[h.Alias] {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// batchCommands are the ssh command lines for each host. The -L given on
+// the command line is left out: every session would fight over its port.
+func batchCommands(hosts []sshHost, opts launchOptions) []string {
+	opts.localForward = ""
+	cmds := make([]string, len(hosts))
+	for i, h := range hosts {
+		cmds[i] = shellJoin(append([]string{"ssh"}, sshArgs(h, opts)...))
+	}
+	return cmds
+}
+
+// tmuxBatchPlan lists the tmux invocations that open cmds in the current
+// session. target is the window the first command created; panes split it,
+// windows ignore it.
+func tmuxBatchPlan(hosts []sshHost, cmds []string, cfg batchConfig, target string) [][]string {
+	var plan [][]string
+	for i := 1; i < len(cmds); i++ {
+		if cfg.Mode == "windows" {
+			plan = append(plan, []string{"new-window", "-d", "-n", hosts[i].Alias, cmds[i]})
+			continue
+		}
+		// Re-apply the layout after each split so panes never run out of room.
+		plan = append(plan,
+			[]string{"split-window", "-t", target, cmds[i]},
+			[]string{"select-layout", "-t", target, cfg.layout()})
+	}
+	return plan
+}
+
+// batchFirstWindow creates the window for the first host and prints its id.
+func batchFirstWindow(hosts []sshHost, cmds []string, cfg batchConfig) []string {
+	name := "sshpick"
+	if cfg.Mode == "windows" {
+		name = hosts[0].Alias
+	}
+	return []string{"new-window", "-P", "-F", "#{window_id}", "-n", name, cmds[0]}
+}
+
+// launchBatch opens every host: in tmux panes or windows when sshpick runs
+// inside tmux, otherwise by printing the commands or connecting in turn.
+func launchBatch(hosts []sshHost, cfg batchConfig, opts launchOptions, w io.Writer) error {
+	if len(hosts) == 0 {
+		return errors.New("no hosts tagged")
+	}
+	cmds := batchCommands(hosts, opts)
+	if os.Getenv("TMUX") != "" {
+		out, err := exec.Command("tmux", batchFirstWindow(hosts, cmds, cfg)...).Output()
+		if err != nil {
+			return fmt.Errorf("tmux new-window: %w", err)
+		}
+		target := strings.TrimSpace(string(out))
+		for _, args := range tmuxBatchPlan(hosts, cmds, cfg, target) {
+			if out, err := exec.Command("tmux", args...).CombinedOutput(); err != nil {
+				return fmt.Errorf("tmux %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+			}
+		}
+		return nil
+	}
+	if cfg.Fallback != "sequential" {
+		fmt.Fprintln(w, "# not inside tmux; run these to connect:")
+		for _, c := range cmds {
+			fmt.Fprintln(w, c)
+		}
+		return nil
+	}
+	opts.localForward = ""
+	opts.subprocess = true // come back for the next host
+	for i, h := range hosts {
+		fmt.Fprintf(w, "[%d/%d] %s\n", i+1, len(hosts), h.Alias)
+		if f := connectHost(h, opts); f != nil {
+			for _, line := range f.lines {
+				fmt.Fprintln(os.Stderr, line)
+			}
+		}
+	}
+	return nil
+}
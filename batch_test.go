@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestTagAndBatch(t *testing.T) {
+	t.Parallel()
+	hosts := []sshHost{{Alias: "web1"}, {Alias: "web2"}, {Alias: "db1"}}
+	m := initialModel(hosts, "", "")
+	m.ready = true
+
+	space := tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+	next, _ := m.Update(space) // web1, cursor moves to web2
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyDown})
+	next, _ = next.(model).Update(space) // db1
+	m = next.(model)
+	if len(m.tagged) != 2 || !m.tagged["web1"] || !m.tagged["db1"] {
+		t.Fatalf("tagged %v", m.tagged)
+	}
+	view := m.View()
+	if !strings.Contains(view, "✓ web1") || !strings.Contains(view, "2 tagged") {
+		t.Fatalf("tags should be visible:\n%s", view)
+	}
+
+	// tags survive a filter that hides them
+	m.applyFilter("web2")
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if cmd == nil || len(m.batch) != 2 || m.batch[0].Alias != "web1" || m.batch[1].Alias != "db1" || m.chosen {
+		t.Fatalf("Enter should open the tagged hosts: %v chosen=%v", m.batch, m.chosen)
+	}
+}
+
+func TestTmuxBatchPlan(t *testing.T) {
+	t.Parallel()
+	hosts := []sshHost{{Alias: "web1", SourcePath: "c"}, {Alias: "web2", SourcePath: "c"}, {Alias: "web3", SourcePath: "c"}}
+	cmds := batchCommands(hosts, launchOptions{localForward: "8080:localhost:80"})
+	if strings.Contains(cmds[0], "-L") || !strings.HasPrefix(cmds[0], "ssh ") || !strings.HasSuffix(cmds[2], " web3") {
+		t.Fatalf("commands %q", cmds)
+	}
+
+	first := batchFirstWindow(hosts, cmds, batchConfig{})
+	if first[0] != "new-window" || first[len(first)-1] != cmds[0] {
+		t.Fatalf("first window %q", first)
+	}
+	plan := tmuxBatchPlan(hosts, cmds, batchConfig{Layout: "even-horizontal"}, "@7")
+	if len(plan) != 4 || strings.Join(plan[0][:3], " ") != "split-window -t @7" || plan[1][3] != "even-horizontal" {
+		t.Fatalf("panes plan %q", plan)
+	}
+	plan = tmuxBatchPlan(hosts, cmds, batchConfig{Mode: "windows"}, "@7")
+	if len(plan) != 2 || strings.Join(plan[1][:4], " ") != "new-window -d -n web3" {
+		t.Fatalf("windows plan %q", plan)
+	}
+
+	if (batchConfig{Mode: "splits"}).validate() == nil || (batchConfig{Fallback: "sequential"}).validate() != nil {
+		t.Fatal("validate")
+	}
+}
+
+func TestLaunchBatchOutsideTmux(t *testing.T) {
+	t.Setenv("TMUX", "")
+	var out bytes.Buffer
+	hosts := []sshHost{{Alias: "web1", SourcePath: "c"}, {Alias: "web2", SourcePath: "c"}}
+	if err := launchBatch(hosts, batchConfig{}, launchOptions{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 3 || !strings.HasSuffix(lines[2], " web2") {
+		t.Fatalf("printed commands:\n%s", out.String())
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bulkOp is one change applied to many Host blocks: set User, add an
+// IdentityFile or add a tag. The inline editor also sets Port and HostName.
+type bulkOp struct {
+	kind  string // "user", "identityfile", "tag", "port" or "hostname"
+	value string
+}
+
+// singleKeywords are the directives a bulkOp replaces rather than adds to,
+// spelled as written into the config.
+var singleKeywords = map[string]string{"user": "User", "port": "Port", "hostname": "HostName"}
+
+// parseBulkOp reads "User ops", "IdentityFile ~/.ssh/k" or "Tag prod"
+// ("Key=Value" works too).
+func parseBulkOp(s string) (bulkOp, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, " \t="); i > 0 && s[i] == '=' {
+		s = s[:i] + " " + s[i+1:]
+	}
+	key, value, _ := strings.Cut(s, " ")
+	op := bulkOp{kind: strings.ToLower(key), value: strings.TrimSpace(value)}
+	switch op.kind {
+	case "user", "identityfile", "tag":
+	default:
+		return op, fmt.Errorf("unsupported change %q (User, IdentityFile or Tag)", key)
+	}
+	return op, op.check()
+}
+
+// check rejects a value that would not stay one argument of one directive
+// once written: comments, line breaks and control characters anywhere, and
+// spaces or commas in anything but an IdentityFile path.
+func (op bulkOp) check() error {
+	bad := op.value == "" || strings.ContainsAny(op.value, "#\n") || (op.kind != "identityfile" && strings.ContainsAny(op.value, " \t,"))
+	for _, r := range op.value {
+		if !unicode.IsPrint(r) {
+			bad = true
+		}
+	}
+	if bad {
+		return fmt.Errorf("invalid value %q", op.value)
+	}
+	return nil
+}
+
+// configChange is one line of the preview; Old is empty for an insertion.
+type configChange struct {
+	Path string
+	Line int // line in the file before editing
+	Old  string
+	New  string
+}
+
+// bulkPlan is a computed bulk edit awaiting confirmation.
+type bulkPlan struct {
+	op      bulkOp
+	aliases map[string]bool
+	changes []configChange
+	files   map[string][]string // new contents by path
+	skipped []string            // hosts that aren't defined in an ssh config
+}
+
+// bulkEdit is the bulk edit overlay (E): first the change is typed, then the
+// diff is previewed.
+type bulkEdit struct {
+	input string
+	count int // hosts the edit applies to
+	plan  *bulkPlan
+	err   error
+}
+
+func isBlockStart(line string) bool {
+	fields := strings.Fields(strings.Replace(strings.TrimSpace(line), "=", " ", 1))
+	return len(fields) > 0 && (strings.EqualFold(fields[0], "host") || strings.EqualFold(fields[0], "match"))
+}
+
+// hostPatterns are the patterns of a Host line, up to any trailing
+// comment; nil for Match and other lines.
+func hostPatterns(line string) []string {
+	if directiveName(line) != "host" {
+		return nil
+	}
+	var patterns []string
+	for _, f := range strings.Fields(strings.Replace(strings.TrimSpace(line), "=", " ", 1))[1:] {
+		if strings.HasPrefix(f, "#") {
+			break
+		}
+		patterns = append(patterns, f)
+	}
+	return patterns
+}
+
+func directiveName(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ""
+	}
+	fields := strings.Fields(strings.Replace(line, "=", " ", 1))
+	return strings.ToLower(fields[0])
+}
+
+var tagsAnnotation = regexp.MustCompile(`(?i)(\btags=)(\S*)`)
+
+// planBulkEdit computes the edit of every config-defined host's block.
+// Aliases sharing a Host line share a block and are edited once.
+func planBulkEdit(hosts []sshHost, op bulkOp) (*bulkPlan, error) {
+	plan := &bulkPlan{op: op, aliases: map[string]bool{}, files: map[string][]string{}}
+	blocks := map[string][]int{}
+	owners := map[string]map[int][]string{} // aliases expected on each Host line
+	for _, h := range hosts {
+		if h.SourceLine == 0 {
+			plan.skipped = append(plan.skipped, h.Alias)
+			continue
+		}
+		plan.aliases[h.Alias] = true
+		if !containsInt(blocks[h.SourcePath], h.SourceLine) {
+			blocks[h.SourcePath] = append(blocks[h.SourcePath], h.SourceLine)
+		}
+		if owners[h.SourcePath] == nil {
+			owners[h.SourcePath] = map[int][]string{}
+		}
+		owners[h.SourcePath][h.SourceLine] = append(owners[h.SourcePath][h.SourceLine], h.Alias)
+	}
+	paths := make([]string, 0, len(blocks))
+	for p := range blocks {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Split(string(data), "\n")
+		starts := blocks[path]
+		// Bottom-up, so insertions don't shift the blocks still to edit.
+		sort.Sort(sort.Reverse(sort.IntSlice(starts)))
+		var changes []configChange
+		for _, start := range starts {
+			if start > len(lines) || !isBlockStart(lines[start-1]) {
+				return nil, fmt.Errorf("%s:%d is no longer a Host line; reload and try again", path, start)
+			}
+			for _, alias := range owners[path][start] {
+				if !containsString(hostPatterns(lines[start-1]), alias) {
+					return nil, fmt.Errorf("%s:%d is no longer the Host line of %s; reload and try again", path, start, alias)
+				}
+			}
+			var c []configChange
+			lines, c = editBlock(lines, start-1, op, path)
+			changes = append(c, changes...)
+		}
+		if len(changes) > 0 {
+			plan.files[path] = lines
+			plan.changes = append(plan.changes, changes...)
+		}
+	}
+	return plan, nil
+}
+
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// editBlock applies op to the block whose Host line is lines[start].
+func editBlock(lines []string, start int, op bulkOp, path string) ([]string, []configChange) {
+	end := start + 1
+	for end < len(lines) && !isBlockStart(lines[end]) {
+		end++
+	}
+	indent := "    "
+	for i := start + 1; i < end; i++ {
+		if directiveName(lines[i]) != "" {
+			indent = lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " \t"))]
+			break
+		}
+	}
+	insertAt := start + 1
+	insert := func(text string) ([]string, []configChange) {
+		out := append(append(append([]string{}, lines[:insertAt]...), indent+text), lines[insertAt:]...)
+		return out, []configChange{{Path: path, Line: insertAt + 1, New: indent + text}}
+	}
+	replace := func(i int, text string) ([]string, []configChange) {
+		old := lines[i]
+		lines[i] = text
+		return lines, []configChange{{Path: path, Line: i + 1, Old: old, New: text}}
+	}
+
+	switch op.kind {
+	case "user", "port", "hostname":
+		keyword := singleKeywords[op.kind]
+		for i := start + 1; i < end; i++ {
+			if directiveName(lines[i]) != op.kind {
+				continue
+			}
+			if fields := strings.Fields(strings.Replace(strings.TrimSpace(lines[i]), "=", " ", 1)); len(fields) > 1 && fields[1] == op.value {
+				return lines, nil
+			}
+			return replace(i, indent+keyword+" "+op.value)
+		}
+		return insert(keyword + " " + op.value)
+	case "identityfile":
+		want := expandHome(op.value)
+		for i := start + 1; i < end; i++ {
+			if directiveName(lines[i]) != "identityfile" {
+				continue
+			}
+			value := strings.TrimSpace(strings.TrimSpace(lines[i])[len("identityfile"):])
+			value = strings.Trim(strings.TrimPrefix(value, "="), ` "`)
+			if expandHome(value) == want {
+				return lines, nil
+			}
+			insertAt = i + 1
+		}
+		return insert("IdentityFile " + op.value)
+	case "tag":
+		for i := start + 1; i < end; i++ {
+			comment := strings.TrimSpace(lines[i])
+			if !strings.HasPrefix(comment, "#") {
+				continue
+			}
+			kv, ok := parseAnnotation(strings.TrimSpace(comment[1:]))
+			if !ok {
+				continue
+			}
+			tags, has := kv["tags"]
+			if !has {
+				return replace(i, strings.TrimRight(lines[i], " \t")+" tags="+op.value)
+			}
+			for _, t := range strings.Split(tags, ",") {
+				if strings.EqualFold(strings.TrimSpace(t), op.value) {
+					return lines, nil
+				}
+			}
+			sep := ","
+			if tags == "" {
+				sep = ""
+			}
+			return replace(i, tagsAnnotation.ReplaceAllString(lines[i], "${1}${2}"+sep+op.value))
+		}
+		return insert("# sshpick: tags=" + op.value)
+	}
+	return lines, nil
+}
+
+// save writes the planned files, each via a temporary file and rename.
+func (p *bulkPlan) save() error {
+	for path, lines := range p.files {
+		if err := writeConfigFile(path, strings.Join(lines, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m model) openBulkEdit() model {
+	if m.readOnly {
+		m.err = errReadOnly
+		return m
+	}
+	if len(m.hosts) == 0 {
+		m.err = errors.New("no hosts to edit")
+		return m
+	}
+	m.err = nil
+	m.bulk = &bulkEdit{count: len(m.hosts)}
+	return m
+}
+
+func (m model) updateBulkEdit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	be := *m.bulk
+	if msg.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+	if msg.String() == "esc" {
+		m.bulk = nil
+		return m, nil
+	}
+	if be.plan != nil {
+		if msg.String() != "y" && msg.String() != "enter" {
+			return m, nil
+		}
+		if err := be.plan.save(); err != nil {
+			be.err = err
+			m.bulk = &be
+			return m, nil
+		}
+		// Reparse rather than patch the hosts: inserted lines move the
+		// Host lines of every block below them.
+		m.bulk, m.err = nil, nil
+		m, cmd := m.reloadConfig()
+		if m.err == nil {
+			m.err = fmt.Errorf("updated %d lines in %d files", len(be.plan.changes), len(be.plan.files))
+		}
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "enter":
+		op, err := parseBulkOp(be.input)
+		if err == nil {
+			be.plan, err = planBulkEdit(m.hosts, op)
+		}
+		be.err = err
+	case "backspace":
+		if be.input != "" {
+			_, n := utf8.DecodeLastRuneInString(be.input)
+			be.input = be.input[:len(be.input)-n]
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			be.input += string(msg.Runes)
+		}
+	}
+	m.bulk = &be
+	return m, nil
+}
+
+func (m model) renderBulkEdit(b *strings.Builder) {
+	be := m.bulk
+	fmt.Fprintln(b, m.styles.title.Render(fmt.Sprintf("Bulk edit %d filtered hosts", be.count)))
+	if be.plan == nil {
+		fmt.Fprintln(b, m.styles.help.Render(tr("help.bulkedit.input")))
+		fmt.Fprintln(b, "> "+be.input)
+	} else {
+		p := be.plan
+		if len(p.changes) == 0 {
+			fmt.Fprintln(b, m.styles.help.Render("Nothing to change. Esc to close"))
+		} else {
+			fmt.Fprintln(b, m.styles.help.Render(tr("help.bulkedit.confirm")))
+		}
+		removed := palette.style(statusBad)
+		added := palette.style(statusOK)
+		for _, c := range p.changes {
+			fmt.Fprintln(b, m.styles.help.Render(fmt.Sprintf("%s:%d", c.Path, c.Line)))
+			if c.Old != "" {
+				fmt.Fprintln(b, removed.Render("- "+c.Old))
+			}
+			fmt.Fprintln(b, added.Render("+ "+c.New))
+		}
+		if len(p.skipped) > 0 {
+			fmt.Fprintln(b, m.styles.help.Render("Not in an ssh config, skipped: "+strings.Join(p.skipped, ", ")))
+		}
+	}
+	if be.err != nil {
+		fmt.Fprintln(b, m.styles.error.Render(be.err.Error()))
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const bulkConfig = `Host web1 web1-alt
+  HostName 10.0.0.1
+  User root
+  # sshpick: color=red
+
+Host web2
+    HostName 10.0.0.2
+    IdentityFile ~/.ssh/old
+    # sshpick: tags=prod
+
+Host *
+  ServerAliveInterval 30
+`
+
+func TestPlanBulkEdit(t *testing.T) {
+	t.Parallel()
+
+	cfg := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(cfg, []byte(bulkConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := parseSSHConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts = append(hosts, sshHost{Alias: "inv1", Source: "inventory"})
+
+	cases := []struct {
+		op      string
+		changes int
+		want    []string
+	}{
+		{"User ops", 2, []string{"  HostName 10.0.0.1\n  User ops\n", "Host web2\n    User ops\n"}},
+		{"IdentityFile=~/.ssh/new", 2, []string{"Host web1 web1-alt\n  IdentityFile ~/.ssh/new\n", "IdentityFile ~/.ssh/old\n    IdentityFile ~/.ssh/new\n"}},
+		{"Tag db", 2, []string{"# sshpick: color=red tags=db\n", "# sshpick: tags=prod,db\n"}},
+		{"Tag prod", 1, []string{"# sshpick: color=red tags=prod\n", "# sshpick: tags=prod\n"}},
+	}
+	for _, c := range cases {
+		op, err := parseBulkOp(c.op)
+		if err != nil {
+			t.Fatalf("parseBulkOp(%q): %v", c.op, err)
+		}
+		plan, err := planBulkEdit(hosts, op)
+		if err != nil {
+			t.Fatalf("%s: %v", c.op, err)
+		}
+		if len(plan.changes) != c.changes || len(plan.skipped) != 1 {
+			t.Fatalf("%s: changes %+v skipped %v", c.op, plan.changes, plan.skipped)
+		}
+		got := strings.Join(plan.files[cfg], "\n")
+		for _, w := range c.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("%s: missing %q in\n%s", c.op, w, got)
+			}
+		}
+	}
+}
+
+func TestBulkEditSave(t *testing.T) {
+	t.Parallel()
+
+	cfg := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(cfg, []byte(bulkConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hosts, _ := parseSSHConfig(cfg)
+	op, _ := parseBulkOp("User ops")
+	plan, err := planBulkEdit(hosts, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.save(); err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := parseSSHConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range reparsed {
+		if h.User != "ops" {
+			t.Errorf("%s: User %q", h.Alias, h.User)
+		}
+	}
+	// The plan refuses to edit a file that changed underneath it.
+	os.WriteFile(cfg, []byte("# moved\n"+bulkConfig), 0o600)
+	if _, err := planBulkEdit(hosts, op); err == nil {
+		t.Fatal("stale line numbers should be rejected")
+	}
+	// So does one where another block now starts at the host's line.
+	os.WriteFile(cfg, []byte("Host other\n  User x\n\n"+bulkConfig), 0o600)
+	hosts[1].SourceLine = 1
+	if _, err := planBulkEdit(hosts[1:2], op); err == nil {
+		t.Fatal("a different Host block at the recorded line should be rejected")
+	}
+}
+
+func TestBulkEditReloadsLines(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(cfg, []byte("Host a\n  HostName 10.0.0.1\n\nHost b\n  HostName 10.0.0.2\n\nHost c\n  HostName 10.0.0.3\n"), 0o600)
+	hosts, err := parseSSHConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(hosts, "", cfg)
+	edit := func(only, change string) {
+		t.Helper()
+		for _, h := range m.allHosts {
+			if h.Alias == only {
+				m.hosts = []sshHost{h}
+			}
+		}
+		m = typeKeys(m.openBulkEdit(), change, "enter")
+		if m.bulk == nil || m.bulk.err != nil {
+			t.Fatalf("%s on %s: %+v", change, only, m.bulk)
+		}
+		m = typeKeys(m, "y")
+	}
+	// Each edit of a inserts a line, moving b and c down.
+	edit("a", "Tag web")
+	edit("a", "User root")
+	edit("c", "User deploy")
+
+	data, _ := os.ReadFile(cfg)
+	want := "Host a\n  User root\n  # sshpick: tags=web\n  HostName 10.0.0.1\n\nHost b\n  HostName 10.0.0.2\n\nHost c\n  User deploy\n  HostName 10.0.0.3\n"
+	if string(data) != want {
+		t.Fatalf("config:\n%s", data)
+	}
+	for _, h := range m.allHosts {
+		if h.Alias == "c" && (h.User != "deploy" || h.SourceLine != 9) {
+			t.Fatalf("c after reload: %+v", h)
+		}
+	}
+}
+
+func TestParseBulkOpRejects(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"ProxyJump x", "User", "Tag a,b", "User a b", "Tag x#y"} {
+		if _, err := parseBulkOp(s); err == nil {
+			t.Errorf("parseBulkOp(%q) accepted", s)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// Check step statuses in a `sshpick check` report.
+const (
+	checkOK      = "ok"
+	checkFailed  = "failed"
+	checkSkipped = "skipped"
+)
+
+// checkStep is the outcome of one check of one host.
+type checkStep struct {
+	Status string   `json:"status"`
+	MS     float64  `json:"ms,omitempty"`
+	Addrs  []string `json:"addrs,omitempty"` // resolve: the addresses found
+	Error  string   `json:"error,omitempty"` // why it failed or was skipped
+}
+
+// hostCheck is one host in the report: name resolution, a TCP connect to
+// the ssh port and a non-interactive login, each only run when the one
+// before it passed.
+type hostCheck struct {
+	Alias   string    `json:"alias"`
+	Source  string    `json:"source"`
+	Address string    `json:"address"`
+	OK      bool      `json:"ok"` // no step failed
+	Resolve checkStep `json:"resolve"`
+	TCP     checkStep `json:"tcp"`
+	SSH     checkStep `json:"ssh"`
+}
+
+// checkReport is the output of `sshpick check -format json`.
+type checkReport struct {
+	CheckedAt time.Time   `json:"checked_at"`
+	OK        int         `json:"ok"`
+	Failed    int         `json:"failed"`
+	Skipped   int         `json:"skipped"`
+	Hosts     []hostCheck `json:"hosts"`
+}
+
+// hostChecker runs the checks. The steps are fields so tests don't need a
+// network.
+type hostChecker struct {
+	timeout time.Duration
+	lookup  func(ctx context.Context, host string) ([]string, error)
+	dial    func(addr string, timeout time.Duration) (time.Duration, error)
+	login   func(h sshHost, timeout time.Duration) error
+}
+
+func newHostChecker(timeout time.Duration) hostChecker {
+	return hostChecker{
+		timeout: timeout,
+		lookup:  net.DefaultResolver.LookupHost,
+		dial:    measureLatency,
+		login: func(h sshHost, timeout time.Duration) error {
+			_, err := runProbe(h, "true", timeout)
+			return err
+		},
+	}
+}
+
+// behindProxy reports whether h is only reachable through a jump host or
+// ProxyCommand, so dialing its address from here says nothing.
+func behindProxy(h sshHost) bool {
+	return h.option("proxyjump") != "" || h.option("proxycommand") != ""
+}
+
+func stepSince(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
+func skippedStep(reason string) checkStep {
+	return checkStep{Status: checkSkipped, Error: reason}
+}
+
+// check runs every step for h. Hosts behind a jump host or ProxyCommand
+// are only reachable through it, so only the login is tried for them.
+func (c hostChecker) check(h sshHost) hostCheck {
+	r := hostCheck{Alias: h.Alias, Source: hostSource(h), Address: dialAddress(h)}
+	if skipsBatchProbes(h) {
+		s := skippedStep(errProbeSkipped.Error())
+		r.Resolve, r.TCP, r.SSH, r.OK = s, s, s, true
+		return r
+	}
+	failed := false
+	fail := func(start time.Time, err error) checkStep {
+		failed = true
+		return checkStep{Status: checkFailed, MS: stepSince(start), Error: err.Error()}
+	}
+
+	if behindProxy(h) {
+		r.Resolve, r.TCP = skippedStep("via a jump host"), skippedStep("via a jump host")
+	} else {
+		host, _, _ := net.SplitHostPort(r.Address)
+		start := time.Now()
+		if ip := net.ParseIP(host); ip != nil {
+			r.Resolve = checkStep{Status: checkOK, Addrs: []string{ip.String()}}
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+			addrs, err := c.lookup(ctx, host)
+			cancel()
+			if err != nil {
+				r.Resolve = fail(start, err)
+			} else {
+				r.Resolve = checkStep{Status: checkOK, MS: stepSince(start), Addrs: addrs}
+			}
+		}
+		if failed {
+			r.TCP = skippedStep("resolve failed")
+		} else {
+			start = time.Now()
+			if _, err := c.dial(r.Address, c.timeout); err != nil {
+				r.TCP = fail(start, err)
+			} else {
+				r.TCP = checkStep{Status: checkOK, MS: stepSince(start)}
+			}
+		}
+	}
+
+	switch {
+	case failed:
+		r.SSH = skippedStep("not reachable")
+	case needsTouch(h):
+		// a login would make the security key blink with nobody there
+		r.SSH = skippedStep("needs a security key touch")
+	default:
+		start := time.Now()
+		if err := c.login(h, c.timeout+5*time.Second); err != nil {
+			r.SSH = fail(start, err)
+		} else {
+			r.SSH = checkStep{Status: checkOK, MS: stepSince(start)}
+		}
+	}
+	r.OK = !failed
+	return r
+}
+
+// checkHosts checks every host, at most concurrency at a time, keeping the
+// hosts' order.
+func checkHosts(hosts []sshHost, concurrency int, c hostChecker) checkReport {
+	report := checkReport{CheckedAt: time.Now().UTC(), Hosts: make([]hostCheck, len(hosts))}
+	forEachHost(hosts, concurrency, func(i int, h sshHost) {
+		report.Hosts[i] = c.check(h)
+	})
+	for _, r := range report.Hosts {
+		switch {
+		case !r.OK:
+			report.Failed++
+		case r.SSH.Status == checkSkipped:
+			report.Skipped++
+		default:
+			report.OK++
+		}
+	}
+	return report
+}
+
+func writeCheckReport(w io.Writer, report checkReport, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "table", "":
+		cell := func(s checkStep) string {
+			if s.Status == checkOK && s.MS > 0 {
+				return fmt.Sprintf("ok %.0fms", s.MS)
+			}
+			return s.Status
+		}
+		for _, r := range report.Hosts {
+			line := fmt.Sprintf("%-20s %-30s %-12s %-12s %-12s", r.Alias, r.Address, cell(r.Resolve), cell(r.TCP), cell(r.SSH))
+			for _, s := range []checkStep{r.Resolve, r.TCP, r.SSH} {
+				if s.Status == checkFailed {
+					line += " " + s.Error
+				}
+			}
+			fmt.Fprintln(w, line)
+		}
+		fmt.Fprintf(w, "%d ok, %d failed, %d skipped\n", report.OK, report.Failed, report.Skipped)
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q (table, json)", format)
+	}
+}
+
+// runCheck implements `sshpick check`, a headless health check for cron:
+// every host of every source (or a selection) is resolved, dialed and
+// logged into without prompts. Failures are posted to the alert webhook,
+// if any, and it exits 1.
+func runCheck(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	settingsPath := fs.String("settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
+	promSource := fs.String("prometheus", "", "Prometheus server URL or file_sd JSON file to import scrape targets from")
+	all := fs.Bool("all", false, "Check every host")
+	filter := fs.String("filter", "", "Only hosts whose alias matches this regex")
+	tag := fs.String("tag", "", "Only hosts with this tag")
+	format := fs.String("format", "table", "Output format: table or json")
+	concurrency := fs.Int("concurrency", 16, "Hosts checked at once")
+	timeout := fs.Duration("timeout", latencyTimeout, "Timeout of the resolve and connect steps")
+	webhook := fs.String("webhook", "", "URL to post failures to (default: alert_webhook from the settings)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !*all && *filter == "" && *tag == "" {
+		fmt.Fprintln(os.Stderr, "usage: sshpick check -all | -filter re | -tag t [-format table|json]")
+		return 2
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown format %q (table, json)\n", *format)
+		return 2
+	}
+	settings, err := loadHostKeyPolicy(*settingsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+		return 2
+	}
+	if *cfgPath == "" {
+		*cfgPath = defaultConfigPath()
+	}
+	hosts, loads := loadDaemonHosts(daemonOptions{cfgPath: *cfgPath, promSource: *promSource, settings: settings})
+	for _, l := range loads {
+		if l.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", l.Name, l.Err)
+		}
+	}
+	selected, err := selectHosts(hosts, *filter, *tag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	report := checkHosts(selected, *concurrency, newHostChecker(*timeout))
+	if err := writeCheckReport(stdout, report, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *webhook == "" {
+		*webhook = settings.AlertWebhook
+	}
+	if text := checkAlert(report); text != "" && *webhook != "" {
+		if err := postAlert(*webhook, text); err != nil {
+			fmt.Fprintln(os.Stderr, "alert:", err)
+		}
+	}
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
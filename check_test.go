@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckHosts(t *testing.T) {
+	t.Parallel()
+
+	hosts := []sshHost{
+		{Alias: "web", Hostname: "web.example.com"},
+		{Alias: "gone", Hostname: "gone.example.com"},
+		{Alias: "closed", Hostname: "10.0.0.3"},
+		{Alias: "denied", Hostname: "10.0.0.4"},
+		{Alias: "inner", Hostname: "10.1.0.1", Options: map[string]string{"proxyjump": "bastion"}},
+		{Alias: "mfa", Hostname: "10.0.0.5", Annotations: map[string]string{"mfa": "true"}},
+	}
+	c := hostChecker{
+		timeout: time.Second,
+		lookup: func(_ context.Context, host string) ([]string, error) {
+			if host == "gone.example.com" {
+				return nil, errors.New("no such host")
+			}
+			return []string{"10.0.0.1"}, nil
+		},
+		dial: func(addr string, _ time.Duration) (time.Duration, error) {
+			if addr == "10.0.0.3:22" {
+				return 0, errors.New("connection refused")
+			}
+			return time.Millisecond, nil
+		},
+		login: func(h sshHost, _ time.Duration) error {
+			switch h.Alias {
+			case "denied":
+				return errors.New("Permission denied (publickey)")
+			case "mfa", "gone", "closed":
+				t.Errorf("%s should not be logged into", h.Alias)
+			}
+			return nil
+		},
+	}
+	report := checkHosts(hosts, 3, c)
+	if report.OK != 2 || report.Failed != 3 || report.Skipped != 1 {
+		t.Fatalf("summary %d ok %d failed %d skipped", report.OK, report.Failed, report.Skipped)
+	}
+	byAlias := map[string]hostCheck{}
+	for _, r := range report.Hosts {
+		byAlias[r.Alias] = r
+	}
+	if r := byAlias["web"]; !r.OK || r.Resolve.Addrs[0] != "10.0.0.1" || r.SSH.Status != checkOK {
+		t.Fatalf("web %+v", r)
+	}
+	if r := byAlias["gone"]; r.Resolve.Status != checkFailed || r.TCP.Status != checkSkipped || r.SSH.Status != checkSkipped {
+		t.Fatalf("gone %+v", r)
+	}
+	if r := byAlias["denied"]; r.OK || r.TCP.Status != checkOK || !strings.Contains(r.SSH.Error, "Permission denied") {
+		t.Fatalf("denied %+v", r)
+	}
+	if r := byAlias["inner"]; !r.OK || r.TCP.Status != checkSkipped || r.SSH.Status != checkOK {
+		t.Fatalf("jump host %+v", r)
+	}
+
+	var buf bytes.Buffer
+	if err := writeCheckReport(&buf, report, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var decoded checkReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded.Hosts) != len(hosts) || decoded.Hosts[0].Alias != "web" {
+		t.Fatalf("json report: %v\n%s", err, buf.String())
+	}
+	buf.Reset()
+	writeCheckReport(&buf, report, "table")
+	if !strings.Contains(buf.String(), "2 ok, 3 failed, 1 skipped") {
+		t.Fatalf("table:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// defaultClipboardPort is where the relay listens on the remote host, the
+// port Clipper uses, so existing remote pbcopy shims keep working.
+const defaultClipboardPort = 8377
+
+// maxClipboardBytes caps one copy; anything longer is cut off.
+const maxClipboardBytes = 1 << 20
+
+// clipboardTokenEnv carries the relay's per-session token to the remote
+// shell. The LC_ prefix gets it through the "AcceptEnv LANG LC_*" most
+// sshd configs ship with.
+const clipboardTokenEnv = "LC_SSHPICK_CLIPBOARD"
+
+// clipboardMode is the per-host `clipboard` annotation: "relay" puts text
+// copied on the remote into the local clipboard with pbcopy, wl-copy, xclip
+// or xsel, and "osc52" writes it to this terminal as an OSC 52 sequence
+// (for when sshpick itself runs somewhere without a clipboard).
+func clipboardMode(h sshHost) string {
+	switch strings.ToLower(h.Annotations["clipboard"]) {
+	case "relay", "on", "yes", "true", "1":
+		return "relay"
+	case "osc52":
+		return "osc52"
+	}
+	return ""
+}
+
+// clipboardRemotePort is the port the host's `clipboard_port` annotation
+// asks for, or defaultClipboardPort.
+func clipboardRemotePort(h sshHost) int {
+	if p, err := strconv.Atoi(h.Annotations["clipboard_port"]); err == nil && p > 0 && p < 65536 {
+		return p
+	}
+	return defaultClipboardPort
+}
+
+// clipboardForwardArgs tunnels the remote's loopback clipboard port back
+// to the local relay and sends the session token along.
+func clipboardForwardArgs(opts launchOptions) []string {
+	if opts.clipboardPort == 0 {
+		return nil
+	}
+	return []string{
+		"-R", fmt.Sprintf("127.0.0.1:%d:127.0.0.1:%d", opts.clipboardRemote, opts.clipboardPort),
+		"-o", "SendEnv=" + clipboardTokenEnv,
+	}
+}
+
+// clipboardCommand picks the local program that sets the clipboard from
+// stdin, or nil when there is none.
+func clipboardCommand() []string {
+	if runtime.GOOS == "darwin" {
+		return []string{"pbcopy"}
+	}
+	var candidates [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		candidates = append(candidates, []string{"wl-copy"})
+	}
+	if os.Getenv("DISPLAY") != "" {
+		candidates = append(candidates, []string{"xclip", "-selection", "clipboard"}, []string{"xsel", "--clipboard", "--input"})
+	}
+	candidates = append(candidates, []string{"clip.exe"}) // WSL
+	for _, c := range candidates {
+		if _, err := lookPath(c[0]); err == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// writeOSC52 asks the terminal to put data on the system clipboard.
+func writeOSC52(w io.Writer, data []byte) error {
+	_, err := fmt.Fprintf(w, "\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString(data))
+	return err
+}
+
+// clipboardSink returns what the relay does with each copy. Relay mode
+// falls back to OSC 52 when no clipboard program is installed.
+func clipboardSink(mode string) func([]byte) error {
+	toTerminal := func(data []byte) error {
+		tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer tty.Close()
+		return writeOSC52(tty, data)
+	}
+	if mode == "osc52" {
+		return toTerminal
+	}
+	argv := clipboardCommand()
+	if argv == nil {
+		return toTerminal
+	}
+	return func(data []byte) error {
+		cmd := exec.Command(argv[0], argv[1:]...)
+		cmd.Stdin = strings.NewReader(string(data))
+		return cmd.Run()
+	}
+}
+
+// serveClipboard copies what is written to each connection, up to
+// maxClipboardBytes, with sink until the listener is closed. A connection
+// must start with the session token on a line of its own: anyone on the
+// remote host can reach the forwarded port, but only the session's shell
+// has the token.
+func serveClipboard(ln net.Listener, token string, sink func([]byte) error) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			r := bufio.NewReader(io.LimitReader(conn, int64(len(token))+1+maxClipboardBytes))
+			line, err := r.ReadString('\n')
+			if err != nil || subtle.ConstantTimeCompare([]byte(strings.TrimSuffix(line, "\n")), []byte(token)) != 1 {
+				fmt.Fprintf(os.Stderr, "\r\nclipboard: ignored a copy without this session's token ($%s)\r\n", clipboardTokenEnv)
+				return
+			}
+			data, err := io.ReadAll(r)
+			if err != nil || len(data) == 0 {
+				return
+			}
+			if err := sink(data); err != nil {
+				fmt.Fprintln(os.Stderr, "\r\nclipboard:", err)
+			}
+		}()
+	}
+}
+
+// startClipboardBridge starts the local relay for a host with a clipboard
+// mode and points opts at it. The returned func stops the relay.
+func startClipboardBridge(h sshHost, opts *launchOptions) (func(), error) {
+	mode := clipboardMode(h)
+	if mode == "" {
+		return func() {}, nil
+	}
+	var raw [16]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(raw[:])
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	// ssh runs as a subprocess and inherits it; SendEnv passes it on.
+	os.Setenv(clipboardTokenEnv, token)
+	go serveClipboard(ln, token, clipboardSink(mode))
+	opts.clipboardPort = ln.Addr().(*net.TCPAddr).Port
+	opts.clipboardRemote = clipboardRemotePort(h)
+	opts.subprocess = true // the relay lives as long as sshpick does
+	return func() {
+		ln.Close()
+		os.Unsetenv(clipboardTokenEnv)
+	}, nil
+}
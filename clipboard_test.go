@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClipboardMode(t *testing.T) {
+	t.Parallel()
+
+	for v, want := range map[string]string{"": "", "off": "", "relay": "relay", "yes": "relay", "OSC52": "osc52", "bogus": ""} {
+		h := sshHost{Alias: "web1", Annotations: map[string]string{"clipboard": v}}
+		if got := clipboardMode(h); got != want {
+			t.Errorf("clipboard=%q: mode %q, want %q", v, got, want)
+		}
+	}
+	h := sshHost{Alias: "web1", Annotations: map[string]string{"clipboard": "relay", "clipboard_port": "2224"}}
+	if p := clipboardRemotePort(h); p != 2224 {
+		t.Fatalf("remote port %d", p)
+	}
+	if p := clipboardRemotePort(sshHost{}); p != defaultClipboardPort {
+		t.Fatalf("default remote port %d", p)
+	}
+}
+
+func TestClipboardForwardArgs(t *testing.T) {
+	t.Parallel()
+
+	opts := launchOptions{clipboardPort: 40001, clipboardRemote: 8377, clearForwards: true}
+	h := sshHost{Alias: "web1", LocalForwards: []string{"8080 localhost:80"}}
+	// ClearAllForwardings would drop the -R too, so the bridge keeps the config's forwards.
+	if got := strings.Join(sshArgs(h, opts), " "); got != "-R 127.0.0.1:8377:127.0.0.1:40001 -o SendEnv=LC_SSHPICK_CLIPBOARD -- web1" {
+		t.Fatalf("ssh args %s", got)
+	}
+}
+
+func TestWriteOSC52(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	if err := writeOSC52(&b, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "\x1b]52;c;aGVsbG8=\x07" {
+		t.Fatalf("osc52 %q", b.String())
+	}
+}
+
+func TestServeClipboard(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan string, 2)
+	go serveClipboard(ln, "6f1c", func(data []byte) error {
+		got <- string(data)
+		return nil
+	})
+	send := func(s string) {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Write([]byte(s))
+		conn.Close()
+	}
+
+	// Other users on the remote don't have the token.
+	send("copied by someone else")
+	send("6f1d\nwrong token")
+	send("6f1c\ncopied on the remote\n")
+	select {
+	case s := <-got:
+		if s != "copied on the remote\n" {
+			t.Fatalf("copied %q", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing copied")
+	}
+	select {
+	case s := <-got:
+		t.Fatalf("copied %q without the token", s)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestClipboardBridgeToken(t *testing.T) {
+	t.Setenv(clipboardTokenEnv, "")
+	var opts launchOptions
+	stop, err := startClipboardBridge(sshHost{Alias: "web1", Annotations: map[string]string{"clipboard": "osc52"}}, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := os.Getenv(clipboardTokenEnv)
+	if len(token) != 32 || opts.clipboardPort == 0 {
+		t.Fatalf("token %q, port %d", token, opts.clipboardPort)
+	}
+	stop()
+	if os.Getenv(clipboardTokenEnv) != "" {
+		t.Fatal("the token should go with the relay")
+	}
+}
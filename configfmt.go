@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// configBlock is a Host or Match line and everything up to the next one,
+// which is also how the parser assigns comments and annotations.
+type configBlock struct {
+	start    int // 1-based line of the Host/Match line
+	lines    []string
+	patterns []string // Host patterns; nil for Match
+}
+
+// movable reports whether the block names only concrete hosts. Wildcard
+// Host blocks and Match blocks act on other hosts, and since ssh uses the
+// first value it finds, moving anything across them changes the result.
+func (b configBlock) movable() bool {
+	if len(b.patterns) == 0 {
+		return false
+	}
+	for _, p := range b.patterns {
+		if strings.ContainsAny(p, "*?!") {
+			return false
+		}
+	}
+	return true
+}
+
+// splitConfig separates the global lines before the first block from the
+// blocks.
+func splitConfig(data string) (preamble []string, blocks []configBlock) {
+	lines := strings.Split(strings.TrimRight(data, "\n"), "\n")
+	for i, line := range lines {
+		if !isBlockStart(line) {
+			if len(blocks) == 0 {
+				preamble = append(preamble, line)
+			} else {
+				blocks[len(blocks)-1].lines = append(blocks[len(blocks)-1].lines, line)
+			}
+			continue
+		}
+		blocks = append(blocks, configBlock{start: i + 1, lines: []string{line}, patterns: hostPatterns(line)})
+	}
+	return preamble, blocks
+}
+
+func joinConfig(preamble []string, blocks []configBlock) string {
+	var all []string
+	all = append(all, preamble...)
+	for _, b := range blocks {
+		all = append(all, b.lines...)
+	}
+	return strings.Join(all, "\n") + "\n"
+}
+
+// fmtOptions controls `sshpick fmt`.
+type fmtOptions struct {
+	indent string
+	order  string // "keep", "alias" or "tag"
+}
+
+// formatConfig normalizes indentation and blank lines and optionally
+// reorders Host blocks. Only runs of consecutive concrete Host blocks are
+// sorted, and only when no alias appears in two of them, so the settings
+// each host ends up with never change.
+func formatConfig(data string, opts fmtOptions) (string, error) {
+	preamble, blocks := splitConfig(data)
+	preamble = tidyLines(preamble, "")
+	for i := range blocks {
+		body := tidyLines(blocks[i].lines[1:], opts.indent)
+		blocks[i].lines = append([]string{strings.TrimSpace(blocks[i].lines[0])}, body...)
+	}
+
+	switch opts.order {
+	case "", "keep":
+	case "alias", "tag":
+		for start := 0; start < len(blocks); {
+			end := start
+			for end < len(blocks) && blocks[end].movable() {
+				end++
+			}
+			if run := blocks[start:end]; len(run) > 1 && !overlapping(run) {
+				sortBlocks(run, opts.order)
+			}
+			start = end + 1
+		}
+	default:
+		return "", fmt.Errorf("unknown order %q (keep, alias, tag)", opts.order)
+	}
+
+	// One blank line between sections.
+	if len(preamble) > 0 && len(blocks) > 0 {
+		preamble = append(preamble, "")
+	}
+	for i := range blocks[:max(len(blocks)-1, 0)] {
+		blocks[i].lines = append(blocks[i].lines, "")
+	}
+	return joinConfig(preamble, blocks), nil
+}
+
+// tidyLines re-indents lines, trims trailing space and collapses blank
+// runs, dropping leading and trailing blanks.
+func tidyLines(lines []string, indent string) []string {
+	var out []string
+	blank := false
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, indent+l)
+	}
+	return out
+}
+
+func overlapping(blocks []configBlock) bool {
+	seen := map[string]bool{}
+	for _, b := range blocks {
+		for _, p := range b.patterns {
+			if seen[strings.ToLower(p)] {
+				return true
+			}
+			seen[strings.ToLower(p)] = true
+		}
+	}
+	return false
+}
+
+// sortBlocks orders by alias, or with "tag" by first tag (untagged last)
+// and then alias.
+func sortBlocks(blocks []configBlock, order string) {
+	key := func(b configBlock) (string, string) {
+		alias := strings.ToLower(b.patterns[0])
+		if order != "tag" {
+			return "", alias
+		}
+		for _, l := range b.lines[1:] {
+			c := strings.TrimSpace(l)
+			if !strings.HasPrefix(c, "#") {
+				continue
+			}
+			if kv, ok := parseAnnotation(strings.TrimSpace(c[1:])); ok && kv["tags"] != "" {
+				first, _, _ := strings.Cut(kv["tags"], ",")
+				return "0" + strings.ToLower(first), alias
+			}
+		}
+		return "1", alias
+	}
+	sort.SliceStable(blocks, func(i, j int) bool {
+		gi, ai := key(blocks[i])
+		gj, aj := key(blocks[j])
+		if gi != gj {
+			return gi < gj
+		}
+		return ai < aj
+	})
+}
+
+// moveBlock swaps alias's block, starting at hostLine, with its neighbour
+// (delta -1 or +1). Blank lines stay where they were, so the layout of the
+// file is kept.
+func moveBlock(data string, hostLine int, alias string, delta int) (string, error) {
+	preamble, blocks := splitConfig(data)
+	i := -1
+	for j, b := range blocks {
+		if b.start == hostLine && containsString(b.patterns, alias) {
+			i = j
+		}
+	}
+	if i < 0 {
+		return "", errors.New("host block not found; the config changed on disk")
+	}
+	j := i + delta
+	if j < 0 || j >= len(blocks) {
+		return "", errors.New("already at the edge of the file")
+	}
+	if !blocks[i].movable() || !blocks[j].movable() {
+		return "", errors.New("won't move across a wildcard Host or Match block: it would change which settings apply")
+	}
+	a, b := trailingBlanks(blocks[i].lines), trailingBlanks(blocks[j].lines)
+	blocks[i].lines, blocks[j].lines = append(trimBlanks(blocks[j].lines), a...), append(trimBlanks(blocks[i].lines), b...)
+	return joinConfig(preamble, blocks), nil
+}
+
+func trailingBlanks(lines []string) []string {
+	n := len(lines)
+	for n > 0 && strings.TrimSpace(lines[n-1]) == "" {
+		n--
+	}
+	return append([]string{}, lines[n:]...)
+}
+
+func trimBlanks(lines []string) []string {
+	return lines[:len(lines)-len(trailingBlanks(lines))]
+}
+
+// moveHostBlock moves the highlighted host's block up or down in its file
+// and reloads the config, so every host's line numbers match the file again.
+func (m model) moveHostBlock(delta int) (model, tea.Cmd) {
+	if m.readOnly {
+		m.err = errReadOnly
+		return m, nil
+	}
+	if len(m.hosts) == 0 || m.hosts[m.cursor].SourceLine == 0 {
+		m.err = errors.New("only hosts defined in an ssh config can be moved")
+		return m, nil
+	}
+	h := m.hosts[m.cursor]
+	data, err := os.ReadFile(h.SourcePath)
+	if err == nil {
+		var out string
+		if out, err = moveBlock(string(data), h.SourceLine, h.Alias, delta); err == nil {
+			err = writeConfigFile(h.SourcePath, out)
+		}
+	}
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	m.err = nil
+	m, cmd := m.reloadConfig()
+	for i, v := range m.hosts {
+		if v.Alias == h.Alias {
+			m.cursor = i
+		}
+	}
+	return m, cmd
+}
+
+// writeConfigFile replaces path via a temporary file, keeping its mode. A
+// symlinked config (a dotfiles checkout) has its target replaced, so the
+// link stays a link.
+func writeConfigFile(path, data string) error {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// runFmt implements `sshpick fmt`. Like gofmt it prints the result unless
+// -w is given; -check exits 1 when the file isn't formatted.
+func runFmt(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	settingsPath := fs.String("settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
+	order := fs.String("order", "keep", "Host block order: keep, alias, or tag (grouped by first tag)")
+	indent := fs.Int("indent", 4, "Spaces before directives inside a block")
+	write := fs.Bool("w", false, "Write the result back to the config")
+	check := fs.Bool("check", false, "Only report whether the config is formatted")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *cfgPath == "" {
+		*cfgPath = defaultConfigPath()
+	}
+	data, err := os.ReadFile(*cfgPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading config:", err)
+		return 2
+	}
+	out, err := formatConfig(string(data), fmtOptions{indent: strings.Repeat(" ", *indent), order: *order})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	switch {
+	case *check:
+		if !bytes.Equal(data, []byte(out)) {
+			fmt.Fprintln(stdout, *cfgPath)
+			return 1
+		}
+	case *write:
+		settings, err := loadAppConfig(*settingsPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+			return 2
+		}
+		if settings.ReadOnly {
+			fmt.Fprintln(os.Stderr, errReadOnly)
+			return 1
+		}
+		if err := writeConfigFile(*cfgPath, out); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	default:
+		io.WriteString(stdout, out)
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatConfig(t *testing.T) {
+	t.Parallel()
+
+	in := "# global\nServerAliveInterval 30\nHost zeta\n\tHostName 10.0.0.9   \n\n\n  # sshpick: tags=web\nHost alpha\nHostName 10.0.0.1\n# sshpick: tags=db\n\n\nHost *\n  User ops\nHost beta\n  HostName 10.0.0.2\n"
+
+	got, err := formatConfig(in, fmtOptions{indent: "    ", order: "keep"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# global\nServerAliveInterval 30\n\nHost zeta\n    HostName 10.0.0.9\n\n    # sshpick: tags=web\n\nHost alpha\n    HostName 10.0.0.1\n    # sshpick: tags=db\n\nHost *\n    User ops\n\nHost beta\n    HostName 10.0.0.2\n"
+	if got != want {
+		t.Fatalf("keep:\n%s\nwant:\n%s", got, want)
+	}
+	if again, _ := formatConfig(got, fmtOptions{indent: "    "}); again != got {
+		t.Fatalf("not idempotent:\n%s", again)
+	}
+
+	// Sorting stops at "Host *": beta stays after it.
+	got, _ = formatConfig(in, fmtOptions{indent: "  ", order: "alias"})
+	if order := blockOrder(got); order != "alpha zeta * beta" {
+		t.Fatalf("alias order %q", order)
+	}
+	got, _ = formatConfig(in, fmtOptions{indent: "  ", order: "tag"})
+	if order := blockOrder(got); order != "alpha zeta * beta" {
+		t.Fatalf("tag order %q", order)
+	}
+
+	// Overlapping blocks are never reordered.
+	overlap := "Host web2\n  User a\nHost web1 web2\n  User b\n"
+	got, _ = formatConfig(overlap, fmtOptions{indent: "  ", order: "alias"})
+	if order := blockOrder(got); order != "web2 web1" {
+		t.Fatalf("overlap order %q", order)
+	}
+	if _, err := formatConfig(in, fmtOptions{order: "size"}); err == nil {
+		t.Fatal("unknown order accepted")
+	}
+}
+
+func blockOrder(config string) string {
+	_, blocks := splitConfig(config)
+	var names []string
+	for _, b := range blocks {
+		names = append(names, b.patterns[0])
+	}
+	return strings.Join(names, " ")
+}
+
+func TestMoveBlock(t *testing.T) {
+	t.Parallel()
+
+	in := "Host a\n  HostName 1\n\nHost b\n  HostName 2\n\nHost *\n  User x\n"
+	out, err := moveBlock(in, 4, "b", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Host b\n  HostName 2\n\nHost a\n  HostName 1\n\nHost *\n  User x\n" {
+		t.Fatalf("moved:\n%s", out)
+	}
+	if _, err := moveBlock(out, 4, "a", 1); err == nil {
+		t.Fatal("moved across Host *")
+	}
+	if _, err := moveBlock(out, 1, "b", -1); err == nil {
+		t.Fatal("moved past the top")
+	}
+	if _, err := moveBlock(out, 2, "b", 1); err == nil {
+		t.Fatal("moved a block that doesn't start at that line")
+	}
+	if _, err := moveBlock(out, 4, "b", -1); err == nil {
+		t.Fatal("moved another host's block")
+	}
+}
+
+func TestMoveHostBlockUpdatesModel(t *testing.T) {
+	t.Parallel()
+
+	cfg := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(cfg, []byte("Host a\n  HostName 10.0.0.1\n\nHost b\n  HostName 10.0.0.2\n"), 0o600)
+	hosts, err := parseSSHConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(hosts, "", cfg)
+	m.cursor = 1
+	m, _ = m.moveHostBlock(-1)
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	if m.allHosts[0].Alias != "b" || m.allHosts[0].SourceLine != 1 || m.hosts[m.cursor].Alias != "b" {
+		t.Fatalf("hosts %+v cursor %d", m.allHosts, m.cursor)
+	}
+
+	m.readOnly = true
+	if m, _ = m.moveHostBlock(1); m.err != errReadOnly {
+		t.Fatalf("read-only: %v", m.err)
+	}
+}
+
+func TestWriteConfigFileFollowsSymlink(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "ssh_config")
+	os.MkdirAll(filepath.Dir(target), 0o700)
+	os.WriteFile(target, []byte("Host a\n"), 0o640)
+	link := filepath.Join(dir, "config")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if err := writeConfigFile(link, "Host b\n"); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("the link was replaced: %v %v", fi, err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "Host b\n" {
+		t.Fatalf("target %q", data)
+	}
+	if fi, _ := os.Stat(target); fi.Mode().Perm() != 0o640 {
+		t.Fatalf("mode %v", fi.Mode())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(target)); len(entries) != 1 {
+		t.Fatalf("temporary file left behind: %v", entries)
+	}
+}
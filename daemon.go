@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// providerLoad is how one host source fared in the last refresh.
+type providerLoad struct {
+	Name     string
+	Duration time.Duration
+	Hosts    int
+	Err      error
+}
+
+// daemonOptions are what `sshpick daemon` reloads from on every refresh.
+type daemonOptions struct {
+	cfgPath    string
+	promSource string
+	settings   appConfig
+	webhook    string // posted hosts going down and coming back
+}
+
+// daemonState is what the daemon knows after its last refresh; it's read
+// by the metrics handler while the next refresh runs.
+type daemonState struct {
+	mu          sync.Mutex
+	hosts       []sshHost
+	providers   []providerLoad
+	checks      []latencyResult
+	checkOK     int // cumulative reachability checks that succeeded
+	checkFailed int // ... and that failed
+	launched    map[string]int
+	refreshedAt time.Time
+	refreshes   int
+
+	launch      launchOptions              // how hosts.command and hosts.connect build ssh
+	refreshNow  chan struct{}              // daemon.refresh asks the loop for an early refresh
+	subscribers map[chan statusChange]bool // GET /events streams
+}
+
+func newDaemonState(opts daemonOptions) *daemonState {
+	return &daemonState{
+		launch:     launchOptions{tagDefaults: opts.settings.TagDefaults},
+		refreshNow: make(chan struct{}, 1),
+	}
+}
+
+// loadDaemonHosts loads every configured source the way the picker does,
+// timing each one.
+func loadDaemonHosts(opts daemonOptions) ([]sshHost, []providerLoad) {
+	var loads []providerLoad
+	timed := func(name string, load func() ([]sshHost, error)) []sshHost {
+		start := time.Now()
+		hosts, err := load()
+		loads = append(loads, providerLoad{Name: name, Duration: time.Since(start), Hosts: len(hosts), Err: err})
+		return hosts
+	}
+
+	hosts := timed("config", func() ([]sshHost, error) {
+		hosts, err := parseSSHConfig(opts.cfgPath)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return hosts, err
+	})
+	if opts.promSource != "" {
+		hosts = mergeHosts(hosts, timed("prometheus", func() ([]sshHost, error) {
+			return loadPrometheusHosts(opts.promSource, opts.settings.ProviderProxy)
+		}))
+	}
+	providers, err := opts.settings.hostProviders()
+	if err != nil {
+		loads = append(loads, providerLoad{Name: "providers", Err: err})
+	}
+	for _, p := range providers {
+		hosts = mergeHosts(hosts, timed(p.sourceName(), func() ([]sshHost, error) {
+			hosts, _, err := loadAllPages(p)
+			return hosts, err
+		}))
+	}
+	closeTunnels()
+	meta, _ := hostMetadata(opts.settings, true)
+	return applyMetadata(hosts, meta), loads
+}
+
+// checkDaemonHosts checks every host is reachable: a TCP connect to its
+// ssh port, or for hosts behind a jump host or ProxyCommand, which can't be
+// dialed from here, a non-interactive login through it (hostChecker).
+func checkDaemonHosts(hosts []sshHost, c hostChecker) []latencyResult {
+	var direct, proxied []sshHost
+	for _, h := range hosts {
+		if behindProxy(h) && !skipsBatchProbes(h) {
+			proxied = append(proxied, h)
+		} else {
+			direct = append(direct, h)
+		}
+	}
+	results := measureHosts(direct, c.timeout)
+	logins := make([]latencyResult, len(proxied))
+	forEachHost(proxied, maxProbes, func(i int, h sshHost) {
+		r := c.check(h)
+		switch {
+		case r.SSH.Status == checkOK:
+			logins[i] = latencyResult{Host: h, Latency: time.Duration(r.SSH.MS * float64(time.Millisecond))}
+		case r.SSH.Status == checkSkipped:
+			logins[i] = latencyResult{Host: h, Err: errProbeSkipped}
+		default:
+			logins[i] = latencyResult{Host: h, Err: errors.New(r.SSH.Error)}
+		}
+	})
+	return append(results, logins...)
+}
+
+// refresh reloads the hosts, checks every one is reachable and re-reads
+// the connection counts from the history store.
+func (d *daemonState) refresh(opts daemonOptions) {
+	hosts, loads := loadDaemonHosts(opts)
+	checks := checkDaemonHosts(hosts, newHostChecker(latencyTimeout))
+	launched := map[string]int{}
+	for _, e := range loadHistory(historyPath()) {
+		launched[e.Alias]++
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	changes := statusChanges(d.checks, checks)
+	d.publish(changes)
+	if text := daemonAlert(d.checks, changes); text != "" && opts.webhook != "" {
+		go func() {
+			if err := postAlert(opts.webhook, text); err != nil {
+				fmt.Fprintln(os.Stderr, "alert:", err)
+			}
+		}()
+	}
+	d.hosts, d.providers, d.checks, d.launched = hosts, loads, checks, launched
+	for _, c := range checks {
+		switch {
+		case errors.Is(c.Err, errProbeSkipped):
+		case c.Err == nil:
+			d.checkOK++
+		default:
+			d.checkFailed++
+		}
+	}
+	d.refreshedAt = time.Now()
+	d.refreshes++
+}
+
+// promLabel escapes a Prometheus label value.
+func promLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// writeMetrics writes the state in the Prometheus text exposition format.
+func (d *daemonState) writeMetrics(w io.Writer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	metric("sshpick_hosts", "gauge", "Hosts known, by source.")
+	for _, s := range sourceCounts(d.hosts) {
+		fmt.Fprintf(w, "sshpick_hosts{source=\"%s\"} %d\n", promLabel(s.Name), s.Count)
+	}
+
+	metric("sshpick_provider_load_duration_seconds", "gauge", "How long each host source took to load in the last refresh.")
+	for _, p := range d.providers {
+		fmt.Fprintf(w, "sshpick_provider_load_duration_seconds{provider=\"%s\"} %g\n", promLabel(p.Name), p.Duration.Seconds())
+	}
+	metric("sshpick_provider_up", "gauge", "Whether each host source loaded without error in the last refresh.")
+	for _, p := range d.providers {
+		up := 1
+		if p.Err != nil {
+			up = 0
+		}
+		fmt.Fprintf(w, "sshpick_provider_up{provider=\"%s\"} %d\n", promLabel(p.Name), up)
+	}
+
+	metric("sshpick_checks_total", "counter", "Reachability checks run, by result.")
+	fmt.Fprintf(w, "sshpick_checks_total{result=\"success\"} %d\n", d.checkOK)
+	fmt.Fprintf(w, "sshpick_checks_total{result=\"failure\"} %d\n", d.checkFailed)
+
+	metric("sshpick_host_up", "gauge", "Whether the host's ssh port accepted a TCP connection (a login, behind a jump host) in the last check.")
+	for _, c := range d.checks {
+		if errors.Is(c.Err, errProbeSkipped) {
+			continue
+		}
+		up := 0
+		if c.Err == nil {
+			up = 1
+		}
+		fmt.Fprintf(w, "sshpick_host_up{alias=\"%s\"} %d\n", promLabel(c.Host.Alias), up)
+	}
+	metric("sshpick_host_connect_seconds", "gauge", "TCP connect time to the host's ssh port (login time, behind a jump host) in the last check.")
+	for _, c := range d.checks {
+		if c.Err == nil {
+			fmt.Fprintf(w, "sshpick_host_connect_seconds{alias=\"%s\"} %g\n", promLabel(c.Host.Alias), c.Latency.Seconds())
+		}
+	}
+
+	metric("sshpick_connections_launched_total", "counter", "Connections launched from sshpick, from the history store.")
+	aliases := make([]string, 0, len(d.launched))
+	for a := range d.launched {
+		aliases = append(aliases, a)
+	}
+	sort.Strings(aliases)
+	for _, a := range aliases {
+		fmt.Fprintf(w, "sshpick_connections_launched_total{alias=\"%s\"} %d\n", promLabel(a), d.launched[a])
+	}
+
+	metric("sshpick_last_refresh_timestamp_seconds", "gauge", "When the daemon last refreshed.")
+	if !d.refreshedAt.IsZero() {
+		fmt.Fprintf(w, "sshpick_last_refresh_timestamp_seconds %d\n", d.refreshedAt.Unix())
+	}
+}
+
+func (d *daemonState) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		d.writeMetrics(w)
+	})
+	mux.HandleFunc("/rpc", d.serveRPC)
+	mux.HandleFunc("/events", d.serveEvents)
+	return mux
+}
+
+// runDaemon implements `sshpick daemon`: it refreshes the hosts and checks
+// them on an interval, serving metrics and the control API (see rpc.go) on
+// a local HTTP endpoint.
+func runDaemon(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	settingsPath := fs.String("settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
+	promSource := fs.String("prometheus", "", "Prometheus server URL or file_sd JSON file to import scrape targets from")
+	listen := fs.String("listen", "127.0.0.1:9273", "Address to serve /metrics, /rpc and /events on")
+	interval := fs.Duration("interval", 5*time.Minute, "How often to reload hosts and check them")
+	webhook := fs.String("webhook", "", "URL to post hosts going down to (default: alert_webhook from the settings)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *interval < 10*time.Second {
+		fmt.Fprintln(os.Stderr, "-interval must be at least 10s")
+		return 2
+	}
+	settings, err := loadHostKeyPolicy(*settingsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+		return 2
+	}
+	if *cfgPath == "" {
+		*cfgPath = defaultConfigPath()
+	}
+	if *webhook == "" {
+		*webhook = settings.AlertWebhook
+	}
+	opts := daemonOptions{cfgPath: *cfgPath, promSource: *promSource, settings: settings, webhook: *webhook}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	state := newDaemonState(opts)
+	srv := &http.Server{Handler: state.handler(), ReadHeaderTimeout: 5 * time.Second}
+	go srv.Serve(ln)
+	fmt.Fprintf(os.Stderr, "serving http://%s/metrics and the API on /rpc\n", ln.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		state.refresh(opts)
+		select {
+		case <-ctx.Done():
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if srv.Shutdown(shutdown) != nil {
+				srv.Close() // event streams don't end on their own
+			}
+			return 0
+		case <-ticker.C:
+		case <-state.refreshNow:
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemonMetrics(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	up, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer up.Close()
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	downAddr := down.Addr().(*net.TCPAddr)
+	down.Close()
+
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config")
+	upAddr := up.Addr().(*net.TCPAddr)
+	config := fmt.Sprintf(`Host web1
+  HostName 127.0.0.1
+  Port %d
+
+Host db1
+  HostName 127.0.0.1
+  Port %d
+
+Host vault
+  # sshpick: mfa
+  HostName 127.0.0.1
+`, upAddr.Port, downAddr.Port)
+	if err := os.WriteFile(cfg, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendHistory(historyPath(), historyEntry{Alias: "web1", Start: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := daemonOptions{cfgPath: cfg}
+	state := newDaemonState(opts)
+	state.refresh(opts)
+	state.refresh(opts)
+
+	srv := httptest.NewServer(state.handler())
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	out := string(body)
+
+	for _, want := range []string{
+		"# TYPE sshpick_hosts gauge\n",
+		`sshpick_hosts{source="config"} 3`,
+		`sshpick_provider_up{provider="config"} 1`,
+		`sshpick_checks_total{result="success"} 2`,
+		`sshpick_checks_total{result="failure"} 2`,
+		`sshpick_host_up{alias="web1"} 1`,
+		`sshpick_host_up{alias="db1"} 0`,
+		`sshpick_host_connect_seconds{alias="web1"} `,
+		`sshpick_connections_launched_total{alias="web1"} 1`,
+		"sshpick_last_refresh_timestamp_seconds ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `alias="vault"`) {
+		t.Error("hosts that opt out of probes must not be checked")
+	}
+	if got := promLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("promLabel = %q", got)
+	}
+}
+
+func TestDaemonChecksJumpHostsByLogin(t *testing.T) {
+	t.Parallel()
+
+	bastion := sshHost{Alias: "db1", Hostname: "10.255.0.1", Options: map[string]string{"proxyjump": "bastion"}}
+	c := hostChecker{
+		timeout: time.Second,
+		dial: func(string, time.Duration) (time.Duration, error) {
+			t.Fatal("a host behind a jump host must not be dialed directly")
+			return 0, nil
+		},
+		login: func(h sshHost, _ time.Duration) error { return nil },
+	}
+	checks := checkDaemonHosts([]sshHost{bastion}, c)
+	if len(checks) != 1 || checks[0].Err != nil {
+		t.Fatalf("checks %+v", checks)
+	}
+	if text := daemonAlert(nil, statusChanges(nil, checks)); text != "" {
+		t.Fatalf("alert for a reachable jump host: %q", text)
+	}
+
+	c.login = func(sshHost, time.Duration) error { return fmt.Errorf("exit status 255: Connection closed by bastion") }
+	checks = checkDaemonHosts([]sshHost{bastion}, c)
+	if len(checks) != 1 || checks[0].Err == nil || !strings.Contains(checks[0].Err.Error(), "bastion") {
+		t.Fatalf("checks %+v", checks)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net"
+	"os/exec"
+	"runtime"
+)
+
+// lookPath is exec.LookPath, swappable so tests don't depend on installed clients.
+var lookPath = exec.LookPath
+
+// desktopEndpoint returns the host:port for an rdp/vnc annotation. A bare
+// annotation (or one without a port) means the host's own address on the
+// protocol's default port.
+func desktopEndpoint(h sshHost, key, defaultPort string) string {
+	v, ok := h.Annotations[key]
+	if !ok {
+		return ""
+	}
+	host := v
+	if h.annotationBool(key) || v == "" {
+		host = h.Hostname
+		if host == "" {
+			host = h.Alias
+		}
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, defaultPort)
+}
+
+// desktopActions launches a graphical client for hosts annotated with
+// `rdp` or `vnc`, e.g. `# sshpick: rdp` or `# sshpick: vnc=10.0.0.9:5901`.
+func desktopActions(h sshHost) []hostAction {
+	var actions []hostAction
+	if ep := desktopEndpoint(h, "rdp", "3389"); ep != "" {
+		actions = append(actions, rdpAction(h, ep))
+	}
+	if ep := desktopEndpoint(h, "vnc", "5900"); ep != "" {
+		actions = append(actions, vncAction(ep))
+	}
+	return actions
+}
+
+// rdpAction prefers FreeRDP, then the macOS rdp:// URL handler. Without
+// either, the action still names xfreerdp so the failure says what's missing.
+func rdpAction(h sshHost, ep string) hostAction {
+	label := "RDP (" + ep + ")"
+	for _, client := range []string{"xfreerdp3", "xfreerdp"} {
+		if _, err := lookPath(client); err == nil {
+			args := []string{"/v:" + ep}
+			if h.User != "" {
+				args = append(args, "/u:"+h.User)
+			}
+			return hostAction{label: label, name: client, args: args}
+		}
+	}
+	if runtime.GOOS == "darwin" {
+		return hostAction{label: label, name: "open", args: []string{"rdp://full%20address=s:" + ep}}
+	}
+	return hostAction{label: label + " — install xfreerdp", name: "xfreerdp", args: []string{"/v:" + ep}}
+}
+
+func vncAction(ep string) hostAction {
+	label := "VNC (" + ep + ")"
+	host, port, _ := net.SplitHostPort(ep)
+	if _, err := lookPath("vncviewer"); err == nil {
+		// host::port is the port (not display number) form every viewer accepts.
+		return hostAction{label: label, name: "vncviewer", args: []string{host + "::" + port}}
+	}
+	if runtime.GOOS == "darwin" {
+		return hostAction{label: label, name: "open", args: []string{"vnc://" + ep}}
+	}
+	return hostAction{label: label + " — install vncviewer", name: "vncviewer", args: []string{host + "::" + port}}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDesktopActions(t *testing.T) {
+	orig := lookPath
+	defer func() { lookPath = orig }()
+	lookPath = func(name string) (string, error) {
+		if name == "xfreerdp" || name == "vncviewer" {
+			return "/usr/bin/" + name, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	h := sshHost{Alias: "win1", Hostname: "10.0.0.9", User: "Administrator",
+		Annotations: map[string]string{"rdp": "true", "vnc": "10.0.0.9:5901"}}
+	got := desktopActions(h)
+	want := []string{
+		"xfreerdp /v:10.0.0.9:3389 /u:Administrator",
+		"vncviewer 10.0.0.9::5901",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Fatalf("action %d = %q, want %q", i, got[i].String(), want[i])
+		}
+	}
+
+	h = sshHost{Alias: "win2", Annotations: map[string]string{"rdp": "rdp-gw.example"}}
+	if got := desktopEndpoint(h, "rdp", "3389"); got != "rdp-gw.example:3389" {
+		t.Fatalf("desktopEndpoint = %q", got)
+	}
+	if len(desktopActions(sshHost{Alias: "linux1"})) != 0 {
+		t.Fatalf("expected no desktop actions without annotations")
+	}
+}
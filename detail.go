@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// option returns a directive value for h (lowercased key) or "".
+func (h sshHost) option(key string) string {
+	return h.Options[strings.ToLower(key)]
+}
+
+// optionIs reports whether a yes/no directive is explicitly set to want.
+func (h sshHost) optionIs(key, want string) bool {
+	return strings.EqualFold(h.option(key), want)
+}
+
+// authStrategy summarizes how ssh will try to authenticate to h, based on
+// IdentitiesOnly, PreferredAuthentications and the *Authentication toggles.
+func authStrategy(h sshHost) string {
+	pubkey := !h.optionIs("PubkeyAuthentication", "no")
+	password := !h.optionIs("PasswordAuthentication", "no") || !h.optionIs("KbdInteractiveAuthentication", "no")
+	if pref := h.option("PreferredAuthentications"); pref != "" {
+		methods := map[string]bool{}
+		for _, m := range strings.Split(pref, ",") {
+			methods[strings.TrimSpace(strings.ToLower(m))] = true
+		}
+		pubkey = pubkey && methods["publickey"]
+		password = password && (methods["password"] || methods["keyboard-interactive"])
+	}
+
+	keys := identityDescription(h)
+	switch {
+	case pubkey && !password:
+		return "key-only: " + keys
+	case pubkey && password:
+		return "key (" + keys + "), password allowed"
+	case password:
+		return "password only"
+	default:
+		return "no usable method (check PreferredAuthentications)"
+	}
+}
+
+func identityDescription(h sshHost) string {
+	names := make([]string, 0, len(h.IdentityFiles))
+	for _, id := range h.IdentityFiles {
+		names = append(names, filepath.Base(id))
+	}
+	if h.optionIs("IdentitiesOnly", "yes") {
+		if len(names) == 0 {
+			return "no IdentityFile set"
+		}
+		return strings.Join(names, ", ")
+	}
+	if len(names) == 0 {
+		return "agent/default keys"
+	}
+	return strings.Join(names, ", ") + " + agent keys"
+}
+
+// detailLines renders the expanded view of a single host.
+func detailLines(h sshHost) []string {
+	var lines []string
+	add := func(label, value string) {
+		if value != "" {
+			lines = append(lines, fmt.Sprintf("%-14s %s", label+":", value))
+		}
+	}
+	add("Alias", h.Alias)
+	add("Hostname", h.Hostname)
+	add("IP", h.IP)
+	add("User", h.User)
+	add("Port", h.Port)
+	chain := h.JumpChain
+	if chain == nil {
+		chain = jumpChain(h.option("proxyjump"))
+	}
+	add("ProxyJump", strings.Join(chain, " → "))
+	add("ProxyCommand", h.option("ProxyCommand"))
+	add("Auth", authStrategy(h))
+	add("Agent", agentSummary(h))
+	add("Security key", securityKeySummary(h))
+	add("Kerberos", kerberosSummary(h))
+	if tags := h.tags(); len(tags) > 0 {
+		add("Tags", strings.Join(tags, ", "))
+	}
+	add("Network", h.Annotations["network"])
+	if spec := h.Annotations["knock"]; spec != "" {
+		add("Port knock", spec)
+	}
+	if h.annotationBool("mfa") {
+		add("MFA", "keyboard-interactive second factor; batch probes skipped")
+	} else if skipsBatchProbes(h) {
+		add("Probes", "skipped (noprobe)")
+	}
+	if oob := outOfBandActions(h); len(oob) > 0 {
+		names := make([]string, 0, len(oob))
+		for _, a := range oob {
+			names = append(names, a.label)
+		}
+		add("Out-of-band", strings.Join(names, ", "))
+	}
+	if rd := desktopActions(h); len(rd) > 0 {
+		names := make([]string, 0, len(rd))
+		for _, a := range rd {
+			names = append(names, a.label)
+		}
+		add("Desktop", strings.Join(names, ", "))
+	}
+	if len(h.IdentityFiles) > 0 {
+		add("IdentityFile", strings.Join(h.IdentityFiles, ", "))
+	}
+	if len(h.LocalForwards) > 0 && len(h.Forwards) == 0 {
+		add("LocalForward", strings.Join(h.LocalForwards, ", "))
+	}
+	if h.SourceLine > 0 {
+		add("Defined at", fmt.Sprintf("%s:%d", h.SourcePath, h.SourceLine))
+		lines = append(lines, inheritedLines(h)...)
+	} else if h.Source != "" && h.Source != "config" {
+		add("Source", h.Source+" "+h.SourcePath)
+	}
+	if len(h.AlsoSources) > 0 {
+		add("Also seen in", strings.Join(h.AlsoSources, ", "))
+	}
+	if len(h.Annotations) > 0 {
+		keys := make([]string, 0, len(h.Annotations))
+		for k := range h.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, k+"="+h.Annotations[k])
+		}
+		add("Annotations", strings.Join(pairs, " "))
+	}
+	return lines
+}
+
+// refreshDetail starts the fetches that feed the panes shown for the
+// highlighted host: who (when shown) and, with the detail pane, the host
+// certificate principals, the pre-auth banner and the smartcard.
+func (m *model) refreshDetail() tea.Cmd {
+	if len(m.hosts) == 0 {
+		return nil
+	}
+	h := m.hosts[m.cursor]
+	var cmds []tea.Cmd
+	if m.showWho {
+		cmds = append(cmds, m.refreshWho(h))
+	}
+	if m.showDetail {
+		cmds = append(cmds, m.refreshPrincipals(h), m.refreshBanner(h), m.refreshCard(h))
+	}
+	return tea.Batch(cmds...)
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestAuthStrategy(t *testing.T) {
+	tests := []struct {
+		name string
+		host sshHost
+		want string
+	}{
+		{
+			name: "defaults",
+			host: sshHost{},
+			want: "key (agent/default keys), password allowed",
+		},
+		{
+			name: "identities only without password",
+			host: sshHost{
+				IdentityFiles: []string{"/home/me/.ssh/id_ed25519"},
+				Options:       map[string]string{"identitiesonly": "yes", "passwordauthentication": "no", "kbdinteractiveauthentication": "no"},
+			},
+			want: "key-only: id_ed25519",
+		},
+		{
+			name: "preferred publickey",
+			host: sshHost{
+				IdentityFiles: []string{"/keys/work"},
+				Options:       map[string]string{"preferredauthentications": "publickey"},
+			},
+			want: "key-only: work + agent keys",
+		},
+		{
+			name: "pubkey disabled",
+			host: sshHost{Options: map[string]string{"pubkeyauthentication": "no"}},
+			want: "password only",
+		},
+		{
+			name: "identities only without files",
+			host: sshHost{Options: map[string]string{"identitiesonly": "yes", "preferredauthentications": "publickey,password"}},
+			want: "key (no IdentityFile set), password allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := authStrategy(tt.host); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// directiveFilter recognizes "keyword:regex" filters such as
+// "proxyjump:bastion-eu" or "identityfile:deploy", where keyword is an
+// ssh_config keyword. Anything else is a plain regex. Directive filters
+// only apply with the "all fields" scope; a narrower scope matches the
+// text against its own fields, so "proxyjump:x" under the alias scope
+// looks for that in aliases.
+func directiveFilter(pattern string) (key, rest string, ok bool) {
+	key, rest, ok = strings.Cut(pattern, ":")
+	if !ok {
+		return "", "", false
+	}
+	key = strings.ToLower(key)
+	if _, known := knownKeywords[key]; !known || key == "host" || key == "match" {
+		return "", "", false
+	}
+	return key, rest, true
+}
+
+// directiveValues returns the values of directive key for h, or with key ""
+// the values of every directive. Hosts from other sources have no
+// Directives, so their fields and Options stand in.
+func (h sshHost) directiveValues(key string) []string {
+	var values []string
+	for _, d := range h.Directives {
+		k, v, _ := strings.Cut(d, " ")
+		if key == "" || k == key {
+			values = append(values, v)
+		}
+	}
+	if len(h.Directives) > 0 {
+		return values
+	}
+	fields := map[string][]string{
+		"hostname":     {h.Hostname},
+		"user":         {h.User},
+		"port":         {h.Port},
+		"identityfile": h.IdentityFiles,
+	}
+	for k, v := range h.Options {
+		if _, set := fields[k]; !set {
+			fields[k] = []string{v}
+		}
+	}
+	for k, vs := range fields {
+		if key == "" || k == key {
+			for _, v := range vs {
+				if v != "" {
+					values = append(values, v)
+				}
+			}
+		}
+	}
+	return values
+}
+
+// filterHostsDirective keeps hosts with a key directive whose value matches
+// the regex; an empty regex keeps hosts that set the directive at all.
+func filterHostsDirective(all []sshHost, key, pattern string) ([]sshHost, error) {
+	re, err := regexp.Compile(strings.TrimSpace(pattern))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]sshHost, 0, len(all))
+	for _, h := range all {
+		for _, v := range h.directiveValues(key) {
+			if re.MatchString(v) {
+				out = append(out, h)
+				break
+			}
+		}
+	}
+	return out, nil
+}
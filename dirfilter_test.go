@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirectiveFilter(t *testing.T) {
+	t.Parallel()
+
+	cfg := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(cfg, []byte(`Host db1
+  HostName 10.0.0.5
+  ProxyJump bastion-eu
+  IdentityFile ~/.ssh/id_deploy
+  LocalForward 5432 localhost:5432
+  LocalForward 6379 cache.internal:6379
+
+Host web1
+  HostName 10.0.0.6
+  ProxyJump bastion-us
+
+Host bastion-eu
+  HostName 1.2.3.4
+`), 0o600)
+	hosts, err := parseSSHConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts = append(hosts, sshHost{Alias: "prom1", Source: "prometheus", Options: map[string]string{"proxyjump": "bastion-eu"}})
+
+	aliases := func(hs []sshHost) string {
+		var names []string
+		for _, h := range hs {
+			names = append(names, h.Alias)
+		}
+		return strings.Join(names, " ")
+	}
+	for pattern, want := range map[string]string{
+		"ProxyJump:bastion-eu": "db1 prom1",
+		"proxyjump:":           "db1 web1 prom1",
+		"identityfile:deploy":  "db1",
+		"cache\\.internal":     "db1",                  // repeated directive, plain regex
+		"bastion-eu":           "db1 bastion-eu prom1", // plain regex also matches the alias
+		"localforward:6379":    "db1",
+		"nosuchkeyword:x":      "",
+	} {
+		got, err := filterHostsRegexScope(hosts, pattern, scopeAll)
+		if err != nil {
+			t.Fatalf("%s: %v", pattern, err)
+		}
+		if aliases(got) != want {
+			t.Errorf("%s: got %q, want %q", pattern, aliases(got), want)
+		}
+	}
+
+	// A narrower scope comes first: the text is matched against its fields
+	// only, never as a directive filter.
+	for _, scope := range []filterScope{scopeAlias, scopeAliasHost} {
+		if got, _ := filterHostsRegexScope(hosts, "proxyjump:bastion-eu", scope); len(got) != 0 {
+			t.Errorf("scope %v: regex matched %q", scope, aliases(got))
+		}
+		if got := filterHostsFuzzy(hosts, "proxyjump:bastion-eu", scope); len(got) != 0 {
+			t.Errorf("scope %v: fuzzy matched %q", scope, aliases(got))
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// listenCommand lists listening TCP sockets with their processes where the
+// login user may see them; netstat covers hosts without iproute2.
+const listenCommand = "ss -tlnp 2>/dev/null || netstat -tlnp 2>/dev/null"
+
+// listener is a TCP port listening on the remote host.
+type listener struct {
+	Addr    string // bind address, e.g. "127.0.0.1", "0.0.0.0" or "::1"
+	Port    int
+	Process string
+}
+
+// parseListeners reads ss -tlnp or netstat -tlnp output. Both put the local
+// address in the fourth column. A port bound on several addresses (IPv4 and
+// IPv6) is listed once, preferring a wildcard or loopback bind.
+func parseListeners(out string) []listener {
+	byPort := map[int]listener{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !(strings.EqualFold(fields[0], "LISTEN") || strings.HasPrefix(fields[0], "tcp")) {
+			continue
+		}
+		// netstat writes IPv6 without brackets, e.g. ":::9100".
+		i := strings.LastIndex(fields[3], ":")
+		if i < 0 {
+			continue
+		}
+		host, portStr := strings.Trim(fields[3][:i], "[]"), fields[3][i+1:]
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			continue
+		}
+		l := listener{Addr: strings.TrimSuffix(host, "%lo"), Port: port, Process: listenerProcess(fields)}
+		if prev, ok := byPort[port]; ok && (prev.reachableViaLoopback() || !l.reachableViaLoopback()) {
+			continue
+		}
+		byPort[port] = l
+	}
+	out2 := make([]listener, 0, len(byPort))
+	for _, l := range byPort {
+		out2 = append(out2, l)
+	}
+	sort.Slice(out2, func(i, j int) bool { return out2[i].Port < out2[j].Port })
+	return out2
+}
+
+// listenerProcess extracts the program name: users:(("grafana",pid=1,fd=8))
+// from ss, or "1234/sshd" from netstat.
+func listenerProcess(fields []string) string {
+	last := fields[len(fields)-1]
+	if i := strings.Index(last, `(("`); i >= 0 {
+		name := last[i+3:]
+		if j := strings.IndexByte(name, '"'); j >= 0 {
+			return name[:j]
+		}
+	}
+	if _, name, ok := strings.Cut(last, "/"); ok && strings.HasPrefix(fields[0], "tcp") {
+		return name
+	}
+	return ""
+}
+
+func (l listener) reachableViaLoopback() bool {
+	switch l.Addr {
+	case "0.0.0.0", "*", "::", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// forwardTarget is the address ssh should connect to on the remote side.
+func (l listener) forwardTarget() string {
+	switch l.Addr {
+	case "0.0.0.0", "*", "127.0.0.1":
+		return "localhost"
+	case "::", "::1":
+		return "[::1]"
+	}
+	if strings.Contains(l.Addr, ":") {
+		return "[" + l.Addr + "]"
+	}
+	return l.Addr
+}
+
+// localPortFree is a variable so tests don't depend on local sockets.
+var localPortFree = func(port int) bool {
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+// pickLocalPort keeps the remote port number when it is unprivileged and
+// free here, otherwise tries the same number above 10000, then 20000.
+func pickLocalPort(remote int, taken map[int]bool) int {
+	for _, p := range []int{remote, remote + 10000, remote + 20000} {
+		if p >= 1024 && p <= 65535 && !taken[p] && localPortFree(p) {
+			return p
+		}
+	}
+	return 0
+}
+
+// forwardSpecs builds -L specs for the chosen listeners.
+func forwardSpecs(chosen []listener) ([]string, error) {
+	taken := map[int]bool{}
+	var specs []string
+	for _, l := range chosen {
+		local := pickLocalPort(l.Port, taken)
+		if local == 0 {
+			return nil, fmt.Errorf("no free local port for remote port %d", l.Port)
+		}
+		taken[local] = true
+		specs = append(specs, fmt.Sprintf("%d:%s:%d", local, l.forwardTarget(), l.Port))
+	}
+	return specs, nil
+}
+
+// portPicker is the service discovery screen (F).
+type portPicker struct {
+	host      sshHost
+	listeners []listener
+	chosen    map[int]bool
+	cursor    int
+	pending   bool
+	err       error
+}
+
+type listenersMsg struct {
+	alias     string
+	listeners []listener
+	err       error
+}
+
+func (m model) openPortPicker() (model, tea.Cmd) {
+	if len(m.hosts) == 0 {
+		return m, nil
+	}
+	h := m.hosts[m.cursor]
+	if skipsBatchProbes(h) {
+		m.err = fmt.Errorf("%s: %v", h.Alias, errProbeSkipped)
+		return m, nil
+	}
+	m.err = nil
+	m.ports = &portPicker{host: h, chosen: map[int]bool{}, pending: true}
+	return m, func() tea.Msg {
+		out, err := runRemote(h, listenCommand, 15*time.Second)
+		if err != nil {
+			return listenersMsg{alias: h.Alias, err: err}
+		}
+		ls := parseListeners(out)
+		if len(ls) == 0 {
+			err = fmt.Errorf("no listening ports found (ss/netstat missing or no output)")
+		}
+		return listenersMsg{alias: h.Alias, listeners: ls, err: err}
+	}
+}
+
+func (m model) updatePortPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := *m.ports
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q":
+		m.ports = nil
+		return m, nil
+	case "j", "down":
+		if len(p.listeners) > 0 {
+			p.cursor = (p.cursor + 1) % len(p.listeners)
+		}
+	case "k", "up":
+		if len(p.listeners) > 0 {
+			p.cursor = (p.cursor - 1 + len(p.listeners)) % len(p.listeners)
+		}
+	case " ", "space":
+		if p.cursor < len(p.listeners) {
+			port := p.listeners[p.cursor].Port
+			chosen := make(map[int]bool, len(p.chosen)+1)
+			for k, v := range p.chosen {
+				chosen[k] = v
+			}
+			chosen[port] = !chosen[port]
+			p.chosen = chosen
+		}
+	case "enter":
+		var picked []listener
+		for _, l := range p.listeners {
+			if p.chosen[l.Port] {
+				picked = append(picked, l)
+			}
+		}
+		if len(picked) == 0 && p.cursor < len(p.listeners) {
+			picked = []listener{p.listeners[p.cursor]}
+		}
+		if len(picked) == 0 {
+			break
+		}
+		specs, err := forwardSpecs(picked)
+		if err != nil {
+			p.err = err
+			break
+		}
+		m.forwards = specs
+		m.ports = nil
+		return m.beginConnect(p.host)
+	}
+	m.ports = &p
+	return m, nil
+}
+
+func (m model) renderPortPicker(b *strings.Builder) {
+	p := m.ports
+	fmt.Fprintln(b, m.styles.title.Render("Listening ports on "+p.host.Alias))
+	fmt.Fprintln(b, m.styles.help.Render(tr("help.discover")))
+	fmt.Fprintln(b, "")
+	if p.pending {
+		fmt.Fprintln(b, m.styles.help.Render("Running "+listenCommand+"…"))
+	}
+	for i, l := range p.listeners {
+		mark := "[ ]"
+		if p.chosen[l.Port] {
+			mark = "[x]"
+		}
+		line := fmt.Sprintf("%s %5d  %-16s %s", mark, l.Port, l.Process, l.Addr)
+		if i == p.cursor {
+			fmt.Fprintln(b, m.styles.selected.Render("> "+line))
+		} else {
+			fmt.Fprintln(b, m.styles.item.Render("  "+line))
+		}
+	}
+	if p.err != nil {
+		fmt.Fprintln(b, "")
+		fmt.Fprintln(b, m.styles.error.Render(p.err.Error()))
+	}
+}
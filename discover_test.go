@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseListeners(t *testing.T) {
+	t.Parallel()
+
+	ss := `State  Recv-Q Send-Q Local Address:Port Peer Address:Port Process
+LISTEN 0      4096   127.0.0.1:3000      0.0.0.0:*     users:(("grafana",pid=812,fd=8))
+LISTEN 0      128    0.0.0.0:22          0.0.0.0:*
+LISTEN 0      128    [::]:22             [::]:*
+LISTEN 0      244    10.0.0.5:5432       0.0.0.0:*     users:(("postgres",pid=90,fd=5))
+`
+	got := parseListeners(ss)
+	if len(got) != 3 {
+		t.Fatalf("listeners %+v", got)
+	}
+	if got[0].Port != 22 || got[1].Port != 3000 || got[1].Process != "grafana" || got[2].Addr != "10.0.0.5" {
+		t.Fatalf("listeners %+v", got)
+	}
+
+	netstat := `Active Internet connections (only servers)
+Proto Recv-Q Send-Q Local Address           Foreign Address         State       PID/Program name
+tcp        0      0 0.0.0.0:9090            0.0.0.0:*               LISTEN      1234/prometheus
+tcp6       0      0 :::9100                 :::*                    LISTEN      -
+`
+	got = parseListeners(netstat)
+	if len(got) != 2 || got[0].Process != "prometheus" || got[1].Port != 9100 || got[1].forwardTarget() != "[::1]" {
+		t.Fatalf("netstat listeners %+v", got)
+	}
+}
+
+func TestForwardSpecs(t *testing.T) {
+	orig := localPortFree
+	defer func() { localPortFree = orig }()
+	localPortFree = func(port int) bool { return port != 3000 }
+
+	specs, err := forwardSpecs([]listener{
+		{Addr: "127.0.0.1", Port: 3000},
+		{Addr: "0.0.0.0", Port: 80},
+		{Addr: "10.0.0.5", Port: 5432},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(specs, " "); got != "13000:localhost:3000 10080:localhost:80 5432:10.0.0.5:5432" {
+		t.Fatalf("specs %s", got)
+	}
+	args := strings.Join(sshArgs(sshHost{Alias: "db1"}, launchOptions{forwards: specs}), " ")
+	if args != "-L 13000:localhost:3000 -L 10080:localhost:80 -L 5432:10.0.0.5:5432 -o ExitOnForwardFailure=yes -- db1" {
+		t.Fatalf("ssh args %s", args)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type findingLevel int
+
+const (
+	levelOK findingLevel = iota
+	levelWarn
+	levelFail
+)
+
+// finding is one diagnostic produced by `sshpick doctor`.
+type finding struct {
+	Level findingLevel
+	Msg   string
+	Fix   string // suggested remediation, if any
+}
+
+func (l findingLevel) symbol() string {
+	switch l {
+	case levelWarn:
+		return "!"
+	case levelFail:
+		return "✗"
+	default:
+		return "✓"
+	}
+}
+
+// runDoctor implements `sshpick doctor` and returns the process exit code.
+func runDoctor(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	dir := defaultSSHDir()
+	if dir == "" {
+		fmt.Fprintln(os.Stderr, "no home directory to find ~/.ssh in; pass -ssh-dir")
+		return 2
+	}
+	if *cfgPath == "" {
+		*cfgPath = defaultConfigPath()
+	}
+
+	findings := doctorChecks(dir, *cfgPath)
+	problems := 0
+	for _, f := range findings {
+		fmt.Fprintf(stdout, "%s %s\n", f.Level.symbol(), f.Msg)
+		if f.Fix != "" {
+			fmt.Fprintf(stdout, "    fix: %s\n", f.Fix)
+		}
+		if f.Level != levelOK {
+			problems++
+		}
+	}
+	if problems > 0 {
+		fmt.Fprintf(stdout, "\n%d problem(s) found\n", problems)
+		return 1
+	}
+	return 0
+}
+
+func doctorChecks(sshDir, cfgPath string) []finding {
+	var out []finding
+	out = append(out, checkSSHBinary()...)
+	out = append(out, checkAgent()...)
+	out = append(out, checkSSHDirPerms(sshDir)...)
+
+	hosts, warnings, err := parseSSHConfigWarnings(cfgPath)
+	switch {
+	case os.IsNotExist(err):
+		out = append(out, finding{Level: levelWarn, Msg: "no ssh config at " + cfgPath, Fix: "create it with: install -m 600 /dev/null " + cfgPath})
+		return out
+	case err != nil:
+		out = append(out, finding{Level: levelFail, Msg: fmt.Sprintf("cannot read %s: %v", cfgPath, err)})
+		return out
+	}
+	out = append(out, finding{Level: levelOK, Msg: fmt.Sprintf("%s parsed: %d hosts", cfgPath, len(hosts))})
+	for _, w := range warnings {
+		out = append(out, finding{Level: levelWarn, Msg: w.String()})
+	}
+	out = append(out, checkFilePerms(cfgPath, 0o022, "chmod 600 "+cfgPath)...)
+	out = append(out, checkDuplicateAliases(hosts)...)
+	out = append(out, checkIncludes(cfgPath, sshDir)...)
+	out = append(out, checkIdentityFiles(hosts)...)
+	out = append(out, checkIdentityAgents(hosts)...)
+	return out
+}
+
+func checkSSHBinary() []finding {
+	bin, err := exec.LookPath("ssh")
+	if err != nil {
+		return []finding{{Level: levelFail, Msg: "ssh binary not found in PATH", Fix: "install OpenSSH client or add it to PATH"}}
+	}
+	return []finding{{Level: levelOK, Msg: "ssh binary: " + bin}}
+}
+
+func checkAgent() []finding {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return []finding{{Level: levelWarn, Msg: "SSH_AUTH_SOCK is not set; no ssh-agent available", Fix: `eval "$(ssh-agent -s)" && ssh-add`}}
+	}
+	conn, err := net.DialTimeout("unix", sock, time.Second)
+	if err != nil {
+		return []finding{{Level: levelWarn, Msg: fmt.Sprintf("ssh-agent socket %s is not reachable: %v", sock, err), Fix: `start a new agent: eval "$(ssh-agent -s)"`}}
+	}
+	conn.Close()
+	return []finding{{Level: levelOK, Msg: "ssh-agent reachable at " + sock}}
+}
+
+func checkSSHDirPerms(dir string) []finding {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return []finding{{Level: levelWarn, Msg: fmt.Sprintf("%s: %v", dir, err), Fix: "mkdir -m 700 " + dir}}
+	}
+	if !fi.IsDir() {
+		return []finding{{Level: levelFail, Msg: dir + " is not a directory"}}
+	}
+	if fi.Mode().Perm()&0o077 != 0 {
+		return []finding{{Level: levelWarn, Msg: fmt.Sprintf("%s has mode %04o; ssh expects it private", dir, fi.Mode().Perm()), Fix: "chmod 700 " + dir}}
+	}
+	return []finding{{Level: levelOK, Msg: dir + " permissions ok"}}
+}
+
+// checkFilePerms flags a file whose mode has any of the bad bits set.
+func checkFilePerms(path string, bad os.FileMode, fix string) []finding {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if fi.Mode().Perm()&bad != 0 {
+		return []finding{{Level: levelWarn, Msg: fmt.Sprintf("%s has mode %04o", path, fi.Mode().Perm()), Fix: fix}}
+	}
+	return nil
+}
+
+func checkDuplicateAliases(hosts []sshHost) []finding {
+	var out []finding
+	seen := map[string]int{}
+	for _, h := range hosts {
+		if first, ok := seen[h.Alias]; ok {
+			out = append(out, finding{
+				Level: levelWarn,
+				Msg:   fmt.Sprintf("Host %s is defined again at line %d; ssh uses the first match (line %d)", h.Alias, h.SourceLine, first),
+				Fix:   "merge or rename the duplicate Host block",
+			})
+			continue
+		}
+		seen[h.Alias] = h.SourceLine
+	}
+	return out
+}
+
+func checkIdentityFiles(hosts []sshHost) []finding {
+	var out []finding
+	checked := map[string]bool{}
+	var ids []string
+	usedBy := map[string][]string{}
+	for _, h := range hosts {
+		for _, id := range h.IdentityFiles {
+			if !checked[id] {
+				checked[id] = true
+				ids = append(ids, id)
+			}
+			usedBy[id] = append(usedBy[id], h.Alias)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if strings.Contains(id, "%") {
+			continue // tokens are expanded by ssh at connect time
+		}
+		f, err := os.Open(id)
+		if err != nil {
+			out = append(out, finding{
+				Level: levelFail,
+				Msg:   fmt.Sprintf("identity file %s (used by %s) is unreadable: %v", id, strings.Join(usedBy[id], ", "), err),
+				Fix:   "fix the IdentityFile path or generate the key with ssh-keygen",
+			})
+			continue
+		}
+		f.Close()
+		out = append(out, checkFilePerms(id, 0o077, "chmod 600 "+id)...)
+	}
+	return out
+}
+
+// includeRef is one Include directive found in a config file.
+type includeRef struct {
+	Path    string
+	Line    int
+	Pattern string
+}
+
+// scanIncludes lists the Include directives of a config file without following them.
+func scanIncludes(path string) ([]includeRef, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var refs []includeRef
+	sc := bufio.NewScanner(f)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		parts := strings.Fields(line)
+		if len(parts) < 2 || !strings.EqualFold(parts[0], "include") {
+			continue
+		}
+		for _, p := range parts[1:] {
+			refs = append(refs, includeRef{Path: path, Line: lineNo, Pattern: strings.Trim(p, `"`)})
+		}
+	}
+	return refs, sc.Err()
+}
+
+func checkIncludes(cfgPath, sshDir string) []finding {
+	refs, err := scanIncludes(cfgPath)
+	if err != nil {
+		return nil
+	}
+	var out []finding
+	for _, r := range refs {
+		pattern := expandHome(r.Pattern)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(sshDir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil || len(matches) == 0 {
+			out = append(out, finding{
+				Level: levelWarn,
+				Msg:   fmt.Sprintf("%s:%d: Include %s matches no files", r.Path, r.Line, r.Pattern),
+				Fix:   "fix the pattern or remove the Include line",
+			})
+		}
+	}
+	return out
+}
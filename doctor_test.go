@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoctorChecksConfigProblems(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0o700); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	key := filepath.Join(dir, "id_test")
+	if err := os.WriteFile(key, []byte("key"), 0o644); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	cfg := filepath.Join(dir, "config")
+	content := `Include config.d/*.conf
+
+Host prod
+  Hostname 127.0.0.1
+  IdentityFile ` + key + `
+  IdentityFile ` + filepath.Join(dir, "missing") + `
+
+Host prod
+  Hostname 127.0.0.2
+`
+	if err := os.WriteFile(cfg, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	var msgs []string
+	for _, f := range doctorChecks(dir, cfg) {
+		if f.Level != levelOK {
+			msgs = append(msgs, f.Msg)
+		}
+	}
+	joined := strings.Join(msgs, "\n")
+	for _, want := range []string{
+		"Include config.d/*.conf matches no files",
+		"Host prod is defined again at line 8",
+		"missing (used by prod) is unreadable",
+		"id_test has mode 0644",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected finding %q in:\n%s", want, joined)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// dualPicker is the two-pane mode for copying between two remote hosts with
+// `scp -3` (traffic is relayed through this machine), or with compare set,
+// for picking two hosts whose effective options to diff.
+type dualPicker struct {
+	compare bool
+	cursor  [2]int
+	active  int // pane being navigated: 0 source, 1 destination
+	step    int // 0 picking hosts, 1 source path, 2 destination path
+	paths   [2]string
+}
+
+// scp3Args builds the scp arguments for copying src:srcPath to dst:dstPath.
+func scp3Args(src, dst sshHost, srcPath, dstPath string) []string {
+	args := append([]string{"-3"}, configFileArgs()...)
+	return append(args, "--", scpLocation(src, srcPath), scpLocation(dst, dstPath))
+}
+
+// scpLocation is host:path for scp. A host outside the ssh config with its
+// own port is written as an scp:// URI instead, since -P would apply to
+// both ends of the copy. The URI's path is relative to the home directory
+// after the first slash, like host:path.
+func scpLocation(h sshHost, path string) string {
+	if hostSource(h) == "config" || h.Port == "" {
+		return sshTarget(h) + ":" + path
+	}
+	host := h.Hostname
+	if host == "" {
+		host = h.Alias
+	}
+	u := url.URL{Scheme: "scp", Host: net.JoinHostPort(host, h.Port), Path: "/" + path}
+	if h.User != "" {
+		u.User = url.User(h.User)
+	}
+	return u.String()
+}
+
+func (m model) updateDual(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	d := *m.dual
+	if msg.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+	if d.step > 0 {
+		i := d.step - 1
+		switch msg.String() {
+		case "esc":
+			d.step = 0
+		case "enter":
+			if d.step == 1 {
+				if strings.TrimSpace(d.paths[0]) == "" {
+					break
+				}
+				d.step = 2
+			} else {
+				src, dst := m.hosts[d.cursor[0]], m.hosts[d.cursor[1]]
+				m.transferArgs = scp3Args(src, dst, d.paths[0], d.paths[1])
+				m.dual = nil
+				return m, tea.Quit
+			}
+		case "backspace":
+			if d.paths[i] != "" {
+				_, n := utf8.DecodeLastRuneInString(d.paths[i])
+				d.paths[i] = d.paths[i][:len(d.paths[i])-n]
+			}
+		default:
+			if msg.Type == tea.KeyRunes && len(d.paths[i]) < 1024 {
+				d.paths[i] += string(msg.Runes)
+			}
+		}
+		m.dual = &d
+		return m, nil
+	}
+
+	n := len(m.hosts)
+	switch msg.String() {
+	case "esc", "q":
+		m.dual = nil
+		return m, nil
+	case "tab", "left", "right", "h", "l":
+		d.active = 1 - d.active
+	case "j", "down":
+		if n > 0 {
+			d.cursor[d.active] = (d.cursor[d.active] + 1) % n
+		}
+	case "k", "up":
+		if n > 0 {
+			d.cursor[d.active] = (d.cursor[d.active] - 1 + n) % n
+		}
+	case "enter":
+		if d.cursor[0] == d.cursor[1] {
+			m.err = errors.New("pick two different hosts")
+			break
+		}
+		m.err = nil
+		if d.compare {
+			m.dual = nil
+			return m.startHostDiff(m.hosts[d.cursor[0]], m.hosts[d.cursor[1]])
+		}
+		d.step = 1
+	}
+	m.dual = &d
+	return m, nil
+}
+
+func (m model) renderDual(b *strings.Builder) {
+	d := m.dual
+	labels := [2]string{"From", "To"}
+	if d.compare {
+		labels = [2]string{"Left", "Right"}
+		fmt.Fprintln(b, m.styles.title.Render("Compare effective options"))
+		fmt.Fprintln(b, m.styles.help.Render(tr("help.dual.compare")))
+	} else {
+		fmt.Fprintln(b, m.styles.title.Render("Copy between hosts (scp -3)"))
+		fmt.Fprintln(b, m.styles.help.Render(tr("help.dual.copy")))
+	}
+	fmt.Fprintln(b, "")
+
+	colWidth := 30
+	if m.width > 10 {
+		colWidth = (m.width - 5) / 2
+	}
+	panes := [2]string{}
+	for p, label := range labels {
+		var col strings.Builder
+		header := label
+		if p == d.active && d.step == 0 {
+			header += " ◀"
+		}
+		fmt.Fprintln(&col, m.styles.title.Render(header))
+		for i, h := range m.hosts {
+			if i == d.cursor[p] {
+				style := m.styles.item.Bold(true)
+				if p == d.active {
+					style = m.styles.selected
+				}
+				fmt.Fprintln(&col, style.Render("> "+h.Alias))
+			} else {
+				fmt.Fprintln(&col, m.styles.item.Render("  "+h.Alias))
+			}
+		}
+		panes[p] = lipgloss.NewStyle().Width(colWidth).Render(col.String())
+	}
+	fmt.Fprintln(b, lipgloss.JoinHorizontal(lipgloss.Top, panes[0], " │ ", panes[1]))
+
+	if d.step > 0 && len(m.hosts) > 0 {
+		src, dst := m.hosts[d.cursor[0]], m.hosts[d.cursor[1]]
+		fmt.Fprintln(b, "")
+		fmt.Fprintln(b, m.styles.help.Render("Source path on "+src.Alias+": "+d.paths[0]))
+		if d.step == 2 {
+			fmt.Fprintln(b, m.styles.help.Render("Destination path on "+dst.Alias+" (empty for home): "+d.paths[1]))
+			fmt.Fprintln(b, m.styles.help.Render("scp "+strings.Join(scp3Args(src, dst, d.paths[0], d.paths[1]), " ")))
+		}
+	}
+}
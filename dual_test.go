@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestDualPickerBuildsScp3(t *testing.T) {
+	m := initialModel([]sshHost{{Alias: "a"}, {Alias: "b"}, {Alias: "c"}}, "", "")
+	press := func(keys ...tea.KeyMsg) {
+		for _, k := range keys {
+			next, _ := m.Update(k)
+			m = next.(model)
+		}
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	press(runes("d"))
+	if m.dual == nil {
+		t.Fatalf("expected dual mode")
+	}
+	// destination pane: move from b to c
+	press(tea.KeyMsg{Type: tea.KeyTab}, runes("j"), enter, runes("/var/log/app.log"), enter, runes("/tmp/"), enter)
+
+	want := []string{"-3", "--", "a:/var/log/app.log", "c:/tmp/"}
+	if !reflect.DeepEqual(m.transferArgs, want) {
+		t.Fatalf("expected %v, got %v", want, m.transferArgs)
+	}
+}
+
+func TestScp3ArgsKeepInventoryPorts(t *testing.T) {
+	src := sshHost{Alias: "db1", Hostname: "10.0.0.5", User: "postgres", Port: "2222", Source: "team"}
+	dst := sshHost{Alias: "v6", Hostname: "2001:db8::1", Port: "22", Source: "team"}
+	got := scp3Args(src, dst, "/var/lib/my dump.sql", "")
+	want := []string{"-3", "--", "scp://postgres@10.0.0.5:2222//var/lib/my%20dump.sql", "scp://[2001:db8::1]:22/"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+	if got := scp3Args(sshHost{Alias: "a", Port: "2222"}, sshHost{Alias: "b", Source: "team", Hostname: "b.example"}, "x", "y"); !reflect.DeepEqual(got, []string{"-3", "--", "a:x", "b.example:y"}) {
+		t.Fatalf("config hosts and hosts without a port keep host:path, got %q", got)
+	}
+}
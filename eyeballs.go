@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+const (
+	// eyeballsDelay is how long each attempt gets before the next address
+	// is tried in parallel (RFC 8305's recommended 250ms).
+	eyeballsDelay   = 250 * time.Millisecond
+	eyeballsTimeout = 5 * time.Second
+)
+
+// eyeballsDial checks one address; a variable so tests can fake the network.
+var eyeballsDial = func(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// interleaveFamilies orders addresses IPv6, IPv4, IPv6, ... keeping the
+// resolver's order within each family.
+func interleaveFamilies(ips []net.IP) []string {
+	var v6, v4 []string
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip.String())
+		} else {
+			v6 = append(v6, ip.String())
+		}
+	}
+	var out []string
+	for i := 0; i < len(v6) || i < len(v4); i++ {
+		if i < len(v6) {
+			out = append(out, v6[i])
+		}
+		if i < len(v4) {
+			out = append(out, v4[i])
+		}
+	}
+	return out
+}
+
+// raceAddresses starts a TCP connection to each address in turn, the next
+// one after eyeballsDelay or as soon as the previous attempt fails, and
+// returns the first address that connects.
+func raceAddresses(ctx context.Context, addrs []string, port string) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Losing attempts outlive the call, so they use the dialer it started with.
+	dial := eyeballsDial
+	type result struct {
+		addr string
+		err  error
+	}
+	results := make(chan result, len(addrs))
+	failed := make(chan struct{}, len(addrs))
+	var lastErr error
+	started, done := 0, 0
+	start := func() {
+		addr := addrs[started]
+		started++
+		go func() {
+			err := dial(ctx, net.JoinHostPort(addr, port))
+			if err != nil {
+				failed <- struct{}{}
+			}
+			results <- result{addr, err}
+		}()
+	}
+	start()
+	timer := time.NewTimer(eyeballsDelay)
+	defer timer.Stop()
+	for done < len(addrs) {
+		select {
+		case r := <-results:
+			done++
+			if r.err == nil {
+				return r.addr, nil
+			}
+			lastErr = r.err
+		case <-failed:
+			if started < len(addrs) {
+				start()
+				timer.Reset(eyeballsDelay)
+			}
+		case <-timer.C:
+			if started < len(addrs) {
+				start()
+				timer.Reset(eyeballsDelay)
+			}
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no addresses")
+	}
+	return "", lastErr
+}
+
+// fastestAddress resolves h's hostname and races its addresses. It returns
+// "" when there is nothing to choose: an IP literal, a single address, or a
+// host reached through a jump host or proxy (which resolves the name itself).
+func fastestAddress(h sshHost) (string, error) {
+	host, port, _ := net.SplitHostPort(dialAddress(h))
+	if net.ParseIP(host) != nil || h.option("proxyjump") != "" || h.option("proxycommand") != "" {
+		return "", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), eyeballsTimeout)
+	defer cancel()
+	ips, err := ipResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return "", err
+	}
+	addrs := interleaveFamilies(ips)
+	if len(addrs) < 2 {
+		return "", nil
+	}
+	return raceAddresses(ctx, addrs, port)
+}
+
+// eyeballsArgs points ssh at the winning address while known_hosts is still
+// checked under the host's name ("[name]:port" off port 22, as ssh writes it).
+func eyeballsArgs(h sshHost, addr string) []string {
+	return []string{"-o", "HostName=" + addr, "-o", "HostKeyAlias=" + knownHostsKey(h)}
+}
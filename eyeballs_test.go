@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInterleaveFamilies(t *testing.T) {
+	t.Parallel()
+
+	ips := []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2"), net.ParseIP("2001:db8::1")}
+	if got := strings.Join(interleaveFamilies(ips), " "); got != "2001:db8::1 192.0.2.1 192.0.2.2" {
+		t.Fatalf("order %s", got)
+	}
+}
+
+func TestRaceAddresses(t *testing.T) {
+	orig := eyeballsDial
+	defer func() { eyeballsDial = orig }()
+
+	// A black-holed IPv6 address loses to IPv4 after the stagger delay.
+	eyeballsDial = func(ctx context.Context, addr string) error {
+		if strings.HasPrefix(addr, "[2001:db8::1]") {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+	start := time.Now()
+	got, err := raceAddresses(context.Background(), []string{"2001:db8::1", "192.0.2.1"}, "22")
+	if err != nil || got != "192.0.2.1" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if time.Since(start) < eyeballsDelay {
+		t.Fatal("second attempt started before the delay")
+	}
+
+	// A refused address hands over immediately.
+	eyeballsDial = func(ctx context.Context, addr string) error {
+		if strings.HasPrefix(addr, "[2001:db8::1]") {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	start = time.Now()
+	if got, _ := raceAddresses(context.Background(), []string{"2001:db8::1", "192.0.2.1"}, "22"); got != "192.0.2.1" {
+		t.Fatalf("got %q", got)
+	}
+	if time.Since(start) >= eyeballsDelay {
+		t.Fatal("waited for the delay after a failure")
+	}
+
+	eyeballsDial = func(context.Context, string) error { return errors.New("unreachable") }
+	if _, err := raceAddresses(context.Background(), []string{"192.0.2.1", "192.0.2.2"}, "22"); err == nil {
+		t.Fatal("expected an error when every address fails")
+	}
+}
+
+func TestEyeballsArgs(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Alias: "db1", Hostname: "db1.example.com", Port: "2222"}
+	got := strings.Join(sshArgs(h, launchOptions{address: "2001:db8::1"}), " ")
+	if got != "-o HostName=2001:db8::1 -o HostKeyAlias=[db1.example.com]:2222 -- db1" {
+		t.Fatalf("args %s", got)
+	}
+	h.Options = map[string]string{"hostkeyalias": "db-cluster"}
+	if got := eyeballsArgs(h, "192.0.2.1"); got[3] != "HostKeyAlias=db-cluster" {
+		t.Fatalf("args %v", got)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sshFailureCode is the exit status ssh reserves for its own errors; any
+// other status comes from the remote command or shell.
+const sshFailureCode = 255
+
+// stderrTailLines is how much of ssh's stderr the failure panel keeps.
+const stderrTailLines = 8
+
+// sessionFailure describes a subprocess ssh that failed to connect.
+type sessionFailure struct {
+	host    sshHost
+	code    int
+	lines   []string // last lines of ssh's stderr, plus relevant debug lines
+	logPath string   // ssh -vvv log, when this was a verbose retry
+}
+
+// tailWriter keeps the last n lines written to it.
+type tailWriter struct {
+	mu      sync.Mutex
+	n       int
+	lines   []string
+	partial string
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	text := w.partial + strings.ReplaceAll(string(p), "\r", "")
+	parts := strings.Split(text, "\n")
+	w.partial = parts[len(parts)-1]
+	for _, line := range parts[:len(parts)-1] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		w.lines = append(w.lines, line)
+		if len(w.lines) > w.n {
+			w.lines = w.lines[len(w.lines)-w.n:]
+		}
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) Lines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	lines := append([]string(nil), w.lines...)
+	if strings.TrimSpace(w.partial) != "" {
+		lines = append(lines, w.partial)
+	}
+	return lines
+}
+
+// remediation pairs an ssh error message with advice.
+type remediation struct {
+	match string
+	hint  func(h sshHost) string
+}
+
+var remediations = []remediation{
+	{"UNPROTECTED PRIVATE KEY FILE", func(sshHost) string {
+		return "The key is readable by others; ssh refuses it. Fix with chmod 600 on the key (sshpick doctor lists them)."
+	}},
+	{"REMOTE HOST IDENTIFICATION HAS CHANGED", func(h sshHost) string {
+		return fmt.Sprintf("The host key changed. If that's expected (reinstall, new IP), verify it and run: ssh-keygen -R %s", knownHostsKey(h))
+	}},
+	{"Host key verification failed", func(h sshHost) string {
+		return "The host key isn't known or doesn't match. Check known_hosts before trusting it."
+	}},
+	{"Permission denied (publickey", func(sshHost) string {
+		return "No offered key was accepted. Check IdentityFile and the agent (ssh-add -l), and the remote authorized_keys."
+	}},
+	{"Too many authentication failures", func(sshHost) string {
+		return "The agent offered too many keys. Set IdentitiesOnly yes with the right IdentityFile for this host."
+	}},
+	{"timed out", func(sshHost) string {
+		return "The host didn't answer. Check VPN/network, or use an out-of-band console (o) if it's down."
+	}},
+	{"Connection refused", func(sshHost) string {
+		return "Nothing is listening on the ssh port: sshd may be down or the Port is wrong."
+	}},
+	{"No route to host", func(sshHost) string {
+		return "The network can't reach the host. Check VPN/network and the Hostname."
+	}},
+	{"Could not resolve hostname", func(sshHost) string {
+		return "DNS lookup failed. Check the Hostname and your resolver/VPN."
+	}},
+}
+
+// hints returns the advice matching the captured stderr, most specific first.
+func (f sessionFailure) hints() []string {
+	text := strings.Join(f.lines, "\n")
+	var out []string
+	for _, r := range remediations {
+		if strings.Contains(text, r.match) {
+			out = append(out, r.hint(f.host))
+		}
+	}
+	return out
+}
+
+func (m model) updateFailure(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "enter", "v":
+		if msg.String() == "v" && m.restrict != nil && !m.restrict.allowsOption("verbose") {
+			return m, nil
+		}
+		h := m.failure.host
+		m.failure = nil
+		m.verboseRetry = msg.String() == "v"
+		return m.beginConnect(h)
+	case "esc":
+		m.failure = nil
+	}
+	return m, nil
+}
+
+func (m model) renderFailure(b *strings.Builder) {
+	f := m.failure
+	fmt.Fprintln(b, m.styles.error.Render(fmt.Sprintf("ssh to %s failed (exit %d)", f.host.Alias, f.code)))
+	for _, line := range f.lines {
+		fmt.Fprintln(b, m.styles.help.Render("  "+line))
+	}
+	for _, hint := range f.hints() {
+		fmt.Fprintln(b, m.styles.item.Render("→ "+hint))
+	}
+	if f.logPath != "" {
+		fmt.Fprintln(b, m.styles.help.Render("Full debug log: "+f.logPath))
+	}
+	fmt.Fprintln(b, m.styles.help.Render(tr("help.failure")))
+}
+
+// resumeAfter prepares the model of a finished TUI run to be shown again
+// with the failure panel open.
+func (m model) resumeAfter(f *sessionFailure) model {
+	m.chosen = false
+	m.selectedHost = sshHost{}
+	m.prompt = nil
+	m.acknowledged = nil
+	m.prechecked = nil
+	m.handoff = nil
+	m.latencyResults = nil
+	m.verboseRetry = false
+	m.failure = f
+	return m
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestTailWriter(t *testing.T) {
+	t.Parallel()
+
+	w := &tailWriter{n: 2}
+	w.Write([]byte("one\r\ntwo\n\nthr"))
+	w.Write([]byte("ee\nfour"))
+	got := strings.Join(w.Lines(), "|")
+	if got != "two|three|four" {
+		t.Fatalf("Lines = %q", got)
+	}
+}
+
+func TestSessionFailureHints(t *testing.T) {
+	t.Parallel()
+
+	f := sessionFailure{host: sshHost{Alias: "web1"}, code: 255, lines: []string{
+		"@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@",
+		"@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @",
+		"Host key verification failed.",
+	}}
+	hints := f.hints()
+	if len(hints) != 2 || !strings.Contains(hints[0], "ssh-keygen -R web1") {
+		t.Fatalf("hints = %q", hints)
+	}
+	if got := (sessionFailure{lines: []string{"ssh: connect to host x port 22: Connection timed out"}}).hints(); len(got) != 1 {
+		t.Fatalf("timeout hints = %q", got)
+	}
+}
+
+func TestFailurePanel_Retry(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Alias: "web1"}
+	m := initialModel([]sshHost{h}, "", "").resumeAfter(&sessionFailure{host: h, code: 255})
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if m.failure != nil || !m.chosen || m.selectedHost.Alias != "web1" || cmd == nil {
+		t.Fatalf("expected Enter to retry the connection, got chosen=%v failure=%v", m.chosen, m.failure)
+	}
+}
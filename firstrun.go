@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// newConfigHeader starts an ssh config created by the setup assistant.
+const newConfigHeader = "# ssh client configuration, see ssh_config(5).\n# Hosts added by sshpick are appended below.\n"
+
+// isInteractive reports whether stdin is a terminal someone can answer
+// setup questions on.
+func isInteractive() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// firstRunSetup is offered when there's no ssh config yet: it creates the
+// config with the permissions ssh insists on, offers to generate a key when
+// there is none, and runs the onboard wizard for a first host. It reports
+// whether a config now exists.
+func firstRunSetup(p prompter, cfgPath string) (bool, error) {
+	fmt.Fprintf(p.out, "There is no ssh config at %s yet.\n", tildePath(cfgPath))
+	if !p.confirm("Set one up now?", true) {
+		fmt.Fprintln(p.out, "Skipped. Run `sshpick onboard` whenever you want to add a host.")
+		return false, nil
+	}
+	dir := filepath.Dir(cfgPath)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return false, err
+	}
+	// ssh refuses keys and configs under a directory others can write to.
+	if fi, err := os.Stat(dir); err == nil && fi.Mode().Perm()&0o022 != 0 {
+		fmt.Fprintf(p.out, "%s is writable by others (%v); ssh will refuse to use it.\n", tildePath(dir), fi.Mode().Perm())
+		if p.confirm("Restrict it to you (0700)?", true) {
+			if err := os.Chmod(dir, 0o700); err != nil {
+				return false, err
+			}
+		}
+	}
+	f, err := os.OpenFile(cfgPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil && !errors.Is(err, fs.ErrExist) {
+		return false, err
+	}
+	if err == nil {
+		if _, err := f.WriteString(newConfigHeader); err != nil {
+			f.Close()
+			return false, err
+		}
+		if err := f.Close(); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(p.out, "Created %s.\n", tildePath(cfgPath))
+	}
+
+	if defaultPublicKey() == "" && p.confirm("\nYou have no ssh key. Generate an ed25519 key?", true) {
+		key := filepath.Join(dir, "id_ed25519")
+		// ssh-keygen asks for the passphrase itself.
+		cmd := exec.Command("ssh-keygen", "-t", "ed25519", "-f", key)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, p.out, os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintln(p.out, "ssh-keygen failed:", err)
+		}
+	}
+
+	if p.confirm("\nAdd a first host now?", true) {
+		fmt.Fprintln(p.out, "")
+		if err := onboard(p, cfgPath, nil); err != nil {
+			// The config is there either way; the wizard can be rerun.
+			fmt.Fprintln(p.out, "onboard:", err)
+			fmt.Fprintln(p.out, "Run `sshpick onboard` to try again.")
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFirstRunSetup(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ssh")
+	os.MkdirAll(dir, 0o700)
+	os.Chmod(dir, 0o777)
+	// a key already exists, so only the directory, config and host questions come up
+	os.WriteFile(filepath.Join(dir, "id_ed25519.pub"), []byte("ssh-ed25519 AAAA me\n"), 0o644)
+	t.Setenv("SSHPICK_SSH_DIR", dir)
+	cfg := filepath.Join(dir, "config")
+
+	var out bytes.Buffer
+	p := prompter{in: bufio.NewReader(strings.NewReader("\ny\nn\n")), out: &out}
+	created, err := firstRunSetup(p, cfg)
+	if err != nil || !created {
+		t.Fatalf("setup: %v %v\n%s", created, err, out.String())
+	}
+	fi, err := os.Stat(cfg)
+	if err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("config should be created 0600: %v %v", fi, err)
+	}
+	if di, _ := os.Stat(dir); di.Mode().Perm() != 0o700 {
+		t.Fatalf("directory should be restricted to 0700, is %v", di.Mode().Perm())
+	}
+	if strings.Contains(out.String(), "Generate") {
+		t.Fatal("shouldn't offer a key when one exists")
+	}
+	if hosts, err := parseSSHConfig(cfg); err != nil || len(hosts) != 0 {
+		t.Fatalf("new config should parse empty: %v %v", hosts, err)
+	}
+
+	// declining creates nothing
+	other := filepath.Join(t.TempDir(), "config")
+	p = prompter{in: bufio.NewReader(strings.NewReader("n\n")), out: &out}
+	if created, err := firstRunSetup(p, other); created || err != nil || fileExists(other) {
+		t.Fatalf("declined setup: %v %v", created, err)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hasConfigForwards reports whether the ssh config sets up any port
+// forwarding for h.
+func hasConfigForwards(h sshHost) bool {
+	return len(h.Forwards) > 0 || len(h.LocalForwards) > 0 || h.option("remoteforward") != "" || h.option("dynamicforward") != ""
+}
+
+// forwardsOffByDefault is the per-host setting: a "forwards=off" annotation
+// (in the ssh config or hosts.yaml) connects without the configured forwards
+// unless the toggle says otherwise.
+func forwardsOffByDefault(h sshHost) bool {
+	switch strings.ToLower(h.Annotations["forwards"]) {
+	case "off", "no", "false", "0":
+		return true
+	}
+	return false
+}
+
+// stripsForwards decides whether connecting to h clears its configured
+// forwards: the host's default, inverted while the L toggle is on, unless
+// forwards were picked one by one in the detail pane.
+func (m model) stripsForwards(h sshHost) bool {
+	if !hasConfigForwards(h) {
+		return false
+	}
+	if len(m.forwardPicks[h.Alias]) > 0 && len(h.Forwards) > 0 {
+		kept, _ := m.pickedForwards(h)
+		return len(kept) == 0
+	}
+	return forwardsOffByDefault(h) != m.flipForwards
+}
+
+// clearForwardingArgs drops the config's forwards with ClearAllForwardings.
+// That option also clears -L and -R given on the command line, so
+// explicitly requested forwards and the clipboard bridge win and the
+// config's stay.
+func clearForwardingArgs(opts launchOptions) []string {
+	if !opts.clearForwards || opts.localForward != "" || len(opts.forwards) > 0 || opts.clipboardPort != 0 {
+		return nil
+	}
+	return []string{"-o", "ClearAllForwardings=yes"}
+}
+
+// portForward is one LocalForward, RemoteForward or DynamicForward that
+// applies to a host, as the ssh flag that sets it up.
+type portForward struct {
+	Flag string // "L", "R" or "D"
+	Spec string // the flag's argument, e.g. "127.0.0.1:8080:db:80"
+}
+
+func (f portForward) String() string {
+	return "-" + f.Flag + " " + f.Spec
+}
+
+var forwardFlags = map[string]string{"localforward": "L", "remoteforward": "R", "dynamicforward": "D"}
+
+// configForwards lists the forwards among a host's effective options, in
+// the order ssh sets them up. The config separates the listen and connect
+// sides with a space where the flag uses a colon.
+func configForwards(origins []optionOrigin) []portForward {
+	var out []portForward
+	for _, o := range origins {
+		if flag, ok := forwardFlags[o.Key]; ok {
+			out = append(out, portForward{Flag: flag, Spec: strings.Join(strings.Fields(o.Value), ":")})
+		}
+	}
+	return out
+}
+
+// forwardOn reports whether h's i-th forward will be set up: as picked in
+// the detail pane, else the host's default.
+func (m model) forwardOn(h sshHost, i int) bool {
+	if on, ok := m.forwardPicks[h.Alias][i]; ok {
+		return on
+	}
+	return forwardsOffByDefault(h) == m.flipForwards
+}
+
+// toggleForward is a digit key in the detail pane: switch the highlighted
+// host's i-th forward on or off for this session.
+func (m model) toggleForward(i int) model {
+	if len(m.hosts) == 0 {
+		return m
+	}
+	h := m.hosts[m.cursor]
+	if i >= len(h.Forwards) {
+		return m
+	}
+	if m.forwardPicks == nil {
+		m.forwardPicks = map[string]map[int]bool{}
+	}
+	if m.forwardPicks[h.Alias] == nil {
+		m.forwardPicks[h.Alias] = map[int]bool{}
+	}
+	m.forwardPicks[h.Alias][i] = !m.forwardOn(h, i)
+	return m
+}
+
+// pickedForwards returns the forwards to set up when the picks leave some
+// on and some off. custom is false when they're all on or all off, which
+// the config and ClearAllForwardings already cover.
+func (m model) pickedForwards(h sshHost) (kept []portForward, custom bool) {
+	for i, f := range h.Forwards {
+		if m.forwardOn(h, i) {
+			kept = append(kept, f)
+		}
+	}
+	return kept, len(kept) > 0 && len(kept) < len(h.Forwards)
+}
+
+// forwardLines are the detail pane's forwards, numbered for toggling.
+func (m model) forwardLines(h sshHost) []string {
+	var lines []string
+	for i, f := range h.Forwards {
+		label := ""
+		if i == 0 {
+			label = "Forwards:"
+		}
+		mark := statusMarks[statusBad]
+		if m.forwardOn(h, i) {
+			mark = statusMarks[statusOK]
+		}
+		lines = append(lines, fmt.Sprintf("%-14s %d %s %s", label, i+1, mark, f))
+	}
+	if len(lines) > 0 {
+		lines = append(lines, fmt.Sprintf("%-14s %s", "", "press 1-9 to toggle before connecting"))
+	}
+	return lines
+}
+
+// resolveSSHConfig is `ssh -G`: every option ssh applies to alias, one
+// "key value" per line. A variable so tests don't need ssh.
+var resolveSSHConfig = func(cfgPath, alias string) (string, error) {
+	out, err := exec.Command("ssh", "-F", cfgPath, "-G", alias).Output()
+	return string(out), err
+}
+
+// quotedOptions take one path that may contain spaces; ssh -G prints them
+// unquoted.
+var quotedOptions = map[string]bool{
+	"certificatefile": true, "controlpath": true, "identityagent": true,
+	"identityfile": true, "securitykeyprovider": true, "xauthlocation": true,
+}
+
+// pickedHostAlias is the name a host is connected under with picked
+// forwards, so the user's own Host block doesn't add its forwards back.
+func pickedHostAlias(h sshHost) string {
+	return "sshpick-" + h.Alias
+}
+
+// pickedForwardsConfig is an ssh config connecting to h as ssh would,
+// minus every forward: ssh can't drop forwards one at a time, and
+// ClearAllForwardings also clears those given as -L, -R and -D. The host
+// is renamed (pickedHostAlias) and written out as ssh -G resolves it.
+// Nothing is included: the user's config would add its Host * forwards
+// back. Jump hosts are resolved from this file too (ssh passes -F on to
+// ProxyJump), so each hop gets its own resolved block.
+func pickedForwardsConfig(h sshHost, cfgPath string) (string, error) {
+	resolved, err := resolveSSHConfig(cfgPath, h.Alias)
+	if err != nil {
+		return "", fmt.Errorf("ssh -G %s: %w", h.Alias, err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Written by sshpick to connect to %s with the forwards picked in the\n# detail pane. Rewritten on every such connection.\n", h.Alias)
+	writeResolvedBlock(&b, pickedHostAlias(h), resolved)
+	seen := map[string]bool{}
+	hops := jumpChain(resolvedOption(resolved, "proxyjump"))
+	for len(hops) > 0 {
+		hop := hopHost(hops[0])
+		hops = hops[1:]
+		if hop == "" || seen[hop] {
+			continue
+		}
+		seen[hop] = true
+		out, err := resolveSSHConfig(cfgPath, hop)
+		if err != nil {
+			return "", fmt.Errorf("ssh -G %s: %w", hop, err)
+		}
+		writeResolvedBlock(&b, hop, out)
+		hops = append(hops, jumpChain(resolvedOption(out, "proxyjump"))...)
+	}
+	return b.String(), nil
+}
+
+// writeResolvedBlock writes ssh -G output as a Host block for alias,
+// without its forwards.
+func writeResolvedBlock(b *strings.Builder, alias, resolved string) {
+	fmt.Fprintf(b, "Host %s\n", alias)
+	for _, line := range strings.Split(resolved, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if key == "" || key == "host" || key == "clearallforwardings" || forwardFlags[key] != "" {
+			continue
+		}
+		if quotedOptions[key] && strings.ContainsAny(value, " \t") {
+			value = `"` + value + `"`
+		}
+		fmt.Fprintf(b, "    %s %s\n", key, value)
+	}
+}
+
+// resolvedOption is key's value in ssh -G output.
+func resolvedOption(resolved, key string) string {
+	for _, line := range strings.Split(resolved, "\n") {
+		if k, v, _ := strings.Cut(strings.TrimSpace(line), " "); k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// pickForwards sets opts up to connect to h with only the kept forwards:
+// a generated config without the host's own, and a flag for each kept one.
+func (opts *launchOptions) pickForwards(h sshHost, kept []portForward, cfgPath string) error {
+	dir := stateDir()
+	if dir == "" {
+		return errors.New("no state directory for the generated ssh config")
+	}
+	text, err := pickedForwardsConfig(h, cfgPath)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "forwards", h.Alias+".conf")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		return err
+	}
+	opts.configFile = path
+	for _, f := range kept {
+		switch f.Flag {
+		case "L":
+			opts.forwards = append(opts.forwards, f.Spec)
+		case "R":
+			opts.remoteForwards = append(opts.remoteForwards, f.Spec)
+		case "D":
+			opts.dynamicForwards = append(opts.dynamicForwards, f.Spec)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestStripsForwards(t *testing.T) {
+	t.Parallel()
+
+	tunnel := sshHost{Alias: "db1", LocalForwards: []string{"5432 localhost:5432"}}
+	quiet := sshHost{Alias: "db2", LocalForwards: []string{"5432 localhost:5432"}, Annotations: map[string]string{"forwards": "off"}}
+	socks := sshHost{Alias: "proxy", Options: map[string]string{"dynamicforward": "1080"}, Annotations: map[string]string{"forwards": "no"}}
+	plain := sshHost{Alias: "web1", Annotations: map[string]string{"forwards": "off"}}
+
+	var m model
+	for h, want := range map[*sshHost]bool{&tunnel: false, &quiet: true, &socks: true, &plain: false} {
+		if got := m.stripsForwards(*h); got != want {
+			t.Errorf("%s: stripsForwards = %v", h.Alias, got)
+		}
+	}
+	m.flipForwards = true
+	for h, want := range map[*sshHost]bool{&tunnel: true, &quiet: false, &socks: false, &plain: false} {
+		if got := m.stripsForwards(*h); got != want {
+			t.Errorf("%s flipped: stripsForwards = %v", h.Alias, got)
+		}
+	}
+
+	if got := strings.Join(sshArgs(tunnel, launchOptions{clearForwards: true}), " "); got != "-o ClearAllForwardings=yes -- db1" {
+		t.Errorf("sshArgs = %q", got)
+	}
+	if got := strings.Join(sshArgs(tunnel, launchOptions{clearForwards: true, forwards: []string{"9100:localhost:9100"}}), " "); got != "-L 9100:localhost:9100 -o ExitOnForwardFailure=yes -- db1" {
+		t.Errorf("explicit forwards must not be cleared: %q", got)
+	}
+}
+
+func TestPickForwards(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	orig := resolveSSHConfig
+	defer func() { resolveSSHConfig = orig }()
+	resolveSSHConfig = func(cfgPath, alias string) (string, error) {
+		if alias != "db1" {
+			return "host " + alias + "\nuser ops\ndynamicforward 1080\n", nil
+		}
+		return "host db1\nuser deploy\nhostname 10.0.0.5\nproxyjump jump1,jump2\nidentityagent /Users/me/Library/Group Containers/op/agent.sock\nlocalforward 8080 localhost:80\ndynamicforward 1080\n", nil
+	}
+
+	cfg := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(cfg, []byte("Host *\n  DynamicForward 1080\nHost db1\n  HostName 10.0.0.5\n  ProxyJump jump1, jump2\n  LocalForward 127.0.0.1:8080 localhost:80\n  RemoteForward 9000 localhost:9000\n"), 0o600)
+	hosts, _, err := parseSSHConfigWarnings(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := hosts[0]
+	var got []string
+	for _, f := range h.Forwards {
+		got = append(got, f.String())
+	}
+	if strings.Join(got, ", ") != "-D 1080, -L 127.0.0.1:8080:localhost:80, -R 9000:localhost:9000" {
+		t.Fatalf("forwards %q", got)
+	}
+	if strings.Join(h.JumpChain, " ") != "jump1 jump2" {
+		t.Fatalf("jump chain %q", h.JumpChain)
+	}
+
+	m := initialModel(hosts, "", cfg)
+	m.showDetail = true
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("2")})
+	m = next.(model)
+	kept, custom := m.pickedForwards(h)
+	if !custom || len(kept) != 2 || m.stripsForwards(h) {
+		t.Fatalf("kept %v custom %v", kept, custom)
+	}
+	if lines := strings.Join(m.forwardLines(h), "\n"); !strings.Contains(lines, "2 ✗ -L") || !strings.Contains(lines, "3 ✓ -R") {
+		t.Fatalf("detail:\n%s", lines)
+	}
+
+	var opts launchOptions
+	if err := opts.pickForwards(h, kept, cfg); err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Join(sshArgs(h, opts), " ")
+	if args != "-R 9000:localhost:9000 -D 1080 -F "+opts.configFile+" -- sshpick-db1" {
+		t.Fatalf("args %q", args)
+	}
+	data, _ := os.ReadFile(opts.configFile)
+	text := string(data)
+	if !strings.Contains(text, "Host sshpick-db1\n") || strings.Contains(text, "localforward") || strings.Contains(text, "dynamicforward") || !strings.Contains(text, "    proxyjump jump1,jump2\n") {
+		t.Fatalf("generated config:\n%s", text)
+	}
+	if !strings.Contains(text, `identityagent "/Users/me/Library/Group Containers/op/agent.sock"`) || strings.Contains(text, "Include") {
+		t.Fatalf("generated config:\n%s", text)
+	}
+	if !strings.Contains(text, "Host jump1\n    user ops\n") || !strings.Contains(text, "Host jump2\n") {
+		t.Fatalf("jump hosts not resolved:\n%s", text)
+	}
+
+	// turning the rest off too is plain ClearAllForwardings
+	for _, k := range []string{"1", "3"} {
+		next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = next.(model)
+	}
+	if _, custom := m.pickedForwards(h); custom || !m.stripsForwards(h) {
+		t.Fatal("all forwards off should strip them")
+	}
+}
+
+func TestPickForwardsDropsHostStarForward(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	orig := resolveSSHConfig
+	defer func() { resolveSSHConfig = orig }()
+	// ssh -G of a host whose only forward comes from Host *
+	resolveSSHConfig = func(cfgPath, alias string) (string, error) {
+		return "host web1\nhostname 10.0.0.7\ndynamicforward 1080\nlocalforward 8080 localhost:80\n", nil
+	}
+	h := sshHost{Alias: "web1", Hostname: "10.0.0.7"}
+	var opts launchOptions
+	if err := opts.pickForwards(h, []portForward{{Flag: "L", Spec: "8080:localhost:80"}}, filepath.Join(t.TempDir(), "config")); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(opts.configFile)
+	if text := string(data); strings.Contains(text, "dynamicforward") || strings.Contains(text, "Include") || strings.Contains(text, "Host *") {
+		t.Fatalf("deselected Host * forward can come back:\n%s", text)
+	}
+	if args := strings.Join(sshArgs(h, opts), " "); strings.Contains(args, "-D") || !strings.Contains(args, "-L 8080:localhost:80") {
+		t.Fatalf("args %q", args)
+	}
+}
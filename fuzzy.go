@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// fuzzyMatch finds term's runes in order in text and scores the match:
+// runes at the start of a word and runs of consecutive runes score higher,
+// spread-out matches lower. Matching ignores case unless term has an upper
+// case letter. positions are byte offsets into text.
+func fuzzyMatch(term, text string) (score int, positions []int, ok bool) {
+	if term == "" {
+		return 0, nil, true
+	}
+	fold := strings.ToLower(term) == term
+	pattern := []rune(term)
+	best := -1
+	// Try every start for the first rune and keep the best greedy match.
+	for start, r := range text {
+		if !runeEqual(r, pattern[0], fold) {
+			continue
+		}
+		s, pos, matched := fuzzyFrom(pattern, text, start, fold)
+		if matched && s > best {
+			best, positions = s, pos
+		}
+	}
+	if best < 0 {
+		return 0, nil, false
+	}
+	return best, positions, true
+}
+
+func fuzzyFrom(pattern []rune, text string, start int, fold bool) (int, []int, bool) {
+	score, pi, prev := 0, 0, -2
+	var positions []int
+	for i, r := range text[start:] {
+		i += start
+		if pi == len(pattern) {
+			break
+		}
+		if !runeEqual(r, pattern[pi], fold) {
+			continue
+		}
+		score++
+		if i == 0 || isWordStart(text, i) {
+			score += 8
+		}
+		if prev >= 0 && i == prev+utf8.RuneLen(r) {
+			score += 5
+		} else if prev >= 0 {
+			score -= 1
+		}
+		positions = append(positions, i)
+		prev = i
+		pi++
+	}
+	return score, positions, pi == len(pattern)
+}
+
+func runeEqual(a, b rune, fold bool) bool {
+	if fold {
+		return unicode.ToLower(a) == b
+	}
+	return a == b
+}
+
+// isWordStart reports whether the rune at byte i follows a separator such
+// as the dots and dashes in hostnames.
+func isWordStart(text string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return strings.ContainsRune(" .-_@/:,", r)
+}
+
+// fuzzyFields are the host fields the fuzzy filter searches in scope.
+func fuzzyFields(h sshHost, scope filterScope) []string {
+	fields := []string{h.Alias}
+	if scope == scopeAlias {
+		return fields
+	}
+	fields = append(fields, h.Hostname, h.IP)
+	if scope == scopeAliasHost {
+		return fields
+	}
+	fields = append(fields, h.User)
+	fields = append(fields, h.Notes...)
+	return append(fields, h.LocalForwards...)
+}
+
+// filterHostsFuzzy keeps hosts where every space-separated term of query
+// fuzzy-matches one of the fields, best matches first. Ties keep the list
+// order. Directive filters (user:root) work the same as with regexes.
+func filterHostsFuzzy(all []sshHost, query string, scope filterScope) []sshHost {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return all
+	}
+	if key, rest, ok := directiveFilter(query); ok && scope == scopeAll {
+		out, _ := filterHostsDirective(all, key, rest)
+		return out
+	}
+	terms := strings.Fields(query)
+	type scored struct {
+		h     sshHost
+		score int
+	}
+	var matched []scored
+	for _, h := range all {
+		total, ok := 0, true
+		for _, term := range terms {
+			best := -1
+			for _, f := range fuzzyFields(h, scope) {
+				if s, _, hit := fuzzyMatch(term, f); hit && s > best {
+					best = s
+				}
+			}
+			if best < 0 {
+				ok = false
+				break
+			}
+			total += best
+		}
+		if ok {
+			matched = append(matched, scored{h, total})
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].score > matched[j].score })
+	out := make([]sshHost, len(matched))
+	for i, s := range matched {
+		out[i] = s.h
+	}
+	return out
+}
+
+// fuzzyHighlights are the byte offsets in text matched by any term of
+// query, for highlighting the alias and hostname columns.
+func fuzzyHighlights(query, text string) map[int]bool {
+	var hl map[int]bool
+	for _, term := range strings.Fields(query) {
+		if _, pos, ok := fuzzyMatch(term, text); ok {
+			for _, p := range pos {
+				if hl == nil {
+					hl = map[int]bool{}
+				}
+				hl[p] = true
+			}
+		}
+	}
+	return hl
+}
+
+// renderHighlighted renders line with style, drawing the runes at the
+// highlighted byte offsets underlined and bold. The style's horizontal
+// padding is kept around the whole line rather than each piece.
+func renderHighlighted(style lipgloss.Style, line string, hl map[int]bool) string {
+	if len(hl) == 0 {
+		return style.Render(line)
+	}
+	pad := strings.Repeat(" ", style.GetPaddingLeft())
+	padRight := strings.Repeat(" ", style.GetPaddingRight())
+	plain := style.UnsetPadding()
+	mark := plain.Underline(true).Bold(true)
+
+	var b strings.Builder
+	b.WriteString(plain.Render(pad))
+	start, marked := 0, false
+	flush := func(end int) {
+		if end > start {
+			s := plain
+			if marked {
+				s = mark
+			}
+			b.WriteString(s.Render(line[start:end]))
+		}
+		start = end
+	}
+	for i := range line {
+		if hl[i] != marked {
+			flush(i)
+			marked = hl[i]
+		}
+	}
+	flush(len(line))
+	b.WriteString(plain.Render(padRight))
+	return b.String()
+}
+
+// filterMode names how the filter matches: "fuzzy" or "regex".
+func (m model) filterMode() string {
+	if m.filterRegex {
+		return "regex"
+	}
+	return "fuzzy"
+}
+
+// renderFilterInput is the filter prompt shown under the list while typing.
+func (m model) renderFilterInput(b *strings.Builder) {
+	mode, invalid := m.filterMode()+", "+m.filterScope.String()+" fields", "Invalid regex: "
+	if _, ok, _ := scopedQuery(m.filterQuery, m.filterScope); ok {
+		mode, invalid = "query", "Invalid query: "
+	}
+	fmt.Fprintln(b, m.styles.help.Render("/ "+m.filterQuery+"▏  ["+mode+"]  (Enter keep, Esc clear, ↑/↓ history, Tab fields, Ctrl+R fuzzy/regex)"))
+	if m.filterErr != nil {
+		fmt.Fprintln(b, m.styles.error.Render(invalid+m.filterErr.Error()))
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	t.Parallel()
+
+	if _, _, ok := fuzzyMatch("pdb", "prod-db1"); !ok {
+		t.Fatal("pdb should match prod-db1")
+	}
+	if _, _, ok := fuzzyMatch("dbp", "prod-db1"); ok {
+		t.Fatal("dbp is out of order")
+	}
+	if _, _, ok := fuzzyMatch("Prod", "prod-db1"); ok {
+		t.Fatal("an upper case term matches case")
+	}
+	_, pos, _ := fuzzyMatch("db", "prod-db1")
+	if len(pos) != 2 || pos[0] != 5 || pos[1] != 6 {
+		t.Fatalf("positions %v: the word-start match should win", pos)
+	}
+	word, _, _ := fuzzyMatch("db", "prod-db1")
+	spread, _, _ := fuzzyMatch("db", "dashboard")
+	if word <= spread {
+		t.Fatalf("scores %d <= %d", word, spread)
+	}
+}
+
+func TestFilterHostsFuzzy(t *testing.T) {
+	t.Parallel()
+
+	hosts := []sshHost{
+		{Alias: "dashboard", Hostname: "dash.example.com"},
+		{Alias: "prod-db1", Hostname: "10.0.0.5", User: "postgres"},
+		{Alias: "web1", Hostname: "web1.example.com", Notes: []string{"behind the proxy"}, LocalForwards: []string{"8080"}},
+	}
+	names := func(hs []sshHost) string {
+		var out []string
+		for _, h := range hs {
+			out = append(out, h.Alias)
+		}
+		return strings.Join(out, " ")
+	}
+	if got := names(filterHostsFuzzy(hosts, "db", scopeAll)); got != "prod-db1 dashboard" {
+		t.Fatalf("db: %s", got)
+	}
+	if got := names(filterHostsFuzzy(hosts, "pg db", scopeAll)); got != "prod-db1" {
+		t.Fatalf("every term must match: %s", got)
+	}
+	if got := names(filterHostsFuzzy(hosts, "proxy", scopeAll)); got != "web1" {
+		t.Fatalf("notes: %s", got)
+	}
+	if got := names(filterHostsFuzzy(hosts, "8080", scopeAliasHost)); got != "" {
+		t.Fatalf("forwards are outside the host scope: %s", got)
+	}
+	if got := names(filterHostsFuzzy(hosts, "user:postgres", scopeAll)); got != "prod-db1" {
+		t.Fatalf("directive filter: %s", got)
+	}
+}
+
+func TestRenderHighlighted(t *testing.T) {
+	t.Parallel()
+
+	style := lipgloss.NewStyle().Padding(0, 1)
+	out := renderHighlighted(style, "> prod-db1", fuzzyHighlights("db", "prod-db1"))
+	if plain := regexp.MustCompile("\x1b\\[[0-9;]*m").ReplaceAllString(out, ""); plain != " > prod-db1 " {
+		t.Fatalf("rendered %q", out)
+	}
+	if renderHighlighted(style, "web1", nil) != style.Render("web1") {
+		t.Fatal("no highlights should render as before")
+	}
+}
+
+func TestFuzzyFilterTyping(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel([]sshHost{{Alias: "dashboard"}, {Alias: "prod-db1"}, {Alias: "web1"}}, "", "")
+	m.ready = true
+	press := func(keys ...tea.KeyMsg) {
+		for _, k := range keys {
+			next, _ := m.Update(k)
+			m = next.(model)
+		}
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	press(runes("/"), runes("d"), runes("b"))
+	if len(m.hosts) != 2 || m.hosts[m.cursor].Alias != "dashboard" {
+		t.Fatalf("hosts %v cursor %d", m.hosts, m.cursor)
+	}
+	// q is part of the query, not quit.
+	press(runes("q"))
+	if !m.filterActive || m.filterQuery != "dbq" || len(m.hosts) != 0 {
+		t.Fatalf("query %q hosts %v", m.filterQuery, m.hosts)
+	}
+	press(tea.KeyMsg{Type: tea.KeyBackspace}, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.lastValidRegex != "db" || len(m.hosts) != 2 {
+		t.Fatalf("applied %q hosts %v", m.lastValidRegex, m.hosts)
+	}
+	press(runes("/"), tea.KeyMsg{Type: tea.KeyEsc})
+	if m.lastValidRegex != "" || m.filterQuery != "" || len(m.hosts) != 3 {
+		t.Fatalf("esc should clear the filter: %q %v", m.lastValidRegex, m.hosts)
+	}
+
+	press(runes("/"), tea.KeyMsg{Type: tea.KeyCtrlR}, runes("^w"))
+	if !m.filterRegex || len(m.hosts) != 1 || m.hosts[0].Alias != "web1" {
+		t.Fatalf("regex mode: %v", m.hosts)
+	}
+}
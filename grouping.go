@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sort"
+)
+
+// hostGrouping is the G key: rows grouped under a header per source or per
+// tag, each group keeping the list's order.
+type hostGrouping int
+
+const (
+	groupNone hostGrouping = iota
+	groupSource
+	groupTag
+)
+
+func (g hostGrouping) String() string {
+	switch g {
+	case groupSource:
+		return "source"
+	case groupTag:
+		return "tag"
+	}
+	return "none"
+}
+
+func parseHostGrouping(s string) hostGrouping {
+	switch s {
+	case "source":
+		return groupSource
+	case "tag":
+		return groupTag
+	}
+	return groupNone
+}
+
+// groupKey is h's group: its source, or its first tag ("" for untagged
+// hosts, which go last).
+func (g hostGrouping) groupKey(h sshHost) string {
+	switch g {
+	case groupSource:
+		return hostSource(h)
+	case groupTag:
+		if tags := h.tags(); len(tags) > 0 {
+			return tags[0]
+		}
+	}
+	return ""
+}
+
+// groupHosts orders hosts by group name, keeping the order within a group.
+func (g hostGrouping) groupHosts(hosts []sshHost) []sshHost {
+	if g == groupNone {
+		return hosts
+	}
+	out := append([]sshHost(nil), hosts...)
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := g.groupKey(out[i]), g.groupKey(out[j])
+		if a == "" || b == "" {
+			return b == "" && a != ""
+		}
+		return a < b
+	})
+	return out
+}
+
+// groupHeader is the header drawn above host i, or "" inside a group.
+func (m model) groupHeader(i int) string {
+	if m.grouping == groupNone {
+		return ""
+	}
+	key := m.grouping.groupKey(m.hosts[i])
+	if i > 0 && m.grouping.groupKey(m.hosts[i-1]) == key {
+		return ""
+	}
+	if key == "" {
+		return "untagged"
+	}
+	return key
+}
+
+// cycleGrouping is the G key: none → source → tag → none.
+func (m model) cycleGrouping() model {
+	m.grouping = (m.grouping + 1) % 3
+	m.applyFilter(m.lastValidRegex)
+	return m
+}
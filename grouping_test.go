@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGroupHosts(t *testing.T) {
+	t.Parallel()
+	tagged := func(alias, tags string) sshHost {
+		return sshHost{Alias: alias, Annotations: map[string]string{"tags": tags}}
+	}
+	m := initialModel([]sshHost{tagged("web1", "web"), {Alias: "misc"}, tagged("db1", "db,prod"), tagged("web2", "web")}, "", "")
+	m = m.cycleGrouping().cycleGrouping()
+	if m.grouping != groupTag {
+		t.Fatalf("grouping %v", m.grouping)
+	}
+	var got, headers []string
+	for i, h := range m.hosts {
+		got = append(got, h.Alias)
+		if hd := m.groupHeader(i); hd != "" {
+			headers = append(headers, hd)
+		}
+	}
+	if strings.Join(got, " ") != "db1 web1 web2 misc" || strings.Join(headers, " ") != "db web untagged" {
+		t.Fatalf("rows %v, headers %v", got, headers)
+	}
+	m.ready, m.width, m.height = true, 120, 40
+	if !strings.Contains(m.View(), "── web") {
+		t.Fatal("no group header drawn")
+	}
+}
+
+func TestViewSpec(t *testing.T) {
+	t.Parallel()
+	var settings appConfig
+	if err := json.Unmarshal([]byte(`{"views": {
+		"prod": "tag:prod",
+		"dbs": {"filter": "tag:db", "order": "recent", "group": "source", "columns": ["notes"]}
+	}}`), &settings); err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel([]sshHost{{Alias: "db1", Annotations: map[string]string{"tags": "db"}}, {Alias: "web1"}}, "", "")
+	m.appConfig = settings
+	m.showNotes = true
+
+	// a plain string only filters
+	m, _ = m.applyView(settings.Views["prod"])
+	if m.lastValidRegex != "tag:prod" || !m.showNotes || len(m.hosts) != 0 {
+		t.Fatalf("filter-only view: %q notes=%v hosts=%d", m.lastValidRegex, m.showNotes, len(m.hosts))
+	}
+	v, ok := m.findView("dbs")
+	if !ok {
+		t.Fatal("dbs not found")
+	}
+	m, _ = m.applyView(v)
+	if m.order != orderRecent || m.grouping != groupSource || !m.showNotes || len(m.hosts) != 1 {
+		t.Fatalf("full view: order %v grouping %v hosts %d", m.order, m.grouping, len(m.hosts))
+	}
+	if got := m.currentView(); got.describe() != "tag:db · recent first · by source · notes" {
+		t.Fatalf("current view %q", got.describe())
+	}
+
+	// views round-trip through state.json, strings staying strings
+	data, err := json.Marshal(uiState{Views: settings.Views})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"prod":"tag:prod"`) || !strings.Contains(string(data), `"group":"source"`) {
+		t.Fatalf("marshaled %s", data)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Host groups on the command line: "@prod-db" names every host tagged
+// prod-db, whether the tag comes from the ssh config, an inventory or
+// provider (Ansible groups become tags), or the metadata store.
+
+// splitGroupArgs takes the leading @group arguments off args, and a "--"
+// after them.
+func splitGroupArgs(args []string) (groups, rest []string) {
+	for len(args) > 0 && strings.HasPrefix(args[0], "@") && len(args[0]) > 1 {
+		groups = append(groups, args[0][1:])
+		args = args[1:]
+	}
+	if len(groups) > 0 && len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	return groups, args
+}
+
+// groupMembers are the hosts in any of groups, in list order.
+func groupMembers(hosts []sshHost, groups []string) ([]sshHost, error) {
+	for _, g := range groups {
+		found := false
+		for _, h := range hosts {
+			if h.hasTag(g) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no hosts in group @%s (groups: %s)", g, strings.Join(knownGroups(hosts), ", "))
+		}
+	}
+	var out []sshHost
+	for _, h := range hosts {
+		for _, g := range groups {
+			if h.hasTag(g) {
+				out = append(out, h)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// knownGroups are every tag in use, sorted.
+func knownGroups(hosts []sshHost) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, h := range hosts {
+		for _, t := range h.tags() {
+			if !seen[t] {
+				seen[t] = true
+				out = append(out, t)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroupArgs(t *testing.T) {
+	t.Parallel()
+
+	groups, rest := splitGroupArgs([]string{"@prod-db", "@cache", "--", "uptime", "-p"})
+	if strings.Join(groups, ",") != "prod-db,cache" || strings.Join(rest, " ") != "uptime -p" {
+		t.Fatalf("groups %v rest %v", groups, rest)
+	}
+	if groups, rest := splitGroupArgs([]string{"echo", "@home", "--"}); groups != nil || len(rest) != 3 {
+		t.Fatalf("@ after the command is an argument: %v %v", groups, rest)
+	}
+
+	tagged := func(alias, tags string) sshHost {
+		return sshHost{Alias: alias, Annotations: map[string]string{"tags": tags}}
+	}
+	hosts := []sshHost{tagged("db1", "prod-db,prod"), tagged("web1", "prod"), tagged("redis", "cache"), {Alias: "laptop"}}
+	members, err := groupMembers(hosts, []string{"cache", "prod-db"})
+	if err != nil || len(members) != 2 || members[0].Alias != "db1" || members[1].Alias != "redis" {
+		t.Fatalf("members %v %v", members, err)
+	}
+	if _, err := groupMembers(hosts, []string{"staging"}); err == nil || !strings.Contains(err.Error(), "groups: cache, prod, prod-db") {
+		t.Fatalf("unknown group: %v", err)
+	}
+}
+
+func TestAnsibleToInventory(t *testing.T) {
+	t.Parallel()
+
+	out := `{
+	  "_meta": {"hostvars": {
+	    "db1": {"ansible_host": "10.0.0.5", "ansible_port": 2222, "ansible_user": "admin"},
+	    "web1": {"ansible_ssh_host": "web1.example.com"}
+	  }},
+	  "all": {"children": ["ungrouped", "prod"]},
+	  "prod": {"children": ["dbservers", "webservers"]},
+	  "dbservers": {"hosts": ["db1"]},
+	  "webservers": {"hosts": ["web1"]},
+	  "ungrouped": {"hosts": ["laptop"]}
+	}`
+	inv, err := ansibleToInventory([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := inventoryToHosts(inv, "ansible", "hosts.ini")
+	if len(hosts) != 3 || hosts[0].Alias != "db1" || hosts[1].Alias != "laptop" {
+		t.Fatalf("hosts %+v", hosts)
+	}
+	db := hosts[0]
+	if db.Hostname != "10.0.0.5" || db.Port != "2222" || db.User != "admin" || db.IP != "10.0.0.5" {
+		t.Fatalf("db1 %+v", db)
+	}
+	if got := strings.Join(db.tags(), ","); got != "dbservers,prod" {
+		t.Fatalf("db1 should be tagged with its group and parents, got %s", got)
+	}
+	if len(hosts[1].tags()) != 0 || hosts[2].Hostname != "web1.example.com" {
+		t.Fatalf("laptop %+v web1 %+v", hosts[1], hosts[2])
+	}
+	if _, err := ansibleToInventory([]byte(`{"web": ["not", "a", "group"]}`)); err == nil {
+		t.Fatal("a malformed group should fail")
+	}
+}
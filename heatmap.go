@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// heatmapDays is how far back the usage heatmap looks.
+const heatmapDays = 90
+
+// heatShades go from no connections to the busiest bucket.
+var heatShades = []rune{'·', '░', '▒', '▓', '█'}
+
+// hostHeat is one heatmap row: connections per bucket, oldest first.
+type hostHeat struct {
+	Alias  string
+	Counts []int
+	Total  int
+}
+
+// usageHeat buckets the last heatmapDays of history for every alias (and
+// any alias in the history that's no longer configured). Busiest hosts come
+// first; hosts nobody connected to sink to the bottom.
+func usageHeat(entries []historyEntry, aliases []string, now time.Time, buckets int) []hostHeat {
+	if buckets < 1 {
+		buckets = 1
+	}
+	rows := map[string]*hostHeat{}
+	row := func(alias string) *hostHeat {
+		if r := rows[alias]; r != nil {
+			return r
+		}
+		r := &hostHeat{Alias: alias, Counts: make([]int, buckets)}
+		rows[alias] = r
+		return r
+	}
+	for _, a := range aliases {
+		row(a)
+	}
+	window := heatmapDays * 24 * time.Hour
+	for _, e := range entries {
+		age := now.Sub(e.Start)
+		if age < 0 || age >= window {
+			continue
+		}
+		i := buckets - 1 - int(age*time.Duration(buckets)/window)
+		r := row(e.Alias)
+		r.Counts[i]++
+		r.Total++
+	}
+	out := make([]hostHeat, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total != out[j].Total {
+			return out[i].Total > out[j].Total
+		}
+		return out[i].Alias < out[j].Alias
+	})
+	return out
+}
+
+// heatCells renders counts as shades scaled to max, which is shared by all
+// rows so they can be compared.
+func heatCells(counts []int, max int) string {
+	var b strings.Builder
+	for _, n := range counts {
+		shade := 0
+		if n > 0 && max > 0 {
+			shade = (n*(len(heatShades)-1) + max - 1) / max
+		}
+		b.WriteRune(heatShades[shade])
+	}
+	return b.String()
+}
+
+// heatBuckets fits the 90 days into the terminal: one cell a day when
+// there's room, otherwise fewer, wider cells.
+func heatBuckets(width int) int {
+	room := width - 32
+	for _, days := range []int{1, 2, 3, 5, 9} {
+		if heatmapDays/days <= room {
+			return heatmapDays / days
+		}
+	}
+	return heatmapDays / 9
+}
+
+func (m model) renderHeatmap(b *strings.Builder) {
+	buckets := heatBuckets(m.width)
+	aliases := make([]string, 0, len(m.allHosts))
+	for _, h := range m.allHosts {
+		aliases = append(aliases, h.Alias)
+	}
+	rows := usageHeat(m.usageEntries, aliases, time.Now(), buckets)
+
+	fmt.Fprintln(b, m.styles.title.Render(fmt.Sprintf("Connections over the last %d days", heatmapDays)))
+	fmt.Fprintln(b, m.styles.help.Render(fmt.Sprintf("Esc/H close • v table • one cell = %d day(s), today on the right", heatmapDays/buckets)))
+	fmt.Fprintln(b, "")
+	max, unused := 0, 0
+	for _, r := range rows {
+		for _, n := range r.Counts {
+			if n > max {
+				max = n
+			}
+		}
+		if r.Total == 0 {
+			unused++
+		}
+	}
+	for _, r := range rows {
+		style := m.styles.item
+		if r.Total == 0 {
+			style = m.styles.help
+		}
+		fmt.Fprintln(b, style.Render(fmt.Sprintf("%-20s %s %5d", r.Alias, heatCells(r.Counts, max), r.Total)))
+	}
+	if unused > 0 {
+		fmt.Fprintln(b, "")
+		fmt.Fprintln(b, m.styles.help.Render(fmt.Sprintf("%d host(s) with no connections in %d days.", unused, heatmapDays)))
+	}
+}
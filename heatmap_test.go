@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsageHeat(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	entries := []historyEntry{
+		{Alias: "web1", Start: now.Add(-time.Hour)},
+		{Alias: "web1", Start: now.Add(-2 * time.Hour)},
+		{Alias: "web1", Start: now.Add(-89 * day)},
+		{Alias: "db1", Start: now.Add(-45 * day)},
+		{Alias: "db1", Start: now.Add(-120 * day)},
+		{Alias: "old", Start: now.Add(-10 * day)},
+	}
+	rows := usageHeat(entries, []string{"db1", "web1", "stage"}, now, 30)
+	var got []string
+	for _, r := range rows {
+		got = append(got, r.Alias)
+	}
+	if strings.Join(got, " ") != "web1 db1 old stage" {
+		t.Fatalf("order %v", got)
+	}
+	web, db, stage := rows[0], rows[1], rows[3]
+	if web.Total != 3 || web.Counts[29] != 2 || web.Counts[0] != 1 {
+		t.Errorf("web1 %+v", web)
+	}
+	if db.Total != 1 || db.Counts[14] != 1 {
+		t.Errorf("db1 %+v", db)
+	}
+	if stage.Total != 0 || len(stage.Counts) != 30 {
+		t.Errorf("stage %+v", stage)
+	}
+	if got := heatCells([]int{0, 1, 2, 4}, 4); got != "·░▒█" {
+		t.Errorf("heatCells = %q", got)
+	}
+	if heatBuckets(200) != 90 || heatBuckets(100) != 45 || heatBuckets(0) != 10 {
+		t.Errorf("heatBuckets %d %d %d", heatBuckets(200), heatBuckets(100), heatBuckets(0))
+	}
+}
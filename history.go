@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// historyEntry is one connection in the history store, an append-only JSON
+// Lines file next to the UI state.
+type historyEntry struct {
+	Alias    string    `json:"alias"`
+	Source   string    `json:"source,omitempty"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration_s,omitempty"` // seconds; 0 when ssh replaced sshpick and couldn't be timed
+	Exit     *int      `json:"exit,omitempty"`
+}
+
+// maxHistoryEntries caps the store; older connections are dropped when
+// sshpick starts with more than this.
+const maxHistoryEntries = 5000
+
+func historyPath() string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "history.jsonl")
+}
+
+// appendHistory adds e to the store. Appends of one short line are atomic
+// enough that concurrent sshpick instances don't interleave.
+func appendHistory(path string, e historyEntry) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadHistory reads the store oldest first, skipping lines it can't parse.
+func loadHistory(path string) []historyEntry {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var entries []historyEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e historyEntry
+		if json.Unmarshal(sc.Bytes(), &e) == nil && e.Alias != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// trimHistory keeps the newest max entries, rewriting the store (temp file
+// and rename) only when it's over the cap. Lines loadHistory skipped as
+// corrupt are dropped by the rewrite too.
+func trimHistory(path string, entries []historyEntry, max int) ([]historyEntry, error) {
+	if path == "" || len(entries) <= max {
+		return entries, nil
+	}
+	entries = entries[len(entries)-max:]
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".history-*.jsonl")
+	if err != nil {
+		return entries, err
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return entries, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return entries, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return entries, err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return entries, err
+	}
+	return entries, os.Rename(tmp.Name(), path)
+}
+
+// hostUsage summarizes one host's history.
+type hostUsage struct {
+	Alias    string
+	Sessions int
+	LastWeek int           // sessions in the 7 days before now
+	PerWeek  float64       // sessions per week since the first one
+	Total    time.Duration // summed duration of timed sessions
+	Last     time.Time
+}
+
+// summarizeHistory aggregates entries per host, busiest (by total time,
+// then sessions) first.
+func summarizeHistory(entries []historyEntry, now time.Time) []hostUsage {
+	byAlias := map[string]*hostUsage{}
+	first := map[string]time.Time{}
+	for _, e := range entries {
+		u := byAlias[e.Alias]
+		if u == nil {
+			u = &hostUsage{Alias: e.Alias}
+			byAlias[e.Alias] = u
+			first[e.Alias] = e.Start
+		}
+		u.Sessions++
+		if now.Sub(e.Start) < 7*24*time.Hour {
+			u.LastWeek++
+		}
+		u.Total += time.Duration(e.Duration * float64(time.Second))
+		if e.Start.After(u.Last) {
+			u.Last = e.Start
+		}
+		if e.Start.Before(first[e.Alias]) {
+			first[e.Alias] = e.Start
+		}
+	}
+	out := make([]hostUsage, 0, len(byAlias))
+	for alias, u := range byAlias {
+		weeks := now.Sub(first[alias]).Hours() / (7 * 24)
+		if weeks < 1 {
+			weeks = 1
+		}
+		u.PerWeek = float64(u.Sessions) / weeks
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total != out[j].Total {
+			return out[i].Total > out[j].Total
+		}
+		if out[i].Sessions != out[j].Sessions {
+			return out[i].Sessions > out[j].Sessions
+		}
+		return out[i].Alias < out[j].Alias
+	})
+	return out
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryStoreAndSummary(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state", "history.jsonl")
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	zero := 0
+	entries := []historyEntry{
+		{Alias: "web1", Start: now.Add(-20 * 24 * time.Hour), Duration: 1800, Exit: &zero},
+		{Alias: "db1", Start: now.Add(-2 * time.Hour), Duration: 3600, Exit: &zero},
+		{Alias: "web1", Start: now.Add(-1 * time.Hour)},
+		{Alias: "web1", Start: now.Add(-30 * time.Minute), Duration: 600, Exit: &zero},
+	}
+	for _, e := range entries {
+		if err := appendHistory(path, e); err != nil {
+			t.Fatalf("appendHistory: %v", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{not json\n")
+	f.Close()
+
+	loaded := loadHistory(path)
+	if len(loaded) != len(entries) {
+		t.Fatalf("loaded %d entries, want %d", len(loaded), len(entries))
+	}
+
+	usage := summarizeHistory(loaded, now)
+	if len(usage) != 2 {
+		t.Fatalf("usage = %#v", usage)
+	}
+	db, web := usage[0], usage[1]
+	if db.Alias != "db1" || db.Total != time.Hour || db.Sessions != 1 {
+		t.Fatalf("db1 usage = %#v", db)
+	}
+	if web.Alias != "web1" || web.Sessions != 3 || web.LastWeek != 2 || web.Total != 40*time.Minute {
+		t.Fatalf("web1 usage = %#v", web)
+	}
+	if web.PerWeek < 1.04 || web.PerWeek > 1.06 {
+		t.Fatalf("web1 per week = %v", web.PerWeek)
+	}
+}
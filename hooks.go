@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hookSet is a list of shell commands run before and after a session.
+type hookSet struct {
+	Pre  []string `json:"pre,omitempty"`
+	Post []string `json:"post,omitempty"`
+}
+
+// hooksConfig holds global hooks plus per-tag and per-host (by alias) ones.
+type hooksConfig struct {
+	hookSet
+	Tags  map[string]hookSet `json:"tags,omitempty"`
+	Hosts map[string]hookSet `json:"hosts,omitempty"`
+}
+
+// hooksFor returns the hooks that apply to h: global first, then its tags in
+// name order, then the host's own.
+func (c hooksConfig) hooksFor(h sshHost) hookSet {
+	out := hookSet{
+		Pre:  append([]string{}, c.Pre...),
+		Post: append([]string{}, c.Post...),
+	}
+	tags := h.tags()
+	sort.Strings(tags)
+	for _, t := range tags {
+		if set, ok := c.Tags[t]; ok {
+			out.Pre = append(out.Pre, set.Pre...)
+			out.Post = append(out.Post, set.Post...)
+		}
+	}
+	if set, ok := c.Hosts[h.Alias]; ok {
+		out.Pre = append(out.Pre, set.Pre...)
+		out.Post = append(out.Post, set.Post...)
+	}
+	return out
+}
+
+// hookEnv exposes the host's fields to hook commands. The IP is looked up
+// if the picker hadn't yet.
+func hookEnv(h sshHost) []string {
+	if h.IP == "" {
+		h.IP = resolveIP(h.Hostname)
+	}
+	return []string{
+		"SSHPICK_ALIAS=" + h.Alias,
+		"SSHPICK_HOSTNAME=" + h.Hostname,
+		"SSHPICK_IP=" + h.IP,
+		"SSHPICK_USER=" + h.User,
+		"SSHPICK_PORT=" + h.Port,
+		"SSHPICK_TAGS=" + strings.Join(h.tags(), ","),
+		"SSHPICK_SOURCE=" + hostSource(h),
+	}
+}
+
+// runHooks runs each command with sh -c, stopping at the first failure.
+func runHooks(stage string, cmds []string, env []string) error {
+	for _, c := range cmds {
+		cmd := exec.Command("sh", "-c", c)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s hook %q: %w", stage, c, err)
+		}
+	}
+	return nil
+}
+
+// postHookEnv adds the session outcome to the host environment.
+func postHookEnv(h sshHost, exitCode int, elapsed time.Duration) []string {
+	return append(hookEnv(h),
+		"SSHPICK_EXIT_CODE="+strconv.Itoa(exitCode),
+		"SSHPICK_DURATION="+strconv.Itoa(int(elapsed.Seconds())),
+	)
+}
+
+// exitCode extracts the process exit status from a Run error.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if ee, ok := err.(*exec.ExitError); ok {
+		return ee.ExitCode()
+	}
+	return 1
+}
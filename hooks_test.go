@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHooksFor(t *testing.T) {
+	var cfg appConfig
+	raw := `{"hooks": {
+		"pre": ["global"],
+		"post": ["notify"],
+		"tags": {"prod": {"pre": ["confirm-prod"]}, "db": {"post": ["db-log"]}},
+		"hosts": {"db1": {"pre": ["db1-only"]}}
+	}}`
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	h := sshHost{Alias: "db1", Annotations: map[string]string{"tags": "prod,db"}}
+	got := cfg.Hooks.hooksFor(h)
+	want := hookSet{
+		Pre:  []string{"global", "confirm-prod", "db1-only"},
+		Post: []string{"notify", "db-log"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %#v, got %#v", want, got)
+	}
+
+	env := postHookEnv(h, 3, 2*time.Second)
+	if err := runHooks("post", []string{`test "$SSHPICK_ALIAS" = db1 && test "$SSHPICK_EXIT_CODE" = 3 && test "$SSHPICK_TAGS" = prod,db`}, env); err != nil {
+		t.Fatalf("hook should see host environment: %v", err)
+	}
+	if err := runHooks("pre", []string{"exit 1", "echo never"}, nil); err == nil {
+		t.Fatalf("expected failing hook to return an error")
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const certScanTimeout = 8 * time.Second
+
+// certKeyFields is how many length-prefixed public key fields follow the
+// nonce in each certificate type (see OpenSSH's PROTOCOL.certkeys).
+var certKeyFields = map[string]int{
+	"ssh-rsa-cert-v01@openssh.com":                2,
+	"ssh-dss-cert-v01@openssh.com":                4,
+	"ecdsa-sha2-nistp256-cert-v01@openssh.com":    2,
+	"ecdsa-sha2-nistp384-cert-v01@openssh.com":    2,
+	"ecdsa-sha2-nistp521-cert-v01@openssh.com":    2,
+	"ssh-ed25519-cert-v01@openssh.com":            1,
+	"sk-ecdsa-sha2-nistp256-cert-v01@openssh.com": 3,
+	"sk-ssh-ed25519-cert-v01@openssh.com":         2,
+}
+
+// certReader reads the ssh wire format.
+type certReader struct{ b []byte }
+
+func (r *certReader) bytes() ([]byte, error) {
+	if len(r.b) < 4 {
+		return nil, errors.New("truncated certificate")
+	}
+	n := binary.BigEndian.Uint32(r.b)
+	if uint32(len(r.b)-4) < n {
+		return nil, errors.New("truncated certificate")
+	}
+	v := r.b[4 : 4+n]
+	r.b = r.b[4+n:]
+	return v, nil
+}
+
+func (r *certReader) skip(n int) error {
+	if len(r.b) < n {
+		return errors.New("truncated certificate")
+	}
+	r.b = r.b[n:]
+	return nil
+}
+
+// parseCertPrincipals returns the valid principals and key id of an OpenSSH
+// certificate blob.
+func parseCertPrincipals(blob []byte) (principals []string, keyID string, err error) {
+	r := &certReader{b: blob}
+	typ, err := r.bytes()
+	if err != nil {
+		return nil, "", err
+	}
+	fields, ok := certKeyFields[string(typ)]
+	if !ok {
+		return nil, "", fmt.Errorf("not a certificate type: %s", typ)
+	}
+	// nonce, then the public key fields
+	for i := 0; i < 1+fields; i++ {
+		if _, err := r.bytes(); err != nil {
+			return nil, "", err
+		}
+	}
+	// serial (uint64) and cert type (uint32)
+	if err := r.skip(8 + 4); err != nil {
+		return nil, "", err
+	}
+	id, err := r.bytes()
+	if err != nil {
+		return nil, "", err
+	}
+	packed, err := r.bytes()
+	if err != nil {
+		return nil, "", err
+	}
+	pr := &certReader{b: packed}
+	for len(pr.b) > 0 {
+		p, err := pr.bytes()
+		if err != nil {
+			return nil, "", err
+		}
+		principals = append(principals, string(p))
+	}
+	return principals, string(id), nil
+}
+
+// fetchCertPrincipals asks the host for its certificates with ssh-keyscan -c
+// and collects their principals.
+func fetchCertPrincipals(h sshHost) ([]string, error) {
+	host := h.Hostname
+	if host == "" {
+		host = h.Alias
+	}
+	args := []string{"-c", "-T", "5"}
+	if h.Port != "" {
+		args = append(args, "-p", h.Port)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), certScanTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ssh-keyscan", append(args, host)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	var principals []string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") || certKeyFields[fields[1]] == 0 {
+			continue
+		}
+		blob, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil {
+			continue
+		}
+		ps, _, err := parseCertPrincipals(blob)
+		if err != nil {
+			continue
+		}
+		for _, p := range ps {
+			if !containsString(principals, p) {
+				principals = append(principals, p)
+			}
+		}
+	}
+	return principals, nil
+}
+
+type certPrincipalsMsg struct {
+	alias      string
+	principals []string
+	err        error
+}
+
+// refreshPrincipals scans h's host certificate once per run while the detail
+// pane is open. Probe-averse hosts are never scanned.
+func (m *model) refreshPrincipals(h sshHost) tea.Cmd {
+	if !m.showDetail || skipsBatchProbes(h) || m.principalsPending[h.Alias] {
+		return nil
+	}
+	if _, done := m.principals[h.Alias]; done {
+		return nil
+	}
+	m.principalsPending[h.Alias] = true
+	return func() tea.Msg {
+		ps, err := fetchCertPrincipals(h)
+		return certPrincipalsMsg{alias: h.Alias, principals: ps, err: err}
+	}
+}
+
+// knownAsLine lists the other names h is known by: names sharing its host key
+// in known_hosts (under its HostKeyAlias too) and principals of its host
+// certificate.
+func (m model) knownAsLine(h sshHost) string {
+	keyName := knownHostName(knownHostsKey(h))
+	var names []string
+	add := func(n string) {
+		if n != "" && n != h.Alias && n != h.Hostname && n != keyName && !containsString(names, n) {
+			names = append(names, n)
+		}
+	}
+	for _, key := range []string{h.Alias, h.Hostname, h.IP, keyName} {
+		for _, n := range m.knownAliases[key] {
+			add(n)
+		}
+	}
+	for _, p := range m.principals[h.Alias] {
+		add(p)
+	}
+	if len(names) == 0 {
+		if m.principalsPending[h.Alias] {
+			return fmt.Sprintf("%-14s checking host certificate…", "Known as:")
+		}
+		return ""
+	}
+	return fmt.Sprintf("%-14s %s", "Known as:", strings.Join(names, ", "))
+}
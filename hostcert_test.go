@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func sshString(s []byte) []byte {
+	out := make([]byte, 4, 4+len(s))
+	binary.BigEndian.PutUint32(out, uint32(len(s)))
+	return append(out, s...)
+}
+
+func TestParseCertPrincipals(t *testing.T) {
+	t.Parallel()
+
+	var packed []byte
+	for _, p := range []string{"web1", "web1.corp.example", "10.0.0.1"} {
+		packed = append(packed, sshString([]byte(p))...)
+	}
+	var blob []byte
+	blob = append(blob, sshString([]byte("ssh-ed25519-cert-v01@openssh.com"))...)
+	blob = append(blob, sshString(make([]byte, 32))...) // nonce
+	blob = append(blob, sshString(make([]byte, 32))...) // public key
+	blob = append(blob, make([]byte, 8+4)...)           // serial, type
+	blob = append(blob, sshString([]byte("host-web1"))...)
+	blob = append(blob, sshString(packed)...)
+	blob = append(blob, make([]byte, 16)...) // validity and the rest
+
+	principals, id, err := parseCertPrincipals(blob)
+	if err != nil {
+		t.Fatalf("parseCertPrincipals: %v", err)
+	}
+	if id != "host-web1" || strings.Join(principals, ",") != "web1,web1.corp.example,10.0.0.1" {
+		t.Fatalf("got id %q principals %q", id, principals)
+	}
+	if _, _, err := parseCertPrincipals(blob[:40]); err == nil {
+		t.Fatalf("expected an error for a truncated certificate")
+	}
+	if _, _, err := parseCertPrincipals(sshString([]byte("ssh-ed25519"))); err == nil {
+		t.Fatalf("expected an error for a plain key")
+	}
+}
+
+func TestKnownAliases(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	content := `# comment
+web1,10.0.0.1 ssh-ed25519 AAAAkey1
+web1.corp.example ssh-ed25519 AAAAkey1
+[db1]:2222 ssh-ed25519 AAAAkey2
+|1|hashed= ssh-ed25519 AAAAkey2
+@cert-authority *.corp.example ssh-ed25519 AAAAca
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := loadKnownHosts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 || entries[4].Marker != "@cert-authority" || entries[2].Line != 4 {
+		t.Fatalf("entries = %#v", entries)
+	}
+	aliases := knownAliases(entries)
+	got := append([]string(nil), aliases["web1"]...)
+	sort.Strings(got)
+	if strings.Join(got, ",") != "10.0.0.1,web1.corp.example" {
+		t.Fatalf("aliases[web1] = %q", got)
+	}
+	if len(aliases["db1"]) != 0 {
+		t.Fatalf("hashed names must not become aliases: %q", aliases["db1"])
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// hostDiff compares the effective options (ssh -G) of two hosts.
+type hostDiff struct {
+	hosts  [2]sshHost
+	opts   [2]map[string]string
+	err    error
+	all    bool // show equal options too
+	offset int
+}
+
+type hostDiffMsg struct {
+	opts [2]map[string]string
+	err  error
+}
+
+// effectiveOptions runs ssh -G, which resolves the config without
+// connecting. Tag defaults are included since sshpick adds them on connect.
+func effectiveOptions(h sshHost, defaults map[string]tagDefaults) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	args := append([]string{"-G"}, tagOptionArgs(h, defaults)...)
+	cmd := exec.CommandContext(ctx, "ssh", append(args, hostArgs(h)...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", h.Alias, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", h.Alias, err)
+	}
+	return parseSSHG(string(out)), nil
+}
+
+// parseSSHG reads "key value" lines; repeated keys (identityfile,
+// localforward, ...) are joined in order.
+func parseSSHG(out string) map[string]string {
+	opts := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		if prev, seen := opts[key]; seen {
+			value = prev + ", " + value
+		}
+		opts[key] = value
+	}
+	return opts
+}
+
+// diffRow is one option in the comparison.
+type diffRow struct {
+	key   string
+	left  string
+	right string
+}
+
+func (r diffRow) differs() bool { return r.left != r.right }
+
+// diffOptions lists the options sorted by name, differing ones only unless
+// all is set.
+func diffOptions(a, b map[string]string, all bool) []diffRow {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	var rows []diffRow
+	for k := range keys {
+		r := diffRow{key: k, left: a[k], right: b[k]}
+		if all || r.differs() {
+			rows = append(rows, r)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].key < rows[j].key })
+	return rows
+}
+
+func (m model) startHostDiff(a, b sshHost) (model, tea.Cmd) {
+	m.diff = &hostDiff{hosts: [2]sshHost{a, b}}
+	defaults := m.appConfig.TagDefaults
+	return m, func() tea.Msg {
+		var msg hostDiffMsg
+		for i, h := range []sshHost{a, b} {
+			if msg.opts[i], msg.err = effectiveOptions(h, defaults); msg.err != nil {
+				break
+			}
+		}
+		return msg
+	}
+}
+
+func (m model) updateHostDiff(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	d := *m.diff
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q":
+		m.diff = nil
+		return m, nil
+	case "a":
+		d.all = !d.all
+		d.offset = 0
+	case "j", "down":
+		if d.offset+1 < len(diffOptions(d.opts[0], d.opts[1], d.all)) {
+			d.offset++
+		}
+	case "k", "up":
+		if d.offset > 0 {
+			d.offset--
+		}
+	}
+	m.diff = &d
+	return m, nil
+}
+
+func (m model) renderHostDiff(b *strings.Builder) {
+	d := m.diff
+	fmt.Fprintln(b, m.styles.title.Render(fmt.Sprintf("Effective options: %s vs %s", d.hosts[0].Alias, d.hosts[1].Alias)))
+	mode := "differences only"
+	if d.all {
+		mode = "all options"
+	}
+	fmt.Fprintln(b, m.styles.help.Render("a toggle all/differences ("+mode+") • j/k scroll • Esc close"))
+	fmt.Fprintln(b, "")
+	switch {
+	case d.err != nil:
+		fmt.Fprintln(b, m.styles.error.Render(d.err.Error()))
+		return
+	case d.opts[0] == nil:
+		fmt.Fprintln(b, m.styles.help.Render("Running ssh -G…"))
+		return
+	}
+
+	rows := diffOptions(d.opts[0], d.opts[1], d.all)
+	if len(rows) == 0 {
+		fmt.Fprintln(b, m.styles.help.Render("No differences."))
+		return
+	}
+	colWidth := 32
+	if m.width > 30 {
+		colWidth = (m.width - 26) / 2
+	}
+	changed := palette.style(statusWarn)
+	cell := lipgloss.NewStyle().Width(colWidth).MaxWidth(colWidth)
+	header := fmt.Sprintf("%-24s", "option")
+	if palette.shapes {
+		header = "  " + header
+	}
+	fmt.Fprintln(b, m.styles.title.Render(header)+cell.Render(d.hosts[0].Alias)+" "+cell.Render(d.hosts[1].Alias))
+	limit := len(rows)
+	if m.height > 8 && limit-d.offset > m.height-6 {
+		limit = d.offset + m.height - 6
+	}
+	for _, r := range rows[d.offset:limit] {
+		line := fmt.Sprintf("%-24s", r.key) + cell.Render(r.left) + " " + cell.Render(r.right)
+		if palette.shapes {
+			// Changed rows are told apart by a mark, not only their color.
+			mark := "  "
+			if r.differs() {
+				mark = statusMarks[statusWarn] + " "
+			}
+			line = mark + line
+		}
+		if r.differs() {
+			fmt.Fprintln(b, changed.Render(line))
+		} else {
+			fmt.Fprintln(b, m.styles.item.Render(line))
+		}
+	}
+}
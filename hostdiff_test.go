@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestDiffOptions(t *testing.T) {
+	t.Parallel()
+
+	a := parseSSHG("user ops\nhostname 10.0.0.1\nport 22\nidentityfile ~/.ssh/a\nidentityfile ~/.ssh/b\n")
+	b := parseSSHG("user ops\nhostname 10.0.0.2\nport 22\nidentityfile ~/.ssh/a\nproxyjump bastion\n")
+	if a["identityfile"] != "~/.ssh/a, ~/.ssh/b" {
+		t.Fatalf("repeated key: %q", a["identityfile"])
+	}
+
+	rows := diffOptions(a, b, false)
+	var keys []string
+	for _, r := range rows {
+		keys = append(keys, r.key)
+	}
+	if len(keys) != 3 || keys[0] != "hostname" || keys[1] != "identityfile" || keys[2] != "proxyjump" {
+		t.Fatalf("differences %v", keys)
+	}
+	if all := diffOptions(a, b, true); len(all) != 5 {
+		t.Fatalf("all rows %d", len(all))
+	}
+}
+
+func TestComparePickerStartsDiff(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel([]sshHost{{Alias: "a"}, {Alias: "b"}}, "", "")
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("D")})
+	m = next.(model)
+	if m.dual == nil || !m.dual.compare {
+		t.Fatal("D should open the compare picker")
+	}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if m.dual != nil || m.diff == nil || cmd == nil {
+		t.Fatalf("enter should start the diff: dual=%v diff=%v", m.dual, m.diff)
+	}
+	if m.diff.hosts[0].Alias != "a" || m.diff.hosts[1].Alias != "b" {
+		t.Fatalf("hosts %v", m.diff.hosts)
+	}
+	next, _ = m.Update(hostDiffMsg{opts: [2]map[string]string{{"user": "x"}, {"user": "y"}}})
+	if d := next.(model).diff; d.opts[1]["user"] != "y" {
+		t.Fatalf("opts not stored: %+v", d)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Host key policies for every ssh sshpick starts (settings host_key_policy).
+const (
+	hostKeyInherit   = ""           // whatever the user's ssh_config says
+	hostKeyStrict    = "strict"     // unknown keys are refused
+	hostKeyAcceptNew = "accept-new" // unknown keys are added, changed keys refused
+	hostKeyAsk       = "ask"        // the TUI shows the fingerprints before connecting
+)
+
+// hostKeyPolicy is set from the settings at startup.
+var hostKeyPolicy = hostKeyInherit
+
+// loadHostKeyPolicy applies the settings file's policy, for subcommands
+// that start ssh.
+func loadHostKeyPolicy(settingsPath string) (appConfig, error) {
+	settings, err := loadAppConfig(settingsPath)
+	if err != nil {
+		return settings, err
+	}
+	if err := validHostKeyPolicy(settings.HostKeyPolicy); err != nil {
+		return settings, err
+	}
+	hostKeyPolicy = settings.HostKeyPolicy
+	return settings, nil
+}
+
+func validHostKeyPolicy(p string) error {
+	switch p {
+	case hostKeyInherit, hostKeyStrict, hostKeyAcceptNew, hostKeyAsk:
+		return nil
+	}
+	return fmt.Errorf("host_key_policy %q: want strict, accept-new or ask", p)
+}
+
+// hostKeyArgs returns the StrictHostKeyChecking option for the policy.
+// Background probes can't prompt, so "ask" refuses unknown keys there; an
+// interactive connection that got past the TUI check leaves the last word
+// to ssh's own prompt.
+func hostKeyArgs(interactive bool) []string {
+	switch hostKeyPolicy {
+	case hostKeyStrict:
+		return []string{"-o", "StrictHostKeyChecking=yes"}
+	case hostKeyAcceptNew:
+		return []string{"-o", "StrictHostKeyChecking=accept-new"}
+	case hostKeyAsk:
+		if interactive {
+			return []string{"-o", "StrictHostKeyChecking=ask"}
+		}
+		return []string{"-o", "StrictHostKeyChecking=yes"}
+	}
+	return nil
+}
+
+// knownHostsKey is how h appears in known_hosts: HostKeyAlias if set (also
+// from Host * or Match blocks), otherwise the hostname, as [name]:port off
+// port 22. Hosts behind one load balancer share a HostKeyAlias so they
+// share its key.
+func knownHostsKey(h sshHost) string {
+	if alias := h.effectiveOption("hostkeyalias"); alias != "" {
+		return alias
+	}
+	host, port, _ := net.SplitHostPort(dialAddress(h))
+	if port != "22" {
+		return "[" + host + "]:" + port
+	}
+	return host
+}
+
+// knownHostMatches reports whether a known_hosts host field (plain,
+// wildcard or hashed) matches name.
+func knownHostMatches(pattern, name string) bool {
+	if strings.HasPrefix(pattern, "|1|") {
+		parts := strings.Split(pattern, "|")
+		if len(parts) != 4 {
+			return false
+		}
+		salt, err1 := base64.StdEncoding.DecodeString(parts[2])
+		want, err2 := base64.StdEncoding.DecodeString(parts[3])
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		mac := hmac.New(sha1.New, salt)
+		mac.Write([]byte(name))
+		return hmac.Equal(mac.Sum(nil), want)
+	}
+	ok, _ := filepath.Match(strings.ToLower(pattern), strings.ToLower(name))
+	return ok
+}
+
+// hostKeyKnown reports whether known_hosts has a usable key for name: a
+// matching entry that isn't negated or revoked.
+func hostKeyKnown(entries []knownHostEntry, name string) bool {
+	return len(knownHostKeysFor(entries, name)) > 0
+}
+
+// knownHostKeysFor returns the usable entries for name.
+func knownHostKeysFor(entries []knownHostEntry, name string) []knownHostEntry {
+	var out []knownHostEntry
+	for _, e := range entries {
+		if e.Marker == "@revoked" {
+			continue
+		}
+		matched := false
+		for _, p := range e.Hosts {
+			if strings.HasPrefix(p, "!") {
+				if knownHostMatches(p[1:], name) {
+					matched = false
+					break
+				}
+				continue
+			}
+			if knownHostMatches(p, name) {
+				matched = true
+			}
+		}
+		if matched {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// hostKeyLine is the detail pane's host key summary: the fingerprints
+// known_hosts has for h, looked up the way ssh does, in h's own
+// known_hosts files and under HostKeyAlias when one is set. The files are
+// read each time so a key trusted since startup shows up.
+func hostKeyLine(h sshHost) string {
+	name := knownHostsKey(h)
+	as := ""
+	if alias := h.effectiveOption("hostkeyalias"); alias != "" {
+		as = " (as " + alias + ")"
+	}
+	keys := knownHostKeysFor(loadHostKnownHosts(h), name)
+	if len(keys) == 0 {
+		return fmt.Sprintf("%-14s not in known_hosts%s", "Host key:", as)
+	}
+	var prints []string
+	for _, k := range keys {
+		prints = append(prints, k.KeyType+" "+k.fingerprint())
+	}
+	return fmt.Sprintf("%-14s %s%s", "Host key:", strings.Join(prints, ", "), as)
+}
+
+// scanHostKeys is a variable so tests don't need a server.
+var scanHostKeys = func(h sshHost) (string, error) {
+	host, port, _ := net.SplitHostPort(dialAddress(h))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ssh-keyscan", "-T", "3", "-p", port, host).Output()
+	return string(out), err
+}
+
+// hostKeyCheck is the "ask" policy's pre-connect check: a host whose key
+// isn't in known_hosts shows its scanned fingerprints first. Hosts behind a
+// jump or proxy can't be scanned from here and are left to ssh's prompt.
+func hostKeyCheck(m model, h sshHost) *connectPrompt {
+	if hostKeyPolicy != hostKeyAsk || h.option("proxyjump") != "" || h.option("proxycommand") != "" {
+		return nil
+	}
+	path := knownHostsWritePath(h)
+	name := knownHostsKey(h)
+	if hostKeyKnown(loadHostKnownHosts(h), name) {
+		return nil
+	}
+	p := &connectPrompt{id: "hostkey"}
+	if path == "" {
+		// UserKnownHostsFile none: ssh won't remember the key either.
+		p.message = fmt.Sprintf("%s has no known host key and UserKnownHostsFile is none.", name)
+		return p
+	}
+	scan, err := scanHostKeys(h)
+	keys := parseKnownHostLines(scan)
+	if err != nil || len(keys) == 0 {
+		p.message = fmt.Sprintf("%s has no known host key and none could be scanned.", name)
+		return p
+	}
+	var lines, prints []string
+	for _, k := range keys {
+		// Record the key under the name ssh will look up.
+		lines = append(lines, strings.Join([]string{name, k.KeyType, k.Key}, " "))
+		prints = append(prints, k.KeyType+" "+k.fingerprint())
+	}
+	p.message = fmt.Sprintf("Unknown host key for %s:\n  %s\nVerify the fingerprint before trusting it.", name, strings.Join(prints, "\n  "))
+	p.actions = append(p.actions, promptAction{
+		key:   "t",
+		label: "trust and connect",
+		cmd: func() *exec.Cmd {
+			return exec.Command("sh", "-c", `mkdir -p "$(dirname "$2")" && printf '%s\n' "$1" >> "$2"`, "sshpick", strings.Join(lines, "\n"), path)
+		},
+	})
+	return p
+}
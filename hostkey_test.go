@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func hashedName(name string) string {
+	salt := []byte("0123456789abcdefghij")
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(name))
+	return "|1|" + base64.StdEncoding.EncodeToString(salt) + "|" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestHostKeyKnown(t *testing.T) {
+	t.Parallel()
+
+	entries := parseKnownHostLines(strings.Join([]string{
+		"db1.example.com,10.0.0.5 ssh-ed25519 AAAA1",
+		hashedName("[web1.example.com]:2222") + " ssh-ed25519 AAAA2",
+		"*.lab.example.com,!evil.lab.example.com ssh-ed25519 AAAA3",
+		"@revoked old.example.com ssh-rsa AAAA4",
+	}, "\n"))
+	for name, want := range map[string]bool{
+		"db1.example.com":         true,
+		"[web1.example.com]:2222": true,
+		"web1.example.com":        false,
+		"x.lab.example.com":       true,
+		"evil.lab.example.com":    false,
+		"old.example.com":         false,
+		"new.example.com":         false,
+	} {
+		if got := hostKeyKnown(entries, name); got != want {
+			t.Errorf("hostKeyKnown(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if got := knownHostsKey(sshHost{Alias: "w", Hostname: "web1.example.com", Port: "2222"}); got != "[web1.example.com]:2222" {
+		t.Errorf("knownHostsKey = %q", got)
+	}
+}
+
+func TestHostKeyPolicyArgs(t *testing.T) {
+	orig := hostKeyPolicy
+	defer func() { hostKeyPolicy = orig }()
+
+	for policy, want := range map[string][2]string{
+		hostKeyInherit:   {"", ""},
+		hostKeyStrict:    {"StrictHostKeyChecking=yes", "StrictHostKeyChecking=yes"},
+		hostKeyAcceptNew: {"StrictHostKeyChecking=accept-new", "StrictHostKeyChecking=accept-new"},
+		hostKeyAsk:       {"StrictHostKeyChecking=ask", "StrictHostKeyChecking=yes"},
+	} {
+		hostKeyPolicy = policy
+		if got := strings.Join(hostKeyArgs(true), " "); got != strings.TrimSpace("-o "+want[0]) && !(want[0] == "" && got == "") {
+			t.Errorf("%q interactive: %q", policy, got)
+		}
+		if got := strings.Join(hostKeyArgs(false), " "); got != strings.TrimSpace("-o "+want[1]) && !(want[1] == "" && got == "") {
+			t.Errorf("%q batch: %q", policy, got)
+		}
+	}
+	hostKeyPolicy = hostKeyAcceptNew
+	if got := strings.Join(sshArgs(sshHost{Alias: "db1"}, launchOptions{}), " "); got != "-o StrictHostKeyChecking=accept-new -- db1" {
+		t.Errorf("sshArgs: %s", got)
+	}
+	if err := validHostKeyPolicy("tofu"); err == nil {
+		t.Error("invalid policy accepted")
+	}
+}
+
+func TestHostKeyCheck(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SSHPICK_SSH_DIR", dir)
+	origPolicy, origScan := hostKeyPolicy, scanHostKeys
+	defer func() { hostKeyPolicy, scanHostKeys = origPolicy, origScan }()
+	hostKeyPolicy = hostKeyAsk
+	scanHostKeys = func(sshHost) (string, error) {
+		return "10.0.0.5 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAAA\n", nil
+	}
+
+	h := sshHost{Alias: "db1", Hostname: "db1.example.com"}
+	p := hostKeyCheck(model{}, h)
+	if p == nil || len(p.actions) != 1 || !strings.Contains(p.message, "SHA256:") {
+		t.Fatalf("prompt %+v", p)
+	}
+	if out, err := p.actions[0].cmd().CombinedOutput(); err != nil {
+		t.Fatalf("trust: %v %s", err, out)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "known_hosts"))
+	if string(data) != "db1.example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAAA\n" {
+		t.Fatalf("known_hosts %q", data)
+	}
+	if p := hostKeyCheck(model{}, h); p != nil {
+		t.Fatalf("trusted host still prompts: %+v", p)
+	}
+	jumped := sshHost{Alias: "x", Options: map[string]string{"proxyjump": "bastion"}}
+	if p := hostKeyCheck(model{}, jumped); p != nil {
+		t.Fatal("hosts behind a jump are left to ssh")
+	}
+}
+
+func TestKnownHostsFileOverrides(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SSHPICK_SSH_DIR", dir)
+	origPolicy, origScan := hostKeyPolicy, scanHostKeys
+	defer func() { hostKeyPolicy, scanHostKeys = origPolicy, origScan }()
+	hostKeyPolicy = hostKeyAsk
+	scanHostKeys = func(sshHost) (string, error) {
+		return "lab1 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBBB\n", nil
+	}
+
+	global := filepath.Join(dir, "fleet_known_hosts")
+	os.WriteFile(global, []byte("db1.example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAAA\n"), 0o644)
+	// GlobalKnownHostsFile inherited from Host *, as the parser records it
+	db := sshHost{Alias: "db1", Hostname: "db1.example.com", Origins: []optionOrigin{{Key: "globalknownhostsfile", Value: global}}}
+	if files := hostKnownHostsFiles(db); files[0] != filepath.Join(dir, "known_hosts") || files[len(files)-1] != global {
+		t.Fatalf("files %v", files)
+	}
+	if p := hostKeyCheck(model{}, db); p != nil {
+		t.Fatalf("a key in the host's GlobalKnownHostsFile counts as known: %+v", p.message)
+	}
+
+	lab := sshHost{Alias: "lab1", Hostname: "lab1", Port: "2222", Options: map[string]string{"userknownhostsfile": `%d/lab_known_hosts "` + dir + `/lab_%h_%p" /tmp/%C`}}
+	files := userKnownHostsFiles(lab)
+	if len(files) != 2 || files[1] != filepath.Join(dir, "lab_lab1_2222") {
+		t.Fatalf("tokens: %v", files)
+	}
+	lab.Options["userknownhostsfile"] = filepath.Join(dir, "lab_known_hosts")
+	p := hostKeyCheck(model{}, lab)
+	if p == nil || len(p.actions) != 1 {
+		t.Fatalf("prompt %+v", p)
+	}
+	if out, err := p.actions[0].cmd().CombinedOutput(); err != nil {
+		t.Fatalf("trust: %v %s", err, out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "lab_known_hosts")); !strings.HasPrefix(string(data), "[lab1]:2222 ") {
+		t.Fatalf("the key should be recorded in the UserKnownHostsFile, got %q", data)
+	}
+	if fileExists(filepath.Join(dir, "known_hosts")) {
+		t.Fatal("the default known_hosts shouldn't be touched")
+	}
+
+	lab.Options["userknownhostsfile"] = "none"
+	if p := hostKeyCheck(model{}, lab); p == nil || len(p.actions) != 0 {
+		t.Fatalf("UserKnownHostsFile none can't record a key: %+v", p)
+	}
+}
+
+func TestHostKeyAlias(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SSHPICK_SSH_DIR", dir)
+	origPolicy := hostKeyPolicy
+	defer func() { hostKeyPolicy = origPolicy }()
+	hostKeyPolicy = hostKeyAsk
+
+	os.WriteFile(filepath.Join(dir, "known_hosts"), []byte("lb.example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAAA\n"), 0o600)
+	cfg := filepath.Join(dir, "config")
+	os.WriteFile(cfg, []byte("Host web*\n  HostKeyAlias lb.example.com\nHost web1\n  HostName 10.0.0.11\nHost web2\n  HostName 10.0.0.12\n  Port 2222\nHost db1\n"), 0o600)
+	hosts, _, err := parseSSHConfigWarnings(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := model{knownEntries: loadAllKnownHosts(hosts)}
+	for _, h := range hosts[:2] {
+		if key := knownHostsKey(h); key != "lb.example.com" {
+			t.Fatalf("%s is looked up as %q", h.Alias, key)
+		}
+		if p := hostKeyCheck(m, h); p != nil {
+			t.Fatalf("%s shares the balancer's key: %s", h.Alias, p.message)
+		}
+		if line := hostKeyLine(h); !strings.Contains(line, "SHA256:") || !strings.Contains(line, "(as lb.example.com)") {
+			t.Fatalf("detail line %q", line)
+		}
+	}
+	if line := hostKeyLine(hosts[2]); !strings.Contains(line, "not in known_hosts") {
+		t.Fatalf("db1: %q", line)
+	}
+
+	// A key recorded after startup shows up, and only in the files the
+	// host itself reads.
+	os.WriteFile(filepath.Join(dir, "known_hosts"), []byte("lb.example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAAA\ndb1 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBBB\n"), 0o600)
+	if line := hostKeyLine(hosts[2]); !strings.Contains(line, "SHA256:") {
+		t.Fatalf("db1 after trusting: %q", line)
+	}
+	own := hosts[2]
+	own.Options = map[string]string{"userknownhostsfile": filepath.Join(dir, "db1_known_hosts")}
+	if line := hostKeyLine(own); !strings.Contains(line, "not in known_hosts") {
+		t.Fatalf("db1 with its own UserKnownHostsFile: %q", line)
+	}
+	own.Options["userknownhostsfile"] = "none"
+	if line := hostKeyLine(own); !strings.Contains(line, "not in known_hosts") {
+		t.Fatalf("db1 with UserKnownHostsFile none: %q", line)
+	}
+}
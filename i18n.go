@@ -0,0 +1,183 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// englishMessages is the message catalog: every UI string that goes
+// through tr, by ID. It's the fallback for IDs a translation leaves out and
+// the template for new translations (sshpick -dump-messages).
+var englishMessages = map[string]string{
+	"title.main":               "Pick an SSH host",
+	"help.main":                "Use h/j/k/l or arrows • Space tag for batch • a actions • / filter (fuzzy) • f filter fields • e edit in $EDITOR • c edit user/port/hostname • E bulk edit • [/] move block • n notes • i details • g option sources • u who • s stats • P reachability • L toggle config forwards • M maintenance • * favorite • o console • r desktop • p sources • d scp between hosts • t transfer files • v saved views • D compare hosts • J jump dependents • F forward remote ports • w warnings • H history • K known_hosts • X fix permissions • S sort by history • G group • C compact rows • b connect fastest • paste hosts to group them • Enter connect • q quit",
+	"help.restricted":          "Use h/j/k/l or arrows • / filter (fuzzy) • f filter fields • n notes • i details • Enter connect • q quit",
+	"help.warnings":            "Esc/w close",
+	"help.bulkedit.input":      "Change: User <name> • IdentityFile <path> • Tag <tag>   (Enter preview, Esc cancel)",
+	"help.bulkedit.confirm":    "y/Enter save • Esc cancel",
+	"help.inlineedit":          "Tab next field • Enter save to config • Esc cancel",
+	"help.runprompt":           "Type the answer • Enter answer this host • Ctrl+A answer every host with this prompt • Tab/↑/↓ pick • Ctrl+C stop",
+	"help.discover":            "j/k move • Space select • Enter connect with forwards • Esc close",
+	"help.dual.compare":        "Tab switch pane • j/k move • Enter compare • Esc back",
+	"help.views":               "j/k move • Enter apply • a save the current filter • x delete • Esc back",
+	"help.transfer":            "Tab push/pull • Enter type paths • 1-9 repeat a recent transfer • s sftp session • Esc back",
+	"help.dual.copy":           "Tab switch pane • j/k move • Enter choose paths • Esc back",
+	"help.failure":             "Enter retry • v retry with -vvv and save the log • Esc dismiss • q quit",
+	"help.deps":                "j/k move • Enter go to host • Esc close",
+	"help.knownhosts":          "j/k move • / search • x remove entry • c add as config host • Esc close",
+	"help.definitions":         "j/k move • Enter open in $EDITOR • Esc close",
+	"help.actions":             "j/k move • Enter or 1-9 run • Esc cancel",
+	"help.paste":               "j/k move • Space select • a all • Enter connect (several open in tmux) • s save new hosts to config • Esc close",
+	"help.sources":             "j/k move • Space toggle • / search • Esc close",
+	"help.usage":               "Esc/H close • v heatmap • time is only known for subprocess sessions",
+	"status.warnings.title":    "Config warnings (%d)",
+	"status.forwarding":        "Forwarding: %s",
+	"status.readonly":          "Read-only mode: editing is disabled",
+	"status.warnings":          "%d config warning(s) — press w to review",
+	"status.hidden":            "Hidden sources: %s  (press p to change)",
+	"status.filter":            "Filter: %s on %s fields  (press / to edit, Backspace to clear)",
+	"status.order":             "Sorted by %s connections  (press S to change)",
+	"status.tagged":            "%d tagged  (Enter opens them together, Space untags)",
+	"status.crawling":          "Fetching more hosts: %s",
+	"status.measuring":         "Measuring latency to %d hosts…",
+	"status.knownhosts.remove": "Remove %s from %s? y/N",
+	"empty.filter":             "No hosts match current filter",
+	"empty.sources":            "No hosts in the shown sources",
+	"empty.config":             "No hosts found in %s",
+	"err.best_mirror":          "best mirror: %w",
+	"err.no_hosts_select":      "no hosts to select",
+	"err.no_hosts_measure":     "no hosts to measure",
+	"err.maintenance":          "maintenance: %w",
+	"err.no_console":           "%s has no out-of-band console annotations (ipmi, ec2-instance, libvirt, console)",
+	"err.no_desktop":           "%s has no rdp or vnc annotation",
+	"err.copy_two_hosts":       "need at least two hosts to copy between",
+	"err.compare_two_hosts":    "need at least two hosts to compare",
+	"err.no_config":            "no config file to edit",
+	"err.not_config_host":      "%s comes from %s, not the ssh config",
+	"err.crawl":                "%s: stopped fetching pages (resumes on the next start): %v",
+	"err.reload":               "reloading the ssh config: %v",
+	"err.banner_cache":         "saving the banner cache: %v",
+}
+
+// catalog is a translation: message IDs to translated strings.
+type catalog map[string]string
+
+// messages is the active translation, set at startup; nil is English.
+var messages catalog
+
+// errNoTranslation is returned for a language without a catalog. It only
+// matters when the settings ask for the language; a locale from the
+// environment quietly falls back to English.
+var errNoTranslation = errors.New("no translation")
+
+//go:embed locales
+var builtinLocales embed.FS
+
+// tr looks up a UI string in the active translation, falling back to
+// English, and formats it with args when there are any.
+func tr(id string, args ...any) string {
+	s, ok := messages[id]
+	if !ok {
+		s, ok = englishMessages[id]
+	}
+	if !ok {
+		s = id // a missing ID shows up on screen rather than as a blank
+	}
+	if len(args) == 0 {
+		return s
+	}
+	return fmt.Sprintf(s, args...)
+}
+
+// trErr is tr for errors, so %w in a message wraps like fmt.Errorf.
+func trErr(id string, args ...any) error {
+	if len(args) == 0 {
+		return errors.New(tr(id))
+	}
+	return fmt.Errorf(tr(id), args...)
+}
+
+// languageFromEnv is the language asked for by SSHPICK_LANG or the locale
+// variables, e.g. "de_DE" from LANG=de_DE.UTF-8; "" for C/POSIX.
+func languageFromEnv() string {
+	for _, name := range []string{"SSHPICK_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		v, _, _ = strings.Cut(v, ".")
+		v, _, _ = strings.Cut(v, "@")
+		if v == "C" || v == "POSIX" {
+			return ""
+		}
+		return v
+	}
+	return ""
+}
+
+// loadCatalog finds the translation for lang: <config dir>/locales/<lang>.json
+// first, so a team can add one without rebuilding, then the ones built into
+// the binary. "de_DE" falls back to "de". English, or "", is nil.
+func loadCatalog(lang string) (catalog, error) {
+	base, _, regional := strings.Cut(lang, "_")
+	if base == "" || base == "en" {
+		return nil, nil
+	}
+	candidates := []string{base}
+	if regional {
+		candidates = []string{lang, base}
+	}
+	for _, l := range candidates {
+		if dir := configDir(); dir != "" {
+			data, err := os.ReadFile(filepath.Join(dir, "locales", l+".json"))
+			if err == nil {
+				return parseCatalog(l, data)
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+		if data, err := builtinLocales.ReadFile("locales/" + l + ".json"); err == nil {
+			return parseCatalog(l, data)
+		}
+	}
+	return nil, fmt.Errorf("%w for %q", errNoTranslation, lang)
+}
+
+// parseCatalog reads a translation file, a JSON object of message IDs to
+// strings. IDs the English catalog doesn't have are an error, so typos and
+// stale entries are caught instead of silently showing English.
+func parseCatalog(lang string, data []byte) (catalog, error) {
+	var c catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("translation %s: %w", lang, err)
+	}
+	var unknown []string
+	for id := range c {
+		if _, ok := englishMessages[id]; !ok {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("translation %s: unknown message IDs %s", lang, strings.Join(unknown, ", "))
+	}
+	return c, nil
+}
+
+// dumpMessages writes the English catalog as JSON, the starting point for a
+// new translation.
+func dumpMessages(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(englishMessages)
+}
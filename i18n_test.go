@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestBuiltinTranslations checks every shipped translation loads and keeps
+// the English format verbs, in order, so tr never misformats a message.
+func TestBuiltinTranslations(t *testing.T) {
+	t.Parallel()
+	verbs := regexp.MustCompile(`%[a-z]`)
+	entries, err := fs.ReadDir(builtinLocales, "locales")
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("no built-in translations: %v", err)
+	}
+	for _, e := range entries {
+		data, _ := builtinLocales.ReadFile("locales/" + e.Name())
+		c, err := parseCatalog(e.Name(), data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, s := range c {
+			want := strings.Join(verbs.FindAllString(englishMessages[id], -1), " ")
+			if got := strings.Join(verbs.FindAllString(s, -1), " "); got != want {
+				t.Errorf("%s %s: verbs %q, English has %q", e.Name(), id, got, want)
+			}
+		}
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	defer func(c catalog) { messages = c }(messages)
+
+	c, err := loadCatalog("de_DE")
+	if err != nil || c["title.main"] == "" {
+		t.Fatalf("de_DE should fall back to the built-in de: %v %v", c, err)
+	}
+	if c, err := loadCatalog("en_US"); c != nil || err != nil {
+		t.Fatalf("English needs no catalog: %v %v", c, err)
+	}
+	if _, err := loadCatalog("xx"); !errors.Is(err, errNoTranslation) {
+		t.Fatalf("missing language: %v", err)
+	}
+
+	// a file in the config dir wins over the built-in one, and missing IDs
+	// fall back to English
+	os.MkdirAll(filepath.Join(dir, "sshpick", "locales"), 0o755)
+	os.WriteFile(filepath.Join(dir, "sshpick", "locales", "de.json"), []byte(`{"empty.config": "Nix in %s"}`), 0o644)
+	if messages, err = loadCatalog("de"); err != nil {
+		t.Fatal(err)
+	}
+	if got := tr("empty.config", "~/.ssh/config"); got != "Nix in ~/.ssh/config" {
+		t.Fatalf("translated %q", got)
+	}
+	if got := tr("empty.filter"); got != englishMessages["empty.filter"] {
+		t.Fatalf("untranslated IDs should be English, got %q", got)
+	}
+	if err := trErr("err.maintenance", fs.ErrNotExist); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("%%w should wrap: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "sshpick", "locales", "fr.json"), []byte(`{"titel.main": "Choisir"}`), 0o644)
+	if _, err := loadCatalog("fr"); err == nil || !strings.Contains(err.Error(), "titel.main") {
+		t.Fatalf("unknown IDs should be reported: %v", err)
+	}
+}
+
+func TestLanguageFromEnv(t *testing.T) {
+	for _, name := range []string{"SSHPICK_LANG", "LC_ALL", "LC_MESSAGES"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("LANG", "pt_BR.UTF-8")
+	if got := languageFromEnv(); got != "pt_BR" {
+		t.Fatalf("LANG: %q", got)
+	}
+	t.Setenv("LC_ALL", "C")
+	if got := languageFromEnv(); got != "" {
+		t.Fatalf("C locale: %q", got)
+	}
+	t.Setenv("SSHPICK_LANG", "de")
+	if got := languageFromEnv(); got != "de" {
+		t.Fatalf("SSHPICK_LANG should win: %q", got)
+	}
+}
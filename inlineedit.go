@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// inlineFields are the fields the c key edits in place, as bulkOp kinds,
+// and their labels.
+var inlineFields = [...]struct{ kind, label string }{
+	{"user", "User"},
+	{"port", "Port"},
+	{"hostname", "HostName"},
+}
+
+// inlineEdit is the c key: the highlighted host's User, Port and HostName
+// as small inputs under its row, saved into its Host block on Enter.
+type inlineEdit struct {
+	host   sshHost
+	field  int
+	values [len(inlineFields)]string
+	orig   [len(inlineFields)]string
+	err    error
+}
+
+func (m model) openInlineEdit() model {
+	if m.readOnly {
+		m.err = errReadOnly
+		return m
+	}
+	if m.cursor >= len(m.hosts) {
+		return m
+	}
+	h := m.hosts[m.cursor]
+	if h.SourceLine == 0 {
+		m.err = fmt.Errorf("%s is not from an ssh config; nothing to edit", h.Alias)
+		return m
+	}
+	m.err = nil
+	e := &inlineEdit{host: h}
+	e.orig = [len(inlineFields)]string{h.User, h.Port, h.Hostname}
+	e.values = e.orig
+	m.inline = e
+	return m
+}
+
+// ops are the edits of the fields changed, checked.
+func (e *inlineEdit) ops() ([]bulkOp, error) {
+	var ops []bulkOp
+	for i, f := range inlineFields {
+		v := strings.TrimSpace(e.values[i])
+		if v == e.orig[i] {
+			continue
+		}
+		if v == "" {
+			return nil, fmt.Errorf("%s can't be empty", f.label)
+		}
+		if f.kind == "port" {
+			if n, err := strconv.Atoi(v); err != nil || n < 1 || n > 65535 {
+				return nil, fmt.Errorf("port %q: want 1-65535", v)
+			}
+		}
+		op := bulkOp{kind: f.kind, value: v}
+		if err := op.check(); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.label, err)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// saveInlineEdit writes the changed fields into the host's block and
+// reloads the config, which also resolves a new HostName.
+func (m model) saveInlineEdit() (model, tea.Cmd) {
+	e := *m.inline
+	ops, err := e.ops()
+	if err == nil && len(ops) == 0 {
+		m.inline = nil
+		return m, nil
+	}
+	changed := 0
+	for _, op := range ops {
+		var plan *bulkPlan
+		if plan, err = planBulkEdit([]sshHost{e.host}, op); err != nil {
+			break
+		}
+		if err = plan.save(); err != nil {
+			break
+		}
+		changed += len(plan.changes)
+	}
+	if err != nil {
+		e.err = err
+		m.inline = &e
+		if changed == 0 {
+			return m, nil
+		}
+		// earlier fields were saved: show them
+		return m.reloadConfig()
+	}
+	m.inline = nil
+	m, cmd := m.reloadConfig()
+	if m.err == nil {
+		m.err = fmt.Errorf("updated %s: %d lines in %s", e.host.Alias, changed, tildePath(e.host.SourcePath))
+	}
+	return m, cmd
+}
+
+func (m model) updateInlineEdit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	e := *m.inline
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.inline = nil
+		return m, nil
+	case "enter":
+		return m.saveInlineEdit()
+	case "tab", "down":
+		e.field = (e.field + 1) % len(inlineFields)
+	case "shift+tab", "up":
+		e.field = (e.field - 1 + len(inlineFields)) % len(inlineFields)
+	case "ctrl+u":
+		e.values[e.field] = ""
+	case "backspace":
+		if v := e.values[e.field]; v != "" {
+			_, n := utf8.DecodeLastRuneInString(v)
+			e.values[e.field] = v[:len(v)-n]
+		}
+	default:
+		// Each field is a single ssh_config argument, so spaces, control
+		// characters and pastes (which may carry whole lines) are dropped.
+		if msg.Type != tea.KeyRunes || msg.Paste {
+			break
+		}
+		for _, r := range msg.Runes {
+			if unicode.IsPrint(r) && r != ' ' && len(e.values[e.field]) < 255 {
+				e.values[e.field] += string(r)
+			}
+		}
+	}
+	e.err = nil
+	m.inline = &e
+	return m, nil
+}
+
+// renderInlineEdit draws the inputs under the highlighted row.
+func (m model) renderInlineEdit(b *strings.Builder) {
+	e := m.inline
+	parts := make([]string, len(inlineFields))
+	for i, f := range inlineFields {
+		if i == e.field {
+			parts[i] = m.styles.selected.Render(f.label + ": [" + e.values[i] + "▏]")
+		} else {
+			parts[i] = m.styles.item.Render(f.label + ": [" + e.values[i] + "]")
+		}
+	}
+	fmt.Fprintln(b, "    "+strings.Join(parts, "  "))
+	fmt.Fprintln(b, m.styles.help.Render("    "+tr("help.inlineedit")))
+	if e.err != nil {
+		fmt.Fprintln(b, m.styles.error.Render("    "+e.err.Error()))
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func typeKeys(m model, keys ...string) model {
+	for _, k := range keys {
+		var msg tea.KeyMsg
+		switch k {
+		case "tab", "enter", "esc", "backspace", "ctrl+u":
+			msg = tea.KeyMsg{Type: map[string]tea.KeyType{"tab": tea.KeyTab, "enter": tea.KeyEnter, "esc": tea.KeyEsc, "backspace": tea.KeyBackspace, "ctrl+u": tea.KeyCtrlU}[k]}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		}
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	return m
+}
+
+func TestInlineEdit(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(cfg, []byte("Host db1\n  HostName 10.0.0.1\n  User root\n\nHost web\n    HostName web.example.com\n"), 0o600)
+	hosts, _, err := parseSSHConfigWarnings(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(hosts, "", cfg)
+	m.ready = true
+	m.cursor = 1 // web
+
+	m = typeKeys(m, "c")
+	if m.inline == nil || m.inline.values[2] != "web.example.com" {
+		t.Fatalf("c should open the editor prefilled, got %+v", m.inline)
+	}
+	if !strings.Contains(m.View(), "HostName: [web.example.com]") {
+		t.Fatalf("inputs should render under the row:\n%s", m.View())
+	}
+	m = typeKeys(m, "ctrl+u", "deploy", "tab", "2", "2", "2", "2", "enter")
+	if m.inline != nil {
+		t.Fatalf("enter should save and close, err %v", m.inline.err)
+	}
+	data, _ := os.ReadFile(cfg)
+	want := "Host web\n    Port 2222\n    User deploy\n    HostName web.example.com\n"
+	if !strings.HasSuffix(string(data), want) || !strings.HasPrefix(string(data), "Host db1\n  HostName 10.0.0.1\n  User root\n") {
+		t.Fatalf("config after edit:\n%s", data)
+	}
+	if h := m.hosts[m.cursor]; h.Alias != "web" || h.User != "deploy" || h.Port != "2222" {
+		t.Fatalf("list should show the edit, got %+v", h)
+	}
+
+	// an existing directive is replaced in place
+	m.cursor = 0
+	m = typeKeys(m, "c", "tab", "tab", "backspace", "9", "enter")
+	data, _ = os.ReadFile(cfg)
+	if !strings.HasPrefix(string(data), "Host db1\n  HostName 10.0.0.9\n  User root\n") {
+		t.Fatalf("config after edit:\n%s", data)
+	}
+}
+
+func TestInlineEditRejects(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(cfg, []byte("Host web\n  User root\n"), 0o600)
+	hosts, _, err := parseSSHConfigWarnings(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(append(hosts, sshHost{Alias: "inv1", Source: "inventory"}), "", cfg)
+
+	m = typeKeys(m, "c", "tab", "x", "enter")
+	if m.inline == nil || m.inline.err == nil {
+		t.Fatal("a non-numeric port should be refused")
+	}
+	m = typeKeys(m, "esc")
+	if data, _ := os.ReadFile(cfg); string(data) != "Host web\n  User root\n" {
+		t.Fatalf("a refused edit must not write:\n%s", data)
+	}
+
+	// A paste can't smuggle a second directive in, and spaces are dropped.
+	m = typeKeys(m, "c", "ctrl+u")
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("deploy\n  ProxyCommand touch /tmp/pwn #"), Paste: true})
+	m = typeKeys(next.(model), "de ploy\t")
+	if got := m.inline.values[0]; got != "deploy" {
+		t.Fatalf("user input %q", got)
+	}
+	for _, v := range []string{"a#b", "a,b", "a b", "a\nb", "a\x1bb"} {
+		m.inline.values[0] = v
+		if m = typeKeys(m, "enter"); m.inline == nil || m.inline.err == nil {
+			t.Fatalf("user %q should be refused", v)
+		}
+	}
+	m = typeKeys(m, "esc")
+	if data, _ := os.ReadFile(cfg); string(data) != "Host web\n  User root\n" {
+		t.Fatalf("a refused edit must not write:\n%s", data)
+	}
+
+	m.cursor = 1
+	if m = typeKeys(m, "c"); m.inline != nil || m.err == nil {
+		t.Fatal("hosts not from the config can't be edited")
+	}
+
+	m.cursor, m.readOnly = 0, true
+	if m = typeKeys(m, "c"); m.inline != nil || m.err != errReadOnly {
+		t.Fatalf("read-only mode should refuse, got %v", m.err)
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// inventoryConfig is one HTTP inventory in the settings file.
+type inventoryConfig struct {
+	Name      string `json:"name,omitempty"`       // source name shown in the UI (default "inventory")
+	URL       string `json:"url"`                  // HTTPS URL of the JSON inventory
+	Token     string `json:"token,omitempty"`      // bearer token, or a keychain:/age: reference
+	TokenEnv  string `json:"token_env,omitempty"`  // environment variable holding the bearer token
+	PublicKey string `json:"public_key,omitempty"` // base64 ed25519 key; when set, <url>.sig must verify
+
+	// ProxyCommand is a template (or preset: ssm, iap, cloudflared) for how
+	// to reach the inventory's hosts; see expandProxyCommand.
+	ProxyCommand string `json:"proxy_command,omitempty"`
+
+	// Proxy is how to reach URL: a socks5:// or http:// proxy, or ssh:<alias>
+	// for a SOCKS tunnel through a bastion. Defaults to provider_proxy.
+	Proxy string `json:"proxy,omitempty"`
+
+	// AllowHTTP sends the token to a plain http:// URL that isn't on this
+	// machine, which is otherwise refused.
+	AllowHTTP bool `json:"allow_http,omitempty"`
+
+	// RateLimit caps the pages per second a paged inventory is fetched at
+	// (default defaultPageRate).
+	RateLimit float64 `json:"rate_limit,omitempty"`
+}
+
+// inventoryFile is the documented host inventory format:
+//
+//	{"hosts": [{"alias": "db1", "hostname": "10.0.0.5", "user": "postgres",
+//	            "port": "22", "tags": ["prod", "db"], "notes": ["primary"],
+//	            "attrs": {"zone": "europe-west1-b"}}]}
+//
+// attrs become annotations, as if written in a "# sshpick:" comment.
+type inventoryFile struct {
+	Hosts []inventoryHost `json:"hosts"`
+	Next  string          `json:"next,omitempty"` // link to the next page, if paged; see inventoryCrawl
+}
+
+type inventoryHost struct {
+	Alias        string            `json:"alias"`
+	Hostname     string            `json:"hostname,omitempty"`
+	User         string            `json:"user,omitempty"`
+	Port         string            `json:"port,omitempty"`
+	ProxyJump    string            `json:"proxy_jump,omitempty"`
+	IdentityFile string            `json:"identity_file,omitempty"`
+	Tags         []string          `json:"tags,omitempty"`
+	Notes        []string          `json:"notes,omitempty"`
+	Attrs        map[string]string `json:"attrs,omitempty"`
+}
+
+// inventoryCache is what's stored on disk between fetches.
+type inventoryCache struct {
+	ETag      string `json:"etag,omitempty"`
+	Body      []byte `json:"body"`
+	Signature []byte `json:"signature,omitempty"`
+}
+
+func (ic inventoryConfig) sourceName() string {
+	if ic.Name != "" {
+		return ic.Name
+	}
+	return "inventory"
+}
+
+// token returns the bearer token, resolving keychain:/age: references.
+func (ic inventoryConfig) token() (string, error) {
+	if ic.TokenEnv != "" {
+		if t := os.Getenv(ic.TokenEnv); t != "" {
+			return t, nil
+		}
+	}
+	t, err := resolveSecret(ic.Token)
+	if err != nil {
+		return "", fmt.Errorf("token: %w", err)
+	}
+	return t, nil
+}
+
+func cacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "sshpick")
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "sshpick")
+	}
+	return ""
+}
+
+func inventoryCachePath(url string) string {
+	dir := cacheDir()
+	if dir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, "inventory-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadInventoryHosts fetches an inventory, revalidating the cached copy with
+// its ETag. If the server is unreachable the cached copy is used and a
+// warning is returned alongside the hosts.
+func loadInventoryHosts(ic inventoryConfig) (hosts []sshHost, warning string, err error) {
+	cachePath := inventoryCachePath(ic.URL)
+	cached := readInventoryCache(cachePath)
+
+	fresh, err := fetchInventory(ic, cached)
+	if err != nil {
+		if cached == nil {
+			return nil, "", fmt.Errorf("%s: %w", ic.sourceName(), err)
+		}
+		warning = fmt.Sprintf("%s: using cached copy: %v", ic.sourceName(), err)
+		fresh = cached
+	}
+	if ic.PublicKey != "" {
+		if err := verifyInventory(ic.PublicKey, fresh.Body, fresh.Signature); err != nil {
+			return nil, warning, fmt.Errorf("%s: %w", ic.sourceName(), err)
+		}
+	}
+	inv, err := parseInventory(fresh.Body)
+	if err != nil {
+		return nil, warning, fmt.Errorf("%s: %w", ic.sourceName(), err)
+	}
+	if fresh != cached {
+		writeInventoryCache(cachePath, fresh)
+	}
+	if inv.Next != "" {
+		more, err := startInventoryCrawl(ic, inv.Next)
+		if err != nil {
+			return nil, warning, fmt.Errorf("%s: %w", ic.sourceName(), err)
+		}
+		inv.Hosts = append(inv.Hosts, more...)
+	} else {
+		os.Remove(inventoryCrawlPath(ic.URL))
+	}
+	hosts = inventoryToHosts(inv, ic.sourceName(), ic.URL)
+	if ic.ProxyCommand != "" {
+		if err := applyProxyTemplate(hosts, ic.ProxyCommand); err != nil {
+			return nil, warning, fmt.Errorf("%s: %w", ic.sourceName(), err)
+		}
+	}
+	return hosts, warning, nil
+}
+
+// inventoryClient makes an inventory's requests: its token, its proxy.
+type inventoryClient struct {
+	token     string
+	allowHTTP bool
+	client    *http.Client
+}
+
+func newInventoryClient(ic inventoryConfig) (*inventoryClient, error) {
+	token, err := ic.token()
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := parseProviderProxy(ic.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := proxy.transport()
+	if err != nil {
+		return nil, err
+	}
+	return &inventoryClient{token: token, allowHTTP: ic.AllowHTTP, client: &http.Client{Timeout: 10 * time.Second, Transport: transport}}, nil
+}
+
+// tokenSafe reports whether a bearer token may be sent to rawURL: over
+// https, or over http only to this machine.
+func tokenSafe(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Scheme, "https") {
+		return true
+	}
+	if host := u.Hostname(); strings.EqualFold(host, "localhost") {
+		return true
+	} else if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	return false
+}
+
+func (c *inventoryClient) get(url, etag string) (*http.Response, error) {
+	if c.token != "" && !c.allowHTTP && !tokenSafe(url) {
+		return nil, fmt.Errorf("not sending the token to %s over plain http (set allow_http to allow it)", url)
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	req.Header.Set("Accept", "application/json, application/yaml;q=0.9")
+	return c.client.Do(req)
+}
+
+func fetchInventory(ic inventoryConfig, cached *inventoryCache) (*inventoryCache, error) {
+	client, err := newInventoryClient(ic)
+	if err != nil {
+		return nil, err
+	}
+	get := client.get
+
+	etag := ""
+	if cached != nil {
+		etag = cached.ETag
+	}
+	resp, err := get(ic.URL, etag)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", ic.URL, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return nil, err
+	}
+	out := &inventoryCache{ETag: resp.Header.Get("ETag"), Body: body}
+	if ic.PublicKey != "" {
+		sigResp, err := get(ic.URL+".sig", "")
+		if err != nil {
+			return nil, err
+		}
+		defer sigResp.Body.Close()
+		if sigResp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s.sig: %s", ic.URL, sigResp.Status)
+		}
+		if out.Signature, err = io.ReadAll(io.LimitReader(sigResp.Body, 4096)); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// verifyInventory checks a detached base64 ed25519 signature over body.
+func verifyInventory(pubKey string, body, sig []byte) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(pubKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("public_key must be a base64 ed25519 public key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return errors.New("signature is not valid base64")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), body, raw) {
+		return errors.New("inventory signature does not verify")
+	}
+	return nil
+}
+
+func readInventoryCache(path string) *inventoryCache {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var c inventoryCache
+	if json.Unmarshal(data, &c) != nil || len(c.Body) == 0 {
+		return nil
+	}
+	return &c
+}
+
+func writeInventoryCache(path string, c *inventoryCache) {
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return
+	}
+	if data, err := json.Marshal(c); err == nil {
+		os.WriteFile(path, data, 0o600)
+	}
+}
+
+// argvSafe reports whether the entry's alias, hostname and user can only
+// ever reach ssh as the destination, with the same rules as ssh:// URLs:
+// a hostname or user of "-oProxyCommand=..." would otherwise be read as an
+// option.
+func (e inventoryHost) argvSafe() bool {
+	if strings.HasPrefix(e.Alias, "-") || strings.ContainsFunc(e.Alias, unicode.IsSpace) {
+		return false
+	}
+	if e.Hostname != "" && !urlHostname.MatchString(e.Hostname) && net.ParseIP(e.Hostname) == nil {
+		return false
+	}
+	return e.User == "" || urlUser.MatchString(e.User)
+}
+
+// inventoryToHosts converts inventory entries; tags become a tags= annotation
+// so they behave like tags from the ssh config. Entries that fail argvSafe
+// are dropped, like those without an alias.
+func inventoryToHosts(inv inventoryFile, source, origin string) []sshHost {
+	hosts := make([]sshHost, 0, len(inv.Hosts))
+	for _, e := range inv.Hosts {
+		if e.Alias == "" || !e.argvSafe() {
+			continue
+		}
+		h := sshHost{
+			Alias:      e.Alias,
+			Hostname:   e.Hostname,
+			User:       e.User,
+			Port:       e.Port,
+			Notes:      e.Notes,
+			SourcePath: origin,
+			Source:     source,
+		}
+		if e.IdentityFile != "" {
+			h.IdentityFiles = []string{expandHome(e.IdentityFile)}
+		}
+		if e.ProxyJump != "" {
+			h.Options = map[string]string{"proxyjump": e.ProxyJump}
+		}
+		for k, v := range e.Attrs {
+			if h.Annotations == nil {
+				h.Annotations = map[string]string{}
+			}
+			h.Annotations[strings.ToLower(k)] = v
+		}
+		if len(e.Tags) > 0 {
+			if h.Annotations == nil {
+				h.Annotations = map[string]string{}
+			}
+			h.Annotations["tags"] = strings.Join(e.Tags, ",")
+		}
+		h.IP = literalIP(h.Hostname)
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testInventory = `{"hosts": [
+  {"alias": "db1", "hostname": "10.0.0.5", "user": "postgres", "port": "2222", "proxy_jump": "bastion", "tags": ["prod", "db"]},
+  {"hostname": "no-alias.invalid"}
+]}`
+
+func TestLoadInventoryHosts_ETagCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	requests, notModified := 0, 0
+	online := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !online {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		requests++
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "no token", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testInventory))
+	}))
+	defer srv.Close()
+
+	t.Setenv("TEAM_TOKEN", "s3cret")
+	ic := inventoryConfig{Name: "team", URL: srv.URL, TokenEnv: "TEAM_TOKEN"}
+
+	for i := 0; i < 2; i++ {
+		hosts, warning, err := loadInventoryHosts(ic)
+		if err != nil || warning != "" {
+			t.Fatalf("fetch %d: err=%v warning=%q", i, err, warning)
+		}
+		if len(hosts) != 1 {
+			t.Fatalf("fetch %d: expected 1 host, got %#v", i, hosts)
+		}
+		h := hosts[0]
+		if h.Source != "team" || h.User != "postgres" || h.option("proxyjump") != "bastion" || !h.hasTag("db") {
+			t.Fatalf("unexpected host %#v", h)
+		}
+	}
+	if requests != 2 || notModified != 1 {
+		t.Fatalf("expected a 304 revalidation, got %d requests / %d not modified", requests, notModified)
+	}
+
+	online = false
+	hosts, warning, err := loadInventoryHosts(ic)
+	if err != nil || warning == "" || len(hosts) != 1 {
+		t.Fatalf("offline: hosts=%d warning=%q err=%v", len(hosts), warning, err)
+	}
+}
+
+func TestLoadInventoryHosts_Signature(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(testInventory)))
+	body := testInventory
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hosts.json.sig" {
+			w.Write([]byte(sig + "\n"))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	ic := inventoryConfig{URL: srv.URL + "/hosts.json", PublicKey: base64.StdEncoding.EncodeToString(pub)}
+	if _, _, err := loadInventoryHosts(ic); err != nil {
+		t.Fatalf("signed inventory rejected: %v", err)
+	}
+
+	body = `{"hosts": [{"alias": "evil", "hostname": "203.0.113.9"}]}`
+	if _, _, err := loadInventoryHosts(ic); err == nil {
+		t.Fatalf("tampered inventory accepted")
+	}
+}
+
+func TestLoadInventoryHosts_YAMLAndPlainHTTPToken(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hosts:\n  - alias: db1\n    hostname: 10.0.0.5\n    tags: [prod]\n"))
+	}))
+	defer srv.Close()
+
+	// A loopback server may get the token over http.
+	ic := inventoryConfig{Name: "team", URL: srv.URL + "/hosts.yaml", Token: "s3cret"}
+	hosts, _, err := loadInventoryHosts(ic)
+	if err != nil || len(hosts) != 1 || hosts[0].Hostname != "10.0.0.5" || !hosts[0].hasTag("prod") {
+		t.Fatalf("YAML inventory: %+v %v", hosts, err)
+	}
+
+	remote := inventoryConfig{Name: "team", URL: "http://inv.example/hosts.json", Token: "s3cret"}
+	if _, _, err := loadInventoryHosts(remote); err == nil || !strings.Contains(err.Error(), "allow_http") {
+		t.Fatalf("token sent over plain http: %v", err)
+	}
+	for raw, want := range map[string]bool{
+		"https://inv.example/h.json": true,
+		"http://127.0.0.1:8080/h":    true,
+		"http://[::1]/h":             true,
+		"http://localhost/h":         true,
+		"http://inv.example/h.json":  false,
+		"HTTP://10.0.0.1/h":          false,
+		"ftp://inv.example/h.json":   false,
+	} {
+		if got := tokenSafe(raw); got != want {
+			t.Errorf("tokenSafe(%q) = %v", raw, got)
+		}
+	}
+}
+
+func TestHostArgs_NonConfigHost(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Alias: "db1", Hostname: "10.0.0.5", User: "postgres", Port: "2222",
+		Options: map[string]string{"proxyjump": "bastion"}, Source: "team"}
+	got := hostArgs(h)
+	want := []string{"-p", "2222", "-J", "bastion", "--", "postgres@10.0.0.5"}
+	if len(got) != len(want) {
+		t.Fatalf("hostArgs = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("hostArgs = %q, want %q", got, want)
+		}
+	}
+	if got := hostArgs(sshHost{Alias: "web", Hostname: "10.0.0.1", Port: "22"}); len(got) != 2 || got[1] != "web" {
+		t.Fatalf("config hosts should connect by alias, got %q", got)
+	}
+}
+
+func TestInventoryToHostsDropsOptionLikeFields(t *testing.T) {
+	t.Parallel()
+
+	inv := inventoryFile{Hosts: []inventoryHost{
+		{Alias: "ok", Hostname: "10.0.0.5", User: "deploy"},
+		{Alias: "v6", Hostname: "::1"},
+		{Alias: "evil-host", Hostname: "-oProxyCommand=touch /tmp/pwn"},
+		{Alias: "evil-user", Hostname: "10.0.0.6", User: "-oProxyCommand=touch"},
+		{Alias: "-oProxyCommand=touch"},
+		{Alias: "spaced", Hostname: "a b"},
+	}}
+	var got []string
+	for _, h := range inventoryToHosts(inv, "team", "https://inv.example") {
+		got = append(got, h.Alias)
+	}
+	if strings.Join(got, " ") != "ok v6" {
+		t.Fatalf("kept %q", got)
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Paged inventories. A cloud account with thousands of instances answers
+// with one page and a "next" link; sshpick shows the first page (plus what
+// earlier runs fetched) at once and streams the rest into the list. The
+// cursor is saved after every page, so quitting halfway resumes there.
+
+const (
+	// defaultPageRate is how many pages per second a crawl requests unless
+	// the inventory sets rate_limit.
+	defaultPageRate = 2
+	// maxRateLimitRetries is how often a 429 or 503 page is retried before
+	// the crawl stops (to resume on the next start).
+	maxRateLimitRetries = 3
+	// maxRetryAfter is the longest Retry-After sshpick waits out.
+	maxRetryAfter = time.Minute
+)
+
+// inventoryCrawl is a paged inventory's progress, kept next to its cache.
+type inventoryCrawl struct {
+	Next  string          `json:"next,omitempty"`  // page to fetch next; "" once the last one is in
+	Hosts []inventoryHost `json:"hosts"`           // hosts of the pages after the first fetched so far
+	Stale []inventoryHost `json:"stale,omitempty"` // the previous complete crawl's, shown until this one ends
+}
+
+func inventoryCrawlPath(rawURL string) string {
+	path := inventoryCachePath(rawURL)
+	if path == "" {
+		return ""
+	}
+	return strings.TrimSuffix(path, ".json") + ".crawl.json"
+}
+
+func readInventoryCrawl(path string) *inventoryCrawl {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var c inventoryCrawl
+	if json.Unmarshal(data, &c) != nil {
+		return nil
+	}
+	return &c
+}
+
+func writeInventoryCrawl(path string, c *inventoryCrawl) {
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return
+	}
+	if data, err := json.Marshal(c); err == nil {
+		os.WriteFile(path, data, 0o600)
+	}
+}
+
+// nextPageURL resolves a page's "next" link against the page's URL, so
+// it may be absolute, a path, or just "?cursor=…".
+func nextPageURL(page, next string) (string, error) {
+	base, err := url.Parse(page)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", fmt.Errorf("next %q: %w", next, err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// startInventoryCrawl records that the inventory at pageURL continues at
+// next and returns the hosts already known beyond the first page: those
+// of an unfinished crawl, which resumes, or of the last complete one,
+// which is shown while a new crawl replaces it.
+func startInventoryCrawl(ic inventoryConfig, next string) ([]inventoryHost, error) {
+	path := inventoryCrawlPath(ic.URL)
+	next, err := nextPageURL(ic.URL, next)
+	if err != nil {
+		return nil, err
+	}
+	crawl := readInventoryCrawl(path)
+	switch {
+	case crawl == nil:
+		crawl = &inventoryCrawl{Next: next}
+	case crawl.Next == "":
+		crawl = &inventoryCrawl{Next: next, Stale: crawl.Hosts}
+	}
+	writeInventoryCrawl(path, crawl)
+	return append(append([]inventoryHost(nil), crawl.Hosts...), crawl.Stale...), nil
+}
+
+// getRateLimited is get, waiting out 429 and 503 answers as their
+// Retry-After asks (or with backoff), a few times.
+func (c *inventoryClient) getRateLimited(url, etag string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.get(url, etag)
+		if err != nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+			return resp, err
+		}
+		resp.Body.Close()
+		wait, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			wait = time.Second << attempt
+		}
+		if attempt == maxRateLimitRetries || wait > maxRetryAfter {
+			return nil, fmt.Errorf("GET %s: %s (retry after %s)", url, resp.Status, wait.Round(time.Second))
+		}
+		time.Sleep(wait)
+	}
+}
+
+// retryAfter reads a Retry-After header: seconds or an HTTP date.
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// inventoryCrawler fetches a paged inventory's remaining pages one at a
+// time.
+type inventoryCrawler struct {
+	ic    inventoryConfig
+	path  string
+	crawl inventoryCrawl
+	first []inventoryHost // the first page, which loadInventoryHosts fetched
+	last  time.Time       // when the previous page was requested
+	shown int             // hosts listed so far; only the picker touches it
+}
+
+// crawler returns the crawler for ic's unfetched pages, or nil when ic isn't
+// paged or has no pages left.
+func (ic inventoryConfig) crawler() *inventoryCrawler {
+	path := inventoryCrawlPath(ic.URL)
+	crawl := readInventoryCrawl(path)
+	cached := readInventoryCache(inventoryCachePath(ic.URL))
+	if crawl == nil || crawl.Next == "" || cached == nil {
+		return nil
+	}
+	inv, err := parseInventory(cached.Body)
+	if err != nil {
+		return nil
+	}
+	return &inventoryCrawler{ic: ic, path: path, crawl: *crawl, first: inv.Hosts, shown: len(inv.Hosts) + len(crawl.Hosts)}
+}
+
+func (c *inventoryCrawler) source() string { return c.ic.sourceName() }
+
+func (c *inventoryCrawler) done() bool { return c.crawl.Next == "" }
+
+// next fetches the next page, at most rate_limit pages a second, and saves
+// the cursor past it.
+func (c *inventoryCrawler) next() ([]inventoryHost, error) {
+	rate := c.ic.RateLimit
+	if rate <= 0 {
+		rate = defaultPageRate
+	}
+	if wait := time.Until(c.last.Add(time.Duration(float64(time.Second) / rate))); wait > 0 {
+		time.Sleep(wait)
+	}
+	c.last = time.Now()
+
+	client, err := newInventoryClient(c.ic)
+	if err != nil {
+		return nil, err
+	}
+	pageURL := c.crawl.Next
+	body, err := fetchInventoryPage(client, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if c.ic.PublicKey != "" {
+		sig, err := fetchInventoryPage(client, pageURL+".sig")
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyInventory(c.ic.PublicKey, body, sig); err != nil {
+			return nil, err
+		}
+	}
+	inv, err := parseInventory(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pageURL, err)
+	}
+	next := ""
+	if inv.Next != "" {
+		if next, err = nextPageURL(pageURL, inv.Next); err != nil {
+			return nil, err
+		}
+	}
+	c.crawl.Hosts = append(c.crawl.Hosts, inv.Hosts...)
+	c.crawl.Next = next
+	if next == "" {
+		c.crawl.Stale = nil
+	}
+	writeInventoryCrawl(c.path, &c.crawl)
+	return inv.Hosts, nil
+}
+
+func fetchInventoryPage(client *inventoryClient, pageURL string) ([]byte, error) {
+	resp, err := client.getRateLimited(pageURL, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", pageURL, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+}
+
+// toHosts converts inventory entries the way loadInventoryHosts does.
+func (c *inventoryCrawler) toHosts(entries []inventoryHost) ([]sshHost, error) {
+	hosts := inventoryToHosts(inventoryFile{Hosts: entries}, c.source(), c.ic.URL)
+	if c.ic.ProxyCommand != "" {
+		if err := applyProxyTemplate(hosts, c.ic.ProxyCommand); err != nil {
+			return nil, err
+		}
+	}
+	return hosts, nil
+}
+
+// all is every host of a finished crawl.
+func (c *inventoryCrawler) all() ([]sshHost, error) {
+	return c.toHosts(append(append([]inventoryHost(nil), c.first...), c.crawl.Hosts...))
+}
+
+// finish fetches the remaining pages without a UI to stream into, for
+// -list, -best and the daemon.
+func (c *inventoryCrawler) finish() ([]sshHost, error) {
+	for !c.done() {
+		if _, err := c.next(); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.source(), err)
+		}
+	}
+	return c.all()
+}
+
+// loadAllPages is p.hosts() with every page of a paged inventory.
+func loadAllPages(p hostProvider) ([]sshHost, string, error) {
+	hosts, warning, err := p.hosts()
+	if err != nil {
+		return hosts, warning, err
+	}
+	ic, ok := p.(inventoryConfig)
+	if !ok {
+		return hosts, warning, nil
+	}
+	if c := ic.crawler(); c != nil {
+		all, err := c.finish()
+		if err != nil {
+			return hosts, warning, err
+		}
+		hosts = mergeHosts(hosts, all)
+	}
+	return hosts, warning, nil
+}
+
+type inventoryPageMsg struct {
+	crawler *inventoryCrawler
+	hosts   []sshHost
+	fetched int  // hosts the crawl has after this page
+	done    bool // the last page: hosts is the whole inventory
+	err     error
+}
+
+// fetchPage is the command fetching c's next page into the picker.
+func (c *inventoryCrawler) fetchPage() tea.Cmd {
+	return func() tea.Msg {
+		entries, err := c.next()
+		if err != nil {
+			return inventoryPageMsg{crawler: c, err: err}
+		}
+		fetched, done := len(c.first)+len(c.crawl.Hosts), c.done()
+		if done {
+			entries = append(append([]inventoryHost(nil), c.first...), c.crawl.Hosts...)
+		}
+		hosts, err := c.toHosts(entries)
+		return inventoryPageMsg{crawler: c, hosts: hosts, fetched: fetched, done: done, err: err}
+	}
+}
+
+// applyInventoryPage merges a page into the list. The last page carries
+// the whole inventory, which replaces the source's hosts so instances gone
+// since the previous crawl disappear.
+func (m model) applyInventoryPage(msg inventoryPageMsg) (model, tea.Cmd) {
+	c := msg.crawler
+	if msg.err != nil {
+		m.crawls = removeCrawler(m.crawls, c)
+		m.err = trErr("err.crawl", c.source(), msg.err)
+		return m, nil
+	}
+	c.shown = msg.fetched
+	hosts := msg.hosts
+	if m.restrict != nil {
+		hosts = restrictHosts(hosts, m.restrict)
+	}
+	meta, _ := hostMetadata(m.appConfig, false)
+	hosts = applyMetadata(hosts, meta)
+	all := m.allHosts
+	if msg.done {
+		all = nil
+		for _, h := range m.allHosts {
+			if h.Source != c.source() {
+				all = append(all, h)
+			}
+		}
+	}
+	m.allHosts = favoritesFirst(mergeHosts(all, hosts))
+	m.applyFilter(m.lastValidRegex)
+	if msg.done {
+		m.crawls = removeCrawler(m.crawls, c)
+		return m, nil
+	}
+	return m, c.fetchPage()
+}
+
+func removeCrawler(crawls []*inventoryCrawler, c *inventoryCrawler) []*inventoryCrawler {
+	var out []*inventoryCrawler
+	for _, o := range crawls {
+		if o != c {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// crawlStatus is the status line while pages are still arriving.
+func (m model) crawlStatus() string {
+	if len(m.crawls) == 0 {
+		return ""
+	}
+	parts := make([]string, len(m.crawls))
+	for i, c := range m.crawls {
+		parts[i] = fmt.Sprintf("%s (%d so far)", c.source(), c.shown)
+	}
+	return tr("status.crawling", strings.Join(parts, ", "))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func pagedInventoryServer(t *testing.T, hits map[string]int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		hits[page]++
+		switch page {
+		case "":
+			fmt.Fprint(w, `{"hosts":[{"alias":"vm1"}],"next":"?page=2"}`)
+		case "2":
+			if hits["2"] == 1 {
+				// rate limited once: retried after Retry-After
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			fmt.Fprint(w, `{"hosts":[{"alias":"vm2"}],"next":"/?page=3"}`)
+		case "3":
+			fmt.Fprint(w, `{"hosts":[{"alias":"vm3"}]}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func aliases(hosts []sshHost) string {
+	var out []string
+	for _, h := range hosts {
+		out = append(out, h.Alias)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ",")
+}
+
+func TestInventoryCrawlResumes(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	hits := map[string]int{}
+	srv := pagedInventoryServer(t, hits)
+	ic := inventoryConfig{Name: "cloud", URL: srv.URL, RateLimit: 1000}
+
+	hosts, _, err := loadInventoryHosts(ic)
+	if err != nil || aliases(hosts) != "vm1" {
+		t.Fatalf("first page: %v %v", aliases(hosts), err)
+	}
+	c := ic.crawler()
+	if c == nil {
+		t.Fatal("no crawler for a paged inventory")
+	}
+	if page, err := c.next(); err != nil || len(page) != 1 || page[0].Alias != "vm2" {
+		t.Fatalf("page 2: %+v %v", page, err)
+	}
+
+	// quit here: the next start shows vm2 at once and fetches only page 3
+	hosts, _, err = loadInventoryHosts(ic)
+	if err != nil || aliases(hosts) != "vm1,vm2" {
+		t.Fatalf("resumed: %v %v", aliases(hosts), err)
+	}
+	all, err := ic.crawler().finish()
+	if err != nil || aliases(all) != "vm1,vm2,vm3" {
+		t.Fatalf("finished: %v %v", aliases(all), err)
+	}
+	if hits["2"] != 2 || hits["3"] != 1 {
+		t.Fatalf("page requests %v", hits)
+	}
+
+	// a finished crawl is shown while the next start crawls again
+	hosts, _, err = loadInventoryHosts(ic)
+	if err != nil || aliases(hosts) != "vm1,vm2,vm3" {
+		t.Fatalf("after a full crawl: %v %v", aliases(hosts), err)
+	}
+	if c := ic.crawler(); c == nil || !strings.HasSuffix(c.crawl.Next, "?page=2") {
+		t.Fatalf("recrawl should start at page 2: %+v", c)
+	}
+}
+
+func TestApplyInventoryPage(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	c := &inventoryCrawler{ic: inventoryConfig{Name: "cloud"}}
+	m := initialModel([]sshHost{
+		{Alias: "web", Source: "config"},
+		{Alias: "gone", Source: "cloud"},
+	}, "", "")
+	m.crawls = []*inventoryCrawler{c}
+
+	m, cmd := m.applyInventoryPage(inventoryPageMsg{crawler: c, hosts: []sshHost{{Alias: "vm2", Source: "cloud"}}, fetched: 2})
+	if aliases(m.allHosts) != "gone,vm2,web" || cmd == nil || !strings.Contains(m.crawlStatus(), "cloud (2 so far)") {
+		t.Fatalf("page: %v, status %q", aliases(m.allHosts), m.crawlStatus())
+	}
+	m, cmd = m.applyInventoryPage(inventoryPageMsg{crawler: c, hosts: []sshHost{{Alias: "vm1", Source: "cloud"}, {Alias: "vm2", Source: "cloud"}}, done: true})
+	if aliases(m.allHosts) != "vm1,vm2,web" || cmd != nil || len(m.crawls) != 0 {
+		t.Fatalf("last page: %v", aliases(m.allHosts))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for v, want := range map[string]time.Duration{
+		"30":                            30 * time.Second,
+		"Wed, 01 May 2024 12:01:00 GMT": time.Minute,
+		"Wed, 01 May 2024 11:00:00 GMT": 0,
+	} {
+		if got, ok := retryAfter(v, now); !ok || got != want {
+			t.Errorf("%q: %v %v, want %v", v, got, ok, want)
+		}
+	}
+	if _, ok := retryAfter("soon", now); ok {
+		t.Error("garbage parsed")
+	}
+}
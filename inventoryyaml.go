@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// parseInventory reads an inventory document: JSON when it starts with
+// "{", YAML otherwise.
+func parseInventory(data []byte) (inventoryFile, error) {
+	var inv inventoryFile
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		err := json.Unmarshal(data, &inv)
+		return inv, err
+	}
+	return parseInventoryYAML(data)
+}
+
+// parseInventoryYAML reads the inventory format written as YAML, in the
+// same small subset as the metadata store: block mappings and "- item"
+// lists, [flow, lists], {flow: maps} and plain or quoted scalars. Unknown
+// keys are ignored, as in the JSON form.
+//
+//	next: https://inv.example/hosts.yaml?page=2
+//	hosts:
+//	  - alias: db1
+//	    hostname: 10.0.0.5
+//	    port: 2222
+//	    tags: [prod, db]
+//	    notes:
+//	      - primary
+//	    attrs:
+//	      zone: europe-west1-b
+func parseInventoryYAML(data []byte) (inventoryFile, error) {
+	var inv inventoryFile
+	var cur *inventoryHost
+	section, field := "", ""
+	hostIndent, keyIndent := -1, -1
+
+	for i, raw := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		line := stripYAMLComment(strings.TrimRight(raw, " \t\r"))
+		text := strings.TrimSpace(line)
+		if text == "" || text == "---" {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+			return inventoryFile{}, fmt.Errorf("line %d: indent with spaces, not tabs", lineNo)
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if indent == 0 {
+			k, v, ok := cutYAMLKey(text)
+			if !ok {
+				return inventoryFile{}, fmt.Errorf("line %d: expected \"key: value\"", lineNo)
+			}
+			section, cur, field = k, nil, ""
+			switch k {
+			case "hosts":
+				if v != "" && v != "[]" {
+					return inventoryFile{}, fmt.Errorf("line %d: hosts must be a list of \"- alias: ...\" entries", lineNo)
+				}
+			case "next":
+				inv.Next = yamlScalar(v)
+			}
+			continue
+		}
+		if section != "hosts" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "- ") || text == "-" {
+			item := strings.TrimSpace(text[1:])
+			if hostIndent == -1 || indent == hostIndent {
+				hostIndent = indent
+				inv.Hosts = append(inv.Hosts, inventoryHost{})
+				cur, field = &inv.Hosts[len(inv.Hosts)-1], ""
+				if item == "" {
+					keyIndent = -1 // set by the host's first key
+					continue
+				}
+				keyIndent = indent + len(text) - len(item)
+				if err := cur.setYAML(item, &field); err != nil {
+					return inventoryFile{}, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+				continue
+			}
+			if cur == nil || indent < keyIndent || (field != "tags" && field != "notes") {
+				return inventoryFile{}, fmt.Errorf("line %d: list item without a key", lineNo)
+			}
+			if v := yamlScalar(item); field == "tags" {
+				cur.Tags = append(cur.Tags, v)
+			} else {
+				cur.Notes = append(cur.Notes, v)
+			}
+			continue
+		}
+
+		if cur != nil && keyIndent == -1 && indent > hostIndent {
+			keyIndent = indent
+		}
+		switch {
+		case cur == nil:
+			return inventoryFile{}, fmt.Errorf("line %d: expected \"- alias: ...\" under hosts", lineNo)
+		case indent == keyIndent:
+			if err := cur.setYAML(text, &field); err != nil {
+				return inventoryFile{}, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		case indent > keyIndent && field == "attrs":
+			k, v, ok := cutYAMLKey(text)
+			if !ok {
+				return inventoryFile{}, fmt.Errorf("line %d: expected \"key: value\"", lineNo)
+			}
+			cur.setAttr(k, yamlScalar(v))
+		default:
+			return inventoryFile{}, fmt.Errorf("line %d: unexpected indentation", lineNo)
+		}
+	}
+	return inv, nil
+}
+
+// cutYAMLKey splits "key: value" into the unquoted key and the trimmed
+// value.
+func cutYAMLKey(text string) (key, value string, ok bool) {
+	k, v, ok := strings.Cut(text, ":")
+	if !ok || (v != "" && v[0] != ' ') {
+		return "", "", false
+	}
+	return yamlScalar(k), strings.TrimSpace(v), true
+}
+
+// setYAML sets one "key: value" of a host. A list or map key with no value
+// leaves its name in field for the block lines that follow.
+func (e *inventoryHost) setYAML(text string, field *string) error {
+	k, v, ok := cutYAMLKey(text)
+	if !ok {
+		return fmt.Errorf("expected \"key: value\", got %q", text)
+	}
+	*field = ""
+	switch k {
+	case "alias":
+		e.Alias = yamlScalar(v)
+	case "hostname":
+		e.Hostname = yamlScalar(v)
+	case "user":
+		e.User = yamlScalar(v)
+	case "port":
+		e.Port = yamlScalar(v)
+	case "proxy_jump":
+		e.ProxyJump = yamlScalar(v)
+	case "identity_file":
+		e.IdentityFile = yamlScalar(v)
+	case "tags", "notes":
+		var list []string
+		switch {
+		case v == "":
+			*field = k
+		case strings.HasPrefix(v, "["):
+			if !strings.HasSuffix(v, "]") {
+				return fmt.Errorf("%s: unterminated list", k)
+			}
+			for _, item := range splitFlowList(v[1 : len(v)-1]) {
+				if item = yamlScalar(item); item != "" {
+					list = append(list, item)
+				}
+			}
+		default:
+			list = []string{yamlScalar(v)}
+		}
+		if k == "tags" {
+			e.Tags = append(e.Tags, list...)
+		} else {
+			e.Notes = append(e.Notes, list...)
+		}
+	case "attrs":
+		switch {
+		case v == "":
+			*field = k
+		case strings.HasPrefix(v, "{") && strings.HasSuffix(v, "}"):
+			for _, item := range splitFlowList(v[1 : len(v)-1]) {
+				if strings.TrimSpace(item) == "" {
+					continue
+				}
+				ak, av, ok := cutYAMLKey(strings.TrimSpace(item))
+				if !ok {
+					return fmt.Errorf("attrs: expected \"key: value\", got %q", item)
+				}
+				e.setAttr(ak, yamlScalar(av))
+			}
+		default:
+			return fmt.Errorf("attrs: expected a mapping")
+		}
+	}
+	return nil
+}
+
+func (e *inventoryHost) setAttr(k, v string) {
+	if e.Attrs == nil {
+		e.Attrs = map[string]string{}
+	}
+	e.Attrs[k] = v
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseInventoryYAML(t *testing.T) {
+	t.Parallel()
+
+	inv, err := parseInventory([]byte(`# team hosts
+next: "https://inv.example/hosts.yaml?page=2"
+version: 3
+hosts:
+  - alias: db1
+    hostname: 10.0.0.5
+    port: 2222
+    tags: [prod, db]
+    notes:
+      - primary   # the big one
+      - 'backups: nightly'
+    attrs:
+      zone: europe-west1-b
+  -
+    alias: web1
+    proxy_jump: bastion
+    tags:
+    - web
+    attrs: {zone: eu, rack: "r1"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := inventoryFile{Next: "https://inv.example/hosts.yaml?page=2", Hosts: []inventoryHost{
+		{Alias: "db1", Hostname: "10.0.0.5", Port: "2222", Tags: []string{"prod", "db"},
+			Notes: []string{"primary", "backups: nightly"}, Attrs: map[string]string{"zone": "europe-west1-b"}},
+		{Alias: "web1", ProxyJump: "bastion", Tags: []string{"web"}, Attrs: map[string]string{"zone": "eu", "rack": "r1"}},
+	}}
+	if !reflect.DeepEqual(inv, want) {
+		t.Fatalf("got %+v\nwant %+v", inv, want)
+	}
+
+	if inv, err := parseInventory([]byte(testInventory)); err != nil || len(inv.Hosts) != 2 {
+		t.Fatalf("JSON still parses: %+v %v", inv, err)
+	}
+
+	for _, bad := range []string{
+		"hosts:\n  alias: db1\n",
+		"hosts:\n  - alias: db1\n     user: x\n",
+		"hosts:\n  - db1\n",
+		"hosts:\n\t- alias: db1\n",
+	} {
+		if _, err := parseInventory([]byte(bad)); err == nil || !strings.Contains(err.Error(), "line ") {
+			t.Errorf("%q: expected a line error, got %v", bad, err)
+		}
+	}
+}
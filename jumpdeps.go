@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// jumpTargets lists the hosts h is reached through: each hop of ProxyJump
+// and the host-like words of ProxyCommand, reduced to bare names.
+func jumpTargets(h sshHost) []string {
+	var targets []string
+	for _, hop := range jumpChain(h.option("proxyjump")) {
+		targets = append(targets, hopHost(hop))
+	}
+	if pc := h.option("proxycommand"); pc != "" && !strings.EqualFold(pc, "none") {
+		for _, word := range strings.Fields(pc) {
+			word = strings.Trim(word, `"'`)
+			if !strings.HasPrefix(word, "-") && !strings.Contains(word, "%") {
+				targets = append(targets, hopHost(word))
+			}
+		}
+	}
+	return targets
+}
+
+// jumpChain splits a ProxyJump value into its hops, the first one ssh
+// connects to first. "none" is no chain.
+func jumpChain(value string) []string {
+	if value == "" || strings.EqualFold(value, "none") {
+		return nil
+	}
+	var hops []string
+	for _, hop := range strings.Split(value, ",") {
+		if hop = strings.TrimSpace(hop); hop != "" {
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// hopHost strips ssh://, user@ and :port from a ProxyJump hop.
+func hopHost(hop string) string {
+	hop = strings.TrimPrefix(strings.TrimSpace(hop), "ssh://")
+	if i := strings.LastIndex(hop, "@"); i >= 0 {
+		hop = hop[i+1:]
+	}
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		return host
+	}
+	return strings.Trim(hop, "[]")
+}
+
+// dependent is a host that reaches the bastion, directly (depth 1) or by
+// jumping through another dependent.
+type dependent struct {
+	host  sshHost
+	via   string // the hop it uses: the bastion itself or another dependent
+	depth int
+}
+
+// jumpsThrough reports whether h reaches target directly.
+func jumpsThrough(h, target sshHost) bool {
+	for _, t := range jumpTargets(h) {
+		if t != "" && (t == target.Alias || t == target.Hostname || t == target.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// dependents finds every host that would lose its route if bastion went
+// down, breadth first so direct dependents come first.
+func dependents(all []sshHost, bastion sshHost) []dependent {
+	var out []dependent
+	seen := map[string]bool{bastion.Alias: true}
+	frontier := []sshHost{bastion}
+	for depth := 1; len(frontier) > 0; depth++ {
+		var next []sshHost
+		for _, hop := range frontier {
+			for _, h := range all {
+				if seen[h.Alias] || !jumpsThrough(h, hop) {
+					continue
+				}
+				seen[h.Alias] = true
+				out = append(out, dependent{host: h, via: hop.Alias, depth: depth})
+				next = append(next, h)
+			}
+		}
+		frontier = next
+	}
+	return out
+}
+
+// dependentsView is the reverse dependency screen (J).
+type dependentsView struct {
+	bastion sshHost
+	list    []dependent
+	cursor  int
+}
+
+func (m model) openDependents() model {
+	if len(m.hosts) == 0 {
+		return m
+	}
+	b := m.hosts[m.cursor]
+	m.deps = &dependentsView{bastion: b, list: dependents(m.allHosts, b)}
+	return m
+}
+
+func (m model) updateDependents(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	v := *m.deps
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q", "J":
+		m.deps = nil
+		return m, nil
+	case "j", "down":
+		if len(v.list) > 0 {
+			v.cursor = (v.cursor + 1) % len(v.list)
+		}
+	case "k", "up":
+		if len(v.list) > 0 {
+			v.cursor = (v.cursor - 1 + len(v.list)) % len(v.list)
+		}
+	case "enter":
+		// Highlight the chosen host in the main list if it is visible.
+		if v.cursor < len(v.list) {
+			for i, h := range m.hosts {
+				if h.Alias == v.list[v.cursor].host.Alias {
+					m.cursor = i
+					m.deps = nil
+					return m, m.refreshDetail()
+				}
+			}
+			m.err = fmt.Errorf("%s is hidden by the current filter", v.list[v.cursor].host.Alias)
+		}
+	}
+	m.deps = &v
+	return m, nil
+}
+
+func (m model) renderDependents(b *strings.Builder) {
+	v := m.deps
+	direct := 0
+	for _, d := range v.list {
+		if d.depth == 1 {
+			direct++
+		}
+	}
+	fmt.Fprintln(b, m.styles.title.Render(fmt.Sprintf("Hosts that reach %s through it: %d direct, %d indirect", v.bastion.Alias, direct, len(v.list)-direct)))
+	fmt.Fprintln(b, m.styles.help.Render(tr("help.deps")))
+	fmt.Fprintln(b, "")
+	if len(v.list) == 0 {
+		fmt.Fprintln(b, m.styles.help.Render("No host uses "+v.bastion.Alias+" in ProxyJump or ProxyCommand."))
+	}
+	for i, d := range v.list {
+		line := fmt.Sprintf("%s%-24s via %s", strings.Repeat("  ", d.depth-1), d.host.Alias, d.via)
+		if i == v.cursor {
+			fmt.Fprintln(b, m.styles.selected.Render("> "+line))
+		} else {
+			fmt.Fprintln(b, m.styles.item.Render("  "+line))
+		}
+	}
+	if m.err != nil {
+		fmt.Fprintln(b, "")
+		fmt.Fprintln(b, m.styles.error.Render(m.err.Error()))
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJumpTargets(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Options: map[string]string{
+		"proxyjump":    "ops@bastion-eu:2222,ssh://[2001:db8::1]:22",
+		"proxycommand": "ssh -W %h:%p -q admin@jump.internal",
+	}}
+	got := strings.Join(jumpTargets(h), " ")
+	if got != "bastion-eu 2001:db8::1 ssh jump.internal" {
+		t.Fatalf("targets %q", got)
+	}
+}
+
+func TestDependents(t *testing.T) {
+	t.Parallel()
+
+	hosts := []sshHost{
+		{Alias: "bastion", Hostname: "1.2.3.4"},
+		{Alias: "inner-jump", Options: map[string]string{"proxyjump": "bastion"}},
+		{Alias: "db1", Options: map[string]string{"proxyjump": "inner-jump"}},
+		{Alias: "web1", Options: map[string]string{"proxycommand": "ssh -W %h:%p 1.2.3.4"}},
+		{Alias: "bastion-2"},
+		{Alias: "web2", Options: map[string]string{"proxyjump": "bastion-2"}},
+	}
+	deps := dependents(hosts, hosts[0])
+	var got []string
+	for _, d := range deps {
+		got = append(got, d.host.Alias+"<"+d.via)
+	}
+	if strings.Join(got, " ") != "inner-jump<bastion web1<bastion db1<inner-jump" {
+		t.Fatalf("dependents %v", got)
+	}
+	if deps[2].depth != 2 {
+		t.Fatalf("depth %d", deps[2].depth)
+	}
+}
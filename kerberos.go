@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// usesKerberos reports whether ssh will try GSSAPI for h.
+func usesKerberos(h sshHost) bool {
+	return h.optionIs("GSSAPIAuthentication", "yes")
+}
+
+func kerberosSummary(h sshHost) string {
+	if !usesKerberos(h) {
+		return ""
+	}
+	s := "GSSAPI"
+	if h.optionIs("GSSAPIDelegateCredentials", "yes") {
+		s += ", delegates credentials"
+	}
+	if id := h.option("GSSAPIServerIdentity"); id != "" {
+		s += ", server identity " + id
+	}
+	return s
+}
+
+// hasKerberosTicket reports whether klist sees a valid ticket. ok is false
+// when klist isn't installed, in which case nothing can be checked.
+var hasKerberosTicket = func() (valid, ok bool) {
+	klist, err := exec.LookPath("klist")
+	if err != nil {
+		return false, false
+	}
+	return exec.Command(klist, "-s").Run() == nil, true
+}
+
+func kerberosCheck(_ model, h sshHost) *connectPrompt {
+	if !usesKerberos(h) {
+		return nil
+	}
+	if valid, ok := hasKerberosTicket(); valid || !ok {
+		return nil
+	}
+	p := &connectPrompt{
+		id:      "kerberos",
+		message: fmt.Sprintf("%s uses Kerberos (GSSAPI) but klist found no valid ticket.", h.Alias),
+	}
+	if _, err := exec.LookPath("kinit"); err == nil {
+		p.actions = append(p.actions, promptAction{key: "k", label: "run kinit", cmd: func() *exec.Cmd { return exec.Command("kinit") }})
+	}
+	return p
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const authorizedKeysCommand = "cat ~/.ssh/authorized_keys ~/.ssh/authorized_keys2 2>/dev/null; true"
+
+// publicKey is one key from a .pub file, the agent or authorized_keys.
+type publicKey struct {
+	Type    string
+	Key     string // base64 blob
+	Comment string
+	Origin  string // where a local key came from, e.g. "id_ed25519.pub" or "agent"
+}
+
+// base64Key guards key blobs that are interpolated into remote commands.
+var base64Key = regexp.MustCompile(`^[A-Za-z0-9+/]+=*$`)
+
+func isKeyType(s string) bool {
+	return strings.HasPrefix(s, "ssh-") || strings.HasPrefix(s, "ecdsa-") || strings.HasPrefix(s, "sk-")
+}
+
+// parsePublicKeys reads authorized_keys / .pub lines. Leading options
+// (from=, command=, ...) are skipped by looking for the key type field.
+func parsePublicKeys(data, origin string) []publicKey {
+	var keys []publicKey
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i+1 < len(fields); i++ {
+			if isKeyType(fields[i]) {
+				keys = append(keys, publicKey{
+					Type:    fields[i],
+					Key:     fields[i+1],
+					Comment: strings.Join(fields[i+2:], " "),
+					Origin:  origin,
+				})
+				break
+			}
+		}
+	}
+	return keys
+}
+
+// localPublicKeys collects the *.pub files in the ssh directory and the
+// keys loaded in the agent.
+func localPublicKeys() []publicKey {
+	var keys []publicKey
+	if dir := defaultSSHDir(); dir != "" {
+		paths, _ := filepath.Glob(filepath.Join(dir, "*.pub"))
+		for _, p := range paths {
+			keys = append(keys, readPublicKeyFile(p)...)
+		}
+	}
+	if out, err := exec.Command("ssh-add", "-L").Output(); err == nil {
+		keys = append(keys, parsePublicKeys(string(out), "agent")...)
+	}
+	return keys
+}
+
+func readPublicKeyFile(path string) []publicKey {
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil
+	}
+	return parsePublicKeys(string(data), filepath.Base(path))
+}
+
+// keyAudit is the authorized_keys review of one host.
+type keyAudit struct {
+	Alias   string
+	Mine    []publicKey // local keys present, with the local origin
+	Unknown []publicKey // authorized keys that aren't ours
+	Err     error
+}
+
+func auditKeys(alias string, authorized, local []publicKey) keyAudit {
+	a := keyAudit{Alias: alias}
+	for _, k := range authorized {
+		found := false
+		for _, l := range local {
+			if l.Key == k.Key {
+				if !containsKey(a.Mine, l.Key) {
+					a.Mine = append(a.Mine, l)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			a.Unknown = append(a.Unknown, k)
+		}
+	}
+	return a
+}
+
+func containsKey(keys []publicKey, key string) bool {
+	for _, k := range keys {
+		if k.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func printKeyAudit(w io.Writer, audits []keyAudit) {
+	for _, a := range audits {
+		if a.Err != nil {
+			fmt.Fprintf(w, "%s: error: %v\n", a.Alias, a.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", a.Alias)
+		if len(a.Mine) == 0 {
+			fmt.Fprintln(w, "  none of your keys")
+		}
+		for _, k := range a.Mine {
+			fmt.Fprintf(w, "  yours    %-12s %s (%s)\n", k.Type, keyFingerprint(k.Key), k.Origin)
+		}
+		for _, k := range a.Unknown {
+			fmt.Fprintf(w, "  UNKNOWN  %-12s %s %s\n", k.Type, keyFingerprint(k.Key), k.Comment)
+		}
+	}
+}
+
+// selectHosts applies the -filter/-tag flags shared by the fleet commands.
+func selectHosts(hosts []sshHost, filter, tag string) ([]sshHost, error) {
+	re, err := regexp.Compile(filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -filter: %w", err)
+	}
+	var selected []sshHost
+	for _, h := range hosts {
+		if re.MatchString(h.Alias) && (tag == "" || h.hasTag(tag)) {
+			selected = append(selected, h)
+		}
+	}
+	return selected, nil
+}
+
+// copyIDArgs builds the ssh-copy-id command line installing pubKey on h.
+// ssh-copy-id's own -i names the key to install, so identities for the
+// connection itself are passed as IdentityFile options.
+func copyIDArgs(h sshHost, pubKey string) []string {
+	args := append([]string{"-i", pubKey, "-o", "BatchMode=yes", "-o", "ConnectTimeout=5"}, hostKeyArgs(false)...)
+	args = append(args, configFileArgs()...)
+	if hostSource(h) != "config" {
+		if h.Port != "" {
+			args = append(args, "-p", h.Port)
+		}
+		if pc := h.option("proxycommand"); pc != "" {
+			args = append(args, "-o", "ProxyCommand="+pc)
+		} else if jump := h.option("proxyjump"); jump != "" {
+			args = append(args, "-o", "ProxyJump="+jump)
+		}
+		for _, id := range h.IdentityFiles {
+			args = append(args, "-o", "IdentityFile="+id)
+		}
+	}
+	return append(args, "--", sshTarget(h))
+}
+
+// runCopyID runs ssh-copy-id and returns its combined output; a variable so
+// tests can stub it.
+var runCopyID = func(args []string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ssh-copy-id", args...).CombinedOutput()
+	return string(out), err
+}
+
+// keyResult is the outcome of installing or removing a key on one host.
+type keyResult struct {
+	Alias  string
+	Status string // e.g. "added", "removed", "not present", "skipped" or "failed"
+	Err    string
+}
+
+// pushKey installs pubKey on every host in parallel. Probe-averse hosts
+// (MFA, noprobe) can't be reached without prompting and are skipped.
+func pushKey(hosts []sshHost, pubKey string, concurrency int) []keyResult {
+	results := make([]keyResult, len(hosts))
+	forEachHost(hosts, concurrency, func(i int, h sshHost) {
+		results[i] = keyResult{Alias: h.Alias}
+		if skipsBatchProbes(h) {
+			results[i].Status = "skipped"
+			results[i].Err = errProbeSkipped.Error()
+			return
+		}
+		out, err := runCopyID(copyIDArgs(h, pubKey), time.Minute)
+		switch {
+		case err != nil:
+			results[i].Status = "failed"
+			results[i].Err = lastLine(out)
+			if results[i].Err == "" {
+				results[i].Err = err.Error()
+			}
+		case strings.Contains(out, "keys were skipped because they already exist"):
+			results[i].Status = "already present"
+		default:
+			results[i].Status = "added"
+		}
+	})
+	return results
+}
+
+// revokeCommand removes every authorized_keys line containing key, keeping
+// a .sshpick-bak copy. The file is rewritten in place so its owner and mode
+// stay the same. "removed" is printed only once the rewrite succeeded; any
+// failing step makes the command exit 1. With dryRun it only reports
+// whether the key is present.
+func revokeCommand(key string, dryRun bool) string {
+	// grep exits 1 when every line matched (nothing left), 2 on errors.
+	act := `if cp -p "$f" "$f.sshpick-bak" && { grep -vF '` + key + `' "$f"; [ $? -le 1 ]; } > "$f.sshpick-tmp" && cat "$f.sshpick-tmp" > "$f"; ` +
+		`then echo removed; else echo "could not rewrite $f" >&2; rc=1; fi; rm -f "$f.sshpick-tmp"`
+	if dryRun {
+		act = "echo present"
+	}
+	return `rc=0; for f in ~/.ssh/authorized_keys ~/.ssh/authorized_keys2; do [ -f "$f" ] && grep -qF '` + key + `' "$f" && { ` + act + `; }; done; exit $rc`
+}
+
+// revokeKey removes key from authorized_keys on every host in parallel.
+func revokeKey(hosts []sshHost, key publicKey, dryRun bool, concurrency int) []keyResult {
+	results := make([]keyResult, len(hosts))
+	forEachHost(hosts, concurrency, func(i int, h sshHost) {
+		results[i] = keyResult{Alias: h.Alias}
+		if skipsBatchProbes(h) {
+			results[i].Status = "skipped"
+			results[i].Err = errProbeSkipped.Error()
+			return
+		}
+		out, err := runRemote(h, revokeCommand(key.Key, dryRun), time.Minute)
+		switch {
+		case err != nil:
+			results[i].Status = "failed"
+			results[i].Err = err.Error()
+		case strings.Contains(out, "removed"):
+			results[i].Status = "removed"
+		case strings.Contains(out, "present"):
+			results[i].Status = "would remove"
+		default:
+			results[i].Status = "not present"
+		}
+	})
+	return results
+}
+
+// printKeyResults prints one line per host and a count per status, in the
+// order the statuses first appear. It returns the number of failures.
+func printKeyResults(w io.Writer, results []keyResult) (failed int) {
+	counts := map[string]int{}
+	var order []string
+	for _, r := range results {
+		if counts[r.Status] == 0 {
+			order = append(order, r.Status)
+		}
+		counts[r.Status]++
+		if r.Err != "" {
+			fmt.Fprintf(w, "%-20s %-16s %s\n", r.Alias, r.Status, r.Err)
+		} else {
+			fmt.Fprintf(w, "%-20s %s\n", r.Alias, r.Status)
+		}
+	}
+	parts := make([]string, 0, len(order))
+	for _, s := range order {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[s], s))
+	}
+	fmt.Fprintf(w, "\n%s\n", strings.Join(parts, ", "))
+	return counts["failed"]
+}
+
+// runKeys implements `sshpick keys audit|push|revoke`. audit exits 1 when
+// any host has keys that aren't among the local ones; push and revoke exit
+// 1 when any host failed.
+func runKeys(args []string, stdout io.Writer) int {
+	usage := "usage: sshpick keys audit|push|revoke [-key file.pub] [-dry-run] [-filter re] [-tag t] [alias...]"
+	if len(args) == 0 || (args[0] != "audit" && args[0] != "push" && args[0] != "revoke") {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	fs := flag.NewFlagSet("keys", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	settingsPath := fs.String("settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
+	filter := fs.String("filter", "", "Only hosts whose alias matches this regex")
+	tag := fs.String("tag", "", "Only hosts with this tag")
+	concurrency := fs.Int("concurrency", 16, "Hosts contacted at once")
+	pubKey := fs.String("key", "", "Public key to install (push) or remove (revoke)")
+	dryRun := fs.Bool("dry-run", false, "revoke: only report where the key is present")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	settings, err := loadHostKeyPolicy(*settingsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+		return 2
+	}
+	if *cfgPath == "" {
+		*cfgPath = defaultConfigPath()
+	}
+	hosts, err := parseSSHConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading config:", err)
+		return 2
+	}
+	meta, _ := hostMetadata(settings, false)
+	hosts = applyMetadata(hosts, meta)
+	if hosts, err = selectHosts(hosts, *filter, *tag); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if fs.NArg() > 0 {
+		var named []sshHost
+		for _, h := range hosts {
+			if containsString(fs.Args(), h.Alias) {
+				named = append(named, h)
+			}
+		}
+		hosts = named
+	}
+	if len(hosts) == 0 {
+		fmt.Fprintln(os.Stderr, "no hosts selected")
+		return 2
+	}
+
+	if args[0] != "audit" {
+		if *pubKey == "" {
+			fmt.Fprintln(os.Stderr, args[0]+" needs -key file.pub")
+			return 2
+		}
+		keys := readPublicKeyFile(*pubKey)
+		if len(keys) != 1 || !base64Key.MatchString(keys[0].Key) {
+			fmt.Fprintln(os.Stderr, *pubKey+": expected exactly one public key")
+			return 2
+		}
+		var results []keyResult
+		if args[0] == "push" {
+			results = pushKey(hosts, expandHome(*pubKey), *concurrency)
+		} else {
+			results = revokeKey(hosts, keys[0], *dryRun, *concurrency)
+		}
+		if printKeyResults(stdout, results) > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	local := append(localPublicKeys(), pkcs11PublicKeys(hosts)...)
+	if len(local) == 0 {
+		fmt.Fprintln(os.Stderr, "warning: no local public keys found (no *.pub files, no agent or smartcard keys)")
+	}
+
+	audits := make([]keyAudit, len(hosts))
+	forEachHost(hosts, *concurrency, func(i int, h sshHost) {
+		out, err := runRemote(h, authorizedKeysCommand, 15*time.Second)
+		if err != nil {
+			audits[i] = keyAudit{Alias: h.Alias, Err: err}
+			return
+		}
+		audits[i] = auditKeys(h.Alias, parsePublicKeys(out, h.Alias), local)
+	})
+	printKeyAudit(stdout, audits)
+	for _, a := range audits {
+		if len(a.Unknown) > 0 {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePublicKeys(t *testing.T) {
+	t.Parallel()
+
+	data := `# managed by ansible
+ssh-ed25519 AAAAmine alice@laptop
+from="10.0.0.0/8",no-pty ssh-rsa AAAAother deploy bot
+
+command="/bin/false" sk-ssh-ed25519@openssh.com AAAAsk
+`
+	keys := parsePublicKeys(data, "db1")
+	if len(keys) != 3 {
+		t.Fatalf("keys %+v", keys)
+	}
+	if keys[1].Type != "ssh-rsa" || keys[1].Key != "AAAAother" || keys[1].Comment != "deploy bot" {
+		t.Fatalf("options not skipped: %+v", keys[1])
+	}
+	if keys[2].Type != "sk-ssh-ed25519@openssh.com" {
+		t.Fatalf("security key: %+v", keys[2])
+	}
+}
+
+func TestAuditKeys(t *testing.T) {
+	t.Parallel()
+
+	local := []publicKey{
+		{Type: "ssh-ed25519", Key: "AAAAmine", Origin: "id_ed25519.pub"},
+		{Type: "ssh-ed25519", Key: "AAAAmine", Origin: "agent"},
+	}
+	authorized := parsePublicKeys("ssh-ed25519 AAAAmine\nssh-rsa AAAAother deploy\n", "db1")
+	a := auditKeys("db1", authorized, local)
+	if len(a.Mine) != 1 || a.Mine[0].Origin != "id_ed25519.pub" || len(a.Unknown) != 1 {
+		t.Fatalf("audit %+v", a)
+	}
+
+	var buf bytes.Buffer
+	printKeyAudit(&buf, []keyAudit{a})
+	if !strings.Contains(buf.String(), "UNKNOWN  ssh-rsa") || !strings.Contains(buf.String(), "deploy") {
+		t.Fatalf("report %q", buf.String())
+	}
+}
+
+func TestCopyIDArgs(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Alias: "db1", Hostname: "10.0.0.5", User: "root", Port: "2222", Source: "inventory",
+		IdentityFiles: []string{"/k/id"}, Options: map[string]string{"proxyjump": "bastion"}}
+	got := strings.Join(copyIDArgs(h, "/k/new.pub"), " ")
+	want := "-i /k/new.pub -o BatchMode=yes -o ConnectTimeout=5 -p 2222 -o ProxyJump=bastion -o IdentityFile=/k/id -- root@10.0.0.5"
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestPushKey(t *testing.T) {
+	orig := runCopyID
+	defer func() { runCopyID = orig }()
+	runCopyID = func(args []string, _ time.Duration) (string, error) {
+		switch args[len(args)-1] {
+		case "web1":
+			return "Number of key(s) added: 1", nil
+		case "web2":
+			return "WARNING: All keys were skipped because they already exist on the remote system.", nil
+		}
+		return "ssh: connect to host web3 port 22: Connection refused\n", errors.New("exit status 1")
+	}
+
+	hosts := []sshHost{{Alias: "web1"}, {Alias: "web2"}, {Alias: "web3"}, {Alias: "web4", Annotations: map[string]string{"mfa": "yes"}}}
+	results := pushKey(hosts, "/k/new.pub", 2)
+	var buf bytes.Buffer
+	if failed := printKeyResults(&buf, results); failed != 1 {
+		t.Fatalf("failed = %d\n%s", failed, buf.String())
+	}
+	for _, want := range []string{"web3                 failed           ssh: connect to host web3 port 22: Connection refused", "1 added, 1 already present, 1 failed, 1 skipped"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("summary missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestRevokeCommand(t *testing.T) {
+	t.Parallel()
+
+	home := t.TempDir()
+	if err := os.Mkdir(filepath.Join(home, ".ssh"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(home, ".ssh", "authorized_keys")
+	if err := os.WriteFile(path, []byte("ssh-ed25519 AAAAkeep me\nno-pty ssh-ed25519 AAAArevoked+/= old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	run := func(dryRun bool) string {
+		cmd := exec.Command("sh", "-c", revokeCommand("AAAArevoked+/=", dryRun))
+		cmd.Env = []string{"HOME=" + home, "PATH=" + os.Getenv("PATH")}
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("sh: %v\n%s", err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	if out := run(true); out != "present" {
+		t.Fatalf("dry run output %q", out)
+	}
+	if out := run(false); out != "removed" {
+		t.Fatalf("output %q", out)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "ssh-ed25519 AAAAkeep me\n" {
+		t.Fatalf("authorized_keys %q", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Fatalf("mode %v", info.Mode())
+	}
+	if out := run(false); out != "" {
+		t.Fatalf("second run output %q", out)
+	}
+
+	// A rewrite that can't be written reports a failure, not "removed".
+	os.WriteFile(path, []byte("ssh-ed25519 AAAArevoked+/= again\n"), 0o600)
+	if err := os.Mkdir(path+".sshpick-tmp", 0o700); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("sh", "-c", revokeCommand("AAAArevoked+/=", false))
+	cmd.Env = []string{"HOME=" + home, "PATH=" + os.Getenv("PATH")}
+	out, err := cmd.Output()
+	if err == nil || strings.Contains(string(out), "removed") {
+		t.Fatalf("expected a failure, got %v %q", err, out)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "ssh-ed25519 AAAArevoked+/= again\n" {
+		t.Fatalf("authorized_keys %q", data)
+	}
+}
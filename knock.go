@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultKnockDelay = 200 * time.Millisecond
+	knockDialTimeout  = 300 * time.Millisecond
+)
+
+// knockStep is one packet of a port-knock sequence.
+type knockStep struct {
+	Port  int
+	Proto string // "tcp" or "udp"
+}
+
+func (k knockStep) String() string {
+	if k.Proto == "tcp" {
+		return strconv.Itoa(k.Port)
+	}
+	return fmt.Sprintf("%d/%s", k.Port, k.Proto)
+}
+
+// parseKnockSequence parses "7000,8000/udp,9000/tcp" (protocol defaults to tcp).
+func parseKnockSequence(spec string) ([]knockStep, error) {
+	var steps []knockStep
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		portStr, proto, ok := strings.Cut(part, "/")
+		if !ok {
+			proto = "tcp"
+		}
+		proto = strings.ToLower(proto)
+		if proto != "tcp" && proto != "udp" {
+			return nil, fmt.Errorf("knock %q: protocol must be tcp or udp", part)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("knock %q: invalid port", part)
+		}
+		steps = append(steps, knockStep{Port: port, Proto: proto})
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("knock sequence %q is empty", spec)
+	}
+	return steps, nil
+}
+
+// knockPlan reads the knock annotations of h: knock=<sequence> and an optional
+// knock-delay=<duration> between packets. It returns no steps if none is set.
+func knockPlan(h sshHost) ([]knockStep, time.Duration, error) {
+	spec := h.Annotations["knock"]
+	if spec == "" {
+		return nil, 0, nil
+	}
+	steps, err := parseKnockSequence(spec)
+	if err != nil {
+		return nil, 0, err
+	}
+	delay := defaultKnockDelay
+	if d := h.Annotations["knock-delay"]; d != "" {
+		if delay, err = time.ParseDuration(d); err != nil {
+			return nil, 0, fmt.Errorf("knock-delay %q: %w", d, err)
+		}
+	}
+	return steps, delay, nil
+}
+
+// performKnock sends the sequence to host. Knock ports are normally filtered,
+// so TCP connection failures are expected and ignored; only the SYN matters.
+// The delay also follows the last packet to give the firewall time to open.
+func performKnock(host string, steps []knockStep, delay time.Duration) {
+	for _, s := range steps {
+		addr := net.JoinHostPort(host, strconv.Itoa(s.Port))
+		if conn, err := net.DialTimeout(s.Proto, addr, knockDialTimeout); err == nil {
+			if s.Proto == "udp" {
+				conn.Write([]byte{0})
+			}
+			conn.Close()
+		}
+		time.Sleep(delay)
+	}
+}
+
+// knockBeforeConnect performs the host's knock sequence, if any, logging to w.
+func knockBeforeConnect(h sshHost, w io.Writer) error {
+	steps, delay, err := knockPlan(h)
+	if err != nil || len(steps) == 0 {
+		return err
+	}
+	target := h.Hostname
+	if target == "" {
+		target = h.Alias
+	}
+	names := make([]string, len(steps))
+	for i, s := range steps {
+		names[i] = s.String()
+	}
+	fmt.Fprintf(w, "knocking %s: %s\n", target, strings.Join(names, " "))
+	performKnock(target, steps, delay)
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKnockPlan(t *testing.T) {
+	h := sshHost{Annotations: map[string]string{"knock": "7000, 8000/udp,9000/TCP", "knock-delay": "50ms"}}
+	steps, delay, err := knockPlan(h)
+	if err != nil {
+		t.Fatalf("knockPlan: %v", err)
+	}
+	want := []knockStep{{7000, "tcp"}, {8000, "udp"}, {9000, "tcp"}}
+	if !reflect.DeepEqual(steps, want) {
+		t.Fatalf("expected %v, got %v", want, steps)
+	}
+	if delay != 50*time.Millisecond {
+		t.Fatalf("expected 50ms delay, got %v", delay)
+	}
+
+	for _, bad := range []string{"70000", "7000/icmp", "x", ","} {
+		if _, err := parseKnockSequence(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+	if steps, _, err := knockPlan(sshHost{}); err != nil || steps != nil {
+		t.Fatalf("no annotation should mean no knock")
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// knownHostEntry is one line of a known_hosts file.
+type knownHostEntry struct {
+	Path    string
+	Line    int
+	Marker  string   // "@cert-authority", "@revoked" or ""
+	Hosts   []string // patterns as written; hashed names start with "|1|"
+	KeyType string
+	Key     string // base64 key blob
+	Comment string
+}
+
+// knownHostsPath is the user's known_hosts file.
+func knownHostsPath() string {
+	dir := defaultSSHDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "known_hosts")
+}
+
+// globalKnownHostsFiles are ssh's defaults for GlobalKnownHostsFile.
+var globalKnownHostsFiles = []string{"/etc/ssh/ssh_known_hosts", "/etc/ssh/ssh_known_hosts2"}
+
+// effectiveOption is the value ssh applies to h for key, including values
+// inherited from other blocks such as Host *.
+func (h sshHost) effectiveOption(key string) string {
+	key = strings.ToLower(key)
+	for _, o := range h.Origins {
+		if o.Key == key {
+			return o.Value
+		}
+	}
+	return h.option(key)
+}
+
+// userKnownHostsFiles are the files ssh checks and records h's host key in:
+// its UserKnownHostsFile, else ~/.ssh/known_hosts and known_hosts2. New keys
+// go to the first one. "none" means there are none.
+func userKnownHostsFiles(h sshHost) []string {
+	if v := h.effectiveOption("userknownhostsfile"); v != "" {
+		return knownHostsFileList(h, v)
+	}
+	dir := defaultSSHDir()
+	if dir == "" {
+		return nil
+	}
+	return []string{filepath.Join(dir, "known_hosts"), filepath.Join(dir, "known_hosts2")}
+}
+
+// hostKnownHostsFiles are every file ssh consults for h's host key: the
+// user's, then GlobalKnownHostsFile (or the /etc/ssh defaults).
+func hostKnownHostsFiles(h sshHost) []string {
+	files := userKnownHostsFiles(h)
+	if v := h.effectiveOption("globalknownhostsfile"); v != "" {
+		return append(files, knownHostsFileList(h, v)...)
+	}
+	return append(files, globalKnownHostsFiles...)
+}
+
+// tokenReplacer expands the ssh_config(5) tokens sshpick can know for h.
+// Ones that need a live connection, like %C or %L, are left as they are.
+func tokenReplacer(h sshHost) *strings.Replacer {
+	host, port, _ := net.SplitHostPort(dialAddress(h))
+	user := h.User
+	if user == "" {
+		user = currentUser()
+	}
+	return strings.NewReplacer("%%", "%", "%d", homeDir(), "%u", currentUser(), "%r", user, "%h", host, "%p", port, "%n", h.Alias, "%k", knownHostsKey(h))
+}
+
+// knownHostsFileList splits a (User|Global)KnownHostsFile value and
+// expands ~ and the tokens ssh allows there. Files with tokens sshpick
+// can't know, like %C, are left out.
+func knownHostsFileList(h sshHost, value string) []string {
+	if strings.EqualFold(value, "none") {
+		return nil
+	}
+	expand := tokenReplacer(h)
+	var out []string
+	for _, f := range strings.Fields(value) {
+		f = expand.Replace(strings.Trim(f, `"`))
+		if strings.Contains(strings.ReplaceAll(f, "%%", ""), "%") {
+			continue
+		}
+		out = append(out, expandHome(f))
+	}
+	return out
+}
+
+// knownHostsWritePath is where a trusted key for h is recorded: the first
+// user known_hosts file, as ssh does.
+func knownHostsWritePath(h sshHost) string {
+	if files := userKnownHostsFiles(h); len(files) > 0 {
+		return files[0]
+	}
+	return ""
+}
+
+// loadHostKnownHosts reads every known_hosts file ssh consults for h.
+// Missing and unreadable files are skipped.
+func loadHostKnownHosts(h sshHost) []knownHostEntry {
+	var entries []knownHostEntry
+	for _, path := range hostKnownHostsFiles(h) {
+		if e, err := loadKnownHosts(path); err == nil {
+			entries = append(entries, e...)
+		}
+	}
+	return entries
+}
+
+// loadAllKnownHosts reads every known_hosts file any of hosts uses, each
+// once, with the default user file first.
+func loadAllKnownHosts(hosts []sshHost) []knownHostEntry {
+	files := []string{knownHostsPath()}
+	for _, h := range hosts {
+		files = append(files, hostKnownHostsFiles(h)...)
+	}
+	var entries []knownHostEntry
+	seen := map[string]bool{"": true}
+	for _, path := range files {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		if e, err := loadKnownHosts(path); err == nil {
+			entries = append(entries, e...)
+		}
+	}
+	return entries
+}
+
+// loadKnownHosts parses a known_hosts file, skipping blank and comment lines.
+func loadKnownHosts(path string) ([]knownHostEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readKnownHosts(f, path)
+}
+
+// parseKnownHostLines parses known_hosts-format text such as ssh-keyscan
+// output.
+func parseKnownHostLines(text string) []knownHostEntry {
+	entries, _ := readKnownHosts(strings.NewReader(text), "")
+	return entries
+}
+
+func readKnownHosts(r io.Reader, path string) ([]knownHostEntry, error) {
+	var entries []knownHostEntry
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		e := knownHostEntry{Path: path, Line: line}
+		if strings.HasPrefix(fields[0], "@") {
+			e.Marker = fields[0]
+			fields = fields[1:]
+		}
+		if len(fields) < 3 {
+			continue
+		}
+		e.Hosts = strings.Split(fields[0], ",")
+		e.KeyType, e.Key = fields[1], fields[2]
+		e.Comment = strings.Join(fields[3:], " ")
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// knownHostName strips the [host]:port form down to the host.
+func knownHostName(pattern string) string {
+	if strings.HasPrefix(pattern, "[") {
+		if i := strings.Index(pattern, "]"); i > 0 {
+			return pattern[1:i]
+		}
+	}
+	return pattern
+}
+
+// knownAliases maps every plain (unhashed, non-wildcard) name in known_hosts
+// to the other names that share a host key with it, either on the same line
+// or on another line with the same key.
+func knownAliases(entries []knownHostEntry) map[string][]string {
+	byKey := map[string][]string{}
+	for _, e := range entries {
+		if e.Marker != "" {
+			continue
+		}
+		for _, p := range e.Hosts {
+			if strings.HasPrefix(p, "|") || strings.HasPrefix(p, "!") || strings.ContainsAny(p, "*?") {
+				continue
+			}
+			name := knownHostName(p)
+			if !containsString(byKey[e.Key], name) {
+				byKey[e.Key] = append(byKey[e.Key], name)
+			}
+		}
+	}
+	out := map[string][]string{}
+	for _, names := range byKey {
+		for _, name := range names {
+			for _, other := range names {
+				if other != name && !containsString(out[name], other) {
+					out[name] = append(out[name], other)
+				}
+			}
+		}
+	}
+	return out
+}
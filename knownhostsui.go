@@ -0,0 +1,268 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// knownHostsBrowser is the known_hosts screen (K).
+type knownHostsBrowser struct {
+	path      string
+	entries   []knownHostEntry
+	cursor    int
+	query     string
+	searching bool
+	removing  *knownHostEntry // x pressed; waiting for y/N
+}
+
+func (e knownHostEntry) fingerprint() string {
+	return keyFingerprint(e.Key)
+}
+
+// keyFingerprint is the SHA256 fingerprint ssh-keygen -l prints for a
+// base64 public key blob.
+func keyFingerprint(key string) string {
+	blob, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return "invalid key"
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+func (e knownHostEntry) displayHosts() string {
+	names := make([]string, 0, len(e.Hosts))
+	for _, h := range e.Hosts {
+		if strings.HasPrefix(h, "|") {
+			h = "(hashed)"
+		}
+		names = append(names, h)
+	}
+	return strings.Join(names, ",")
+}
+
+func (kb *knownHostsBrowser) visible() []knownHostEntry {
+	if kb.query == "" {
+		return kb.entries
+	}
+	q := strings.ToLower(kb.query)
+	var out []knownHostEntry
+	for _, e := range kb.entries {
+		if strings.Contains(strings.ToLower(e.displayHosts()+" "+e.KeyType+" "+e.fingerprint()), q) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (m model) openKnownHosts() model {
+	path := knownHostsPath()
+	entries, err := loadKnownHosts(path)
+	if err != nil && !os.IsNotExist(err) {
+		m.err = fmt.Errorf("known_hosts: %w", err)
+		return m
+	}
+	m.err = nil
+	m.knownHosts = &knownHostsBrowser{path: path, entries: entries}
+	return m
+}
+
+func (m model) updateKnownHosts(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	kb := *m.knownHosts
+	if e := kb.removing; e != nil {
+		kb.removing = nil
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		if msg.String() == "y" || msg.String() == "Y" {
+			kb = m.removeKnownHostEntry(kb, *e)
+		}
+		m.knownHosts = &kb
+		return m, nil
+	}
+	if kb.searching {
+		switch msg.String() {
+		case "enter", "esc":
+			kb.searching = false
+			if msg.String() == "esc" {
+				kb.query = ""
+			}
+		case "ctrl+c":
+			return m, tea.Quit
+		case "backspace":
+			if kb.query != "" {
+				_, n := utf8.DecodeLastRuneInString(kb.query)
+				kb.query = kb.query[:len(kb.query)-n]
+			}
+		default:
+			if msg.Type == tea.KeyRunes && len(kb.query) < 128 {
+				kb.query += string(msg.Runes)
+			}
+		}
+		kb.cursor = 0
+		m.knownHosts = &kb
+		return m, nil
+	}
+
+	visible := kb.visible()
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q", "K":
+		m.knownHosts = nil
+		return m, nil
+	case "j", "down":
+		if len(visible) > 0 {
+			kb.cursor = (kb.cursor + 1) % len(visible)
+		}
+	case "k", "up":
+		if len(visible) > 0 {
+			kb.cursor = (kb.cursor - 1 + len(visible)) % len(visible)
+		}
+	case "/":
+		kb.searching = true
+	case "x", "c":
+		if kb.cursor >= len(visible) {
+			break
+		}
+		if m.readOnly {
+			m.err = errReadOnly
+			break
+		}
+		e := visible[kb.cursor]
+		if msg.String() == "x" {
+			m.err = nil
+			kb.removing = &e
+			break
+		}
+		h, err := addKnownHostToConfig(m.configPath, e, m.allHosts)
+		if err != nil {
+			m.err = err
+			break
+		}
+		m.allHosts = append(m.allHosts, h)
+		m.applyFilter(m.lastValidRegex)
+		m.err, m.status = nil, fmt.Sprintf("added Host %s to %s", h.Alias, m.configPath)
+	}
+	m.knownHosts = &kb
+	return m, nil
+}
+
+// removeKnownHostEntry deletes a confirmed entry and rereads the file.
+func (m *model) removeKnownHostEntry(kb knownHostsBrowser, e knownHostEntry) knownHostsBrowser {
+	if err := removeKnownHost(kb.path, e); err != nil {
+		m.err = err
+		return kb
+	}
+	entries, err := loadKnownHosts(kb.path)
+	if err != nil && !os.IsNotExist(err) {
+		m.err = err
+		return kb
+	}
+	kb.entries = entries
+	if kb.cursor >= len(kb.visible()) && kb.cursor > 0 {
+		kb.cursor--
+	}
+	m.err, m.status = nil, fmt.Sprintf("removed %s from %s", e.displayHosts(), kb.path)
+	return kb
+}
+
+func (m model) renderKnownHosts(b *strings.Builder) {
+	kb := m.knownHosts
+	fmt.Fprintln(b, m.styles.title.Render(fmt.Sprintf("known_hosts (%d entries)", len(kb.entries))))
+	if kb.removing != nil {
+		fmt.Fprintln(b, m.styles.error.Render(fmt.Sprintf(tr("status.knownhosts.remove"), kb.removing.displayHosts(), kb.path)))
+	} else {
+		fmt.Fprintln(b, m.styles.help.Render(tr("help.knownhosts")))
+	}
+	if kb.searching || kb.query != "" {
+		fmt.Fprintln(b, m.styles.help.Render("/ "+kb.query))
+	}
+	fmt.Fprintln(b, "")
+	visible := kb.visible()
+	if len(visible) == 0 {
+		fmt.Fprintln(b, m.styles.error.Render("No entries"))
+	}
+	for i, e := range visible {
+		hosts := e.displayHosts()
+		if e.Marker != "" {
+			hosts = e.Marker + " " + hosts
+		}
+		line := fmt.Sprintf("%-40s %-20s %s", hosts, e.KeyType, e.fingerprint())
+		if i == kb.cursor {
+			fmt.Fprintln(b, m.styles.selected.Render("> "+line))
+		} else {
+			fmt.Fprintln(b, m.styles.item.Render("  "+line))
+		}
+	}
+	if m.err != nil {
+		fmt.Fprintln(b, "")
+		fmt.Fprintln(b, m.styles.error.Render(m.err.Error()))
+	} else if m.status != "" {
+		fmt.Fprintln(b, "")
+		fmt.Fprintln(b, m.styles.help.Render(m.status))
+	}
+}
+
+// removeKnownHost deletes e's line from the file, after checking the line
+// still holds the same key (the file may have changed since it was read).
+func removeKnownHost(path string, e knownHostEntry) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if e.Line < 1 || e.Line > len(lines) || !strings.Contains(lines[e.Line-1], e.Key) {
+		return errors.New("known_hosts changed on disk; reopen it and try again")
+	}
+	lines = append(lines[:e.Line-1], lines[e.Line:]...)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "")), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// addKnownHostToConfig appends a Host block for the entry's first plain name.
+func addKnownHostToConfig(cfgPath string, e knownHostEntry, existing []sshHost) (sshHost, error) {
+	if e.Marker != "" {
+		return sshHost{}, fmt.Errorf("%s entries aren't hosts", e.Marker)
+	}
+	name, port := "", ""
+	for _, p := range e.Hosts {
+		if strings.HasPrefix(p, "|") || strings.HasPrefix(p, "!") || strings.ContainsAny(p, "*?") {
+			continue
+		}
+		name = knownHostName(p)
+		if strings.HasPrefix(p, "[") {
+			if i := strings.LastIndex(p, "]:"); i > 0 {
+				port = p[i+2:]
+			}
+		}
+		break
+	}
+	if name == "" {
+		return sshHost{}, errors.New("entry has no readable host name (hashed?)")
+	}
+	for _, h := range existing {
+		if h.Alias == name || h.Hostname == name {
+			return sshHost{}, fmt.Errorf("%s is already in the config as %s", name, h.Alias)
+		}
+	}
+
+	if err := appendHostBlock(cfgPath, hostBlock{Alias: name, HostName: name, Port: port}); err != nil {
+		return sshHost{}, err
+	}
+	return sshHost{Alias: name, Hostname: name, Port: port, IP: literalIP(name), SourcePath: cfgPath, Source: "config"}, nil
+}
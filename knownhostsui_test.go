@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestRemoveKnownHost(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	content := "10.0.0.1 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAAA\n" +
+		"[10.0.0.2]:2222 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBBB\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := loadKnownHosts(path)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("loadKnownHosts: %v %v", entries, err)
+	}
+	if !strings.HasPrefix(entries[0].fingerprint(), "SHA256:") {
+		t.Fatalf("fingerprint %q", entries[0].fingerprint())
+	}
+
+	if err := removeKnownHost(path, entries[0]); err != nil {
+		t.Fatalf("removeKnownHost: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "[10.0.0.2]:2222 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBBB\n" {
+		t.Fatalf("file after removal: %q", data)
+	}
+	// The stale entry no longer matches its line.
+	if err := removeKnownHost(path, entries[0]); err == nil {
+		t.Fatal("removing a changed line should fail")
+	}
+}
+
+func TestAddKnownHostToConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := filepath.Join(t.TempDir(), "config")
+	e := knownHostEntry{Hosts: []string{"|1|hashed", "[10.0.0.2]:2222"}, KeyType: "ssh-ed25519", Key: "AAAA"}
+	h, err := addKnownHostToConfig(cfg, e, nil)
+	if err != nil {
+		t.Fatalf("addKnownHostToConfig: %v", err)
+	}
+	if h.Alias != "10.0.0.2" || h.Port != "2222" {
+		t.Fatalf("host %+v", h)
+	}
+	data, _ := os.ReadFile(cfg)
+	if !strings.Contains(string(data), "Host 10.0.0.2\n    HostName 10.0.0.2\n    Port 2222\n") {
+		t.Fatalf("config %q", data)
+	}
+	if _, err := addKnownHostToConfig(cfg, e, []sshHost{h}); err == nil {
+		t.Fatal("adding a host already in the config should fail")
+	}
+}
+
+func TestKnownHostsRemoveAsksFirst(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	content := "10.0.0.1 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAAA\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := loadKnownHosts(path)
+	m := initialModel(nil, "", "")
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = next.(model)
+	m.knownHosts = &knownHostsBrowser{path: path, entries: entries}
+	key := func(k string) {
+		next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = next.(model)
+	}
+
+	key("x")
+	if m.knownHosts.removing == nil || !strings.Contains(m.View(), "Remove 10.0.0.1 from") {
+		t.Fatalf("expected a confirmation prompt:\n%s", m.View())
+	}
+	key("n")
+	if data, _ := os.ReadFile(path); string(data) != content || m.knownHosts.removing != nil {
+		t.Fatalf("n must cancel, file %q", data)
+	}
+
+	key("x")
+	key("y")
+	if data, _ := os.ReadFile(path); len(data) != 0 || len(m.knownHosts.entries) != 0 {
+		t.Fatalf("y must remove the line, file %q", data)
+	}
+	if m.err != nil || !strings.HasPrefix(m.status, "removed 10.0.0.1 from ") {
+		t.Fatalf("err %v, status %q", m.err, m.status)
+	}
+	if !strings.Contains(m.View(), m.styles.help.Render(m.status)) {
+		t.Fatalf("success should render as status:\n%s", m.View())
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const latencyTimeout = 3 * time.Second
+
+// latencyResult is the outcome of a TCP connect to one host's ssh port.
+type latencyResult struct {
+	Host    sshHost
+	Latency time.Duration
+	Err     error
+}
+
+// bestMirrorMsg carries the measurements for the hosts that were visible when
+// best-mirror mode was triggered.
+type bestMirrorMsg struct{ results []latencyResult }
+
+var errProbeSkipped = errors.New("skipped (mfa/noprobe annotation)")
+
+// skipsBatchProbes reports whether h opted out of background and batch probes,
+// e.g. an MFA-protected host whose rate limits must not be tripped.
+func skipsBatchProbes(h sshHost) bool {
+	return h.annotationBool("mfa") || h.annotationBool("noprobe")
+}
+
+// dialAddress is the host:port sshpick dials for a host, defaulting to the
+// alias when no Hostname is set and to port 22.
+func dialAddress(h sshHost) string {
+	host := h.Hostname
+	if host == "" {
+		host = h.Alias
+	}
+	port := h.Port
+	if port == "" {
+		port = "22"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func measureLatency(addr string, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(start)
+	conn.Close()
+	return elapsed, nil
+}
+
+// measureHosts dials every host concurrently and returns the results sorted
+// fastest first, with unreachable hosts last.
+func measureHosts(hosts []sshHost, timeout time.Duration) []latencyResult {
+	results := make([]latencyResult, len(hosts))
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		if skipsBatchProbes(h) {
+			results[i] = latencyResult{Host: h, Err: errProbeSkipped}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, h sshHost) {
+			defer wg.Done()
+			d, err := measureLatency(dialAddress(h), timeout)
+			results[i] = latencyResult{Host: h, Latency: d, Err: err}
+		}(i, h)
+	}
+	wg.Wait()
+	sort.SliceStable(results, func(i, j int) bool {
+		if (results[i].Err == nil) != (results[j].Err == nil) {
+			return results[i].Err == nil
+		}
+		return results[i].Latency < results[j].Latency
+	})
+	return results
+}
+
+// fastestHost picks the first reachable host from sorted results.
+func fastestHost(results []latencyResult) (sshHost, error) {
+	if len(results) == 0 || results[0].Err != nil {
+		return sshHost{}, errors.New("no host answered")
+	}
+	return results[0].Host, nil
+}
+
+func measureHostsCmd(hosts []sshHost) tea.Cmd {
+	hosts = append([]sshHost{}, hosts...)
+	return func() tea.Msg {
+		return bestMirrorMsg{results: measureHosts(hosts, latencyTimeout)}
+	}
+}
+
+func printLatencyTable(w io.Writer, results []latencyResult) {
+	for i, r := range results {
+		marker := " "
+		if i == 0 && r.Err == nil {
+			marker = "*"
+		}
+		if r.Err != nil {
+			if errors.Is(r.Err, errProbeSkipped) {
+				fmt.Fprintf(w, "%s %-20s %-30s %v\n", marker, r.Host.Alias, dialAddress(r.Host), r.Err)
+				continue
+			}
+			fmt.Fprintf(w, "%s %-20s %-30s unreachable (%v)\n", marker, r.Host.Alias, dialAddress(r.Host), r.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%s %-20s %-30s %s\n", marker, r.Host.Alias, dialAddress(r.Host), r.Latency.Round(100*time.Microsecond))
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMeasureHostsPrefersReachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	_, deadPort, _ := net.SplitHostPort(closed.Addr().String())
+	closed.Close()
+
+	hosts := []sshHost{
+		{Alias: "mfa", Hostname: "127.0.0.1", Port: port, Annotations: map[string]string{"mfa": "true"}},
+		{Alias: "down", Hostname: "127.0.0.1", Port: deadPort},
+		{Alias: "up", Hostname: "127.0.0.1", Port: port},
+	}
+	results := measureHosts(hosts, time.Second)
+	if len(results) != 3 || results[1].Err == nil || results[2].Err == nil {
+		t.Fatalf("expected unreachable and skipped hosts sorted last, got %#v", results)
+	}
+	if !errors.Is(results[1].Err, errProbeSkipped) {
+		t.Fatalf("expected mfa host to be skipped, got %v", results[1].Err)
+	}
+	h, err := fastestHost(results)
+	if err != nil || h.Alias != "up" {
+		t.Fatalf("expected up, got %q (%v)", h.Alias, err)
+	}
+	if _, err := fastestHost(results[1:]); err == nil {
+		t.Fatalf("expected error when no host answered")
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// alfredItem is one row of an Alfred Script Filter.
+type alfredItem struct {
+	UID          string `json:"uid"`
+	Title        string `json:"title"`
+	Subtitle     string `json:"subtitle,omitempty"`
+	Arg          string `json:"arg"`
+	Autocomplete string `json:"autocomplete"`
+	Match        string `json:"match,omitempty"`
+}
+
+// launcherHost is a host as the json format reports it, for Raycast
+// extensions and similar scripts.
+type launcherHost struct {
+	Alias    string   `json:"alias"`
+	Hostname string   `json:"hostname,omitempty"`
+	User     string   `json:"user,omitempty"`
+	Port     string   `json:"port,omitempty"`
+	Source   string   `json:"source"`
+	Tags     []string `json:"tags,omitempty"`
+	Notes    []string `json:"notes,omitempty"`
+	Favorite bool     `json:"favorite,omitempty"`
+	Command  string   `json:"command"` // shell command that connects
+}
+
+// hostSubtitle is the one-line description launchers show under the alias.
+func hostSubtitle(h sshHost) string {
+	var parts []string
+	if dest := h.Hostname; dest != "" {
+		if h.User != "" {
+			dest = h.User + "@" + dest
+		}
+		if h.Port != "" && h.Port != "22" {
+			dest += ":" + h.Port
+		}
+		parts = append(parts, dest)
+	}
+	if tags := h.tags(); len(tags) > 0 {
+		parts = append(parts, "["+strings.Join(tags, ",")+"]")
+	}
+	if src := hostSource(h); src != "config" {
+		parts = append(parts, "("+src+")")
+	}
+	if len(h.Notes) > 0 && h.Notes[0] != "" {
+		parts = append(parts, "— "+h.Notes[0])
+	}
+	return strings.Join(parts, " ")
+}
+
+// writeLauncherHosts prints hosts for a launcher: "lines" is one
+// tab-separated line per host with the alias first (for wofi, rofi, dmenu
+// and fzf), "alfred" is Script Filter JSON and "json" a plain list.
+func writeLauncherHosts(w io.Writer, hosts []sshHost, format string) error {
+	switch format {
+	case "lines":
+		for _, h := range hosts {
+			fmt.Fprintf(w, "%s\t%s\n", h.Alias, hostSubtitle(h))
+		}
+		return nil
+	case "alfred":
+		items := make([]alfredItem, 0, len(hosts))
+		for _, h := range hosts {
+			items = append(items, alfredItem{
+				UID:          h.Alias,
+				Title:        h.Alias,
+				Subtitle:     hostSubtitle(h),
+				Arg:          h.Alias,
+				Autocomplete: h.Alias,
+				Match:        strings.Join(append([]string{h.Alias, h.Hostname, h.User}, h.tags()...), " "),
+			})
+		}
+		enc := json.NewEncoder(w)
+		return enc.Encode(map[string][]alfredItem{"items": items})
+	case "json":
+		out := make([]launcherHost, 0, len(hosts))
+		for _, h := range hosts {
+			out = append(out, launcherHost{
+				Alias:    h.Alias,
+				Hostname: h.Hostname,
+				User:     h.User,
+				Port:     h.Port,
+				Source:   hostSource(h),
+				Tags:     h.tags(),
+				Notes:    h.Notes,
+				Favorite: h.annotationBool("favorite"),
+				Command:  shellJoin([]string{"sshpick", "connect", h.Alias}),
+			})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+	return fmt.Errorf("unknown format %q (lines, alfred, json)", format)
+}
+
+// runLauncher implements `sshpick launcher`, listing every host from every
+// source for a desktop launcher.
+func runLauncher(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("launcher", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	settingsPath := fs.String("settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
+	filter := fs.String("filter", "", "Only hosts whose alias matches this regex")
+	tag := fs.String("tag", "", "Only hosts with this tag")
+	format := fs.String("format", "lines", "Output format: lines (wofi/rofi/dmenu), alfred or json (Raycast)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	settings, err := loadAppConfig(*settingsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+		return 2
+	}
+	if *cfgPath == "" {
+		*cfgPath = defaultConfigPath()
+	}
+	hosts, _ := loadDaemonHosts(daemonOptions{cfgPath: *cfgPath, settings: settings})
+	if hosts, err = selectHosts(favoritesFirst(hosts), *filter, *tag); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := writeLauncherHosts(stdout, hosts, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
+
+// runConnect implements `sshpick connect <alias>`: it connects straight to
+// a host, skipping the picker. Launchers without a terminal pass -terminal,
+// e.g. -terminal "kitty -e", to have it re-run inside one.
+func runConnect(args []string) int {
+	fs := flag.NewFlagSet("connect", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	settingsPath := fs.String("settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
+	terminal := fs.String("terminal", "", "Open the session in this terminal command, e.g. \"kitty -e\" or \"foot\"")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: sshpick connect [-terminal cmd] <alias>|@group")
+		return 2
+	}
+	alias := fs.Arg(0)
+
+	if *terminal != "" {
+		self, err := os.Executable()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		argv := append(strings.Fields(*terminal), self, "connect")
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name != "terminal" {
+				argv = append(argv, "-"+f.Name+"="+f.Value.String())
+			}
+		})
+		cmd := exec.Command(argv[0], append(argv[1:], alias)...)
+		if err := cmd.Start(); err != nil {
+			fmt.Fprintln(os.Stderr, "terminal:", err)
+			return 1
+		}
+		cmd.Process.Release()
+		return 0
+	}
+
+	settings, err := loadHostKeyPolicy(*settingsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+		return 2
+	}
+	if *cfgPath == "" {
+		*cfgPath = defaultConfigPath()
+	}
+	hosts, _ := loadDaemonHosts(daemonOptions{cfgPath: *cfgPath, settings: settings})
+	if groups, _ := splitGroupArgs([]string{alias}); len(groups) > 0 {
+		members, err := groupMembers(hosts, groups)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(members) > 1 {
+			// several hosts open the way Space-tagged ones do
+			opts := launchOptions{hooks: settings.Hooks, notify: settings.Notify, tagDefaults: settings.TagDefaults}
+			if err := launchBatch(members, settings.Batch, opts, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			return 0
+		}
+		alias = members[0].Alias
+	}
+	for _, h := range hosts {
+		if h.Alias != alias {
+			continue
+		}
+		opts := launchOptions{hooks: settings.Hooks, notify: settings.Notify, tagDefaults: settings.TagDefaults, clearForwards: forwardsOffByDefault(h)}
+		if f := connectHost(h, opts); f != nil {
+			for _, line := range f.lines {
+				fmt.Fprintln(os.Stderr, line)
+			}
+			return f.code
+		}
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "no host %q\n", alias)
+	return 1
+}
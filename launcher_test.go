@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteLauncherHosts(t *testing.T) {
+	t.Parallel()
+
+	hosts := []sshHost{
+		{Alias: "db1", Hostname: "10.0.0.5", User: "postgres", Port: "2222", Notes: []string{"primary"}, Annotations: map[string]string{"tags": "prod,db"}},
+		{Alias: "web1", Hostname: "web1.example.com", Source: "inventory"},
+	}
+
+	var b bytes.Buffer
+	if err := writeLauncherHosts(&b, hosts, "lines"); err != nil {
+		t.Fatal(err)
+	}
+	want := "db1\tpostgres@10.0.0.5:2222 [prod,db] — primary\nweb1\tweb1.example.com (inventory)\n"
+	if b.String() != want {
+		t.Fatalf("lines:\n%q\nwant\n%q", b.String(), want)
+	}
+
+	b.Reset()
+	if err := writeLauncherHosts(&b, hosts, "alfred"); err != nil {
+		t.Fatal(err)
+	}
+	var alfred struct{ Items []alfredItem }
+	if err := json.Unmarshal(b.Bytes(), &alfred); err != nil {
+		t.Fatal(err)
+	}
+	if len(alfred.Items) != 2 || alfred.Items[0].Arg != "db1" || alfred.Items[0].Match != "db1 10.0.0.5 postgres prod db" {
+		t.Fatalf("alfred: %s", b.String())
+	}
+
+	b.Reset()
+	if err := writeLauncherHosts(&b, hosts, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var list []launcherHost
+	if err := json.Unmarshal(b.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[1].Source != "inventory" || list[0].Command != "sshpick connect db1" {
+		t.Fatalf("json: %s", b.String())
+	}
+
+	if err := writeLauncherHosts(&b, hosts, "xml"); err == nil {
+		t.Fatal("unknown format accepted")
+	}
+}
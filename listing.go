@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// hostRecord is one host in `sshpick -json` output. Field names are part of
+// the scripting interface: add to them, don't rename.
+type hostRecord struct {
+	Alias         string            `json:"alias"`
+	Hostname      string            `json:"hostname,omitempty"`
+	IP            string            `json:"ip,omitempty"`
+	User          string            `json:"user,omitempty"`
+	Port          string            `json:"port,omitempty"`
+	LocalForwards []string          `json:"local_forwards,omitempty"`
+	Forwards      []string          `json:"forwards,omitempty"` // as ssh flags, e.g. "-R 9000:localhost:9000"
+	JumpChain     []string          `json:"proxy_jump,omitempty"`
+	IdentityFiles []string          `json:"identity_files,omitempty"`
+	Tags          []string          `json:"tags,omitempty"`
+	Notes         []string          `json:"notes,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+	Source        string            `json:"source"`
+	AlsoSources   []string          `json:"also_sources,omitempty"`
+	SourcePath    string            `json:"source_path,omitempty"`
+	SourceLine    int               `json:"source_line,omitempty"`
+}
+
+func newHostRecord(h sshHost) hostRecord {
+	var forwards []string
+	for _, f := range h.Forwards {
+		forwards = append(forwards, f.String())
+	}
+	var notes []string
+	for _, n := range h.Notes {
+		if n != "" {
+			notes = append(notes, n)
+		}
+	}
+	return hostRecord{
+		Alias:         h.Alias,
+		Hostname:      h.Hostname,
+		IP:            h.IP,
+		User:          h.User,
+		Port:          h.Port,
+		LocalForwards: h.LocalForwards,
+		Forwards:      forwards,
+		JumpChain:     h.JumpChain,
+		IdentityFiles: h.IdentityFiles,
+		Tags:          h.tags(),
+		Notes:         notes,
+		Annotations:   h.Annotations,
+		Source:        hostSource(h),
+		AlsoSources:   h.AlsoSources,
+		SourcePath:    h.SourcePath,
+		SourceLine:    h.SourceLine,
+	}
+}
+
+// writeHostsJSON prints hosts as a JSON array, for `sshpick -json`.
+func writeHostsJSON(w io.Writer, hosts []sshHost) error {
+	records := make([]hostRecord, len(hosts))
+	for i, h := range hosts {
+		records[i] = newHostRecord(h)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(records)
+}
+
+// writeHostsTable prints hosts as an aligned table, for `sshpick -list`.
+// Empty cells are "-" so the columns stay splittable with awk or cut -w.
+func writeHostsTable(w io.Writer, hosts []sshHost) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ALIAS\tHOSTNAME\tUSER\tPORT\tSOURCE")
+	cell := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	for _, h := range hosts {
+		source := hostSource(h)
+		if h.SourcePath != "" && h.SourceLine > 0 {
+			source = fmt.Sprintf("%s:%d", tildePath(h.SourcePath), h.SourceLine)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", h.Alias, cell(h.Hostname), cell(h.User), cell(h.Port), source)
+	}
+	return tw.Flush()
+}
+
+// printSelection is what `sshpick -print` writes instead of connecting:
+// the alias, or the ssh command line sshpick would have run.
+func printSelection(w io.Writer, mode string, h sshHost, opts launchOptions) {
+	if mode == "command" {
+		fmt.Fprintln(w, shellJoin(append([]string{"ssh"}, sshArgs(h, opts)...)))
+		return
+	}
+	fmt.Fprintln(w, h.Alias)
+}
+
+// printPicked is -print for a TUI run that ended in something other than a
+// single host: every host of a tag batch or paste group, or the command
+// line of a transfer or action-menu program. Nothing is run.
+func printPicked(w io.Writer, mode string, m model, opts launchOptions) error {
+	var argv []string
+	switch {
+	case len(m.transferArgs) > 0:
+		tool := m.transferTool
+		if tool == "" {
+			tool = "scp"
+		}
+		argv = append([]string{tool}, m.transferArgs...)
+	case m.handoff != nil:
+		argv = append([]string{m.handoff.name}, m.handoff.args...)
+	case len(m.batch) > 0:
+		for _, h := range m.batch {
+			printSelection(w, mode, h, opts)
+		}
+		return nil
+	case m.workspace != nil:
+		for _, h := range m.workspace.hosts {
+			printSelection(w, mode, h, opts)
+		}
+		return nil
+	default:
+		return nil
+	}
+	if mode != "command" {
+		return fmt.Errorf("-print %s: %s was picked, not a host", mode, argv[0])
+	}
+	fmt.Fprintln(w, shellJoin(argv))
+	return nil
+}
+
+// validPrintMode checks the -print value.
+func validPrintMode(mode string) error {
+	switch mode {
+	case "", "alias", "command":
+		return nil
+	}
+	return fmt.Errorf("unknown -print mode %q (want alias or command)", mode)
+}
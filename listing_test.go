@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestHostListing(t *testing.T) {
+	t.Parallel()
+	hosts := []sshHost{
+		{Alias: "db1", Hostname: "db1.example.com", User: "ops", LocalForwards: []string{"5432 localhost:5432"}, Notes: []string{"primary", ""}, Annotations: map[string]string{"tags": "prod, db"}, SourcePath: "/etc/ssh/config", SourceLine: 3},
+		{Alias: "node-7", Hostname: "10.0.0.7", Port: "9100", Source: "prometheus"},
+	}
+
+	var out bytes.Buffer
+	if err := writeHostsJSON(&out, hosts); err != nil {
+		t.Fatal(err)
+	}
+	var records []hostRecord
+	if err := json.Unmarshal(out.Bytes(), &records); err != nil {
+		t.Fatalf("output isn't JSON: %v\n%s", err, out.String())
+	}
+	r := records[0]
+	if len(records) != 2 || r.Source != "config" || r.SourceLine != 3 || len(r.Notes) != 1 || strings.Join(r.Tags, ",") != "prod,db" || len(r.LocalForwards) != 1 {
+		t.Fatalf("records %+v", records)
+	}
+	if records[1].Source != "prometheus" || records[1].Port != "9100" {
+		t.Fatalf("inventory host %+v", records[1])
+	}
+
+	out.Reset()
+	if err := writeHostsTable(&out, hosts); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || len(strings.Fields(lines[1])) != 5 || !strings.HasSuffix(lines[1], "/etc/ssh/config:3") || !strings.Contains(lines[2], "prometheus") {
+		t.Fatalf("table:\n%s", out.String())
+	}
+}
+
+func TestPrintSelection(t *testing.T) {
+	t.Parallel()
+	h := sshHost{Alias: "db1", Hostname: "db1.example.com", SourcePath: "/x"}
+	var out bytes.Buffer
+	printSelection(&out, "alias", h, launchOptions{})
+	if out.String() != "db1\n" {
+		t.Fatalf("alias: %q", out.String())
+	}
+	out.Reset()
+	printSelection(&out, "command", h, launchOptions{localForward: "8080:localhost:80"})
+	if got := strings.TrimSpace(out.String()); !strings.HasPrefix(got, "ssh -L 8080:localhost:80") || !strings.HasSuffix(got, " db1") {
+		t.Fatalf("command: %q", got)
+	}
+	if validPrintMode("command") != nil || validPrintMode("url") == nil {
+		t.Fatal("validPrintMode")
+	}
+}
+
+func TestPrintPicked(t *testing.T) {
+	t.Parallel()
+	web1, web2 := sshHost{Alias: "web1", SourcePath: "/x"}, sshHost{Alias: "web2", SourcePath: "/x"}
+	var out bytes.Buffer
+	if err := printPicked(&out, "alias", model{batch: []sshHost{web1, web2}}, launchOptions{}); err != nil || out.String() != "web1\nweb2\n" {
+		t.Fatalf("batch: %q %v", out.String(), err)
+	}
+	out.Reset()
+	ws := &workspaceLaunch{name: "sshpick-paste-1", hosts: []sshHost{web2}}
+	if err := printPicked(&out, "alias", model{workspace: ws}, launchOptions{}); err != nil || out.String() != "web2\n" {
+		t.Fatalf("paste group: %q %v", out.String(), err)
+	}
+
+	out.Reset()
+	transfer := model{transferArgs: []string{"-3", "web1:/var/log/app.log", "web2:/tmp/"}}
+	if err := printPicked(&out, "command", transfer, launchOptions{}); err != nil || out.String() != "scp -3 web1:/var/log/app.log web2:/tmp/\n" {
+		t.Fatalf("transfer: %q %v", out.String(), err)
+	}
+	out.Reset()
+	if err := printPicked(&out, "alias", transfer, launchOptions{}); err == nil || out.Len() != 0 {
+		t.Fatalf("a transfer has no alias to print: %q", out.String())
+	}
+	handoff := model{handoff: &hostAction{name: "mosh", args: []string{"web1"}}}
+	if err := printPicked(&out, "command", handoff, launchOptions{}); err != nil || out.String() != "mosh web1\n" {
+		t.Fatalf("handoff: %q %v", out.String(), err)
+	}
+}
@@ -2,16 +2,21 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -24,30 +29,115 @@ type sshHost struct {
 	IP            string // resolved from Hostname if it's not already an IP
 	User          string
 	Port          string
-	LocalForwards []string
+	LocalForwards []string      // local ports of the LocalForwards, for the list
+	Forwards      []portForward // every Local/Remote/DynamicForward that applies, Host * ones included
+	JumpChain     []string      // ProxyJump hops, first hop first
+	IdentityFiles []string
+	Options       map[string]string // every directive in the block, lowercased key, first value wins (like ssh)
+	Directives    []string          // every directive as "key value" (lowercased key), repeats included
 	Notes         []string
+	Annotations   map[string]string // from "# sshpick: key=value" comments
 	SourcePath    string
-	SourceLine    int // 1-based line number of the Host directive
+	SourceLine    int            // 1-based line number of the Host directive
+	Source        string         // where the host came from: "config", "prometheus", ...
+	AlsoSources   []string       // other sources that reported the same machine, merged into this row
+	Origins       []optionOrigin // where each option ssh applies to the host was set, in order
 }
 type model struct {
-	allHosts       []sshHost
-	hosts          []sshHost
-	cursor         int
-	ready          bool
-	width          int
-	height         int
-	showNotes      bool
-	err            error
-	chosen         bool
-	selectedHost   sshHost
-	title          string
-	styles         styles
-	localForward   string
-	configPath     string
-	filterActive   bool
-	filterQuery    string
-	lastValidRegex string
-	filterErr      error
+	allHosts          []sshHost
+	hosts             []sshHost
+	cursor            int
+	ready             bool
+	width             int
+	height            int
+	showNotes         bool
+	err               error
+	chosen            bool
+	selectedHost      sshHost
+	title             string
+	styles            styles
+	localForward      string
+	configPath        string
+	filterActive      bool
+	filterQuery       string
+	lastValidRegex    string
+	filterRegex       bool // the filter is a regular expression rather than fuzzy
+	filterErr         error
+	filterHistory     []string // most recent first
+	historyPos        int      // index into filterHistory while browsing, -1 for the typed draft
+	filterDraft       string
+	filterScope       filterScope
+	hiddenSources     map[string]bool
+	sourcePanel       bool
+	sourceCursor      int
+	sourceQuery       string
+	sourceSearch      bool
+	measuring         bool
+	latencyResults    []latencyResult
+	warnings          []parseWarning
+	showWarnings      bool
+	showDetail        bool
+	prompt            *connectPrompt
+	acknowledged      map[string]bool // pre-connect prompts answered with "connect anyway"
+	prechecked        map[string]any  // slow pre-connect lookups done for the current connection
+	appConfig         appConfig
+	dual              *dualPicker
+	diff              *hostDiff
+	deps              *dependentsView
+	ports             *portPicker
+	paste             *pasteGroup      // hosts pasted into the picker
+	workspace         *workspaceLaunch // tmux session to open instead of ssh
+	tagged            map[string]bool  // hosts tagged with Space for a batch, by alias
+	batch             []sshHost        // tagged hosts to open together instead of ssh
+	definitions       *definitionsView
+	resolving         map[string]bool     // hostnames with a DNS lookup in flight
+	forwards          []string            // extra -L specs for the chosen host, from the port picker
+	transferArgs      []string            // scp arguments to run instead of ssh, set by transfer modes
+	transferTool      string              // program transferArgs are for: scp (the default) or sftp
+	transferDone      *transferEntry      // the t transfer behind transferArgs, remembered once it starts
+	transfer          *transferPicker     // t: copy files to or from the highlighted host
+	scpMode           bool                // -scp: Enter opens the transfer overlay instead of connecting
+	crawls            []*inventoryCrawler // paged inventories still streaming in
+	views             map[string]viewSpec // saved views by name, from the v menu
+	grouping          hostGrouping        // G: rows grouped by source or tag
+	compact           bool                // C: rows show only the alias
+	viewMenu          *viewMenu
+	showStats         bool
+	stats             map[string]hostStats // by alias
+	statsPending      map[string]bool
+	showReach         bool
+	reach             map[string]reachability // by alias
+	reachPending      map[string]bool
+	probeGen          int
+	showWho           bool
+	who               map[string]whoResult // by alias
+	whoPending        map[string]bool
+	maintenance       map[string]maintenanceEntry // by alias
+	actions           *actionMenu
+	handoff           *hostAction // program to run instead of ssh, chosen from an action menu
+	failure           *sessionFailure
+	verboseRetry      bool           // the chosen connection is a -vvv retry from the failure panel
+	usage             []hostUsage    // connection history screen, when open
+	usageEntries      []historyEntry // the history behind usage, for the heatmap
+	usageHeatmap      bool
+	order             hostOrder               // S: config order or by connection history
+	used              map[string]hostUsage    // connection history by alias, for order and the last-connected column
+	flipForwards      bool                    // L: invert every host's forwards default for this session
+	forwardPicks      map[string]map[int]bool // alias -> forward index -> on, from the detail pane's digit keys
+	readOnly          bool                    // -read-only: nothing may modify the ssh or sshpick config
+	restrict          *restrictConfig
+	knownAliases      map[string][]string // other names sharing a host key in known_hosts
+	knownEntries      []knownHostEntry    // every known_hosts file the hosts use, read at startup
+	principals        map[string][]string // host certificate principals by alias
+	principalsPending map[string]bool
+	knownHosts        *knownHostsBrowser
+	bulk              *bulkEdit
+	inline            *inlineEdit
+	banners           map[string]hostBanner // pre-auth banners by alias (banner_preview)
+	bannersPending    map[string]bool
+	cards             map[string]cardStatus // smartcard listings by PKCS11Provider
+	cardsPending      map[string]bool
+	status            string // what the last key did, shown until the next key
 }
 
 type styles struct {
@@ -71,21 +161,52 @@ func defaultStyles() styles {
 }
 
 func parseSSHConfig(path string) ([]sshHost, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
+	hosts, _, err := parseSSHConfigWarnings(path)
+	return hosts, err
+}
 
+// maxIncludeDepth is how deeply Include directives may nest, as in ssh.
+const maxIncludeDepth = 16
+
+// parseSSHConfigWarnings is parseSSHConfig that also reports lines it could
+// not make sense of (unknown directives, missing values, bad ports).
+// Include directives are followed, and each host records the file it was
+// defined in.
+func parseSSHConfigWarnings(path string) ([]sshHost, []parseWarning, error) {
 	var (
+		warnings      []parseWarning
 		hosts         []sshHost
 		aliases       []string              // aliases for the current Host block
 		fields        = map[string]string{} // collected key/values for the block
+		options       = map[string]string{}
+		directives    []string
 		localForwards []string
+		identityFiles []string
 		notes         []string
+		annotations   map[string]string
+		hostPath      string
 		hostLine      int
+		reading       = map[string]bool{}                        // files being parsed, to refuse Include cycles
+		blocks        = []optionBlock{{Patterns: []string{"*"}}} // for Origins; lines before any Host apply to all
 	)
 
+	// comments are notes unless they're "sshpick:" annotations
+	addComment := func(c string) {
+		if c == "" {
+			return
+		}
+		if kv, ok := parseAnnotation(c); ok {
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			for k, v := range kv {
+				annotations[k] = v
+			}
+			return
+		}
+		notes = append(notes, c)
+	}
+
 	// helper to read a field or ""
 	get := func(k string) string {
 		if v, ok := fields[k]; ok {
@@ -114,93 +235,259 @@ func parseSSHConfig(path string) ([]sshHost, error) {
 				User:          user,
 				Port:          port,
 				LocalForwards: append([]string{}, localForwards...),
+				IdentityFiles: append([]string{}, identityFiles...),
 				Notes:         append([]string{}, notes...),
-				SourcePath:    path,
+				Options:       copyStringMap(options),
+				Directives:    append([]string{}, directives...),
+				Annotations:   copyStringMap(annotations),
+				SourcePath:    hostPath,
 				SourceLine:    hostLine,
+				Source:        "config",
 			}
-			// Fill IP if Hostname is an IP; otherwise try a DNS lookup (best-effort)
-			if h.Hostname != "" {
-				if ip := net.ParseIP(h.Hostname); ip != nil {
-					h.IP = ip.String()
-				} else if ips, err := net.LookupIP(h.Hostname); err == nil && len(ips) > 0 {
-					h.IP = ips[0].String()
-				}
-			}
+			h.IP = literalIP(h.Hostname)
 			hosts = append(hosts, h)
 		}
 		// reset for next block
 		aliases = nil
 		fields = map[string]string{}
+		options = map[string]string{}
+		directives = nil
 		localForwards = nil
+		identityFiles = nil
 		notes = nil
-		hostLine = 0
+		annotations = nil
+		hostPath, hostLine = "", 0
 	}
 
-	sc := bufio.NewScanner(f)
-	lineNo := 0
-	for sc.Scan() {
-		lineNo++
-		raw := sc.Text()
-		line := strings.TrimSpace(raw)
-		if line == "" {
-			continue
+	// Relative Include paths are resolved against the top-level config's
+	// directory (~/.ssh for the user config, /etc/ssh for the system one).
+	base := filepath.Dir(path)
+	var parseFile func(path string, depth int) error
+	parseFile = func(path string, depth int) error {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
 		}
-		if strings.HasPrefix(line, "#") {
-			if note := strings.TrimSpace(line[1:]); note != "" {
-				notes = append(notes, note)
-			}
-			continue
+		f, err := os.Open(path)
+		if err != nil {
+			return err
 		}
-		comment := ""
-		if idx := strings.Index(line, "#"); idx >= 0 {
-			comment = strings.TrimSpace(line[idx+1:])
-			line = strings.TrimSpace(line[:idx])
+		defer f.Close()
+		reading[abs] = true
+		defer delete(reading, abs)
+
+		sc := bufio.NewScanner(f)
+		lineNo := 0
+		for sc.Scan() {
+			lineNo++
+			raw := sc.Text()
+			line := strings.TrimSpace(raw)
 			if line == "" {
-				if comment != "" {
-					notes = append(notes, comment)
+				continue
+			}
+			if strings.HasPrefix(line, "#") {
+				addComment(strings.TrimSpace(line[1:]))
+				continue
+			}
+			if idx := strings.Index(line, "#"); idx >= 0 {
+				addComment(strings.TrimSpace(line[idx+1:]))
+				line = strings.TrimSpace(line[:idx])
+				if line == "" {
+					continue
 				}
+			}
+			// "Key=Value" and "Key = Value" are equivalent to "Key Value"
+			if i := strings.IndexAny(line, " \t="); i > 0 {
+				rest := strings.TrimLeft(line[i:], " \t")
+				if strings.HasPrefix(rest, "=") {
+					line = line[:i] + " " + strings.TrimLeft(rest[1:], " \t")
+				}
+			}
+			parts := strings.Fields(line)
+			key := strings.ToLower(parts[0])
+			if msg := directiveWarning(parts[0], strings.Join(parts[1:], " ")); msg != "" {
+				warnings = append(warnings, parseWarning{Path: path, Line: lineNo, Msg: msg})
+			}
+			if len(parts) < 2 {
 				continue
 			}
-		}
-		if comment != "" {
-			notes = append(notes, comment)
-		}
-		parts := strings.Fields(line)
-		if len(parts) < 2 {
-			continue
-		}
 
-		key := strings.ToLower(parts[0])
-		// value is the text after the key (preserves spaces inside)
-		value := strings.TrimSpace(line[len(parts[0]):])
-
-		switch key {
-		case "host":
-			// new block -> commit the previous one
-			commit()
-			// capture all aliases on this line
-			aliases = parts[1:]
-			hostLine = lineNo
-		case "hostname", "user", "port":
-			fields[key] = value
-		case "localforward":
-			if len(parts) >= 2 {
-				if port := extractLocalForwardPort(strings.TrimSpace(parts[1])); port != "" {
-					localForwards = append(localForwards, port)
+			// value is the text after the key (preserves spaces inside)
+			value := strings.TrimSpace(line[len(parts[0]):])
+			if key == "include" {
+				// The included lines are read in place, inside the current block.
+				for _, inc := range includedFiles(base, parts[1:]) {
+					switch {
+					case reading[inc]:
+						warnings = append(warnings, parseWarning{Path: path, Line: lineNo, Msg: fmt.Sprintf("Include cycle: %s is already being read", inc)})
+					case depth >= maxIncludeDepth:
+						warnings = append(warnings, parseWarning{Path: path, Line: lineNo, Msg: fmt.Sprintf("Include nested more than %d deep", maxIncludeDepth)})
+					default:
+						if err := parseFile(inc, depth+1); err != nil {
+							warnings = append(warnings, parseWarning{Path: path, Line: lineNo, Msg: fmt.Sprintf("Include %s: %v", inc, err)})
+						}
+					}
+				}
+				continue
+			}
+			switch key {
+			case "host":
+				blocks = append(blocks, optionBlock{Patterns: parts[1:], Path: path, Line: lineNo})
+			case "match":
+				blocks = append(blocks, optionBlock{Match: true, Path: path, Line: lineNo})
+			default:
+				cur := &blocks[len(blocks)-1]
+				cur.Options = append(cur.Options, optionOrigin{Key: key, Value: value, Path: path, Line: lineNo})
+			}
+			if _, seen := options[key]; !seen && key != "host" {
+				options[key] = value
+			}
+			if key != "host" {
+				directives = append(directives, key+" "+value)
+			}
+
+			switch key {
+			case "host":
+				// new block -> commit the previous one
+				commit()
+				// capture all aliases on this line
+				aliases = parts[1:]
+				hostPath, hostLine = path, lineNo
+			case "hostname", "user", "port":
+				fields[key] = value
+			case "localforward":
+				if len(parts) >= 2 {
+					if port := extractLocalForwardPort(strings.TrimSpace(parts[1])); port != "" {
+						localForwards = append(localForwards, port)
+					}
 				}
+			case "identityfile":
+				identityFiles = append(identityFiles, expandHome(strings.Trim(value, `"`)))
+			default:
+				// ignore other directives for now (ProxyJump, etc.)
 			}
-		default:
-			// ignore other directives for now (IdentityFile, ProxyJump, etc.)
 		}
+		return sc.Err()
+	}
+
+	if err := parseFile(path, 0); err != nil {
+		return nil, nil, err
 	}
 	// commit the last block
 	commit()
+	for i := range hosts {
+		hosts[i].Origins = effectiveOrigins(blocks, hosts[i].Alias)
+		hosts[i].Forwards = configForwards(hosts[i].Origins)
+		hosts[i].JumpChain = jumpChain(hosts[i].effectiveOption("proxyjump"))
+	}
+	return hosts, warnings, nil
+}
 
-	if err := sc.Err(); err != nil {
-		return nil, err
+// includedFiles expands the arguments of an Include directive: ~ and globs
+// are expanded, relative paths are taken from base, and patterns that
+// match nothing are ignored, as ssh does.
+func includedFiles(base string, patterns []string) []string {
+	var files []string
+	for _, p := range patterns {
+		p = expandHome(strings.Trim(p, `"`))
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(base, p)
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			continue
+		}
+		for _, m := range matches {
+			if abs, err := filepath.Abs(m); err == nil {
+				files = append(files, abs)
+			}
+		}
+	}
+	return files
+}
+
+// parseAnnotation recognizes "sshpick: key=value key2=value2" comments. A bare
+// key (no "=") is stored with the value "true".
+func parseAnnotation(comment string) (map[string]string, bool) {
+	const prefix = "sshpick:"
+	if len(comment) < len(prefix) || !strings.EqualFold(comment[:len(prefix)], prefix) {
+		return nil, false
+	}
+	kv := map[string]string{}
+	for _, field := range strings.Fields(comment[len(prefix):]) {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok {
+			v = "true"
+		}
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			kv[k] = strings.TrimSpace(v)
+		}
+	}
+	return kv, true
+}
+
+// annotationBool reports whether an annotation is set to a truthy value
+// ("true", "yes", "on" or "1"; a bare key counts as "true").
+func (h sshHost) annotationBool(key string) bool {
+	switch strings.ToLower(h.Annotations[key]) {
+	case "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+// copyStringMap copies a per-block string map, returning nil when empty.
+func copyStringMap(a map[string]string) map[string]string {
+	if len(a) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(a))
+	for k, v := range a {
+		out[k] = v
+	}
+	return out
+}
+
+// expandHome replaces a leading "~/" with the user's home directory.
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home := homeDir(); home != "" {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
+
+// literalIP returns host when it's already an IP address, else "". Loaders
+// use it so nothing waits on DNS; the picker resolves the rest in the
+// background (see resolveHostsCmd), other paths with resolveHostIPs.
+func literalIP(host string) string {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// resolveIP returns host itself if it's already an IP, otherwise the first
+// address from a DNS lookup through ipResolver (best-effort, "" on failure).
+func resolveIP(host string) string {
+	if host == "" {
+		return ""
+	}
+	if ip := literalIP(host); ip != "" {
+		return ip
+	}
+	if resolveTimeout <= 0 {
+		return ""
 	}
-	return hosts, nil
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	if ips, err := ipResolver.LookupIP(ctx, "ip", host); err == nil && len(ips) > 0 {
+		return ips[0].String()
+	}
+	return ""
 }
+
 func extractLocalForwardPort(arg string) string {
 	arg = strings.TrimSpace(arg)
 	if arg == "" {
@@ -216,34 +503,229 @@ func extractLocalForwardPort(arg string) string {
 }
 func initialModel(hosts []sshHost, localForward string, configPath string) model {
 	return model{
-		allHosts:     hosts,
-		hosts:        hosts,
-		title:        "Pick an SSH host",
-		styles:       defaultStyles(),
-		localForward: localForward,
-		configPath:   configPath,
+		allHosts:          hosts,
+		hosts:             hosts,
+		title:             tr("title.main"),
+		styles:            defaultStyles(),
+		localForward:      localForward,
+		configPath:        configPath,
+		historyPos:        -1,
+		stats:             map[string]hostStats{},
+		statsPending:      map[string]bool{},
+		who:               map[string]whoResult{},
+		whoPending:        map[string]bool{},
+		principals:        map[string][]string{},
+		principalsPending: map[string]bool{},
+		banners:           map[string]hostBanner{},
+		bannersPending:    map[string]bool{},
 	}
 }
 
-func (m model) Init() tea.Cmd { return nil }
+func (m model) Init() tea.Cmd {
+	var cmds []tea.Cmd
+	if m.showStats {
+		cmds = append(cmds, m.startStats())
+	}
+	if len(m.resolving) > 0 {
+		cmds = append(cmds, resolveHostsCmd(m.resolving))
+	}
+	if m.showReach {
+		cmds = append(cmds, m.startProbes(), probeTick(m.probeGen))
+	}
+	for _, c := range m.crawls {
+		cmds = append(cmds, c.fetchPage())
+	}
+	return tea.Batch(cmds...)
+}
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
-	case editorFinishedMsg:
+	case ipResolvedMsg:
+		m.applyResolved(msg)
+		return m, nil
+
+	case bestMirrorMsg:
+		m.measuring = false
+		m.latencyResults = msg.results
+		h, err := fastestHost(msg.results)
+		if err != nil {
+			m.err = trErr("err.best_mirror", err)
+			return m, nil
+		}
+		m.chosen = true
+		m.selectedHost = h
+		return m, tea.Quit
+
+	case promptActionMsg:
 		if msg.err != nil {
 			m.err = msg.err
+			return m, nil
+		}
+		if m.prompt != nil {
+			// The action may have fixed what a lookup found (a card
+			// inserted, a VPN brought up), so look again.
+			m.prechecked = nil
+			return m.beginConnect(m.prompt.host)
+		}
+		return m, nil
+
+	case precheckMsg:
+		if m.prompt == nil || m.prompt.pending == nil {
+			return m, nil
+		}
+		if m.prechecked == nil {
+			m.prechecked = map[string]any{}
+		}
+		m.prechecked[msg.key] = msg.result
+		if st, ok := msg.result.(cardStatus); ok {
+			if m.cards == nil {
+				m.cards = map[string]cardStatus{}
+			}
+			m.cards[st.provider] = st
+		}
+		return m.beginConnect(m.prompt.host)
+
+	case statsMsg:
+		delete(m.statsPending, msg.alias)
+		if m.stats == nil {
+			m.stats = map[string]hostStats{}
+		}
+		m.stats[msg.alias] = msg.stats
+		return m, nil
+
+	case reachMsg:
+		delete(m.reachPending, msg.alias)
+		if m.reach == nil {
+			m.reach = map[string]reachability{}
+		}
+		m.reach[msg.alias] = msg.result
+		if q, ok, _ := scopedQuery(m.lastValidRegex, m.filterScope); ok && q.usesChecks() && !m.filterActive {
+			m.applyFilter(m.lastValidRegex)
+		}
+		return m, nil
+
+	case probeTickMsg:
+		return m.updateProbeTick(msg)
+
+	case certPrincipalsMsg:
+		delete(m.principalsPending, msg.alias)
+		m.principals[msg.alias] = msg.principals
+		return m, nil
+
+	case cardStatusMsg:
+		delete(m.cardsPending, msg.provider)
+		if m.cards == nil {
+			m.cards = map[string]cardStatus{}
+		}
+		m.cards[msg.provider] = msg.status
+		return m, nil
+
+	case bannerMsg:
+		delete(m.bannersPending, msg.alias)
+		m.banners[msg.alias] = msg.banner
+		if err := saveBanners(bannerCachePath(), m.banners); err != nil {
+			m.err = trErr("err.banner_cache", err)
+		}
+		return m, nil
+
+	case whoMsg:
+		delete(m.whoPending, msg.alias)
+		m.who[msg.alias] = msg.result
+		return m, nil
+
+	case listenersMsg:
+		if m.ports != nil && m.ports.host.Alias == msg.alias {
+			p := *m.ports
+			p.pending, p.listeners, p.err = false, msg.listeners, msg.err
+			m.ports = &p
+		}
+		return m, nil
+
+	case hostDiffMsg:
+		if m.diff != nil {
+			m.diff.opts, m.diff.err = msg.opts, msg.err
 		}
 		return m, nil
 
+	case inventoryPageMsg:
+		return m.applyInventoryPage(msg)
+
+	case editorFinishedMsg:
+		// Reload even when the editor exits non-zero: the file may have
+		// been saved before it did.
+		m, cmd := m.reloadConfig()
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		return m, cmd
+
 	case tea.KeyMsg:
+		m.status = ""
+		if m.failure != nil {
+			return m.updateFailure(msg)
+		}
+		if m.usage != nil {
+			return m.updateUsage(msg)
+		}
+		if m.knownHosts != nil {
+			return m.updateKnownHosts(msg)
+		}
+		if m.bulk != nil {
+			return m.updateBulkEdit(msg)
+		}
+		if m.inline != nil {
+			return m.updateInlineEdit(msg)
+		}
+		if m.deps != nil {
+			return m.updateDependents(msg)
+		}
+		if m.ports != nil {
+			return m.updatePortPicker(msg)
+		}
+		if m.paste != nil {
+			return m.updatePasteGroup(msg)
+		}
+		if m.definitions != nil {
+			return m.updateDefinitions(msg)
+		}
+		if m.prompt != nil {
+			return m.updatePrompt(msg)
+		}
+		if m.actions != nil {
+			return m.updateActionMenu(msg)
+		}
+		if m.diff != nil {
+			return m.updateHostDiff(msg)
+		}
+		if m.dual != nil {
+			return m.updateDual(msg)
+		}
+		if m.transfer != nil {
+			return m.updateTransfer(msg)
+		}
+		if m.viewMenu != nil {
+			return m.updateViewMenu(msg)
+		}
+		if m.sourcePanel {
+			return m.updateSourcePanel(msg)
+		}
+		if m.showWarnings {
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "esc", "w", "q":
+				m.showWarnings = false
+			}
+			return m, nil
+		}
 		if m.filterActive {
 			switch msg.String() {
 			case "esc":
 				m.filterActive = false
 				m.filterErr = nil
-				m.filterQuery = m.lastValidRegex
-				m.applyFilter(m.lastValidRegex)
+				m.filterQuery, m.lastValidRegex = "", ""
+				m.applyFilter("")
 				return m, nil
 			case "enter":
 				pattern := m.filterQuery
@@ -253,8 +735,21 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				m.lastValidRegex = pattern
 				m.filterActive = false
+				m.rememberFilter(pattern)
+				return m, nil
+			case "up", "down":
+				m.browseFilterHistory(msg.String() == "up")
+				return m, nil
+			case "tab":
+				m.filterScope = m.filterScope.next()
+				m.applyFilter(m.filterQuery)
+				return m, nil
+			case "ctrl+r":
+				m.filterRegex = !m.filterRegex
+				m.filterErr = nil
+				m.applyFilter(m.filterQuery)
 				return m, nil
-			case "ctrl+c", "q":
+			case "ctrl+c":
 				return m, tea.Quit
 			case "backspace":
 				if m.filterQuery != "" {
@@ -268,7 +763,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return m, nil
 			default:
-				if msg.Type == tea.KeyRunes {
+				if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
 					// Avoid unbounded growth.
 					if len(m.filterQuery) < 256 {
 						m.filterQuery += string(msg.Runes)
@@ -280,6 +775,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		if msg.Paste {
+			// Pasted hosts bypass the allowlist, so restricted mode ignores them.
+			if m.restrict != nil {
+				return m, nil
+			}
+			return m.openPasteGroup(string(msg.Runes)), nil
+		}
+		if m.restrict != nil && !restrictedKeys[msg.String()] {
+			return m, nil
+		}
 		switch msg.String() {
 		case "ctrl+c", "q", "esc":
 			return m, tea.Quit
@@ -288,36 +793,199 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "j", "l", "down":
 			if len(m.hosts) > 0 {
 				m.cursor = (m.cursor + 1) % len(m.hosts)
+				return m, m.refreshDetail()
 			}
 		// up
 		case "k", "h", "up":
 			if len(m.hosts) > 0 {
 				m.cursor = (m.cursor - 1 + len(m.hosts)) % len(m.hosts)
+				return m, m.refreshDetail()
 			}
+		case " ", "space":
+			return m.toggleTag(), nil
 		case "enter":
+			if len(m.tagged) > 0 {
+				m.batch = m.taggedHosts()
+				return m, tea.Quit
+			}
 			if len(m.hosts) == 0 {
-				m.err = errors.New("no hosts to select")
+				m.err = trErr("err.no_hosts_select")
 				return m, nil
 			}
-			m.chosen = true
-			m.selectedHost = m.hosts[m.cursor]
-			return m, tea.Quit
+			if m.scpMode {
+				return m.openTransfer(), nil
+			}
+			m.verboseRetry = false
+			m.forwards = nil
+			return m.beginConnect(m.hosts[m.cursor])
 		case "n":
 			m.showNotes = !m.showNotes
+		case "b":
+			if len(m.hosts) == 0 {
+				m.err = trErr("err.no_hosts_measure")
+				return m, nil
+			}
+			if m.measuring {
+				return m, nil
+			}
+			m.measuring = true
+			m.err = nil
+			return m, measureHostsCmd(m.hosts)
+		case "f":
+			m.filterScope = m.filterScope.next()
+			m.applyFilter(m.lastValidRegex)
+		case "i":
+			m.showDetail = !m.showDetail
+			return m, m.refreshDetail()
+		case "g":
+			return m.openDefinitions(), nil
+		case "u":
+			m.showWho = !m.showWho
+			if m.showWho && len(m.hosts) > 0 {
+				return m, m.refreshWho(m.hosts[m.cursor])
+			}
+		case "M":
+			if m.readOnly {
+				m.err = errReadOnly
+				return m, nil
+			}
+			if len(m.hosts) == 0 {
+				return m, nil
+			}
+			entries, err := toggleMaintenance(maintenancePath(m.appConfig), m.hosts[m.cursor].Alias)
+			if err != nil {
+				m.err = trErr("err.maintenance", err)
+				return m, nil
+			}
+			m.maintenance = entries
+		case "*":
+			return m.toggleFavoriteHost(), nil
+		case "L":
+			m.flipForwards = !m.flipForwards
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			if m.showDetail {
+				return m.toggleForward(int(msg.String()[0] - '1')), nil
+			}
+		case "a":
+			return m.openActionMenu(), nil
+		case "K":
+			return m.openKnownHosts(), nil
+		case "E":
+			return m.openBulkEdit(), nil
+		case "c":
+			return m.openInlineEdit(), nil
+		case "J":
+			return m.openDependents(), nil
+		case "F":
+			return m.openPortPicker()
+		case "[", "ctrl+up":
+			return m.moveHostBlock(-1)
+		case "]", "ctrl+down":
+			return m.moveHostBlock(1)
+		case "H":
+			m.usageEntries = loadHistory(historyPath())
+			m.usage = summarizeHistory(m.usageEntries, time.Now())
+			if m.usage == nil {
+				m.usage = []hostUsage{}
+			}
+		case "o":
+			if len(m.hosts) == 0 {
+				return m, nil
+			}
+			h := m.hosts[m.cursor]
+			items := outOfBandActions(h)
+			if len(items) == 0 {
+				m.err = trErr("err.no_console", h.Alias)
+				return m, nil
+			}
+			m.err = nil
+			m.actions = &actionMenu{title: "Out-of-band access to " + h.Alias, items: items}
+		case "r":
+			if len(m.hosts) == 0 {
+				return m, nil
+			}
+			h := m.hosts[m.cursor]
+			items := desktopActions(h)
+			switch len(items) {
+			case 0:
+				m.err = trErr("err.no_desktop", h.Alias)
+				return m, nil
+			case 1:
+				return m.chooseAction(items[0])
+			}
+			m.err = nil
+			m.actions = &actionMenu{title: "Remote desktop on " + h.Alias, items: items}
+		case "s":
+			m.showStats = !m.showStats
+			if m.showStats {
+				return m, m.startStats()
+			}
+		case "P":
+			return m.toggleProbes()
+		case "X":
+			return m.fixHostPerms(), nil
+		case "S":
+			return m.cycleOrder(), nil
+		case "C":
+			m.compact = !m.compact
+		case "G":
+			return m.cycleGrouping(), nil
+		case "d":
+			if len(m.hosts) < 2 {
+				m.err = trErr("err.copy_two_hosts")
+				return m, nil
+			}
+			m.err = nil
+			m.dual = &dualPicker{cursor: [2]int{m.cursor, (m.cursor + 1) % len(m.hosts)}}
+			return m, nil
+		case "t":
+			return m.openTransfer(), nil
+		case "v":
+			return m.openViewMenu(), nil
+		case "D":
+			if len(m.hosts) < 2 {
+				m.err = trErr("err.compare_two_hosts")
+				return m, nil
+			}
+			m.err = nil
+			m.dual = &dualPicker{compare: true, cursor: [2]int{m.cursor, (m.cursor + 1) % len(m.hosts)}}
+			return m, nil
+		case "w":
+			if len(m.warnings) > 0 {
+				m.showWarnings = true
+			}
+		case "p":
+			m.sourcePanel = true
+			m.sourceCursor = 0
+			return m, nil
 		case "/":
 			m.filterActive = true
 			m.filterQuery = m.lastValidRegex
+			m.historyPos = -1
 			return m, nil
 		case "e":
+			if m.readOnly {
+				m.err = errReadOnly
+				return m, nil
+			}
 			if len(m.hosts) == 0 || m.configPath == "" {
-				m.err = errors.New("no config file to edit")
+				m.err = trErr("err.no_config")
+				return m, nil
+			}
+			if src := m.hosts[m.cursor].Source; src != "" && src != "config" {
+				m.err = trErr("err.not_config_host", m.hosts[m.cursor].Alias, src)
 				return m, nil
 			}
 			line := m.hosts[m.cursor].SourceLine
 			if line <= 0 {
 				line = 1
 			}
-			cmd, err := editorCommand(m.configPath, line)
+			// Hosts from an Included file are edited in that file.
+			path := m.hosts[m.cursor].SourcePath
+			if path == "" {
+				path = m.configPath
+			}
+			cmd, err := editorCommand(path, line)
 			if err != nil {
 				m.err = err
 				return m, nil
@@ -355,17 +1023,30 @@ func editorCommand(path string, line int) (*exec.Cmd, error) {
 	bin := parts[0]
 	baseArgs := parts[1:]
 
+	// GUI editors return at once unless told to wait, and the config is
+	// re-read as soon as the command exits.
 	switch filepath.Base(bin) {
 	case "code", "code-insiders", "cursor":
-		args := append(append([]string{}, baseArgs...), "--goto", fmt.Sprintf("%s:%d:1", path, line))
+		args := append([]string{}, baseArgs...)
+		if !hasArg(args, "-w", "--wait") {
+			args = append(args, "--wait")
+		}
+		args = append(args, "--goto", fmt.Sprintf("%s:%d:1", path, line))
 		return exec.Command(bin, args...), nil
-	case "vim", "nvim", "vi":
+	case "vim", "nvim", "vi", "emacs", "emacsclient", "kak", "micro":
 		args := append(append([]string{}, baseArgs...), fmt.Sprintf("+%d", line), path)
 		return exec.Command(bin, args...), nil
 	case "nano":
 		args := append(append([]string{}, baseArgs...), fmt.Sprintf("+%d,1", line), path)
 		return exec.Command(bin, args...), nil
 	case "subl", "sublime_text":
+		args := append([]string{}, baseArgs...)
+		if !hasArg(args, "-w", "--wait") {
+			args = append(args, "--wait")
+		}
+		args = append(args, fmt.Sprintf("%s:%d", path, line))
+		return exec.Command(bin, args...), nil
+	case "hx", "helix":
 		args := append(append([]string{}, baseArgs...), fmt.Sprintf("%s:%d", path, line))
 		return exec.Command(bin, args...), nil
 	default:
@@ -375,20 +1056,80 @@ func editorCommand(path string, line int) (*exec.Cmd, error) {
 	}
 }
 
+// hasArg reports whether args already contains one of the given flags.
+func hasArg(args []string, flags ...string) bool {
+	for _, a := range args {
+		for _, f := range flags {
+			if a == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filterScope selects which host fields the filter matches against.
+type filterScope int
+
+const (
+	scopeAll       filterScope = iota // every field including notes and forwards
+	scopeAlias                        // alias only
+	scopeAliasHost                    // alias, hostname and IP
+)
+
+var scopeNames = map[filterScope]string{
+	scopeAll:       "all",
+	scopeAlias:     "alias",
+	scopeAliasHost: "host",
+}
+
+func (s filterScope) String() string { return scopeNames[s] }
+
+func (s filterScope) next() filterScope { return (s + 1) % filterScope(len(scopeNames)) }
+
+func parseFilterScope(name string) (filterScope, error) {
+	for s, n := range scopeNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return s, nil
+		}
+	}
+	return scopeAll, fmt.Errorf("unknown filter fields %q (want alias, host or all)", name)
+}
+
 func filterHostsRegex(all []sshHost, pattern string) ([]sshHost, error) {
+	return filterHostsRegexScope(all, pattern, scopeAll)
+}
+
+func filterHostsRegexScope(all []sshHost, pattern string, scope filterScope) ([]sshHost, error) {
 	pattern = strings.TrimSpace(pattern)
 	if pattern == "" {
 		return all, nil
 	}
+	if key, rest, ok := directiveFilter(pattern); ok && scope == scopeAll {
+		return filterHostsDirective(all, key, rest)
+	}
 	re, err := regexp.Compile(pattern)
 	if err != nil {
 		return nil, err
 	}
 	out := make([]sshHost, 0, len(all))
 	for _, h := range all {
+		if scope == scopeAlias {
+			if re.MatchString(h.Alias) {
+				out = append(out, h)
+			}
+			continue
+		}
 		matched := re.MatchString(h.Alias) ||
 			re.MatchString(h.Hostname) ||
-			re.MatchString(h.IP) ||
+			re.MatchString(h.IP)
+		if scope == scopeAliasHost {
+			if matched {
+				out = append(out, h)
+			}
+			continue
+		}
+		matched = matched ||
 			re.MatchString(h.User) ||
 			re.MatchString(h.Port)
 		if !matched {
@@ -407,6 +1148,14 @@ func filterHostsRegex(all []sshHost, pattern string) ([]sshHost, error) {
 				}
 			}
 		}
+		if !matched {
+			for _, v := range h.directiveValues("") {
+				if re.MatchString(v) {
+					matched = true
+					break
+				}
+			}
+		}
 		if matched {
 			out = append(out, h)
 		}
@@ -414,62 +1163,262 @@ func filterHostsRegex(all []sshHost, pattern string) ([]sshHost, error) {
 	return out, nil
 }
 
-func (m *model) applyFilter(pattern string) {
-	filtered, err := filterHostsRegex(m.allHosts, pattern)
-	if err != nil {
-		m.filterErr = err
+// maxFilterHistory caps how many filter expressions are remembered.
+const maxFilterHistory = 20
+
+// rememberFilter moves pattern to the front of the filter history.
+func (m *model) rememberFilter(pattern string) {
+	if strings.TrimSpace(pattern) == "" {
 		return
 	}
-	m.filterErr = nil
-	m.hosts = filtered
-	if len(m.hosts) == 0 {
-		m.cursor = 0
-		return
+	hist := []string{pattern}
+	for _, p := range m.filterHistory {
+		if p != pattern {
+			hist = append(hist, p)
+		}
 	}
-	if m.cursor >= len(m.hosts) {
-		m.cursor = len(m.hosts) - 1
+	if len(hist) > maxFilterHistory {
+		hist = hist[:maxFilterHistory]
 	}
+	m.filterHistory = hist
 }
 
-func (m model) View() string {
-	if !m.ready {
-		return "loading...\n"
+// browseFilterHistory steps through previous filters in the filter input; the
+// text typed before browsing is kept as a draft and restored past the newest entry.
+func (m *model) browseFilterHistory(older bool) {
+	if len(m.filterHistory) == 0 {
+		return
 	}
-	var b strings.Builder
-
+	if m.historyPos == -1 {
+		m.filterDraft = m.filterQuery
+	}
+	pos := m.historyPos
+	if older {
+		if pos+1 >= len(m.filterHistory) {
+			return
+		}
+		pos++
+	} else {
+		if pos < 0 {
+			return
+		}
+		pos--
+	}
+	m.historyPos = pos
+	if pos == -1 {
+		m.filterQuery = m.filterDraft
+	} else {
+		m.filterQuery = m.filterHistory[pos]
+	}
+	m.applyFilter(m.filterQuery)
+}
+
+// applyFilter narrows the list to hosts matching pattern, fuzzy or as a
+// regex. The cursor stays on the highlighted host while it still matches;
+// otherwise a fuzzy filter moves it to the best match.
+func (m *model) applyFilter(pattern string) {
+	var filtered []sshHost
+	q, isQuery, err := scopedQuery(pattern, m.filterScope)
+	if isQuery {
+		if err != nil {
+			m.filterErr = err
+			return
+		}
+		filtered = m.filterHostsQuery(m.hostsInShownSources(), q)
+	} else if m.filterRegex {
+		var err error
+		if filtered, err = filterHostsRegexScope(m.hostsInShownSources(), pattern, m.filterScope); err != nil {
+			m.filterErr = err
+			return
+		}
+	} else {
+		filtered = filterHostsFuzzy(m.hostsInShownSources(), pattern, m.filterScope)
+	}
+	// A fuzzy query already ranks by match quality.
+	if m.filterRegex || isQuery || strings.TrimSpace(pattern) == "" {
+		filtered = m.orderHosts(filtered)
+	}
+	filtered = m.grouping.groupHosts(filtered)
+	m.filterErr = nil
+	current := ""
+	if m.cursor < len(m.hosts) {
+		current = m.hosts[m.cursor].Alias
+	}
+	m.hosts = filtered
+	if len(m.hosts) == 0 {
+		m.cursor = 0
+		return
+	}
+	for i, h := range m.hosts {
+		if h.Alias == current {
+			m.cursor = i
+			return
+		}
+	}
+	switch {
+	case !m.filterRegex:
+		m.cursor = 0
+	case m.cursor >= len(m.hosts):
+		m.cursor = len(m.hosts) - 1
+	}
+}
+
+func (m model) View() string {
+	if !m.ready {
+		return "loading...\n"
+	}
+	var b strings.Builder
+
+	if m.sourcePanel {
+		m.renderSourcePanel(&b)
+		return b.String()
+	}
+	if m.diff != nil {
+		m.renderHostDiff(&b)
+		return b.String()
+	}
+	if m.dual != nil {
+		m.renderDual(&b)
+		if m.err != nil {
+			fmt.Fprintln(&b, m.styles.error.Render(m.err.Error()))
+		}
+		return b.String()
+	}
+	if m.transfer != nil {
+		m.renderTransfer(&b)
+		return b.String()
+	}
+	if m.viewMenu != nil {
+		m.renderViewMenu(&b)
+		return b.String()
+	}
+	if m.usage != nil {
+		m.renderUsage(&b)
+		return b.String()
+	}
+	if m.knownHosts != nil {
+		m.renderKnownHosts(&b)
+		return b.String()
+	}
+	if m.bulk != nil {
+		m.renderBulkEdit(&b)
+		return b.String()
+	}
+	if m.deps != nil {
+		m.renderDependents(&b)
+		return b.String()
+	}
+	if m.ports != nil {
+		m.renderPortPicker(&b)
+		return b.String()
+	}
+	if m.paste != nil {
+		m.renderPasteGroup(&b)
+		return b.String()
+	}
+	if m.definitions != nil {
+		m.renderDefinitions(&b)
+		return b.String()
+	}
+	if m.showWarnings {
+		fmt.Fprintln(&b, m.styles.title.Render(tr("status.warnings.title", len(m.warnings))))
+		fmt.Fprintln(&b, m.styles.help.Render(tr("help.warnings")))
+		fmt.Fprintln(&b, "")
+		for _, w := range m.warnings {
+			fmt.Fprintln(&b, m.styles.error.Render(w.String()))
+		}
+		return b.String()
+	}
+
 	fmt.Fprintln(&b, m.styles.title.Render(m.title))
-	fmt.Fprintln(&b, m.styles.help.Render("Use h/j/k/l or arrows • / filter (regex) • e edit in $EDITOR • n notes • Enter connect • q quit"))
+	if m.failure != nil {
+		m.renderFailure(&b)
+		fmt.Fprintln(&b, "")
+	}
+	if m.restrict != nil {
+		fmt.Fprintln(&b, m.styles.help.Render(tr("help.restricted")))
+	} else {
+		fmt.Fprintln(&b, m.styles.help.Render(tr("help.main")))
+	}
 	if m.localForward != "" {
-		fmt.Fprintln(&b, m.styles.help.Render("Forwarding: "+m.localForward))
+		fmt.Fprintln(&b, m.styles.help.Render(tr("status.forwarding", m.localForward)))
 	}
-	if m.lastValidRegex != "" && !m.filterActive {
-		fmt.Fprintln(&b, m.styles.help.Render("Filter: /"+m.lastValidRegex+"/  (press / to edit, Backspace to clear)"))
+	if m.readOnly {
+		fmt.Fprintln(&b, m.styles.help.Render(tr("status.readonly")))
 	}
-	if m.filterActive {
-		fmt.Fprintln(&b, m.styles.help.Render("/ "+m.filterQuery+"  (Enter to apply, Esc to cancel)"))
-		if m.filterErr != nil {
-			fmt.Fprintln(&b, m.styles.error.Render("Invalid regex: "+m.filterErr.Error()))
+	if len(m.warnings) > 0 {
+		fmt.Fprintln(&b, m.styles.error.Render(tr("status.warnings", len(m.warnings))))
+	}
+	if len(m.hiddenSources) > 0 {
+		hidden := make([]string, 0, len(m.hiddenSources))
+		for _, s := range sourceCounts(m.allHosts) {
+			if m.hiddenSources[s.Name] {
+				hidden = append(hidden, s.Name)
+			}
 		}
+		fmt.Fprintln(&b, m.styles.help.Render(tr("status.hidden", strings.Join(hidden, ", "))))
+	}
+	if m.lastValidRegex != "" && !m.filterActive {
+		shown := m.lastValidRegex + " (fuzzy)"
+		if m.filterRegex {
+			shown = "/" + m.lastValidRegex + "/"
+		}
+		fmt.Fprintln(&b, m.styles.help.Render(tr("status.filter", shown, m.filterScope.String())))
+	}
+	if m.order != orderConfig {
+		fmt.Fprintln(&b, m.styles.help.Render(tr("status.order", m.order.String())))
+	}
+	if len(m.tagged) > 0 {
+		fmt.Fprintln(&b, m.styles.help.Render(tr("status.tagged", len(m.tagged))))
+	}
+	if m.measuring {
+		fmt.Fprintln(&b, m.styles.help.Render(tr("status.measuring", len(m.hosts))))
+	}
+	if s := m.crawlStatus(); s != "" {
+		fmt.Fprintln(&b, m.styles.help.Render(s))
 	}
 	fmt.Fprintln(&b, "")
 
 	if len(m.hosts) == 0 {
-		if strings.TrimSpace(m.lastValidRegex) != "" {
-			fmt.Fprintln(&b, m.styles.error.Render("No hosts match current filter"))
+		if m.filterActive {
+			m.renderFilterInput(&b)
+			fmt.Fprintln(&b, "")
+		}
+		if strings.TrimSpace(m.lastValidRegex) != "" || (m.filterActive && strings.TrimSpace(m.filterQuery) != "") {
+			fmt.Fprintln(&b, m.styles.error.Render(tr("empty.filter")))
+		} else if len(m.hiddenSources) > 0 {
+			fmt.Fprintln(&b, m.styles.error.Render(tr("empty.sources")))
 		} else {
-			fmt.Fprintln(&b, m.styles.error.Render("No hosts found in ~/.ssh/config"))
+			fmt.Fprintln(&b, m.styles.error.Render(tr("empty.config", m.configPath)))
 		}
 		return b.String()
 	}
 
+	highlight := m.lastValidRegex
+	if m.filterActive {
+		highlight = m.filterQuery
+	}
+	if m.filterRegex {
+		highlight = ""
+	}
+	now := time.Now()
 	for i, h := range m.hosts {
+		if header := m.groupHeader(i); header != "" {
+			fmt.Fprintln(&b, m.styles.title.Render("── "+header))
+		}
 		ipText := ""
 		if h.IP != "" {
 			ipText = "IP: " + h.IP
+		} else if m.resolving[h.Hostname] {
+			ipText = "IP: resolving…"
 		}
 
+		alias := h.Alias
+		if h.annotationBool("favorite") {
+			alias += " ★"
+		}
 		parts := []string{
-			fmt.Sprintf("%-15s", h.Alias),
+			fmt.Sprintf("%-15s", alias),
 			fmt.Sprintf("Hostname: %-25s", h.Hostname),
 		}
 		if h.Port != "" {
@@ -479,18 +1428,79 @@ func (m model) View() string {
 		if ipText != "" {
 			parts = append(parts, ipText)
 		}
-		if lfLen := len(h.LocalForwards); lfLen == 1 {
+		if last := m.lastConnected(h, now); last != "" {
+			parts = append(parts, last)
+		}
+		if kept, custom := m.pickedForwards(h); custom {
+			parts = append(parts, fmt.Sprintf("[forwards %d/%d]", len(kept), len(h.Forwards)))
+		} else if m.stripsForwards(h) {
+			parts = append(parts, "[forwards off]")
+		} else if lfLen := len(h.LocalForwards); lfLen == 1 {
 			parts = append(parts, h.LocalForwards[0])
 		} else if lfLen > 1 {
 			parts = append(parts, "LocalForward: "+strings.Join(h.LocalForwards, ","))
 		}
+		if needsTouch(h) {
+			parts = append(parts, "[touch key]")
+		}
+		if (h.Source != "" && h.Source != "config") || len(h.AlsoSources) > 0 {
+			parts = append(parts, "["+strings.Join(append([]string{hostSource(h)}, h.AlsoSources...), "+")+"]")
+		}
+		maint, inMaint := m.inMaintenance(h)
+		if inMaint {
+			parts = append(parts, "["+maint.describe()+"]")
+		}
 
-		line := strings.Join(parts, "  ")
+		// a checkmark column while any host is tagged for a batch
+		mark := ""
+		if len(m.tagged) > 0 {
+			mark = "  "
+			if m.tagged[h.Alias] {
+				mark = "✓ "
+			}
+		}
+		line := mark + strings.Join(parts, "  ")
+		if m.compact {
+			line = mark + alias
+		}
+		// matched runes of the alias and hostname, offset past the "> " prefix
+		hl := map[int]bool{}
+		for p := range fuzzyHighlights(highlight, h.Alias) {
+			hl[2+len(mark)+p] = true
+		}
+		for p := range fuzzyHighlights(highlight, h.Hostname) {
+			if m.compact {
+				break
+			}
+			hl[2+len(mark)+len(parts[0])+len("  Hostname: ")+p] = true
+		}
 
+		// the status cells carry their own color, so they go outside the row style
+		suffix := ""
+		if m.showReach {
+			if cell := m.reachCell(h); cell != "" {
+				suffix += "  " + cell
+			}
+		}
+		if m.showStats {
+			if cell := m.statsCell(h); cell != "" {
+				suffix += "  " + cell
+			}
+		}
 		if i == m.cursor {
-			fmt.Fprintln(&b, m.styles.selected.Render("> "+line))
+			fmt.Fprintln(&b, renderHighlighted(m.styles.selected, "> "+line, hl)+suffix)
+			if m.inline != nil {
+				m.renderInlineEdit(&b)
+			}
 		} else {
-			fmt.Fprintln(&b, m.styles.item.Render("  "+line))
+			style := m.styles.item
+			if c, ok := annotationColor(h.Annotations["color"]); ok {
+				style = style.Foreground(c)
+			}
+			if inMaint {
+				style = style.Foreground(lipgloss.Color("240")).Faint(true)
+			}
+			fmt.Fprintln(&b, renderHighlighted(style, "  "+line, hl)+suffix)
 		}
 		if m.showNotes && len(h.Notes) > 0 {
 			for _, note := range h.Notes {
@@ -502,69 +1512,716 @@ func (m model) View() string {
 		}
 	}
 
+	if m.filterActive {
+		fmt.Fprintln(&b, "")
+		m.renderFilterInput(&b)
+	}
+	if m.prompt != nil {
+		fmt.Fprintln(&b, "")
+		m.renderPrompt(&b)
+	}
+	if m.actions != nil {
+		fmt.Fprintln(&b, "")
+		m.renderActionMenu(&b)
+	}
+
+	if m.showDetail && m.cursor < len(m.hosts) {
+		fmt.Fprintln(&b, "")
+		for _, line := range detailLines(m.hosts[m.cursor]) {
+			fmt.Fprintln(&b, m.styles.help.Render("  "+line))
+		}
+		for _, line := range m.cardLines(m.hosts[m.cursor]) {
+			fmt.Fprintln(&b, m.styles.help.Render("  "+line))
+		}
+		for _, line := range m.forwardLines(m.hosts[m.cursor]) {
+			fmt.Fprintln(&b, m.styles.help.Render("  "+line))
+		}
+		for _, line := range m.permLines(m.hosts[m.cursor]) {
+			fmt.Fprintln(&b, m.styles.error.Render("  "+line))
+		}
+		fmt.Fprintln(&b, m.styles.help.Render("  "+hostKeyLine(m.hosts[m.cursor])))
+		if line := m.knownAsLine(m.hosts[m.cursor]); line != "" {
+			fmt.Fprintln(&b, m.styles.help.Render("  "+line))
+		}
+		for _, line := range m.bannerLines(m.hosts[m.cursor]) {
+			fmt.Fprintln(&b, m.styles.help.Render("  "+line))
+		}
+	}
+	if m.showWho && m.cursor < len(m.hosts) {
+		if !m.showDetail {
+			fmt.Fprintln(&b, "")
+		}
+		for _, line := range m.whoLines(m.hosts[m.cursor]) {
+			fmt.Fprintln(&b, m.styles.help.Render("  "+line))
+		}
+	}
+
 	if m.err != nil {
 		fmt.Fprintln(&b, "")
 		fmt.Fprintln(&b, m.styles.error.Render(m.err.Error()))
+	} else if m.status != "" {
+		fmt.Fprintln(&b, "")
+		fmt.Fprintln(&b, m.styles.help.Render(m.status))
 	}
 	return b.String()
 }
 
-func runSSH(host string, localForward string) error {
-	// Replace current process with ssh for clean TTY behavior
-	bin, err := exec.LookPath("ssh")
+// namedColors maps friendly color names to ANSI 256 palette indexes.
+var namedColors = map[string]string{
+	"black":   "0",
+	"red":     "9",
+	"green":   "10",
+	"yellow":  "11",
+	"blue":    "12",
+	"magenta": "13",
+	"purple":  "13",
+	"cyan":    "14",
+	"white":   "15",
+	"gray":    "245",
+	"grey":    "245",
+	"orange":  "208",
+	"pink":    "212",
+}
+
+// annotationColor accepts a color name, an ANSI palette index or a #rrggbb hex value.
+func annotationColor(v string) (lipgloss.Color, bool) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "" {
+		return "", false
+	}
+	if c, ok := namedColors[v]; ok {
+		return lipgloss.Color(c), true
+	}
+	if strings.HasPrefix(v, "#") && (len(v) == 4 || len(v) == 7) {
+		return lipgloss.Color(v), true
+	}
+	if n, err := strconv.Atoi(v); err == nil && n >= 0 && n <= 255 {
+		return lipgloss.Color(v), true
+	}
+	return "", false
+}
+
+func runSSH(args []string) error {
+	return execTool("ssh", args)
+}
+
+// execTool replaces the current process with name (ssh, scp, ...) for clean
+// TTY behavior; it only returns on failure.
+func execTool(name string, args []string) error {
+	bin, err := exec.LookPath(name)
 	if err != nil {
 		return err
 	}
-	args := []string{"ssh"}
-	if localForward != "" {
-		args = append(args, "-L", localForward)
+	return syscall.Exec(bin, append([]string{name}, args...), os.Environ())
+}
+
+// launchOptions are the command-line settings that shape the ssh invocation.
+type launchOptions struct {
+	localForward    string
+	forwards        []string // more -L specs, e.g. picked from the remote's listening ports
+	remoteForwards  []string // -R specs picked in the detail pane
+	dynamicForwards []string // -D specs picked in the detail pane
+	configFile      string   // -F: the config written by pickForwards
+	clearForwards   bool     // connect without the config's forwards (ClearAllForwardings)
+	clipboardPort   int      // local clipboard relay, tunneled from clipboardRemote on the host
+	clipboardRemote int
+	address         string // address that won the Happy Eyeballs race, passed as HostName
+	shareBastion    bool
+	subprocess      bool   // keep sshpick running under ssh even without hooks or notify
+	verboseLog      string // when set, ssh runs with -vvv and logs debug output here
+	tagDefaults     map[string]tagDefaults
+	hooks           hooksConfig
+	notify          notifyConfig
+}
+
+// sshArgs builds the ssh arguments (without argv[0]) for connecting to h.
+func sshArgs(h sshHost, opts launchOptions) []string {
+	var args []string
+	if opts.verboseLog != "" {
+		args = append(args, "-vvv", "-E", opts.verboseLog)
+	}
+	if opts.localForward != "" {
+		args = append(args, "-L", opts.localForward)
+	}
+	for _, f := range opts.forwards {
+		args = append(args, "-L", f)
+	}
+	for _, f := range opts.remoteForwards {
+		args = append(args, "-R", f)
+	}
+	for _, f := range opts.dynamicForwards {
+		args = append(args, "-D", f)
+	}
+	if len(opts.explicitForwards()) > 0 {
+		args = append(args, "-o", "ExitOnForwardFailure=yes")
+	}
+	args = append(args, clipboardForwardArgs(opts)...)
+	args = append(args, clearForwardingArgs(opts)...)
+	if opts.shareBastion {
+		args = append(args, bastionArgs(h)...)
+	}
+	args = append(args, tagOptionArgs(h, opts.tagDefaults)...)
+	args = append(args, hostKeyArgs(true)...)
+	if opts.address != "" {
+		args = append(args, eyeballsArgs(h, opts.address)...)
+	}
+	if opts.configFile != "" {
+		args = append(args, "-F", opts.configFile)
+		h.Alias = pickedHostAlias(h)
+		return append(args, destinationArgs(h)...)
+	}
+	return append(args, hostArgs(h)...)
+}
+
+// sshTarget is the destination handed to ssh: the alias for hosts from the
+// ssh config, [user@]hostname for hosts ssh itself doesn't know about.
+func sshTarget(h sshHost) string {
+	if hostSource(h) == "config" || h.Hostname == "" {
+		return h.Alias
+	}
+	if h.User != "" {
+		return h.User + "@" + h.Hostname
 	}
-	args = append(args, host)
-	return syscall.Exec(bin, args, os.Environ())
+	return h.Hostname
+}
+
+// hostArgs are the config file from -ssh-dir, if any, then destinationArgs.
+func hostArgs(h sshHost) []string {
+	return append(configFileArgs(), destinationArgs(h)...)
+}
+
+// destinationArgs spell out the port, jump host and identity of hosts that
+// aren't in the ssh config, followed by "--" and the destination, so a
+// destination can never be read as an option.
+func destinationArgs(h sshHost) []string {
+	var args []string
+	if hostSource(h) != "config" {
+		if h.Port != "" {
+			args = append(args, "-p", h.Port)
+		}
+		if pc := h.option("proxycommand"); pc != "" {
+			args = append(args, "-o", "ProxyCommand="+pc)
+		} else if jump := h.option("proxyjump"); jump != "" {
+			args = append(args, "-J", jump)
+		}
+		for _, id := range h.IdentityFiles {
+			args = append(args, "-i", id)
+		}
+	}
+	return append(args, "--", sshTarget(h))
 }
 
 func main() {
-	var cfgPath, localForward string
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "doctor":
+			os.Exit(runDoctor(os.Args[2:], os.Stdout))
+		case "lint":
+			os.Exit(runLint(os.Args[2:], os.Stdout))
+		case "auth":
+			os.Exit(runAuth(os.Args[2:], os.Stdout))
+		case "workspace":
+			os.Exit(runWorkspace(os.Args[2:], os.Stdout))
+		case "keys":
+			os.Exit(runKeys(os.Args[2:], os.Stdout))
+		case "fmt":
+			os.Exit(runFmt(os.Args[2:], os.Stdout))
+		case "onboard":
+			os.Exit(runOnboard(os.Args[2:], os.Stdout))
+		case "versions":
+			os.Exit(runVersions(os.Args[2:], os.Stdout))
+		case "check":
+			os.Exit(runCheck(os.Args[2:], os.Stdout))
+		case "run":
+			os.Exit(runRun(os.Args[2:], os.Stdout))
+		case "daemon":
+			os.Exit(runDaemon(os.Args[2:]))
+		case "launcher":
+			os.Exit(runLauncher(os.Args[2:], os.Stdout))
+		case "connect":
+			os.Exit(runConnect(os.Args[2:]))
+		case "open":
+			os.Exit(runOpen(os.Args[2:]))
+		}
+	}
+
+	var cfgPath, localForward, promSource, settingsPath, printMode string
+	var listMode, jsonMode bool
+	var fresh, shareBastion, notify, showStats, showReach, dumpCatalog, subprocess, readOnly, happyEyeballs, scpMode bool
+	var filterFields, bestPattern, restrictPath, viewName string
 	flag.StringVar(&cfgPath, "config", "", "Path to ssh config (default: ~/.ssh/config)")
+	flag.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	flag.StringVar(&settingsPath, "settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
 	flag.StringVar(&localForward, "L", "", "Local port forward (e.g. 8080:localhost:8080)")
+	flag.StringVar(&promSource, "prometheus", "", "Prometheus server URL or file_sd JSON file to import scrape targets from")
+	flag.StringVar(&filterFields, "filter-fields", "", "Fields the filter matches: alias, host (alias+hostname+IP) or all")
+	flag.StringVar(&bestPattern, "best", "", "Measure latency to hosts matching this regex and connect to the fastest")
+	flag.BoolVar(&shareBastion, "share-bastion", false, "Reuse one ControlMaster connection per ProxyJump bastion")
+	flag.BoolVar(&subprocess, "subprocess", false, "Run ssh as a child process and explain connection failures in the picker")
+	flag.BoolVar(&notify, "notify", false, "Run ssh as a subprocess and notify when the session ends")
+	flag.BoolVar(&showStats, "stats", false, "Show remote load/disk stats (fetched over ssh in BatchMode)")
+	flag.BoolVar(&showReach, "probe", false, "Probe every visible host's ssh port in the background and show up/down with latency")
+	flag.StringVar(&restrictPath, "restrict", "", "Admin allowlist (JSON) limiting selectable hosts and overrides, for shared bastions")
+	flag.BoolVar(&happyEyeballs, "happy-eyeballs", false, "Race a host's IPv6/IPv4 addresses and connect to the first that answers")
+	flag.BoolVar(&dumpCatalog, "dump-messages", false, "Print the English UI strings as JSON, the template for a translation, and exit")
+	flag.BoolVar(&listMode, "list", false, "Print the hosts (optionally only those matching a regex argument) as a table and exit")
+	flag.BoolVar(&jsonMode, "json", false, "Print the hosts (optionally only those matching a regex argument) as JSON and exit")
+	flag.StringVar(&printMode, "print", "", "Print the picked host instead of connecting: alias or command (the full ssh command line)")
+	flag.BoolVar(&readOnly, "read-only", false, "Disable every feature that modifies the ssh or sshpick config (for shared jump boxes)")
+	flag.BoolVar(&scpMode, "scp", false, "Enter opens the file transfer (scp/sftp) prompt instead of connecting")
+	flag.StringVar(&viewName, "view", "", "Open with a saved view (its filter, sort, grouping and columns), by name")
+	flag.BoolVar(&fresh, "fresh", false, "Start with a clean UI state instead of restoring the last session")
+	flag.DurationVar(&resolveTimeout, "resolve-timeout", resolveTimeout, "Timeout for each background DNS lookup of the IP column; 0 disables lookups")
 	flag.Parse()
 
+	settings, err := loadAppConfig(settingsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+		os.Exit(1)
+	}
+	if err := validPrintMode(printMode); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validHostKeyPolicy(settings.HostKeyPolicy); err != nil {
+		fmt.Fprintln(os.Stderr, "error in sshpick config:", err)
+		os.Exit(1)
+	}
+	if err := settings.Batch.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "error in sshpick config:", err)
+		os.Exit(1)
+	}
+	providers, err := settings.hostProviders()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error in sshpick config:", err)
+		os.Exit(1)
+	}
+	hostKeyPolicy = settings.HostKeyPolicy
+	if palette, err = loadPalette(settings.Theme, settings.StatusShapes); err != nil {
+		fmt.Fprintln(os.Stderr, "error in sshpick config:", err)
+		os.Exit(1)
+	}
+	if settings.Language != "" {
+		if messages, err = loadCatalog(settings.Language); err != nil {
+			fmt.Fprintln(os.Stderr, "error in sshpick config:", err)
+			os.Exit(1)
+		}
+	} else if messages, err = loadCatalog(languageFromEnv()); err != nil && !errors.Is(err, errNoTranslation) {
+		fmt.Fprintln(os.Stderr, "error loading translation:", err)
+		os.Exit(1)
+	}
+	if dumpCatalog {
+		if err := dumpMessages(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if ipResolver, err = newResolver(settings.Resolver); err != nil {
+		fmt.Fprintln(os.Stderr, "error in resolver settings:", err)
+		os.Exit(1)
+	}
+	launch := launchOptions{localForward: localForward, shareBastion: shareBastion, subprocess: subprocess, hooks: settings.Hooks, notify: settings.Notify, tagDefaults: settings.TagDefaults}
+	if notify {
+		launch.notify.Enabled = true
+	}
+	scope := scopeAll
+	if filterFields != "" {
+		var err error
+		if scope, err = parseFilterScope(filterFields); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
 	if cfgPath == "" {
-		cfgPath = filepath.Join(os.Getenv("HOME"), ".ssh", "config")
+		cfgPath = defaultConfigPath()
 	}
 
-	hosts, err := parseSSHConfig(cfgPath)
+	hosts, warnings, err := parseSSHConfigWarnings(cfgPath)
+	if os.IsNotExist(err) && cfgPath != "" && isInteractive() && !readOnly && !settings.ReadOnly && restrictPath == "" && bestPattern == "" && !listMode && !jsonMode && printMode == "" {
+		// First run: offer to set things up instead of showing an empty list.
+		created, setupErr := firstRunSetup(prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout}, cfgPath)
+		if setupErr != nil {
+			fmt.Fprintln(os.Stderr, "setup:", setupErr)
+			os.Exit(1)
+		}
+		if created {
+			hosts, warnings, err = parseSSHConfigWarnings(cfgPath)
+		}
+	}
 	if err != nil && !os.IsNotExist(err) {
 		fmt.Fprintln(os.Stderr, "error reading config:", err)
 		os.Exit(1)
 	}
-	p := tea.NewProgram(initialModel(hosts, localForward, cfgPath), tea.WithAltScreen())
-	m, err := p.Run()
+	if promSource != "" {
+		promHosts, err := loadPrometheusHosts(promSource, settings.ProviderProxy)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error reading prometheus targets:", err)
+			os.Exit(1)
+		}
+		hosts = mergeHosts(hosts, promHosts)
+	}
+	// The picker streams the pages of paged inventories in; the other
+	// modes need the whole list up front.
+	streaming := !listMode && !jsonMode && bestPattern == ""
+	var crawls []*inventoryCrawler
+	for _, p := range providers {
+		load := loadAllPages
+		if streaming {
+			load = hostProvider.hosts
+		}
+		extra, warning, err := load(p)
+		if warning != "" {
+			fmt.Fprintln(os.Stderr, "warning:", warning)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "warning: skipping host source:", err)
+			continue
+		}
+		hosts = mergeHosts(hosts, extra)
+		if ic, ok := p.(inventoryConfig); ok && streaming {
+			if c := ic.crawler(); c != nil {
+				crawls = append(crawls, c)
+			}
+		}
+	}
+	if len(crawls) == 0 {
+		closeTunnels()
+	}
+	var restrict *restrictConfig
+	if restrictPath != "" {
+		if restrict, err = loadRestrict(restrictPath); err != nil {
+			fmt.Fprintln(os.Stderr, "error reading allowlist:", err)
+			os.Exit(1)
+		}
+		hosts = restrictHosts(hosts, restrict)
+		if localForward != "" && !restrict.allowsOption("L") {
+			fmt.Fprintln(os.Stderr, "-L is not allowed here")
+			os.Exit(2)
+		}
+		if bestPattern != "" || shareBastion {
+			fmt.Fprintln(os.Stderr, "-best and -share-bastion are not allowed here")
+			os.Exit(2)
+		}
+	}
+	meta, metaWarnings := hostMetadata(settings, true)
+	for _, w := range metaWarnings {
+		fmt.Fprintln(os.Stderr, "warning:", w)
+	}
+	hosts = favoritesFirst(applyMetadata(hosts, meta))
+	if listMode || jsonMode {
+		// no picker to fill the IPs in later; ip: queries need them too
+		resolveHostIPs(hosts)
+		listed, err := filterHostsRegexScope(hosts, flag.Arg(0), scope)
+		if q, ok, qerr := scopedQuery(flag.Arg(0), scope); ok {
+			listed, err = model{}.filterHostsQuery(hosts, q), qerr
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid pattern:", err)
+			os.Exit(2)
+		}
+		write := writeHostsTable
+		if jsonMode {
+			write = writeHostsJSON
+		}
+		if err := write(os.Stdout, listed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if bestPattern != "" {
+		runBestMirror(hosts, bestPattern, scope, launch)
+		return
+	}
+	start := initialModel(hosts, localForward, cfgPath)
+	start.warnings = warnings
+	start.resolving = unresolvedHostnames(hosts)
+	start.appConfig = settings
+	if settings.BannerPreview {
+		start.banners = loadBanners(bannerCachePath())
+	}
+	if start.maintenance, err = loadMaintenance(maintenancePath(settings)); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not read maintenance file:", err)
+	}
+	start.showStats = showStats
+	start.showReach = showReach
+	start.scpMode = scpMode
+	start.crawls = crawls
+	start.readOnly = readOnly || settings.ReadOnly || restrict != nil
+	start.restrict = restrict
+	start.knownEntries = loadAllKnownHosts(hosts)
+	start.knownAliases = knownAliases(start.knownEntries)
+	history, err := trimHistory(historyPath(), loadHistory(historyPath()), maxHistoryEntries)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "tui error:", err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, "warning: could not trim history:", err)
+	}
+	start.used = usageByAlias(summarizeHistory(history, time.Now()))
+	stPath := statePath()
+	if !fresh {
+		start.restoreState(loadState(stPath))
+	}
+	if filterFields != "" {
+		start.filterScope = scope
+		start.applyFilter(start.lastValidRegex)
+	}
+	if viewName != "" {
+		v, ok := start.findView(viewName)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "no saved view %q\n", viewName)
+			os.Exit(2)
+		}
+		// Init starts the checks of the view's columns; the commands
+		// returned here would never run, so nothing is in flight yet.
+		start, _ = start.applyView(v)
+		start.statsPending, start.reachPending = nil, nil
+		if start.err != nil {
+			fmt.Fprintf(os.Stderr, "view %s: %v\n", viewName, start.err)
+			os.Exit(2)
+		}
 	}
+	for {
+		programOpts := []tea.ProgramOption{tea.WithAltScreen()}
+		if printMode != "" {
+			// stdout is for the answer: draw on stderr, read keys from the
+			// terminal even when stdin is a pipe.
+			programOpts = append(programOpts, tea.WithOutput(os.Stderr))
+			if !isInteractive() {
+				programOpts = append(programOpts, tea.WithInputTTY())
+			}
+		}
+		p := tea.NewProgram(start, programOpts...)
+		m, err := p.Run()
+		closeTunnels()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "tui error:", err)
+			os.Exit(1)
+		}
 
-	final := m.(model)
-	if !final.chosen || final.selectedHost.Alias == "" {
-		return
+		final := m.(model)
+		if err := saveState(stPath, final.snapshotState()); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: could not save state:", err)
+		}
+		if len(final.latencyResults) > 0 {
+			printLatencyTable(os.Stderr, final.latencyResults)
+		}
+		if printMode != "" && !final.chosen {
+			if err := printPicked(os.Stdout, printMode, final, launch); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+		if len(final.transferArgs) > 0 {
+			if final.transferDone != nil {
+				if err := rememberTransfer(transfersPath(), *final.transferDone); err != nil {
+					fmt.Fprintln(os.Stderr, "warning: could not remember transfer:", err)
+				}
+			}
+			runTransfer(final.transferTool, final.transferArgs)
+			return
+		}
+		if final.handoff != nil {
+			runHandoff(*final.handoff)
+			return
+		}
+		if len(final.batch) > 0 {
+			if err := launchBatch(final.batch, settings.Batch, launch, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+		if w := final.workspace; w != nil {
+			if err := workspaceUp(w.name, w.ws, w.hosts); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+		if !final.chosen || final.selectedHost.Alias == "" {
+			return
+		}
+		opts := launch
+		opts.forwards = final.forwards
+		opts.clearForwards = final.stripsForwards(final.selectedHost)
+		if kept, custom := final.pickedForwards(final.selectedHost); custom {
+			if err := opts.pickForwards(final.selectedHost, kept, cfgPath); err != nil {
+				fmt.Fprintln(os.Stderr, "cannot connect with the picked forwards:", err)
+				os.Exit(1)
+			}
+		}
+		if happyEyeballs || settings.HappyEyeballs {
+			if addr, err := fastestAddress(final.selectedHost); err == nil {
+				opts.address = addr
+			}
+		}
+		if final.verboseRetry {
+			opts.verboseLog = verboseLogPath(final.selectedHost)
+		}
+		if printMode != "" {
+			printSelection(os.Stdout, printMode, final.selectedHost, opts)
+			return
+		}
+		failure := connectHost(final.selectedHost, opts)
+		if failure == nil {
+			return
+		}
+		// Back to the picker with the failure explained.
+		start = final.resumeAfter(failure)
+	}
+}
+
+// runTransfer hands the terminal over to scp, exiting on failure.
+func runTransfer(tool string, args []string) {
+	if tool == "" {
+		tool = "scp"
+	}
+	if err := execTool(tool, args); err != nil {
+		if e := runToolSubprocess(tool, args); e != nil {
+			fmt.Fprintln(os.Stderr, tool+" error:", e)
+			os.Exit(1)
+		}
 	}
+}
 
+// runBestMirror measures every host matching pattern and connects to the
+// fastest one without starting the TUI.
+func runBestMirror(hosts []sshHost, pattern string, scope filterScope, launch launchOptions) {
+	matched, err := filterHostsRegexScope(hosts, pattern, scope)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid -best pattern:", err)
+		os.Exit(2)
+	}
+	if len(matched) == 0 {
+		fmt.Fprintln(os.Stderr, "no hosts match", pattern)
+		os.Exit(1)
+	}
+	results := measureHosts(matched, latencyTimeout)
+	printLatencyTable(os.Stderr, results)
+	h, err := fastestHost(results)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "best mirror:", err)
+		os.Exit(1)
+	}
+	if f := connectHost(h, launch); f != nil {
+		os.Exit(f.code)
+	}
+}
+
+// connectHost hands the terminal over to ssh for h, exiting on failure.
+// Pre hooks run first; when post hooks, notifications or -subprocess apply,
+// ssh runs as a subprocess so they can run after the session ends. A
+// subprocess ssh that fails to connect is returned so the TUI can explain it.
+func connectHost(h sshHost, opts launchOptions) *sessionFailure {
+	hooks := opts.hooks.hooksFor(h)
+	if err := runHooks("pre", hooks.Pre, hookEnv(h)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := knockBeforeConnect(h, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: skipping port knock:", err)
+	}
+	if stop, err := startClipboardBridge(h, &opts); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: no clipboard bridge:", err)
+	} else {
+		defer stop()
+	}
+	if opts.verboseLog != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.verboseLog), 0o700); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: no debug log:", err)
+			opts.verboseLog = ""
+		}
+	}
+	args := sshArgs(h, opts)
+	// Forwards run under sshpick so a taken local port can be retried.
+	forwarding := len(opts.explicitForwards()) > 0
+	if opts.subprocess || len(hooks.Post) > 0 || opts.notify.Enabled || forwarding {
+		tail := &tailWriter{n: stderrTailLines}
+		start := time.Now()
+		var err error
+		if forwarding {
+			tail, err = runSSHForwarding(h, &opts)
+		} else {
+			err = runSSHSubprocessTee(args, tail)
+		}
+		code, elapsed := exitCode(err), time.Since(start)
+		if herr := runHooks("post", hooks.Post, postHookEnv(h, code, elapsed)); herr != nil {
+			fmt.Fprintln(os.Stderr, herr)
+		}
+		if nerr := notifySessionEnd(opts.notify, h, code, elapsed, os.Stdout); nerr != nil {
+			fmt.Fprintln(os.Stderr, "warning: notification failed:", nerr)
+		}
+		exit := code
+		if herr := appendHistory(historyPath(), historyEntry{Alias: h.Alias, Source: hostSource(h), Start: start, Duration: elapsed.Seconds(), Exit: &exit}); herr != nil {
+			fmt.Fprintln(os.Stderr, "warning: could not record history:", herr)
+		}
+		if code == sshFailureCode {
+			f := &sessionFailure{host: h, code: code, lines: tail.Lines(), logPath: opts.verboseLog}
+			if f.logPath != "" {
+				f.lines = append(f.lines, relevantLogLines(f.logPath, stderrTailLines)...)
+			}
+			return f
+		}
+		if opts.verboseLog != "" {
+			fmt.Fprintln(os.Stderr, "ssh debug log:", opts.verboseLog)
+		}
+		os.Exit(code)
+	}
+	// ssh replaces sshpick below, so only the start can be recorded.
+	if herr := appendHistory(historyPath(), historyEntry{Alias: h.Alias, Source: hostSource(h), Start: time.Now()}); herr != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not record history:", herr)
+	}
 	// Prefer a clean handoff to ssh (replaces current process).
-	if err := runSSH(final.selectedHost.Alias, localForward); err != nil {
+	if err := runSSH(args); err != nil {
 		// Fallback: spawn ssh as a subprocess.
-		args := []string{}
-		if localForward != "" {
-			args = append(args, "-L", localForward)
-		}
-		args = append(args, final.selectedHost.Alias)
-		cmd := exec.Command("ssh", args...)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if e := cmd.Run(); e != nil {
+		if e := runSSHSubprocess(args); e != nil {
 			fmt.Fprintln(os.Stderr, "ssh error:", e)
 			os.Exit(1)
 		}
 	}
+	return nil
+}
+
+// runSSHSubprocess runs ssh as a child that owns the terminal. sshpick
+// catches and drops SIGINT/SIGQUIT meanwhile, so Ctrl+C typed at an MFA or
+// password prompt stops ssh without killing sshpick underneath it.
+func runSSHSubprocess(args []string) error {
+	return runToolSubprocess("ssh", args)
+}
+
+// runSSHSubprocessTee is runSSHSubprocess with ssh's stderr also copied to
+// tail. Prompts still work: ssh asks for passwords on /dev/tty.
+func runSSHSubprocessTee(args []string, tail io.Writer) error {
+	return runToolSubprocessTo("ssh", args, io.MultiWriter(os.Stderr, tail))
+}
+
+func runToolSubprocess(name string, args []string) error {
+	return runToolSubprocessTo(name, args, os.Stderr)
+}
+
+func runToolSubprocessTo(name string, args []string, stderr io.Writer) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = stderr
+	// Caught, not ignored: an ignored signal stays ignored in the child
+	// across exec, and ssh must still stop on Ctrl+C.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGQUIT)
+	done := make(chan struct{})
+	defer func() {
+		signal.Stop(sigs)
+		close(done)
+	}()
+	go func() {
+		for {
+			select {
+			case <-sigs:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return cmd.Run()
 }
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"errors"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -53,6 +58,23 @@ func TestFilterHostsRegex(t *testing.T) {
 		}
 	})
 
+	t.Run("scope limits matched fields", func(t *testing.T) {
+		out, err := filterHostsRegexScope(hosts, "primary|staging", scopeAliasHost)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(out) != 1 || out[0].Alias != "stage" {
+			t.Fatalf("expected notes ignored, got %#v", out)
+		}
+		out, err = filterHostsRegexScope(hosts, "staging|db", scopeAlias)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(out) != 1 || out[0].Alias != "db" {
+			t.Fatalf("expected alias-only match, got %#v", out)
+		}
+	})
+
 	t.Run("invalid regex returns error", func(t *testing.T) {
 		_, err := filterHostsRegex(hosts, "(")
 		if err == nil {
@@ -99,3 +121,191 @@ Host stage other
 	}
 }
 
+func TestParseSSHConfig_Annotations(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config")
+	content := `Host prod
+  # sshpick: color=red
+  # primary database
+  Hostname 127.0.0.1 # sshpick: Color=#ff8800 pinned
+
+Host stage
+  Hostname 127.0.0.1
+`
+	if err := os.WriteFile(cfg, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	hosts, err := parseSSHConfig(cfg)
+	if err != nil {
+		t.Fatalf("parseSSHConfig: %v", err)
+	}
+	if len(hosts) != 2 {
+		t.Fatalf("expected 2 hosts, got %d", len(hosts))
+	}
+	prod := hosts[0]
+	if got := prod.Annotations["color"]; got != "#ff8800" {
+		t.Fatalf("color: expected #ff8800, got %q", got)
+	}
+	if got := prod.Annotations["pinned"]; got != "true" {
+		t.Fatalf("pinned: expected true, got %q", got)
+	}
+	if len(prod.Notes) != 1 || prod.Notes[0] != "primary database" {
+		t.Fatalf("annotations must not become notes, got %#v", prod.Notes)
+	}
+	if hosts[1].Annotations != nil {
+		t.Fatalf("stage should have no annotations, got %#v", hosts[1].Annotations)
+	}
+	if _, ok := annotationColor("mauve"); ok {
+		t.Fatalf("unknown color names should be rejected")
+	}
+}
+
+func TestFilterHistoryBrowse(t *testing.T) {
+	m := initialModel([]sshHost{{Alias: "prod"}, {Alias: "db"}}, "", "")
+	m.rememberFilter("prod")
+	m.rememberFilter("db")
+	m.rememberFilter("prod")
+	if len(m.filterHistory) != 2 || m.filterHistory[0] != "prod" {
+		t.Fatalf("expected deduped most-recent-first history, got %#v", m.filterHistory)
+	}
+
+	m.filterActive = true
+	m.filterQuery = "dra"
+	m.browseFilterHistory(true)
+	m.browseFilterHistory(true)
+	if m.filterQuery != "db" {
+		t.Fatalf("expected oldest entry db, got %q", m.filterQuery)
+	}
+	m.browseFilterHistory(true)
+	if m.filterQuery != "db" {
+		t.Fatalf("browsing past the oldest entry should stay put, got %q", m.filterQuery)
+	}
+	m.browseFilterHistory(false)
+	m.browseFilterHistory(false)
+	if m.filterQuery != "dra" {
+		t.Fatalf("expected draft restored, got %q", m.filterQuery)
+	}
+}
+
+func TestParseSSHConfigWarnings(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config")
+	content := `Host prod
+  Hostnme 10.0.0.1
+  Port=2222
+  User
+  ProxyJump bastion
+
+Host bad
+  Port 70000
+  FrobnicateAll yes
+
+Host spaced
+  Port = 2200
+`
+	if err := os.WriteFile(cfg, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	hosts, warnings, err := parseSSHConfigWarnings(cfg)
+	if err != nil {
+		t.Fatalf("parseSSHConfigWarnings: %v", err)
+	}
+	if len(hosts) != 3 || hosts[0].Port != "2222" || hosts[2].Port != "2200" {
+		t.Fatalf("expected Key=Value and Key = Value ports to parse, got %#v", hosts)
+	}
+	want := []string{
+		cfg + `:2: unknown directive "Hostnme" (did you mean "Hostname"?)`,
+		cfg + `:4: User has no value`,
+		cfg + `:8: invalid port "70000"`,
+		cfg + `:9: unknown directive "FrobnicateAll"`,
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("expected %d warnings, got %v", len(want), warnings)
+	}
+	for i, w := range warnings {
+		if w.String() != want[i] {
+			t.Errorf("warning %d: expected %q, got %q", i, want[i], w.String())
+		}
+	}
+}
+
+func TestParseSSHConfig_Include(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "config.d"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"config": `Include config.d/*.conf
+Host top
+  Hostname 10.0.0.1
+  Include extra
+`,
+		"config.d/10-web.conf": `
+Host web1
+  Hostname 10.0.1.1
+`,
+		"config.d/20-nested.conf": `Host nested
+  Include config
+`,
+		"extra": "  User deploy\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hosts, warnings, err := parseSSHConfigWarnings(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byAlias := map[string]sshHost{}
+	for _, h := range hosts {
+		byAlias[h.Alias] = h
+	}
+	if len(hosts) != 3 {
+		t.Fatalf("hosts %+v", hosts)
+	}
+	web := byAlias["web1"]
+	if web.Hostname != "10.0.1.1" || web.SourcePath != filepath.Join(dir, "config.d", "10-web.conf") || web.SourceLine != 2 {
+		t.Fatalf("web1 %+v", web)
+	}
+	// Directives included inside a block belong to it.
+	if top := byAlias["top"]; top.User != "deploy" || top.SourcePath != filepath.Join(dir, "config") || top.SourceLine != 2 {
+		t.Fatalf("top %+v", top)
+	}
+	if _, ok := byAlias["nested"]; !ok {
+		t.Fatalf("nested include missing: %+v", hosts)
+	}
+	// Relative paths are from the top-level config's directory, so the nested
+	// file includes the config that's already being read.
+	cycles := 0
+	for _, w := range warnings {
+		if strings.Contains(w.Msg, "Include cycle") {
+			cycles++
+		}
+	}
+	if cycles == 0 {
+		t.Fatalf("no cycle warning: %v", warnings)
+	}
+}
+
+func TestSubprocessKeepsInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	// A child that inherited an ignored SIGINT would survive its own kill.
+	err := runToolSubprocessTo("sh", []string{"-c", "kill -INT $$; exit 0"}, io.Discard)
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) {
+		t.Fatalf("expected sh to die of SIGINT, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// maintenanceEntry marks a host as being worked on.
+type maintenanceEntry struct {
+	By     string    `json:"by,omitempty"`
+	Since  time.Time `json:"since"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// maintenancePath is the settings' shared file if configured, otherwise a
+// local file next to the UI state.
+func maintenancePath(cfg appConfig) string {
+	if cfg.MaintenanceFile != "" {
+		return expandHome(cfg.MaintenanceFile)
+	}
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "maintenance.json")
+}
+
+// loadMaintenance reads the maintenance file keyed by alias. A missing file
+// means no host is in maintenance.
+func loadMaintenance(path string) (map[string]maintenanceEntry, error) {
+	entries := map[string]maintenanceEntry{}
+	if path == "" {
+		return entries, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return entries, err
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return map[string]maintenanceEntry{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// toggleMaintenance flips alias in the file. The file is re-read first so
+// changes made by teammates to a shared file aren't overwritten.
+func toggleMaintenance(path, alias string) (map[string]maintenanceEntry, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no maintenance file location")
+	}
+	entries, err := loadMaintenance(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := entries[alias]; ok {
+		delete(entries, alias)
+	} else {
+		entries[alias] = maintenanceEntry{By: currentUser(), Since: time.Now().Truncate(time.Second)}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+	return entries, os.Rename(tmp, path)
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// inMaintenance reports whether h is marked, either in the maintenance file
+// or statically with a "maintenance" annotation.
+func (m model) inMaintenance(h sshHost) (maintenanceEntry, bool) {
+	if e, ok := m.maintenance[h.Alias]; ok {
+		return e, true
+	}
+	if h.annotationBool("maintenance") {
+		return maintenanceEntry{Reason: "annotated in ssh config"}, true
+	}
+	return maintenanceEntry{}, false
+}
+
+func (e maintenanceEntry) describe() string {
+	s := "maintenance"
+	if e.By != "" {
+		s += " by " + e.By
+	}
+	if !e.Since.IsZero() {
+		s += " since " + e.Since.Local().Format("Jan 2 15:04")
+	}
+	if e.Reason != "" {
+		s += ": " + e.Reason
+	}
+	return s
+}
+
+// maintenanceCheck warns before connecting to a host someone is working on.
+func maintenanceCheck(m model, h sshHost) *connectPrompt {
+	e, ok := m.inMaintenance(h)
+	if !ok {
+		return nil
+	}
+	return &connectPrompt{
+		id:      "maintenance",
+		message: fmt.Sprintf("%s is in %s.", h.Alias, e.describe()),
+	}
+}
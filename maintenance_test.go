@@ -0,0 +1,37 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestToggleMaintenance(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "shared", "maintenance.json")
+	entries, err := toggleMaintenance(path, "db1")
+	if err != nil {
+		t.Fatalf("toggleMaintenance: %v", err)
+	}
+	if _, ok := entries["db1"]; !ok {
+		t.Fatalf("expected db1 in maintenance, got %#v", entries)
+	}
+
+	m := initialModel([]sshHost{{Alias: "db1"}, {Alias: "web"}}, "", "")
+	if m.maintenance, err = loadMaintenance(path); err != nil {
+		t.Fatalf("loadMaintenance: %v", err)
+	}
+	if p := maintenanceCheck(m, m.hosts[0]); p == nil {
+		t.Fatalf("expected maintenance prompt for db1")
+	}
+	if p := maintenanceCheck(m, m.hosts[1]); p != nil {
+		t.Fatalf("web is not in maintenance")
+	}
+	if p := maintenanceCheck(m, sshHost{Alias: "x", Annotations: map[string]string{"maintenance": "true"}}); p == nil {
+		t.Fatalf("annotated host should be in maintenance")
+	}
+
+	if entries, err = toggleMaintenance(path, "db1"); err != nil || len(entries) != 0 {
+		t.Fatalf("expected db1 cleared, got %#v (%v)", entries, err)
+	}
+}
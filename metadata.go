@@ -0,0 +1,470 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hostMeta is what the sidecar store holds for one alias, for users who
+// can't or don't want to add "# sshpick:" comments to a managed ssh config.
+type hostMeta struct {
+	Tags     []string
+	Color    string
+	Notes    []string
+	Favorite bool
+	Fields   map[string]string // any other key, applied as an annotation
+}
+
+// metadataPath is the sidecar store, next to the settings file.
+func metadataPath() string {
+	dir := configDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "hosts.yaml")
+}
+
+// loadMetadata reads the sidecar store. A missing file means no metadata.
+func loadMetadata(path string) (map[string]hostMeta, error) {
+	if path == "" {
+		return map[string]hostMeta{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]hostMeta{}, nil
+	}
+	if err != nil {
+		return map[string]hostMeta{}, err
+	}
+	meta, err := parseMetadata(data)
+	if err != nil {
+		return map[string]hostMeta{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return meta, nil
+}
+
+// parseMetadata reads the small YAML subset the store uses: aliases at the
+// top level, each with indented "key: value" pairs whose values are
+// scalars, [flow, lists] or "- item" block lists.
+//
+//	db1:
+//	  tags: [prod, db]
+//	  color: "#ff8800"
+//	  favorite: true
+//	  notes:
+//	    - primary database
+//	  owner: team-data
+func parseMetadata(data []byte) (map[string]hostMeta, error) {
+	meta := map[string]hostMeta{}
+	var alias, key string
+	var list []string
+	inList, inline := false, false
+	keyIndent := -1
+
+	flush := func() error {
+		if key == "" {
+			return nil
+		}
+		m := meta[alias]
+		if err := m.set(key, list, inList); err != nil {
+			return err
+		}
+		meta[alias] = m
+		key, list, inList, inline = "", nil, false, false
+		return nil
+	}
+
+	for i, raw := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		line := stripYAMLComment(strings.TrimRight(raw, " \t\r"))
+		if strings.TrimSpace(line) == "" || strings.TrimSpace(line) == "---" {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", lineNo)
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		text := strings.TrimSpace(line)
+
+		switch {
+		case indent == 0:
+			if err := flush(); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			name, rest, ok := strings.Cut(text, ":")
+			if !ok || strings.TrimSpace(rest) != "" {
+				return nil, fmt.Errorf("line %d: expected \"alias:\"", lineNo)
+			}
+			if alias = yamlScalar(name); alias == "" {
+				return nil, fmt.Errorf("line %d: empty alias", lineNo)
+			}
+			if _, ok := meta[alias]; !ok {
+				meta[alias] = hostMeta{}
+			}
+			keyIndent = -1
+		case alias == "":
+			return nil, fmt.Errorf("line %d: indented line before any alias", lineNo)
+		case strings.HasPrefix(text, "- ") || text == "-":
+			if key == "" || indent < keyIndent || inline {
+				return nil, fmt.Errorf("line %d: list item without a key", lineNo)
+			}
+			inList = true
+			list = append(list, yamlScalar(strings.TrimPrefix(text, "-")))
+		default:
+			if keyIndent == -1 {
+				keyIndent = indent
+			}
+			if indent != keyIndent {
+				return nil, fmt.Errorf("line %d: unexpected indentation", lineNo)
+			}
+			if err := flush(); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo-1, err)
+			}
+			k, v, ok := strings.Cut(text, ":")
+			if !ok || (v != "" && v[0] != ' ') {
+				return nil, fmt.Errorf("line %d: expected \"key: value\"", lineNo)
+			}
+			key = strings.ToLower(strings.TrimSpace(k))
+			v = strings.TrimSpace(v)
+			inline = v != ""
+			switch {
+			case strings.HasPrefix(v, "["):
+				if !strings.HasSuffix(v, "]") {
+					return nil, fmt.Errorf("line %d: unterminated list", lineNo)
+				}
+				list, inList = []string{}, true
+				for _, item := range splitFlowList(v[1 : len(v)-1]) {
+					if item = yamlScalar(item); item != "" {
+						list = append(list, item)
+					}
+				}
+			case v != "":
+				list = []string{yamlScalar(v)}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// set stores one key's values; single-valued keys reject lists.
+func (m *hostMeta) set(key string, values []string, isList bool) error {
+	single := func() (string, error) {
+		if isList {
+			return "", fmt.Errorf("%s: expected a single value", key)
+		}
+		if len(values) == 0 {
+			return "", nil
+		}
+		return values[0], nil
+	}
+	switch key {
+	case "tags":
+		m.Tags = append(m.Tags, values...)
+	case "notes":
+		m.Notes = append(m.Notes, values...)
+	case "color":
+		v, err := single()
+		if err != nil {
+			return err
+		}
+		if _, ok := annotationColor(v); v != "" && !ok {
+			return fmt.Errorf("color: unknown color %q", v)
+		}
+		m.Color = v
+	case "favorite":
+		v, err := single()
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(v) {
+		case "true", "yes", "on":
+			m.Favorite = true
+		case "false", "no", "off", "":
+			m.Favorite = false
+		default:
+			return fmt.Errorf("favorite: expected true or false, got %q", v)
+		}
+	default:
+		v, err := single()
+		if err != nil {
+			return err
+		}
+		if m.Fields == nil {
+			m.Fields = map[string]string{}
+		}
+		m.Fields[key] = v
+	}
+	return nil
+}
+
+// stripYAMLComment drops a " #" comment that isn't inside quotes.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' '):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitFlowList splits "a, 'b, c'" at commas outside quotes.
+func splitFlowList(s string) []string {
+	var out []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			out = append(out, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, s[start:])
+}
+
+// yamlScalar unquotes a plain, 'single' or "double" quoted scalar.
+func yamlScalar(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 {
+		switch {
+		case s[0] == '"' && s[len(s)-1] == '"':
+			if u, err := strconv.Unquote(s); err == nil {
+				return u
+			}
+		case s[0] == '\'' && s[len(s)-1] == '\'':
+			return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+		}
+	}
+	return s
+}
+
+// quoteYAML quotes a scalar when it would otherwise be read back
+// differently, e.g. "#ff8800" or "a: b".
+func quoteYAML(s string) string {
+	if s == "" || strings.ContainsAny(s, "#:,[]{}\"'\\\n\t") || strings.TrimSpace(s) != s ||
+		strings.HasPrefix(s, "-") || strings.HasPrefix(s, "&") || strings.HasPrefix(s, "*") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// setFavorite edits the store in place so that alias is (or isn't) a
+// favorite, leaving every other line, comment and the order as written.
+// An existing "favorite:" line is rewritten or dropped; otherwise one is
+// added under the alias, or a new alias block is appended.
+func setFavorite(data []byte, alias string, on bool) []byte {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var out []string
+	head, keyIndent, found := -1, "", false
+	in := false
+	for _, raw := range lines {
+		line := stripYAMLComment(strings.TrimRight(raw, " \t\r\n"))
+		text := strings.TrimSpace(line)
+		indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		if text != "" && text != "---" && indent == "" {
+			name, _, _ := strings.Cut(text, ":")
+			in = yamlScalar(name) == alias
+			if in && head == -1 {
+				head = len(out)
+			}
+			out = append(out, raw)
+			continue
+		}
+		if !in || text == "" || strings.HasPrefix(text, "-") {
+			out = append(out, raw)
+			continue
+		}
+		if keyIndent == "" {
+			keyIndent = indent
+		}
+		k, _, _ := strings.Cut(text, ":")
+		if strings.ToLower(strings.TrimSpace(k)) != "favorite" {
+			out = append(out, raw)
+			continue
+		}
+		if on && !found {
+			out = append(out, indent+"favorite: true"+raw[len(line):])
+		}
+		found = true
+	}
+
+	switch {
+	case !on && found && head != -1 && blockEmpty(out[head+1:]):
+		out = append(out[:head], out[head+1:]...)
+	case !on || found:
+	case head != -1:
+		if keyIndent == "" {
+			keyIndent = "  "
+		}
+		out = append(out[:head+1], append([]string{keyIndent + "favorite: true\n"}, out[head+1:]...)...)
+	default:
+		if n := len(out); n > 0 && !strings.HasSuffix(out[n-1], "\n") {
+			out[n-1] += "\n"
+		}
+		out = append(out, quoteYAML(alias)+":\n", "  favorite: true\n")
+	}
+	return []byte(strings.Join(out, ""))
+}
+
+// blockEmpty reports whether the lines up to the next alias hold nothing.
+func blockEmpty(lines []string) bool {
+	for _, l := range lines {
+		if t := strings.TrimSpace(l); t != "" {
+			return strings.TrimLeft(l, " ") == l && t != "---" && !strings.HasPrefix(t, "#")
+		}
+	}
+	return true
+}
+
+// applyMetadata layers the store over the hosts: tags and notes are added,
+// while color, favorite and custom fields override the host's annotations,
+// since the store is the user's own layer over a config they may not control.
+func applyMetadata(hosts []sshHost, meta map[string]hostMeta) []sshHost {
+	if len(meta) == 0 {
+		return hosts
+	}
+	out := make([]sshHost, len(hosts))
+	for i, h := range hosts {
+		m, ok := meta[h.Alias]
+		if !ok {
+			out[i] = h
+			continue
+		}
+		h.Annotations = copyStringMap(h.Annotations)
+		if h.Annotations == nil {
+			h.Annotations = map[string]string{}
+		}
+		for k, v := range m.Fields {
+			h.Annotations[k] = v
+		}
+		if tags := h.tags(); len(m.Tags) > 0 {
+			for _, t := range m.Tags {
+				if !h.hasTag(t) {
+					tags = append(tags, t)
+				}
+			}
+			h.Annotations["tags"] = strings.Join(tags, ",")
+		}
+		if m.Color != "" {
+			h.Annotations["color"] = m.Color
+		}
+		if m.Favorite {
+			h.Annotations["favorite"] = "true"
+		}
+		for _, n := range m.Notes {
+			if !containsString(h.Notes, n) {
+				h.Notes = append(append([]string(nil), h.Notes...), n)
+			}
+		}
+		if len(h.Annotations) == 0 {
+			h.Annotations = nil
+		}
+		out[i] = h
+	}
+	return out
+}
+
+// favoritesFirst moves favorite hosts to the top, keeping the order within
+// favorites and within the rest.
+func favoritesFirst(hosts []sshHost) []sshHost {
+	out := append([]sshHost(nil), hosts...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].annotationBool("favorite") && !out[j].annotationBool("favorite")
+	})
+	return out
+}
+
+// toggleFavorite flips alias's favorite flag in the store. The file is
+// re-read first so edits made since startup aren't overwritten, and only
+// the alias's favorite line changes.
+func toggleFavorite(path, alias string) (bool, error) {
+	if path == "" {
+		return false, errors.New("no metadata file location")
+	}
+	meta, err := loadMetadata(path)
+	if err != nil {
+		return false, err
+	}
+	on := !meta[alias].Favorite
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, setFavorite(data, alias, on), 0o644); err != nil {
+		return false, err
+	}
+	return on, os.Rename(tmp, path)
+}
+
+// toggleFavoriteHost is the "*" key: it records the change in the store and
+// updates the host's rows in place.
+func (m model) toggleFavoriteHost() model {
+	if len(m.hosts) == 0 {
+		return m
+	}
+	if m.readOnly {
+		m.err = errReadOnly
+		return m
+	}
+	alias := m.hosts[m.cursor].Alias
+	on, err := toggleFavorite(metadataPath(), alias)
+	if err != nil {
+		m.err = fmt.Errorf("favorite: %w", err)
+		return m
+	}
+	m.err = nil
+	mark := func(hosts []sshHost) []sshHost {
+		out := append([]sshHost(nil), hosts...)
+		for i, h := range out {
+			if h.Alias != alias {
+				continue
+			}
+			h.Annotations = copyStringMap(h.Annotations)
+			if on {
+				if h.Annotations == nil {
+					h.Annotations = map[string]string{}
+				}
+				h.Annotations["favorite"] = "true"
+			} else {
+				delete(h.Annotations, "favorite")
+			}
+			out[i] = h
+		}
+		return out
+	}
+	m.allHosts = mark(m.allHosts)
+	m.hosts = mark(m.hosts)
+	return m
+}
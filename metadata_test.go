@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	t.Parallel()
+
+	meta, err := parseMetadata([]byte(`# team hosts
+db1:
+  tags: [prod, "db"]
+  color: "#ff8800"   # orange
+  favorite: yes
+  notes:
+    - primary database
+    - 'replica: db2'
+  Owner: team-data
+"web 1":
+  notes:
+  - frontend
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]hostMeta{
+		"db1": {
+			Tags:     []string{"prod", "db"},
+			Color:    "#ff8800",
+			Notes:    []string{"primary database", "replica: db2"},
+			Favorite: true,
+			Fields:   map[string]string{"owner": "team-data"},
+		},
+		"web 1": {Notes: []string{"frontend"}},
+	}
+	if !reflect.DeepEqual(meta, want) {
+		t.Fatalf("got %#v", meta)
+	}
+
+	for _, bad := range []string{
+		"  tags: [a]\n",
+		"db1:\n  color: mauve\n",
+		"db1:\n  color: [red, blue]\n",
+		"db1:\n  favorite: maybe\n",
+		"db1:\n  tags: [a\n",
+		"db1:\n  - a\n",
+		"db1:\n  tags: a\n    - b\n",
+		"db1:\n  tags: a\n   user: b\n",
+		"db1:\n\ttags: a\n",
+	} {
+		if _, err := parseMetadata([]byte(bad)); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+func TestApplyMetadata(t *testing.T) {
+	t.Parallel()
+
+	hosts := []sshHost{
+		{Alias: "web1"},
+		{Alias: "db1", Notes: []string{"primary database"}, Annotations: map[string]string{"tags": "prod", "color": "red"}},
+	}
+	meta := map[string]hostMeta{
+		"db1":  {Tags: []string{"PROD", "db"}, Color: "green", Notes: []string{"primary database", "ask #data"}, Favorite: true, Fields: map[string]string{"owner": "team-data"}},
+		"gone": {Favorite: true},
+	}
+	out := favoritesFirst(applyMetadata(hosts, meta))
+	db := out[0]
+	if db.Alias != "db1" || out[1].Alias != "web1" {
+		t.Fatalf("favorites should sort first: %v, %v", out[0].Alias, out[1].Alias)
+	}
+	if got := strings.Join(db.tags(), ","); got != "prod,db" {
+		t.Errorf("tags %q", got)
+	}
+	if db.Annotations["color"] != "green" || db.Annotations["owner"] != "team-data" || !db.annotationBool("favorite") {
+		t.Errorf("annotations %v", db.Annotations)
+	}
+	if len(db.Notes) != 2 {
+		t.Errorf("notes %v", db.Notes)
+	}
+	if hosts[1].Annotations["color"] != "red" || len(hosts[1].Notes) != 1 {
+		t.Error("input hosts were modified")
+	}
+}
+
+func TestToggleFavorite(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "sshpick", "hosts.yaml")
+	if on, err := toggleFavorite(path, "db1"); err != nil || !on {
+		t.Fatalf("toggle on: %v %v", on, err)
+	}
+	data, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, append(data, "web1:\n  owner: me\n"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if on, err := toggleFavorite(path, "db1"); err != nil || on {
+		t.Fatalf("toggle off: %v %v", on, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "web1:\n  owner: me\n" {
+		t.Fatalf("file %q", data)
+	}
+}
+
+func TestSetFavoriteKeepsFile(t *testing.T) {
+	t.Parallel()
+
+	const file = `# team hosts
+web1:
+    owner: me   # ask first
+    favorite: no
+db1:
+    tags: [prod]
+`
+	for _, tc := range []struct {
+		alias string
+		on    bool
+		want  string
+	}{
+		{"web1", true, strings.Replace(file, "favorite: no", "favorite: true", 1)},
+		{"web1", false, strings.Replace(file, "    favorite: no\n", "", 1)},
+		{"db1", true, strings.Replace(file, "db1:\n", "db1:\n    favorite: true\n", 1)},
+		{"db1", false, file},
+		{"app1", true, file + "app1:\n  favorite: true\n"},
+	} {
+		got := string(setFavorite([]byte(file), tc.alias, tc.on))
+		if got != tc.want {
+			t.Errorf("%s %v:\n%s\nwant:\n%s", tc.alias, tc.on, got, tc.want)
+		}
+	}
+
+	// A block left with nothing in it goes along with its favorite line.
+	if got := string(setFavorite([]byte("a:\n  owner: x\ndb1:\n  favorite: true\n"), "db1", false)); got != "a:\n  owner: x\n" {
+		t.Errorf("got %q", got)
+	}
+	if got := string(setFavorite(nil, "db1", true)); got != "db1:\n  favorite: true\n" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// hostOrder is how the list is sorted: as written in the config, or by the
+// connection history.
+type hostOrder int
+
+const (
+	orderConfig hostOrder = iota
+	orderRecent
+	orderFrequent
+)
+
+func (o hostOrder) String() string {
+	switch o {
+	case orderRecent:
+		return "recent"
+	case orderFrequent:
+		return "frequent"
+	}
+	return "config"
+}
+
+func parseHostOrder(s string) hostOrder {
+	switch s {
+	case "recent":
+		return orderRecent
+	case "frequent":
+		return orderFrequent
+	}
+	return orderConfig
+}
+
+// usageByAlias indexes summarizeHistory's output for the list.
+func usageByAlias(usage []hostUsage) map[string]hostUsage {
+	out := make(map[string]hostUsage, len(usage))
+	for _, u := range usage {
+		out[u.Alias] = u
+	}
+	return out
+}
+
+// sortByHistory orders hosts by last connection (recent) or number of
+// connections (frequent, ties broken by the last one). Hosts never
+// connected to keep their config order after the rest.
+func sortByHistory(hosts []sshHost, used map[string]hostUsage, order hostOrder) []sshHost {
+	out := append([]sshHost(nil), hosts...)
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := used[out[i].Alias], used[out[j].Alias]
+		if order == orderFrequent && a.Sessions != b.Sessions {
+			return a.Sessions > b.Sessions
+		}
+		return a.Last.After(b.Last)
+	})
+	return out
+}
+
+// orderHosts applies m.order to a filtered list.
+func (m model) orderHosts(hosts []sshHost) []sshHost {
+	if m.order == orderConfig || len(m.used) == 0 {
+		return hosts
+	}
+	return sortByHistory(hosts, m.used, m.order)
+}
+
+// cycleOrder is the S key: config → recent → frequent → config.
+func (m model) cycleOrder() model {
+	m.order = (m.order + 1) % 3
+	m.applyFilter(m.lastValidRegex)
+	return m
+}
+
+// lastConnected is the row's "last connected" column, e.g. "3h ago".
+func (m model) lastConnected(h sshHost, now time.Time) string {
+	u, ok := m.used[h.Alias]
+	if !ok || u.Last.IsZero() {
+		return ""
+	}
+	return "Last: " + timeAgo(now.Sub(u.Last))
+}
+
+// timeAgo rounds d to the largest sensible unit.
+func timeAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 60*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+	return fmt.Sprintf("%dmo ago", int(d.Hours()/24/30))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryOrder(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	entries := []historyEntry{
+		{Alias: "db1", Start: now.Add(-3 * time.Hour)},
+		{Alias: "db1", Start: now.Add(-2 * time.Hour)},
+		{Alias: "web1", Start: now.Add(-10 * time.Minute)},
+	}
+	hosts := []sshHost{{Alias: "cache"}, {Alias: "db1"}, {Alias: "mail"}, {Alias: "web1"}}
+	m := initialModel(hosts, "", "")
+	m.ready = true
+	m.used = usageByAlias(summarizeHistory(entries, now))
+
+	aliases := func() string {
+		var out []string
+		for _, h := range m.hosts {
+			out = append(out, h.Alias)
+		}
+		return strings.Join(out, " ")
+	}
+	m = m.cycleOrder()
+	if m.order != orderRecent || aliases() != "web1 db1 cache mail" {
+		t.Fatalf("recent: %s", aliases())
+	}
+	m = m.cycleOrder()
+	if m.order != orderFrequent || aliases() != "db1 web1 cache mail" {
+		t.Fatalf("frequent: %s", aliases())
+	}
+	if got := m.lastConnected(hosts[3], now); got != "Last: 10m ago" {
+		t.Fatalf("last connected %q", got)
+	}
+	if m.lastConnected(hosts[0], now) != "" {
+		t.Fatal("hosts never connected to have no last-connected column")
+	}
+	if st := m.snapshotState(); st.Order != "frequent" {
+		t.Fatalf("order should be saved, got %q", st.Order)
+	}
+	m = m.cycleOrder()
+	if m.order != orderConfig || aliases() != "cache db1 mail web1" {
+		t.Fatalf("config: %s", aliases())
+	}
+}
+
+func TestTrimHistory(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		appendHistory(path, historyEntry{Alias: "h" + string(rune('0'+i)), Start: start.Add(time.Duration(i) * time.Hour)})
+	}
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("garbage\n")
+	f.Close()
+
+	kept, err := trimHistory(path, loadHistory(path), 3)
+	if err != nil || len(kept) != 3 || kept[0].Alias != "h2" {
+		t.Fatalf("trim: %v %v", kept, err)
+	}
+	if reloaded := loadHistory(path); len(reloaded) != 3 || reloaded[2].Alias != "h4" {
+		t.Fatalf("rewritten store: %v", reloaded)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "garbage") {
+		t.Fatal("corrupt lines should be dropped by the rewrite")
+	}
+	if kept, _ := trimHistory(path, kept, 3); len(kept) != 3 {
+		t.Fatal("a store under the cap is left alone")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tags returns the host's tags from a "tags=prod,db" annotation.
+func (h sshHost) tags() []string {
+	var out []string
+	for _, t := range strings.Split(h.Annotations["tags"], ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (h sshHost) hasTag(tag string) bool {
+	for _, t := range h.tags() {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredNetworks lists the networks h needs: those named in its network=
+// annotation plus those configured for any of its tags.
+func requiredNetworks(h sshHost, cfg appConfig) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(name string) {
+		if name = strings.TrimSpace(name); name != "" && !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	for _, n := range strings.Split(h.Annotations["network"], ",") {
+		add(n)
+	}
+	names := make([]string, 0, len(cfg.Networks))
+	for name := range cfg.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, tag := range cfg.Networks[name].Tags {
+			if h.hasTag(tag) {
+				add(name)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// networkUp checks a network's indicators; every configured indicator must pass.
+func networkUp(n networkConfig) (bool, string) {
+	if n.Interface != "" {
+		iface, err := net.InterfaceByName(n.Interface)
+		if err != nil {
+			return false, "interface " + n.Interface + " not found"
+		}
+		if iface.Flags&net.FlagUp == 0 {
+			return false, "interface " + n.Interface + " is down"
+		}
+	}
+	if n.Probe != "" {
+		conn, err := net.DialTimeout("tcp", n.Probe, time.Second)
+		if err != nil {
+			return false, n.Probe + " unreachable"
+		}
+		conn.Close()
+	}
+	return true, ""
+}
+
+// networkState is a networkUp result, kept while a connection's checks run.
+type networkState struct {
+	up     bool
+	reason string
+}
+
+// networkCheck is the pre-connect check for required networks. The
+// indicators are checked in the background, since a probe can take a
+// second to time out.
+func networkCheck(m model, h sshHost) *connectPrompt {
+	for _, name := range requiredNetworks(h, m.appConfig) {
+		n, ok := m.appConfig.Networks[name]
+		if !ok {
+			return &connectPrompt{
+				id:      "network:" + name,
+				message: fmt.Sprintf("%s requires network %q, which is not defined in the sshpick config.", h.Alias, name),
+			}
+		}
+		p := &connectPrompt{
+			id:      "network:" + name,
+			message: fmt.Sprintf("Checking network %q for %s…", name, h.Alias),
+		}
+		r, waiting := precheck(m, "network:"+name, p, func() any {
+			up, reason := networkUp(n)
+			return networkState{up: up, reason: reason}
+		})
+		if waiting != nil {
+			return waiting
+		}
+		st := r.(networkState)
+		if st.up {
+			continue
+		}
+		p.message = fmt.Sprintf("%s requires network %q: %s.", h.Alias, name, st.reason)
+		if n.Up != "" {
+			cmd := n.Up
+			p.actions = append(p.actions, promptAction{
+				key:   "u",
+				label: "run `" + cmd + "`",
+				cmd:   func() *exec.Cmd { return exec.Command("sh", "-c", cmd) },
+			})
+		}
+		return p
+	}
+	return nil
+}
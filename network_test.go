@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestNetworkCheck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	up := ln.Addr().String()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	down := closed.Addr().String()
+	closed.Close()
+	defer ln.Close()
+
+	m := initialModel(nil, "", "")
+	m.appConfig = appConfig{Networks: map[string]networkConfig{
+		"corp-vpn": {Probe: down, Up: "true", Tags: []string{"corp"}},
+		"lab":      {Probe: up},
+	}}
+
+	h := sshHost{Alias: "web", Annotations: map[string]string{"network": "lab", "tags": "prod, corp"}}
+	if got := requiredNetworks(h, m.appConfig); !reflect.DeepEqual(got, []string{"lab", "corp-vpn"}) {
+		t.Fatalf("unexpected required networks %v", got)
+	}
+
+	if p := networkCheck(m, h); p == nil || p.pending == nil || p.id != "network:lab" {
+		t.Fatalf("networks should be checked in the background, got %#v", p)
+	}
+	p := settle(t, m, networkCheck, h)
+	if p == nil || p.id != "network:corp-vpn" || len(p.actions) != 1 {
+		t.Fatalf("expected corp-vpn prompt with an up action, got %#v", p)
+	}
+
+	if p := settle(t, m, networkCheck, sshHost{Alias: "lab1", Annotations: map[string]string{"network": "lab"}}); p != nil {
+		t.Fatalf("reachable network should not prompt, got %q", p.message)
+	}
+	if p := settle(t, m, networkCheck, sshHost{Alias: "x", Annotations: map[string]string{"network": "nope"}}); p == nil {
+		t.Fatalf("undefined network should prompt")
+	}
+}
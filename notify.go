@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// notifyConfig controls the notification sent when a subprocess session ends.
+type notifyConfig struct {
+	Enabled     bool   `json:"enabled,omitempty"`
+	Method      string `json:"method,omitempty"`       // "desktop" (default), "osc777" or "bell"
+	MinDuration string `json:"min_duration,omitempty"` // only notify for sessions at least this long, e.g. "30s"
+}
+
+func (n notifyConfig) minDuration() time.Duration {
+	d, err := time.ParseDuration(n.MinDuration)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// notifySessionEnd tells the user a session finished. Desktop notifications
+// fall back to an OSC 777 escape when no notifier is installed.
+func notifySessionEnd(n notifyConfig, h sshHost, code int, elapsed time.Duration, term io.Writer) error {
+	if !n.Enabled || elapsed < n.minDuration() {
+		return nil
+	}
+	title := "sshpick: " + h.Alias
+	body := fmt.Sprintf("session ended (exit %d) after %s", code, elapsed.Round(time.Second))
+
+	switch strings.ToLower(n.Method) {
+	case "bell":
+		_, err := io.WriteString(term, "\a")
+		return err
+	case "osc777":
+		return writeOSC777(term, title, body)
+	case "", "desktop":
+		if cmd := desktopNotifyCommand(title, body); cmd != nil {
+			return cmd.Run()
+		}
+		return writeOSC777(term, title, body)
+	default:
+		return fmt.Errorf("unknown notify method %q", n.Method)
+	}
+}
+
+func desktopNotifyCommand(title, body string) *exec.Cmd {
+	if runtime.GOOS == "darwin" {
+		if _, err := exec.LookPath("osascript"); err == nil {
+			script := fmt.Sprintf("display notification %q with title %q", body, title)
+			return exec.Command("osascript", "-e", script)
+		}
+		return nil
+	}
+	if _, err := exec.LookPath("notify-send"); err == nil {
+		return exec.Command("notify-send", title, body)
+	}
+	return nil
+}
+
+// writeOSC777 emits the urxvt/foot/wezterm notification escape plus a bell
+// for terminals that don't support it.
+func writeOSC777(w io.Writer, title, body string) error {
+	clean := func(s string) string { return strings.NewReplacer(";", ",", "\x07", "", "\x1b", "").Replace(s) }
+	_, err := fmt.Fprintf(w, "\x1b]777;notify;%s;%s\x07\a", clean(title), clean(body))
+	return err
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestNotifySessionEnd(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Alias: "db1"}
+	tests := []struct {
+		name string
+		cfg  notifyConfig
+		want string
+	}{
+		{"disabled", notifyConfig{Method: "bell"}, ""},
+		{"bell", notifyConfig{Enabled: true, Method: "bell"}, "\a"},
+		{"osc777", notifyConfig{Enabled: true, Method: "OSC777"}, "\x1b]777;notify;sshpick: db1;session ended (exit 2) after 1m30s\x07\a"},
+		{"short session", notifyConfig{Enabled: true, Method: "bell", MinDuration: "5m"}, ""},
+		{"long enough", notifyConfig{Enabled: true, Method: "bell", MinDuration: "30s"}, "\a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := notifySessionEnd(tt.cfg, h, 2, 90*time.Second, &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Fatalf("got %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+
+	if err := notifySessionEnd(notifyConfig{Enabled: true, Method: "pager"}, h, 0, time.Minute, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected an unknown method to fail")
+	}
+}
+
+func TestWriteOSC777StripsSeparators(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	if err := writeOSC777(&out, "a;b", "c\x07d\x1b"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "\x1b]777;notify;a,b;cd\x07\a"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
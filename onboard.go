@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hostBlock is a new Host entry written to the ssh config by sshpick.
+type hostBlock struct {
+	Alias        string
+	HostName     string
+	User         string
+	Port         string
+	IdentityFile string
+	Tags         []string
+}
+
+func (b hostBlock) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "\nHost %s\n    HostName %s\n", b.Alias, b.HostName)
+	if b.User != "" {
+		fmt.Fprintf(&s, "    User %s\n", b.User)
+	}
+	if b.Port != "" && b.Port != "22" {
+		fmt.Fprintf(&s, "    Port %s\n", b.Port)
+	}
+	if b.IdentityFile != "" {
+		fmt.Fprintf(&s, "    IdentityFile %s\n", b.IdentityFile)
+	}
+	if len(b.Tags) > 0 {
+		fmt.Fprintf(&s, "    # sshpick: tags=%s\n", strings.Join(b.Tags, ","))
+	}
+	return s.String()
+}
+
+// appendHostBlock adds b to the end of the ssh config, creating the file
+// if needed.
+func appendHostBlock(cfgPath string, b hostBlock) error {
+	if cfgPath == "" {
+		return errors.New("no config file to add to")
+	}
+	if err := os.MkdirAll(filepath.Dir(cfgPath), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(cfgPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// splitAddress accepts host, host:port, [v6]:port or a bare IPv6 address.
+func splitAddress(addr string) (host, port string) {
+	if h, p, err := net.SplitHostPort(addr); err == nil {
+		return h, p
+	}
+	return strings.Trim(addr, "[]"), "22"
+}
+
+// defaultAlias is the first DNS label of a hostname, or an IP unchanged.
+func defaultAlias(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	label, _, _ := strings.Cut(host, ".")
+	return label
+}
+
+// prompter asks questions on a terminal (or any reader, for tests).
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p prompter) ask(question, def string) string {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	line, _ := p.in.ReadString('\n')
+	if line = strings.TrimSpace(line); line != "" {
+		return line
+	}
+	return def
+}
+
+func (p prompter) confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	switch strings.ToLower(p.ask(question+" ("+hint+")", "")) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
+
+// defaultPublicKey prefers id_ed25519.pub, then any other *.pub file.
+func defaultPublicKey() string {
+	dir := defaultSSHDir()
+	if dir == "" {
+		return ""
+	}
+	if p := filepath.Join(dir, "id_ed25519.pub"); fileExists(p) {
+		return p
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.pub"))
+	if len(paths) > 0 {
+		return paths[0]
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// runOnboard implements `sshpick onboard`, a guided flow for adding a new
+// server: scan and trust its host key, push a key, verify a key-based login
+// and write the Host block.
+func runOnboard(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("onboard", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	settingsPath := fs.String("settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
+	readOnly := fs.Bool("read-only", false, "Refuse to modify the ssh config")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	settings, err := loadAppConfig(*settingsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+		return 2
+	}
+	if *readOnly || settings.ReadOnly {
+		fmt.Fprintln(os.Stderr, errReadOnly)
+		return 1
+	}
+	if *cfgPath == "" {
+		*cfgPath = defaultConfigPath()
+	}
+	existing, err := parseSSHConfig(*cfgPath)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, "error reading config:", err)
+		return 2
+	}
+
+	p := prompter{in: bufio.NewReader(os.Stdin), out: stdout}
+	if err := onboard(p, *cfgPath, existing); err != nil {
+		fmt.Fprintln(os.Stderr, "onboard:", err)
+		return 1
+	}
+	return 0
+}
+
+func onboard(p prompter, cfgPath string, existing []sshHost) error {
+	addr := p.ask("Address (host or host:port)", "")
+	if addr == "" {
+		return errors.New("no address given")
+	}
+	host, port := splitAddress(addr)
+	b := hostBlock{HostName: host, Port: port}
+
+	b.Alias = p.ask("Alias", defaultAlias(host))
+	for _, h := range existing {
+		if h.Alias == b.Alias {
+			return fmt.Errorf("%s is already defined at %s:%d", b.Alias, h.SourcePath, h.SourceLine)
+		}
+	}
+	b.User = p.ask("User", currentUser())
+	if tags := p.ask("Tags (comma-separated, optional)", ""); tags != "" {
+		for _, t := range strings.Split(tags, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				b.Tags = append(b.Tags, t)
+			}
+		}
+	}
+	target := b.User + "@" + host
+
+	// 1. Host key: scanning also proves the port answers.
+	fmt.Fprintf(p.out, "\nScanning host key of %s port %s…\n", host, port)
+	scan, err := exec.Command("ssh-keyscan", "-T", "5", "-p", port, host).Output()
+	keys := parseKnownHostLines(string(scan))
+	if err != nil || len(keys) == 0 {
+		return fmt.Errorf("%s:%s is not reachable or sent no host key", host, port)
+	}
+	for _, k := range keys {
+		fmt.Fprintf(p.out, "  %-20s %s\n", k.KeyType, k.fingerprint())
+	}
+	if !p.confirm("Trust these keys and add them to known_hosts?", false) {
+		return errors.New("host key not trusted")
+	}
+	if err := appendKnownHosts(knownHostsPath(), string(scan)); err != nil {
+		return err
+	}
+
+	// 2. Key: ssh-copy-id prompts for the password itself.
+	if pub := p.ask("Public key to install (empty to skip)", defaultPublicKey()); pub != "" {
+		pub = expandHome(pub)
+		cmd := exec.Command("ssh-copy-id", "-i", pub, "-p", port, target)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, p.out, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("ssh-copy-id: %w", err)
+		}
+		b.IdentityFile = tildePath(strings.TrimSuffix(pub, ".pub"))
+	}
+
+	// 3. Key-based login, without falling back to a password.
+	fmt.Fprintf(p.out, "Testing login as %s…\n", target)
+	test := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=5", "-p", port}
+	if b.IdentityFile != "" {
+		test = append(test, "-i", expandHome(b.IdentityFile))
+	}
+	if out, err := exec.Command("ssh", append(test, target, "true")...).CombinedOutput(); err != nil {
+		fmt.Fprintf(p.out, "  login failed: %s\n", lastLine(string(out)))
+		if !p.confirm("Write the config block anyway?", false) {
+			return errors.New("login test failed")
+		}
+	} else {
+		fmt.Fprintln(p.out, "  ok")
+	}
+
+	// 4. Config block.
+	fmt.Fprintf(p.out, "\nAppending to %s:%s", cfgPath, b)
+	if !p.confirm("Save?", true) {
+		return errors.New("not saved")
+	}
+	if err := appendHostBlock(cfgPath, b); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "Added %s. Connect with: ssh %s\n", b.Alias, b.Alias)
+	return nil
+}
+
+// tildePath shortens a path under the home directory to ~/…, as people
+// write it in ssh configs.
+func tildePath(p string) string {
+	if home := homeDir(); home != "" && strings.HasPrefix(p, home+string(filepath.Separator)) {
+		return "~" + p[len(home):]
+	}
+	return p
+}
+
+// appendKnownHosts adds ssh-keyscan output to known_hosts.
+func appendKnownHosts(path, lines string) error {
+	if path == "" {
+		return errors.New("no known_hosts location")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(lines); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitAddress(t *testing.T) {
+	t.Parallel()
+
+	for addr, want := range map[string][2]string{
+		"db1.example.com":      {"db1.example.com", "22"},
+		"db1.example.com:2222": {"db1.example.com", "2222"},
+		"[2001:db8::1]:2222":   {"2001:db8::1", "2222"},
+		"2001:db8::1":          {"2001:db8::1", "22"},
+	} {
+		host, port := splitAddress(addr)
+		if host != want[0] || port != want[1] {
+			t.Errorf("splitAddress(%q) = %q, %q", addr, host, port)
+		}
+	}
+	if defaultAlias("db1.example.com") != "db1" || defaultAlias("10.0.0.5") != "10.0.0.5" {
+		t.Error("defaultAlias")
+	}
+}
+
+func TestAppendHostBlock(t *testing.T) {
+	t.Parallel()
+
+	cfg := filepath.Join(t.TempDir(), "ssh", "config")
+	b := hostBlock{Alias: "db1", HostName: "db1.example.com", User: "ops", Port: "2222", IdentityFile: "~/.ssh/id_ed25519", Tags: []string{"prod", "db"}}
+	if err := appendHostBlock(cfg, b); err != nil {
+		t.Fatalf("appendHostBlock: %v", err)
+	}
+	hosts, err := parseSSHConfig(cfg)
+	if err != nil || len(hosts) != 1 {
+		t.Fatalf("parse: %v %v", hosts, err)
+	}
+	h := hosts[0]
+	if h.Hostname != "db1.example.com" || h.User != "ops" || h.Port != "2222" || !h.hasTag("db") || len(h.IdentityFiles) != 1 {
+		t.Fatalf("round trip: %+v", h)
+	}
+}
+
+func TestPrompter(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	p := prompter{in: bufio.NewReader(strings.NewReader("\nweb\nY\n\n")), out: &out}
+	if got := p.ask("User", "ops"); got != "ops" {
+		t.Fatalf("default answer: %q", got)
+	}
+	if got := p.ask("Alias", "db1"); got != "web" {
+		t.Fatalf("answer: %q", got)
+	}
+	if !p.confirm("Trust?", false) || p.confirm("Save?", false) {
+		t.Fatal("confirm")
+	}
+	if !strings.Contains(out.String(), "User [ops]: ") || !strings.Contains(out.String(), "Trust? (y/N): ") {
+		t.Fatalf("prompts %q", out.String())
+	}
+}
+
+func TestOnboardRefusesExistingAlias(t *testing.T) {
+	t.Parallel()
+
+	cfg := filepath.Join(t.TempDir(), "config")
+	p := prompter{in: bufio.NewReader(strings.NewReader("db1.example.com\n\n")), out: &bytes.Buffer{}}
+	err := onboard(p, cfg, []sshHost{{Alias: "db1", SourcePath: "config", SourceLine: 3}})
+	if err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := os.Stat(cfg); !os.IsNotExist(err) {
+		t.Fatal("config was written")
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// optionOrigin is one directive as ssh applies it to a host, and where it
+// was written.
+type optionOrigin struct {
+	Key       string // lowercased
+	Value     string
+	Path      string
+	Line      int
+	Block     string // patterns of the Host line it's under; "" above any Host line
+	BlockPath string
+	BlockLine int
+}
+
+// optionBlock is a Host or Match block (or the lines above the first one)
+// with its directives, in file order across Includes.
+type optionBlock struct {
+	Patterns []string
+	Match    bool
+	Path     string
+	Line     int
+	Options  []optionOrigin
+}
+
+// multiValued directives add up across blocks instead of the first one
+// winning.
+var multiValued = map[string]bool{
+	"identityfile":    true,
+	"certificatefile": true,
+	"localforward":    true,
+	"remoteforward":   true,
+	"dynamicforward":  true,
+	"sendenv":         true,
+}
+
+// hostPatternsMatch is ssh's Host line matching: some pattern matches the
+// alias and no negated one does.
+func hostPatternsMatch(patterns []string, alias string) bool {
+	matched := false
+	for _, p := range patterns {
+		negated := strings.HasPrefix(p, "!")
+		ok, _ := filepath.Match(strings.ToLower(strings.TrimPrefix(p, "!")), strings.ToLower(alias))
+		if ok && negated {
+			return false
+		}
+		matched = matched || ok
+	}
+	return matched
+}
+
+// effectiveOrigins walks the blocks the way ssh does, first value wins, and
+// returns every directive that applies to alias. Match blocks are skipped:
+// their conditions depend on more than the alias.
+func effectiveOrigins(blocks []optionBlock, alias string) []optionOrigin {
+	var out []optionOrigin
+	seen := map[string]bool{}
+	for _, b := range blocks {
+		if b.Match || !hostPatternsMatch(b.Patterns, alias) {
+			continue
+		}
+		for _, o := range b.Options {
+			if seen[o.Key] && !multiValued[o.Key] {
+				continue
+			}
+			seen[o.Key] = true
+			if b.Line > 0 {
+				o.Block = strings.Join(b.Patterns, " ")
+			}
+			o.BlockPath, o.BlockLine = b.Path, b.Line
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// inherited reports whether o was set outside h's own Host block.
+func (o optionOrigin) inherited(h sshHost) bool {
+	return o.BlockPath != h.SourcePath || o.BlockLine != h.SourceLine
+}
+
+// originLocation is o's file:line, relative to the directory of h's config
+// when it's under it (config.d/work.conf:12).
+func originLocation(h sshHost, o optionOrigin) string {
+	path := tildePath(o.Path)
+	if rel, err := filepath.Rel(filepath.Dir(h.SourcePath), o.Path); err == nil && filepath.IsLocal(rel) {
+		path = rel
+	}
+	return fmt.Sprintf("%s:%d", path, o.Line)
+}
+
+// describeOrigin is one origin for the detail pane and the definitions
+// screen, e.g. "user deploy  config.d/work.conf:12 via Host *.corp".
+func describeOrigin(h sshHost, o optionOrigin) string {
+	s := fmt.Sprintf("%s %s  %s", o.Key, o.Value, originLocation(h, o))
+	switch {
+	case !o.inherited(h):
+	case o.Block == "":
+		s += " (above every Host)"
+	default:
+		s += " via Host " + o.Block
+	}
+	return s
+}
+
+// inheritedLines lists the options h picks up from other blocks, for the
+// detail pane.
+func inheritedLines(h sshHost) []string {
+	var lines []string
+	for _, o := range h.Origins {
+		if !o.inherited(h) {
+			continue
+		}
+		label := ""
+		if len(lines) == 0 {
+			label = "Inherited:"
+		}
+		lines = append(lines, fmt.Sprintf("%-14s %s", label, describeOrigin(h, o)))
+	}
+	return lines
+}
+
+// definitionsView is the `g` screen: every option that applies to the
+// host and where it's set, each one openable in $EDITOR.
+type definitionsView struct {
+	host   sshHost
+	cursor int
+}
+
+func (m model) openDefinitions() model {
+	if len(m.hosts) == 0 {
+		return m
+	}
+	h := m.hosts[m.cursor]
+	if hostSource(h) != "config" || len(h.Origins) == 0 {
+		m.err = fmt.Errorf("%s has no options from the ssh config", h.Alias)
+		return m
+	}
+	m.err = nil
+	m.definitions = &definitionsView{host: h}
+	return m
+}
+
+func (m model) updateDefinitions(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	d := m.definitions
+	m.err = nil
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "g", "q":
+		m.definitions = nil
+	case "j", "down":
+		d.cursor = (d.cursor + 1) % len(d.host.Origins)
+	case "k", "up":
+		d.cursor = (d.cursor - 1 + len(d.host.Origins)) % len(d.host.Origins)
+	case "enter", "e":
+		if m.readOnly {
+			m.err = errReadOnly
+			return m, nil
+		}
+		o := d.host.Origins[d.cursor]
+		if o.Path == "" {
+			m.err = errors.New("no file to open")
+			return m, nil
+		}
+		cmd, err := editorCommand(o.Path, o.Line)
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		return m, tea.ExecProcess(cmd, func(err error) tea.Msg { return editorFinishedMsg{err: err} })
+	}
+	return m, nil
+}
+
+func (m model) renderDefinitions(b *strings.Builder) {
+	d := m.definitions
+	fmt.Fprintln(b, m.styles.title.Render("Where "+d.host.Alias+"'s options are set"))
+	fmt.Fprintln(b, m.styles.help.Render(tr("help.definitions")))
+	fmt.Fprintln(b, "")
+	for i, o := range d.host.Origins {
+		line := describeOrigin(d.host, o)
+		if i == d.cursor {
+			fmt.Fprintln(b, m.styles.selected.Render("> "+line))
+		} else {
+			fmt.Fprintln(b, m.styles.item.Render("  "+line))
+		}
+	}
+	if m.err != nil {
+		fmt.Fprintln(b, "")
+		fmt.Fprintln(b, m.styles.error.Render(m.err.Error()))
+	}
+}
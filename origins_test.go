@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestEffectiveOrigins(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "config.d"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"config": `Compression yes
+Include config.d/*.conf
+
+Host db.corp
+  User root
+  IdentityFile ~/.ssh/db
+
+Host * !bastion
+  User nobody
+  IdentityFile ~/.ssh/default
+`,
+		"config.d/work.conf": `# work hosts
+Host *.corp
+  User deploy
+  Port 2222
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hosts, err := parseSSHConfig(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 {
+		t.Fatalf("hosts %+v", hosts)
+	}
+	h := hosts[0]
+	var got []string
+	for _, o := range h.Origins {
+		got = append(got, describeOrigin(h, o))
+	}
+	want := []string{
+		"compression yes  config:1 (above every Host)",
+		"user deploy  config.d/work.conf:3 via Host *.corp",
+		"port 2222  config.d/work.conf:4 via Host *.corp",
+		"identityfile ~/.ssh/db  config:6",
+		"identityfile ~/.ssh/default  config:10 via Host * !bastion",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("origins:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if lines := inheritedLines(h); len(lines) != 4 || !strings.HasPrefix(lines[0], "Inherited:") {
+		t.Fatalf("inherited %q", lines)
+	}
+}
+
+func TestHostPatternsMatch(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		patterns []string
+		alias    string
+		want     bool
+	}{
+		{[]string{"*.corp"}, "db.corp", true},
+		{[]string{"web?"}, "web1", true},
+		{[]string{"web?"}, "web10", false},
+		{[]string{"*", "!bastion"}, "bastion", false},
+		{[]string{"!bastion"}, "web1", false},
+		{[]string{"DB.CORP"}, "db.corp", true},
+	}
+	for _, c := range cases {
+		if got := hostPatternsMatch(c.patterns, c.alias); got != c.want {
+			t.Errorf("%v vs %s: %v, want %v", c.patterns, c.alias, got, c.want)
+		}
+	}
+}
+
+func TestDefinitionsReadOnly(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Alias: "web1", Source: "config", SourcePath: "/tmp/config", SourceLine: 1,
+		Origins: []optionOrigin{{Key: "user", Value: "deploy", Path: "/tmp/config", Line: 2}}}
+	m := initialModel([]sshHost{h}, "", "/tmp/config")
+	m.readOnly = true
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("g")})
+	m = next.(model)
+	if m.definitions == nil {
+		t.Fatal("g did not open the definitions screen")
+	}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m = next.(model); cmd != nil || m.err != errReadOnly {
+		t.Fatalf("read-only mode opened an editor: %v", m.err)
+	}
+}
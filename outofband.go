@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// hostAction is a program sshpick can hand the terminal to for a host, as an
+// alternative to the regular ssh connection, or (with key set) one of the
+// TUI's own key bindings offered from a menu.
+type hostAction struct {
+	label string
+	name  string
+	args  []string
+	key   string
+}
+
+func (a hostAction) String() string {
+	return a.name + " " + strings.Join(a.args, " ")
+}
+
+// outOfBandActions lists the console methods declared by annotations, for
+// reaching a host when ssh itself is down:
+//
+//	# sshpick: ipmi=bmc-web1.example ipmi-user=admin
+//	# sshpick: ec2-instance=i-0abc123 aws-region=eu-west-1
+//	# sshpick: libvirt=web1 libvirt-uri=qemu+ssh://hv1/system
+//	# sshpick: console=~/bin/console   (run with the alias as its argument)
+func outOfBandActions(h sshHost) []hostAction {
+	var actions []hostAction
+	if bmc := h.Annotations["ipmi"]; bmc != "" {
+		args := []string{"-I", "lanplus", "-H", bmc}
+		if u := h.Annotations["ipmi-user"]; u != "" {
+			args = append(args, "-U", u)
+		}
+		// -a prompts for the BMC password instead of taking it in argv.
+		args = append(args, "-a", "sol", "activate")
+		actions = append(actions, hostAction{label: "IPMI serial-over-LAN (" + bmc + ")", name: "ipmitool", args: args})
+	}
+	if id := h.Annotations["ec2-instance"]; id != "" {
+		args := []string{"ec2-instance-connect", "ssh", "--instance-id", id}
+		if r := h.Annotations["aws-region"]; r != "" {
+			args = append(args, "--region", r)
+		}
+		if h.User != "" {
+			args = append(args, "--os-user", h.User)
+		}
+		actions = append(actions, hostAction{label: "EC2 Instance Connect (" + id + ")", name: "aws", args: args})
+	}
+	if dom := h.Annotations["libvirt"]; dom != "" {
+		var args []string
+		if uri := h.Annotations["libvirt-uri"]; uri != "" {
+			args = append(args, "-c", uri)
+		}
+		args = append(args, "console", dom)
+		actions = append(actions, hostAction{label: "libvirt console (" + dom + ")", name: "virsh", args: args})
+	}
+	if c := h.Annotations["console"]; c != "" {
+		actions = append(actions, hostAction{label: "console (" + c + ")", name: expandHome(c), args: []string{h.Alias}})
+	}
+	return actions
+}
+
+// actionMenu lets the user pick one of a host's actions; digits pick directly.
+type actionMenu struct {
+	title  string
+	items  []hostAction
+	cursor int
+}
+
+func (m model) updateActionMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	menu := *m.actions
+	switch key := msg.String(); key {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q":
+		m.actions = nil
+		return m, nil
+	case "j", "down":
+		menu.cursor = (menu.cursor + 1) % len(menu.items)
+	case "k", "up":
+		menu.cursor = (menu.cursor - 1 + len(menu.items)) % len(menu.items)
+	case "enter":
+		return m.chooseAction(menu.items[menu.cursor])
+	default:
+		if n, err := strconv.Atoi(key); err == nil && n >= 1 && n <= len(menu.items) {
+			return m.chooseAction(menu.items[n-1])
+		}
+	}
+	m.actions = &menu
+	return m, nil
+}
+
+func (m model) chooseAction(a hostAction) (tea.Model, tea.Cmd) {
+	m.actions = nil
+	switch a.key {
+	case "":
+	case "enter":
+		return m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	default:
+		return m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(a.key)})
+	}
+	m.handoff = &a
+	return m, tea.Quit
+}
+
+func (m model) renderActionMenu(b *strings.Builder) {
+	menu := m.actions
+	fmt.Fprintln(b, m.styles.title.Render(menu.title))
+	for i, a := range menu.items {
+		line := fmt.Sprintf("%d  %s", i+1, a.label)
+		if a.key != "" {
+			line += "  [" + a.key + "]"
+		}
+		if i == menu.cursor {
+			fmt.Fprintln(b, m.styles.selected.Render("> "+line))
+		} else {
+			fmt.Fprintln(b, m.styles.item.Render("  "+line))
+		}
+	}
+	fmt.Fprintln(b, m.styles.help.Render(tr("help.actions")))
+}
+
+// runHandoff gives the terminal to a chosen action, exiting with its status.
+func runHandoff(a hostAction) {
+	if err := execTool(a.name, a.args); err != nil {
+		if e := runToolSubprocess(a.name, a.args); e != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", a.name, e)
+			os.Exit(exitCode(e))
+		}
+	}
+}
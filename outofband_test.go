@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestOutOfBandActions(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Alias: "web1", User: "ec2-user", Annotations: map[string]string{
+		"ipmi":         "bmc-web1",
+		"ipmi-user":    "admin",
+		"ec2-instance": "i-0abc",
+		"aws-region":   "eu-west-1",
+		"libvirt":      "web1",
+		"libvirt-uri":  "qemu+ssh://hv1/system",
+	}}
+	got := outOfBandActions(h)
+	want := []string{
+		"ipmitool -I lanplus -H bmc-web1 -U admin -a sol activate",
+		"aws ec2-instance-connect ssh --instance-id i-0abc --region eu-west-1 --os-user ec2-user",
+		"virsh -c qemu+ssh://hv1/system console web1",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Fatalf("action %d = %q, want %q", i, got[i].String(), want[i])
+		}
+	}
+	if len(outOfBandActions(sshHost{Alias: "plain"})) != 0 {
+		t.Fatalf("expected no actions without annotations")
+	}
+}
+
+func TestActionMenu_DigitChooses(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Alias: "web1", Annotations: map[string]string{"libvirt": "web1", "console": "/usr/local/bin/con"}}
+	m := initialModel([]sshHost{h}, "", "")
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")})
+	m = next.(model)
+	if m.actions == nil || len(m.actions.items) != 2 {
+		t.Fatalf("expected a menu with two actions, got %#v", m.actions)
+	}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("2")})
+	m = next.(model)
+	if cmd == nil || m.handoff == nil || m.handoff.String() != "/usr/local/bin/con web1" {
+		t.Fatalf("expected handoff to the console program, got %#v", m.handoff)
+	}
+}
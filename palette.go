@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// statusLevel is how a status indicator reads: fine, worth a look, or bad.
+type statusLevel int
+
+const (
+	statusOK statusLevel = iota
+	statusWarn
+	statusBad
+)
+
+// statusPalette colors status indicators. With shapes on, each one also
+// carries a mark, so the level doesn't depend on telling colors apart.
+type statusPalette struct {
+	colors [3]lipgloss.Color // by statusLevel; "" leaves the text uncolored
+	shapes bool
+}
+
+// statusMarks are the shapes shown with shapes on, by statusLevel.
+var statusMarks = [3]string{"✓", "▲", "✗"}
+
+// themes are the palettes the settings' "theme" can pick.
+var themes = map[string]statusPalette{
+	// green, yellow, red
+	"default": {colors: [3]lipgloss.Color{"10", "11", "9"}},
+	// Okabe–Ito blue, orange and vermillion, distinguishable with the
+	// common color vision deficiencies
+	"colorblind": {colors: [3]lipgloss.Color{"#0072B2", "#E69F00", "#D55E00"}, shapes: true},
+	// no color at all, only shapes
+	"mono": {shapes: true},
+}
+
+// palette is the active theme, set from the settings at startup.
+var palette = themes["default"]
+
+// loadPalette picks the theme named in the settings; statusShapes adds the
+// shapes to a theme without them.
+func loadPalette(theme string, statusShapes bool) (statusPalette, error) {
+	if theme == "" {
+		theme = "default"
+	}
+	p, ok := themes[theme]
+	if !ok {
+		names := make([]string, 0, len(themes))
+		for name := range themes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return p, fmt.Errorf("unknown theme %q (%s)", theme, strings.Join(names, ", "))
+	}
+	p.shapes = p.shapes || statusShapes
+	return p, nil
+}
+
+func (p statusPalette) color(l statusLevel) lipgloss.Color {
+	return p.colors[l]
+}
+
+func (p statusPalette) style(l statusLevel) lipgloss.Style {
+	s := lipgloss.NewStyle()
+	if c := p.colors[l]; c != "" {
+		s = s.Foreground(c)
+	}
+	return s
+}
+
+// render colors text for the level, prefixed by its shape when shapes are on.
+func (p statusPalette) render(l statusLevel, text string) string {
+	if p.shapes {
+		text = statusMarks[l] + " " + text
+	}
+	return p.style(l).Render(text)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadPalette(t *testing.T) {
+	t.Parallel()
+	p, err := loadPalette("", false)
+	if err != nil || p.shapes || p.color(statusBad) != "9" {
+		t.Fatalf("default theme: %+v, %v", p, err)
+	}
+	if p, _ = loadPalette("default", true); !p.shapes {
+		t.Fatal("status_shapes should turn shapes on")
+	}
+	p, err = loadPalette("colorblind", false)
+	if err != nil || !p.shapes || p.color(statusOK) == "10" {
+		t.Fatalf("colorblind theme: %+v, %v", p, err)
+	}
+	if _, err := loadPalette("neon", false); err == nil || !strings.Contains(err.Error(), "colorblind") {
+		t.Fatalf("unknown theme should list the themes, got %v", err)
+	}
+}
+
+func TestStatsCellShapes(t *testing.T) {
+	defer func(p statusPalette) { palette = p }(palette)
+	m := model{stats: map[string]hostStats{
+		"full": {Load1: 0.1, CPUs: 1, DiskPct: 95},
+		"fine": {Load1: 0.1, CPUs: 1, DiskPct: 10},
+	}}
+
+	palette = themes["default"]
+	if cell := m.statsCell(sshHost{Alias: "full"}); strings.Contains(cell, "✗") {
+		t.Fatalf("default theme shouldn't add shapes: %q", cell)
+	}
+	palette, _ = loadPalette("mono", false)
+	if cell := m.statsCell(sshHost{Alias: "full"}); !strings.HasPrefix(cell, "✗ load") {
+		t.Fatalf("mono theme should mark a full disk with ✗: %q", cell)
+	}
+	if cell := m.statsCell(sshHost{Alias: "fine"}); !strings.HasPrefix(cell, "✓ load") {
+		t.Fatalf("mono theme should mark a healthy host with ✓: %q", cell)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pasteWorkspace prefixes the tmux session opened for several pasted hosts.
+const pasteWorkspace = "sshpick-paste"
+
+// pasteGroup is the screen shown after a list of hosts is pasted into the
+// picker: the pasted hosts, all selected, ready to connect or save.
+type pasteGroup struct {
+	hosts   []sshHost
+	chosen  map[int]bool
+	cursor  int
+	skipped []string // pasted words that weren't hosts
+	status  string
+	err     error
+}
+
+// workspaceLaunch is a tmux session to bring up once the picker exits.
+type workspaceLaunch struct {
+	name  string
+	ws    workspaceConfig
+	hosts []sshHost
+}
+
+// parsePastedHosts reads hostnames separated by newlines, commas or spaces,
+// each optionally with a user and port (user@host:port) or as an ssh://
+// URL. Anything else, and lines starting with #, is skipped.
+func parsePastedHosts(text string) (urls []sshURL, skipped []string) {
+	seen := map[sshURL]bool{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, word := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\r' }) {
+			var u sshURL
+			var err error
+			switch {
+			case net.ParseIP(word) != nil:
+				u.Host = word
+			case strings.Contains(word, "://"):
+				u, err = parseSSHURL(word)
+			default:
+				u, err = parseSSHURL("ssh://" + word)
+			}
+			if err != nil || u.Fingerprint != "" {
+				skipped = append(skipped, word)
+				continue
+			}
+			if !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+	return urls, skipped
+}
+
+// openPasteGroup turns pasted text into a group. Hosts the picker already
+// has are reused; the rest become ad-hoc "paste" hosts added to the list.
+func (m model) openPasteGroup(text string) model {
+	urls, skipped := parsePastedHosts(text)
+	if len(urls) == 0 {
+		m.err = errors.New("nothing in the paste looks like a host")
+		return m
+	}
+	taken := map[string]bool{}
+	for _, h := range m.allHosts {
+		taken[h.Alias] = true
+	}
+	g := &pasteGroup{chosen: map[int]bool{}, skipped: skipped}
+	var added []sshHost
+	for _, u := range urls {
+		h, ok := matchURLHost(m.allHosts, u)
+		if !ok {
+			h = urlHost(u)
+			h.Source = "paste"
+			h.Alias = pastedAlias(taken, h.Alias, u)
+			taken[h.Alias] = true
+			added = append(added, h)
+		}
+		g.chosen[len(g.hosts)] = true
+		g.hosts = append(g.hosts, h)
+	}
+	if len(g.hosts) == 0 {
+		m.err = errors.New("nothing in the paste looks like a host")
+		return m
+	}
+	m.allHosts = append(append([]sshHost(nil), m.allHosts...), added...)
+	m.applyFilter(m.lastValidRegex)
+	m.err = nil
+	m.paste = g
+	return m
+}
+
+// pastedAlias is an alias for a pasted host that no other host has: the
+// short name, the full name, then either with the user (web1-bob) or a
+// number appended, so a user@host colliding with a configured host is still
+// listed.
+func pastedAlias(taken map[string]bool, alias string, u sshURL) string {
+	candidates := []string{alias, u.Host}
+	if u.User != "" {
+		candidates = append(candidates, alias+"-"+u.User, u.Host+"-"+u.User)
+	}
+	for _, c := range candidates {
+		if !taken[c] {
+			return c
+		}
+	}
+	base := candidates[len(candidates)-1]
+	for i := 2; ; i++ {
+		if c := fmt.Sprintf("%s-%d", base, i); !taken[c] {
+			return c
+		}
+	}
+}
+
+func (g *pasteGroup) selected() []sshHost {
+	var out []sshHost
+	for i, h := range g.hosts {
+		if g.chosen[i] {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// savePasted appends the selected ad-hoc hosts to the ssh config and marks
+// them as config hosts from then on.
+func (m model) savePasted() model {
+	g := m.paste
+	if m.readOnly {
+		g.err = errReadOnly
+		return m
+	}
+	saved := 0
+	for i, h := range g.hosts {
+		if !g.chosen[i] || h.Source != "paste" {
+			continue
+		}
+		if err := appendHostBlock(m.configPath, hostBlock{Alias: h.Alias, HostName: h.Hostname, User: h.User, Port: h.Port}); err != nil {
+			g.err = err
+			break
+		}
+		h.Source, h.SourcePath = "", m.configPath
+		g.hosts[i] = h
+		for j := range m.allHosts {
+			if m.allHosts[j].Alias == h.Alias {
+				m.allHosts[j] = h
+			}
+		}
+		saved++
+	}
+	m.applyFilter(m.lastValidRegex)
+	g.status = fmt.Sprintf("Saved %d host(s) to %s.", saved, tildePath(m.configPath))
+	return m
+}
+
+func (m model) updatePasteGroup(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	g := m.paste
+	g.err, g.status = nil, ""
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q":
+		m.paste = nil
+	}
+	if m.paste == nil || len(g.hosts) == 0 {
+		m.paste = nil
+		return m, nil
+	}
+	switch msg.String() {
+	case "j", "down":
+		g.cursor = (g.cursor + 1) % len(g.hosts)
+	case "k", "up":
+		g.cursor = (g.cursor - 1 + len(g.hosts)) % len(g.hosts)
+	case " ", "space":
+		g.chosen[g.cursor] = !g.chosen[g.cursor]
+	case "a":
+		all := len(g.selected()) < len(g.hosts)
+		for i := range g.hosts {
+			g.chosen[i] = all
+		}
+	case "s":
+		return m.savePasted(), nil
+	case "enter":
+		picked := g.selected()
+		switch len(picked) {
+		case 0:
+			g.err = errors.New("nothing selected")
+		case 1:
+			m.paste = nil
+			return m.beginConnect(picked[0])
+		default:
+			ws := workspaceConfig{}
+			for _, h := range picked {
+				ws.Hosts = append(ws.Hosts, h.Alias)
+			}
+			m.paste = nil
+			// One session per run, so an older paste group's session isn't reused.
+			name := fmt.Sprintf("%s-%d", pasteWorkspace, os.Getpid())
+			m.workspace = &workspaceLaunch{name: name, ws: ws, hosts: picked}
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+func (m model) renderPasteGroup(b *strings.Builder) {
+	g := m.paste
+	fmt.Fprintln(b, m.styles.title.Render(fmt.Sprintf("Pasted hosts (%d)", len(g.hosts))))
+	fmt.Fprintln(b, m.styles.help.Render(tr("help.paste")))
+	fmt.Fprintln(b, "")
+	for i, h := range g.hosts {
+		mark := "[ ]"
+		if g.chosen[i] {
+			mark = "[x]"
+		}
+		origin := "new"
+		if h.Source != "paste" {
+			origin = "in " + hostSource(h)
+		}
+		line := fmt.Sprintf("%s %-20s %-40s %s", mark, h.Alias, hostSubtitle(h), origin)
+		if i == g.cursor {
+			fmt.Fprintln(b, m.styles.selected.Render("> "+line))
+		} else {
+			fmt.Fprintln(b, m.styles.item.Render("  "+line))
+		}
+	}
+	if len(g.skipped) > 0 {
+		fmt.Fprintln(b, "")
+		fmt.Fprintln(b, m.styles.help.Render("Skipped: "+strings.Join(g.skipped, ", ")))
+	}
+	if g.status != "" {
+		fmt.Fprintln(b, "")
+		fmt.Fprintln(b, m.styles.help.Render(g.status))
+	}
+	if g.err != nil {
+		fmt.Fprintln(b, "")
+		fmt.Fprintln(b, m.styles.error.Render(g.err.Error()))
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParsePastedHosts(t *testing.T) {
+	t.Parallel()
+
+	urls, skipped := parsePastedHosts("# prod\nweb1.example.com\r\nroot@db1:2222, 10.0.0.7\n\nfe80::1 web1.example.com\nssh://ops@cache1\n-oProxyCommand=x not/a/host\n")
+	want := []sshURL{
+		{Host: "web1.example.com"},
+		{User: "root", Host: "db1", Port: "2222"},
+		{Host: "10.0.0.7"},
+		{Host: "fe80::1"},
+		{User: "ops", Host: "cache1"},
+	}
+	if len(urls) != len(want) {
+		t.Fatalf("urls %+v", urls)
+	}
+	for i := range want {
+		if urls[i] != want[i] {
+			t.Fatalf("url %d = %+v, want %+v", i, urls[i], want[i])
+		}
+	}
+	if strings.Join(skipped, " ") != "-oProxyCommand=x not/a/host" {
+		t.Fatalf("skipped %q", skipped)
+	}
+}
+
+func TestPasteGroup(t *testing.T) {
+	t.Parallel()
+
+	cfg := filepath.Join(t.TempDir(), "config")
+	m := initialModel([]sshHost{{Alias: "web1", Hostname: "web1.example.com"}, {Alias: "db1"}}, "", cfg)
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("web1.example.com\ndb1.example.com\nops@db1.example.org"), Paste: true})
+	m = next.(model)
+	if m.paste == nil || len(m.paste.hosts) != 3 {
+		t.Fatalf("paste group %+v", m.paste)
+	}
+	g := m.paste
+	if g.hosts[0].Alias != "web1" || g.hosts[0].Source == "paste" {
+		t.Fatalf("existing host not reused: %+v", g.hosts[0])
+	}
+	// db1 is taken, so the new hosts fall back to their full names.
+	if g.hosts[1].Alias != "db1.example.com" || g.hosts[2].Alias != "db1.example.org" || g.hosts[2].User != "ops" {
+		t.Fatalf("new hosts %+v", g.hosts[1:])
+	}
+	if len(m.allHosts) != 4 {
+		t.Fatalf("hosts %+v", m.allHosts)
+	}
+
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	m = next.(model)
+	data, err := os.ReadFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); !strings.Contains(s, "Host db1.example.com") || !strings.Contains(s, "User ops") || strings.Contains(s, "Host web1") {
+		t.Fatalf("saved config:\n%s", s)
+	}
+	if m.paste.hosts[1].Source != "" {
+		t.Fatalf("saved host still ad hoc: %+v", m.paste.hosts[1])
+	}
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if cmd == nil || m.workspace == nil || strings.Join(m.workspace.ws.Hosts, " ") != "web1 db1.example.com db1.example.org" {
+		t.Fatalf("workspace %+v", m.workspace)
+	}
+}
+
+func TestPasteIgnoredWhenRestricted(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel([]sshHost{{Alias: "web1"}}, "", "/tmp/ssh_config")
+	m.restrict = &restrictConfig{}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("evil.example.com"), Paste: true})
+	if m = next.(model); m.paste != nil || len(m.allHosts) != 1 {
+		t.Fatalf("paste accepted in restricted mode: %+v", m.paste)
+	}
+}
+
+func TestPasteCollidingHosts(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel([]sshHost{{Alias: "web1", Hostname: "web1", User: "alice"}}, "", "/tmp/ssh_config")
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("bob@web1\ncarol@web1"), Paste: true})
+	m = next.(model)
+	if m.paste == nil || len(m.paste.hosts) != 2 {
+		t.Fatalf("paste group %+v", m.paste)
+	}
+	if a, b := m.paste.hosts[0], m.paste.hosts[1]; a.Alias != "web1-bob" || a.User != "bob" || b.Alias != "web1-carol" {
+		t.Fatalf("colliding hosts %+v", m.paste.hosts)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	if m = next.(model); m.paste.cursor != 1 {
+		t.Fatalf("cursor %d", m.paste.cursor)
+	}
+}
+
+func TestPasteGroupWithoutHosts(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel([]sshHost{{Alias: "web1"}}, "", "/tmp/ssh_config")
+	m.paste = &pasteGroup{chosen: map[int]bool{}}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	if m = next.(model); m.paste != nil {
+		t.Fatalf("empty paste group kept open: %+v", m.paste)
+	}
+}
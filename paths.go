@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+// sshDirOverride is set by -ssh-dir; SSHPICK_SSH_DIR does the same for
+// environments where flags are awkward (containers, CI).
+var sshDirOverride string
+
+// homeDir is the user's home directory: $HOME, else the passwd entry (for
+// containers and CI that don't set HOME), else "".
+func homeDir() string {
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		return home
+	}
+	if u, err := user.Current(); err == nil && u.HomeDir != "" {
+		return u.HomeDir
+	}
+	return ""
+}
+
+// defaultSSHDir is the directory holding the ssh config and keys, or "" when
+// there's no override and no home directory to find it in.
+func defaultSSHDir() string {
+	if sshDirOverride != "" {
+		return sshDirOverride
+	}
+	if dir := os.Getenv("SSHPICK_SSH_DIR"); dir != "" {
+		return dir
+	}
+	if home := homeDir(); home != "" {
+		return filepath.Join(home, ".ssh")
+	}
+	return ""
+}
+
+// defaultConfigPath is the ssh config inside defaultSSHDir, or "" if unknown.
+func defaultConfigPath() string {
+	dir := defaultSSHDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "config")
+}
+
+// configFileArgs point ssh, scp and sftp at the config sshpick read when
+// -ssh-dir or SSHPICK_SSH_DIR moved it; on their own they'd read
+// ~/.ssh/config.
+func configFileArgs() []string {
+	if sshDirOverride == "" && os.Getenv("SSHPICK_SSH_DIR") == "" {
+		return nil
+	}
+	path := defaultConfigPath()
+	if !fileExists(path) {
+		return nil
+	}
+	return []string{"-F", path}
+}
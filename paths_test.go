@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultSSHDir(t *testing.T) {
+	t.Setenv("SSHPICK_SSH_DIR", "")
+	t.Setenv("HOME", "/home/fixture")
+	if got := defaultSSHDir(); got != filepath.Join("/home/fixture", ".ssh") {
+		t.Fatalf("defaultSSHDir = %q", got)
+	}
+
+	t.Setenv("SSHPICK_SSH_DIR", "/fixtures/ssh")
+	if got := defaultConfigPath(); got != filepath.Join("/fixtures/ssh", "config") {
+		t.Fatalf("defaultConfigPath = %q", got)
+	}
+
+	// Without HOME the passwd entry is used; either way it must not be a
+	// relative path.
+	t.Setenv("SSHPICK_SSH_DIR", "")
+	t.Setenv("HOME", "")
+	if got := defaultSSHDir(); got != "" && !filepath.IsAbs(got) {
+		t.Fatalf("defaultSSHDir without HOME = %q", got)
+	}
+}
+
+func TestConfigFileArgs(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SSHPICK_SSH_DIR", dir)
+	h := sshHost{Alias: "web1"}
+	if got := sshArgs(h, launchOptions{}); !reflect.DeepEqual(got, []string{"--", "web1"}) {
+		t.Fatalf("no config in the ssh dir, no -F: %q", got)
+	}
+
+	cfg := filepath.Join(dir, "config")
+	os.WriteFile(cfg, []byte("Host web1\n"), 0o600)
+	want := "-F " + cfg + " "
+	for name, args := range map[string][]string{
+		"ssh":    sshArgs(h, launchOptions{}),
+		"sftp":   sftpArgs(h),
+		"scp":    scpArgs(h, transferEntry{Local: "a", Remote: "b", Push: true}),
+		"scp -3": scp3Args(h, sshHost{Alias: "web2"}, "a", "b"),
+		"remote": remoteArgs(h, "uptime", false),
+	} {
+		if !strings.Contains(strings.Join(args, " "), want) {
+			t.Errorf("%s args %q lack -F %s", name, args, cfg)
+		}
+	}
+
+	// The config written for picked forwards replaces it.
+	got := sshArgs(h, launchOptions{configFile: "/tmp/picked"})
+	if strings.Count(strings.Join(got, " "), "-F ") != 1 || !strings.Contains(strings.Join(got, " "), "-F /tmp/picked") {
+		t.Fatalf("picked forwards config: %q", got)
+	}
+}
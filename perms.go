@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// permProblem is a file ssh will refuse, or silently skip, because of its
+// mode: a private key others can read, a config others can write.
+type permProblem struct {
+	Path string
+	What string // "ssh directory", "ssh config", "identity file"
+	Mode os.FileMode
+	Want os.FileMode
+}
+
+func (p permProblem) String() string {
+	return fmt.Sprintf("%s %s is %04o, ssh wants %04o", p.What, tildePath(p.Path), p.Mode, p.Want)
+}
+
+// defaultIdentities are the keys ssh tries when no IdentityFile is set.
+var defaultIdentities = []string{"id_rsa", "id_ecdsa", "id_ecdsa_sk", "id_ed25519", "id_ed25519_sk"}
+
+// checkPerm reports path when its mode has any of the bad bits set.
+// Missing files aren't a permissions problem.
+func checkPerm(path, what string, bad, want os.FileMode) []permProblem {
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode().Perm()&bad == 0 {
+		return nil
+	}
+	return []permProblem{{Path: path, What: what, Mode: fi.Mode().Perm(), Want: want}}
+}
+
+// hostPermProblems checks what ssh reads to connect to h: the ssh
+// directory, the config file h is defined in and its identity files (or
+// the default keys when it names none).
+func hostPermProblems(h sshHost, sshDir string) []permProblem {
+	var out []permProblem
+	if sshDir != "" {
+		// ssh only refuses a directory others can write to.
+		out = append(out, checkPerm(sshDir, "ssh directory", 0o022, 0o700)...)
+	}
+	if hostSource(h) == "config" && h.SourcePath != "" {
+		out = append(out, checkPerm(h.SourcePath, "ssh config", 0o022, 0o600)...)
+	}
+	ids := h.IdentityFiles
+	if len(ids) == 0 && sshDir != "" {
+		for _, name := range defaultIdentities {
+			ids = append(ids, filepath.Join(sshDir, name))
+		}
+	}
+	for _, id := range ids {
+		if strings.Contains(id, "%") {
+			continue // tokens are expanded by ssh at connect time
+		}
+		out = append(out, checkPerm(expandHome(id), "identity file", 0o077, 0o600)...)
+	}
+	return out
+}
+
+// fixPerms chmods every problem to the mode ssh wants.
+func fixPerms(problems []permProblem) error {
+	var errs []error
+	for _, p := range problems {
+		if err := os.Chmod(p.Path, p.Want); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// permLines are the detail pane's warnings for h.
+func (m model) permLines(h sshHost) []string {
+	var lines []string
+	for i, p := range hostPermProblems(h, defaultSSHDir()) {
+		label := ""
+		if i == 0 {
+			label = "Permissions:"
+		}
+		lines = append(lines, fmt.Sprintf("%-14s %s", label, p))
+	}
+	if len(lines) > 0 && !m.readOnly {
+		lines = append(lines, fmt.Sprintf("%-14s %s", "", "press X to fix"))
+	}
+	return lines
+}
+
+// fixHostPerms is the X key: chmod what hostPermProblems found for the
+// highlighted host.
+func (m model) fixHostPerms() model {
+	if len(m.hosts) == 0 {
+		return m
+	}
+	if m.readOnly {
+		m.err = errReadOnly
+		return m
+	}
+	h := m.hosts[m.cursor]
+	problems := hostPermProblems(h, defaultSSHDir())
+	if len(problems) == 0 {
+		m.err, m.status = nil, fmt.Sprintf("Permissions for %s are fine.", h.Alias)
+		return m
+	}
+	if m.err = fixPerms(problems); m.err == nil {
+		m.status = fmt.Sprintf("Fixed %d permission problem(s) for %s.", len(problems), h.Alias)
+	}
+	return m
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestHostPermProblems(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "ssh")
+	os.MkdirAll(dir, 0o700)
+	cfg := filepath.Join(dir, "config")
+	key := filepath.Join(dir, "work_ed25519")
+	os.WriteFile(cfg, []byte("Host db1\n"), 0o600)
+	os.WriteFile(key, []byte("key"), 0o600)
+	os.WriteFile(filepath.Join(dir, "id_ed25519"), []byte("key"), 0o600)
+	os.Chmod(dir, 0o775)
+	os.Chmod(cfg, 0o666)
+	os.Chmod(key, 0o644)
+
+	h := sshHost{Alias: "db1", SourcePath: cfg, SourceLine: 1, IdentityFiles: []string{key, "~/.ssh/id_%h"}}
+	problems := hostPermProblems(h, dir)
+	if len(problems) != 3 {
+		t.Fatalf("want the directory, config and key flagged, got %v", problems)
+	}
+	if s := problems[2].String(); !strings.Contains(s, "0644") || !strings.Contains(s, "0600") {
+		t.Fatalf("problem text %q", s)
+	}
+	// a host without IdentityFile is checked against the default keys
+	os.Chmod(filepath.Join(dir, "id_ed25519"), 0o640)
+	if got := hostPermProblems(sshHost{Alias: "web", Source: "prometheus"}, dir); len(got) != 2 || got[1].What != "identity file" {
+		t.Fatalf("default keys: %v", got)
+	}
+
+	if err := fixPerms(problems); err != nil {
+		t.Fatal(err)
+	}
+	if left := hostPermProblems(h, dir); len(left) != 0 {
+		t.Fatalf("still wrong after fixing: %v", left)
+	}
+	if fi, _ := os.Stat(key); fi.Mode().Perm() != 0o600 {
+		t.Fatalf("key is %04o", fi.Mode().Perm())
+	}
+
+	// a directory others can only read is fine by ssh
+	os.Chmod(dir, 0o755)
+	if left := hostPermProblems(h, dir); len(left) != 0 {
+		t.Fatalf("0755 ssh directory flagged: %v", left)
+	}
+
+	m := initialModel([]sshHost{h}, "", cfg)
+	m.readOnly = true
+	if m = m.fixHostPerms(); m.err != errReadOnly {
+		t.Fatalf("read-only mode should refuse to chmod: %v", m.err)
+	}
+}
+
+func TestFixHostPermsStatus(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SSHPICK_SSH_DIR", dir)
+	os.Chmod(dir, 0o700)
+	cfg := filepath.Join(dir, "config")
+	os.WriteFile(cfg, []byte("Host db1\n"), 0o666)
+	os.Chmod(cfg, 0o666)
+	h := sshHost{Alias: "db1", SourcePath: cfg, SourceLine: 1}
+
+	m := initialModel([]sshHost{h}, "", cfg)
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("X")})
+	m = next.(model)
+	if m.err != nil || !strings.Contains(m.status, "Fixed 1") || !strings.Contains(m.View(), m.status) {
+		t.Fatalf("err %v, status %q", m.err, m.status)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	if next.(model).status != "" {
+		t.Fatal("the status should clear on the next key")
+	}
+}
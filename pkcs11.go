@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pkcs11Timeout bounds a card listing; a wedged reader must not hang the
+// picker.
+const pkcs11Timeout = 10 * time.Second
+
+// pkcs11Provider is the PKCS#11 library h loads its keys from, or "" when
+// it uses none.
+func pkcs11Provider(h sshHost) string {
+	p := strings.Trim(h.effectiveOption("pkcs11provider"), `"`)
+	if p == "" || strings.EqualFold(p, "none") {
+		return ""
+	}
+	return expandHome(p)
+}
+
+// listPKCS11Keys lists the public keys on the card behind provider with
+// ssh-keygen -D. A variable so tests don't need a card.
+var listPKCS11Keys = func(provider string) ([]publicKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pkcs11Timeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ssh-keygen", "-D", provider).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parsePublicKeys(string(out), "pkcs11:"+filepath.Base(provider)), nil
+}
+
+// cardStatus is what a provider's card listing found.
+type cardStatus struct {
+	provider string
+	keys     []publicKey
+	err      error
+}
+
+// checkCard lists the card's keys. A missing library is reported as such
+// rather than as ssh-keygen's exit status.
+func checkCard(provider string) cardStatus {
+	if _, err := os.Stat(provider); err != nil {
+		return cardStatus{provider: provider, err: fmt.Errorf("PKCS11Provider %s: %w", tildePath(provider), err)}
+	}
+	keys, err := listPKCS11Keys(provider)
+	return cardStatus{provider: provider, keys: keys, err: err}
+}
+
+func (s cardStatus) present() bool {
+	return s.err == nil && len(s.keys) > 0
+}
+
+type cardStatusMsg struct {
+	provider string
+	status   cardStatus
+}
+
+// refreshCard lists the card of the highlighted host's provider for the
+// detail pane, once per provider per run.
+func (m *model) refreshCard(h sshHost) tea.Cmd {
+	provider := pkcs11Provider(h)
+	if !m.showDetail || provider == "" || m.cardsPending[provider] {
+		return nil
+	}
+	if _, done := m.cards[provider]; done {
+		return nil
+	}
+	if m.cardsPending == nil {
+		m.cardsPending = map[string]bool{}
+	}
+	m.cardsPending[provider] = true
+	return func() tea.Msg {
+		return cardStatusMsg{provider: provider, status: checkCard(provider)}
+	}
+}
+
+// cardLines are the detail pane's smartcard lines: the provider and each
+// identity on the card.
+func (m model) cardLines(h sshHost) []string {
+	provider := pkcs11Provider(h)
+	if provider == "" {
+		return nil
+	}
+	head := fmt.Sprintf("%-14s %s", "Smartcard:", tildePath(provider))
+	st, done := m.cards[provider]
+	switch {
+	case !done:
+		return []string{head + " (checking card…)"}
+	case st.err != nil:
+		return []string{head + " (no card: " + st.err.Error() + ")"}
+	case len(st.keys) == 0:
+		return []string{head + " (no keys on the card)"}
+	}
+	lines := []string{head}
+	for _, k := range st.keys {
+		lines = append(lines, fmt.Sprintf("%-14s %s %s %s", "", k.Type, keyFingerprint(k.Key), k.Comment))
+	}
+	return lines
+}
+
+// pkcs11Check is the pre-connect check for hosts that need a hardware
+// token: without the card ssh falls back to other keys or a password,
+// or fails after a PIN prompt that can't succeed. The card is listed in the
+// background and again after "check again".
+func pkcs11Check(m model, h sshHost) *connectPrompt {
+	provider := pkcs11Provider(h)
+	if provider == "" {
+		return nil
+	}
+	p := &connectPrompt{id: "pkcs11", message: fmt.Sprintf("Checking the smartcard for %s…", h.Alias)}
+	r, waiting := precheck(m, "pkcs11:"+provider, p, func() any { return checkCard(provider) })
+	if waiting != nil {
+		return waiting
+	}
+	st := r.(cardStatus)
+	if st.present() {
+		return nil
+	}
+	if _, err := os.Stat(provider); err != nil {
+		p.message = fmt.Sprintf("%s loads keys from %s, which doesn't exist.", h.Alias, tildePath(provider))
+		return p
+	}
+	p.message = fmt.Sprintf("%s needs a smartcard (PKCS11Provider %s) but no card with keys was found.", h.Alias, filepath.Base(provider))
+	p.actions = append(p.actions, promptAction{
+		key:   "r",
+		label: "check again",
+		cmd:   func() *exec.Cmd { return exec.Command("ssh-keygen", "-D", provider) },
+	})
+	return p
+}
+
+// pkcs11PublicKeys are the keys on the cards hosts use, for keys audit.
+func pkcs11PublicKeys(hosts []sshHost) []publicKey {
+	var keys []publicKey
+	seen := map[string]bool{}
+	for _, h := range hosts {
+		provider := pkcs11Provider(h)
+		if provider == "" || seen[provider] {
+			continue
+		}
+		seen[provider] = true
+		keys = append(keys, checkCard(provider).keys...)
+	}
+	return keys
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPKCS11Check(t *testing.T) {
+	orig := listPKCS11Keys
+	defer func() { listPKCS11Keys = orig }()
+	inserted := false
+	listPKCS11Keys = func(provider string) ([]publicKey, error) {
+		if !inserted {
+			return nil, errors.New("exit status 255")
+		}
+		return parsePublicKeys("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAAA PIV AUTH pubkey\n", "pkcs11:opensc-pkcs11.so"), nil
+	}
+	lib := filepath.Join(t.TempDir(), "opensc-pkcs11.so")
+	os.WriteFile(lib, nil, 0o644)
+
+	h := sshHost{Alias: "vault", Origins: []optionOrigin{{Key: "pkcs11provider", Value: `"` + lib + `"`}}}
+	if pkcs11Provider(h) != lib || pkcs11Provider(sshHost{Options: map[string]string{"pkcs11provider": "none"}}) != "" {
+		t.Fatalf("provider %q", pkcs11Provider(h))
+	}
+	p := settle(t, model{}, pkcs11Check, h)
+	if p == nil || len(p.actions) != 1 || !strings.Contains(p.message, "no card") {
+		t.Fatalf("prompt %+v", p)
+	}
+	inserted = true
+	if p := settle(t, model{}, pkcs11Check, h); p != nil {
+		t.Fatalf("card present: %s", p.message)
+	}
+
+	m := model{showDetail: true}
+	msg := m.refreshCard(h)()
+	next, _ := m.Update(msg)
+	lines := next.(model).cardLines(h)
+	if len(lines) != 2 || !strings.Contains(lines[1], "ssh-ed25519 SHA256:") || !strings.Contains(lines[1], "PIV AUTH") {
+		t.Fatalf("detail %q", lines)
+	}
+
+	missing := sshHost{Alias: "old", Options: map[string]string{"pkcs11provider": "/nonexistent/lib.so"}}
+	if p := settle(t, model{}, pkcs11Check, missing); p == nil || !strings.Contains(p.message, "doesn't exist") {
+		t.Fatalf("missing library: %+v", p)
+	}
+}
+
+func TestPKCS11CheckInBackground(t *testing.T) {
+	orig := listPKCS11Keys
+	defer func() { listPKCS11Keys = orig }()
+	inserted, listed := false, 0
+	listPKCS11Keys = func(provider string) ([]publicKey, error) {
+		listed++
+		if !inserted {
+			return nil, errors.New("exit status 255")
+		}
+		return parsePublicKeys("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAAA PIV AUTH pubkey\n", "pkcs11:opensc-pkcs11.so"), nil
+	}
+	lib := filepath.Join(t.TempDir(), "opensc-pkcs11.so")
+	os.WriteFile(lib, nil, 0o644)
+	h := sshHost{Alias: "vault", Options: map[string]string{"pkcs11provider": lib}}
+	m := initialModel([]sshHost{h}, "", "")
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if m.prompt == nil || m.prompt.pending == nil || cmd == nil || listed != 0 {
+		t.Fatalf("the card should be listed in the background: prompt %+v, listed %d", m.prompt, listed)
+	}
+	next, _ = m.Update(cmd())
+	m = next.(model)
+	if m.prompt == nil || m.prompt.pending != nil || !strings.Contains(m.prompt.message, "no card") || listed != 1 {
+		t.Fatalf("prompt %+v, listed %d", m.prompt, listed)
+	}
+
+	// "check again" after inserting the card lists it anew.
+	inserted = true
+	next, cmd = m.Update(promptActionMsg{})
+	m = next.(model)
+	if m.prompt == nil || m.prompt.pending == nil || cmd == nil {
+		t.Fatalf("retry should list the card again, prompt %+v", m.prompt)
+	}
+	next, _ = m.Update(cmd())
+	m = next.(model)
+	if !m.chosen || m.prompt != nil || listed != 2 || !m.cards[lib].present() {
+		t.Fatalf("chosen %v, prompt %+v, listed %d, cards %+v", m.chosen, m.prompt, listed, m.cards)
+	}
+}
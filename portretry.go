@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// forwardRetries is how many times a session is restarted on a new local
+// port after ssh reports the old one taken.
+const forwardRetries = 3
+
+// forwardSpec is a -L spec split around its local port: [bind:]port:rest.
+type forwardSpec struct {
+	bind string // "" or the bind address, brackets kept
+	port int
+	rest string // host:hostport or a remote socket path
+}
+
+// parseForwardSpec understands the TCP forms of -L. Unix socket forwards
+// have no port to move and report ok=false.
+func parseForwardSpec(spec string) (forwardSpec, bool) {
+	var f forwardSpec
+	s := spec
+	if strings.HasPrefix(s, "[") {
+		end := strings.Index(s, "]:")
+		if end < 0 {
+			return f, false
+		}
+		f.bind, s = s[:end+1], s[end+2:]
+	}
+	first, rest, ok := strings.Cut(s, ":")
+	if !ok {
+		return f, false
+	}
+	port, err := strconv.Atoi(first)
+	if err != nil && f.bind == "" {
+		// bind:port:host:hostport
+		f.bind = first
+		if first, rest, ok = strings.Cut(rest, ":"); !ok {
+			return f, false
+		}
+		port, err = strconv.Atoi(first)
+	}
+	if err != nil || port < 1 || port > 65535 || rest == "" {
+		return f, false
+	}
+	f.port, f.rest = port, rest
+	return f, true
+}
+
+func (f forwardSpec) String() string {
+	if f.bind != "" {
+		return fmt.Sprintf("%s:%d:%s", f.bind, f.port, f.rest)
+	}
+	return fmt.Sprintf("%d:%s", f.port, f.rest)
+}
+
+// nextFreePort is the first port after p that's free here and not taken by
+// another forward of the same session.
+func nextFreePort(p int, taken map[int]bool) int {
+	for q := p + 1; q <= 65535 && q <= p+100; q++ {
+		if !taken[q] && localPortFree(q) {
+			return q
+		}
+	}
+	return 0
+}
+
+// explicitForwards lists the -L specs sshpick adds itself: -L and the
+// port picker's. These are the ones it can move.
+func (opts launchOptions) explicitForwards() []string {
+	var specs []string
+	if opts.localForward != "" {
+		specs = append(specs, opts.localForward)
+	}
+	return append(specs, opts.forwards...)
+}
+
+// moveForward rewrites every explicit forward on local port from to the
+// next free port. It reports false when no forward uses that port or no
+// free port was found.
+func (opts *launchOptions) moveForward(from int) (int, bool) {
+	taken := map[int]bool{}
+	for _, s := range opts.explicitForwards() {
+		if f, ok := parseForwardSpec(s); ok {
+			taken[f.port] = true
+		}
+	}
+	to := nextFreePort(from, taken)
+	if to == 0 {
+		return 0, false
+	}
+	moved := false
+	move := func(s string) string {
+		f, ok := parseForwardSpec(s)
+		if !ok || f.port != from {
+			return s
+		}
+		moved = true
+		f.port = to
+		return f.String()
+	}
+	opts.localForward = move(opts.localForward)
+	forwards := make([]string, len(opts.forwards))
+	for i, s := range opts.forwards {
+		forwards[i] = move(s)
+	}
+	opts.forwards = forwards
+	return to, moved
+}
+
+// freeForwardPorts moves forwards whose local port is already taken before
+// ssh even starts, returning what it moved.
+func freeForwardPorts(opts *launchOptions) []string {
+	var notes []string
+	for _, s := range opts.explicitForwards() {
+		f, ok := parseForwardSpec(s)
+		if !ok || f.bind != "" || localPortFree(f.port) {
+			continue
+		}
+		if to, ok := opts.moveForward(f.port); ok {
+			notes = append(notes, fmt.Sprintf("local port %d is in use; using %d", f.port, to))
+		}
+	}
+	return notes
+}
+
+var (
+	cannotListen = regexp.MustCompile(`cannot listen to port: (\d+)`)
+	bindInUse    = regexp.MustCompile(`bind \[?[^\]\s]*\]?:(\d+): Address already in use`)
+)
+
+// failedForwardPort finds the local port ssh couldn't listen on in its
+// stderr, as printed with ExitOnForwardFailure.
+func failedForwardPort(lines []string) (int, bool) {
+	for _, line := range lines {
+		for _, re := range []*regexp.Regexp{cannotListen, bindInUse} {
+			if m := re.FindStringSubmatch(line); m != nil {
+				port, err := strconv.Atoi(m[1])
+				return port, err == nil
+			}
+		}
+	}
+	return 0, false
+}
+
+// describeForwards is the final mapping, e.g.
+// "localhost:18080 → localhost:8080 on db1".
+func describeForwards(h sshHost, opts launchOptions) []string {
+	var out []string
+	for _, s := range opts.explicitForwards() {
+		f, ok := parseForwardSpec(s)
+		if !ok {
+			out = append(out, s+" on "+h.Alias)
+			continue
+		}
+		bind := f.bind
+		if bind == "" || bind == "*" {
+			bind = "localhost"
+		}
+		out = append(out, fmt.Sprintf("%s:%d → %s on %s", strings.Trim(bind, "[]"), f.port, f.rest, h.Alias))
+	}
+	return out
+}
+
+func printForwards(w io.Writer, h sshHost, opts launchOptions) {
+	style := palette.style(statusOK).Bold(true)
+	for _, line := range describeForwards(h, opts) {
+		fmt.Fprintln(w, style.Render("forwarding "+line))
+	}
+}
+
+// runSSHForwarding runs ssh as a subprocess with ExitOnForwardFailure. When
+// ssh can't listen on a local port it's restarted on the next free one,
+// and the final mapping is printed before each attempt.
+func runSSHForwarding(h sshHost, opts *launchOptions) (*tailWriter, error) {
+	for _, note := range freeForwardPorts(opts) {
+		fmt.Fprintln(os.Stderr, note)
+	}
+	for attempt := 0; ; attempt++ {
+		printForwards(os.Stderr, h, *opts)
+		tail := &tailWriter{n: stderrTailLines}
+		err := runSSHSubprocessTee(sshArgs(h, *opts), tail)
+		if exitCode(err) != sshFailureCode || attempt == forwardRetries {
+			return tail, err
+		}
+		port, ok := failedForwardPort(tail.Lines())
+		if !ok {
+			return tail, err
+		}
+		to, ok := opts.moveForward(port)
+		if !ok {
+			return tail, err
+		}
+		fmt.Fprintf(os.Stderr, "local port %d is in use; retrying with %d\n", port, to)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseForwardSpec(t *testing.T) {
+	t.Parallel()
+
+	for spec, want := range map[string]forwardSpec{
+		"8080:localhost:80":             {port: 8080, rest: "localhost:80"},
+		"127.0.0.1:8080:db:5432":        {bind: "127.0.0.1", port: 8080, rest: "db:5432"},
+		"[::1]:8080:[2001:db8::5]:5432": {bind: "[::1]", port: 8080, rest: "[2001:db8::5]:5432"},
+		"*:2222:localhost:22":           {bind: "*", port: 2222, rest: "localhost:22"},
+		"9000:/run/docker.sock":         {port: 9000, rest: "/run/docker.sock"},
+	} {
+		got, ok := parseForwardSpec(spec)
+		if !ok || got != want || got.String() != spec {
+			t.Errorf("%s: %+v %v (%s)", spec, got, ok, got.String())
+		}
+	}
+	for _, bad := range []string{"/tmp/sock:/run/docker.sock", "8080", "99999:localhost:80", "[::1:8080:x:1"} {
+		if f, ok := parseForwardSpec(bad); ok {
+			t.Errorf("%s parsed as %+v", bad, f)
+		}
+	}
+}
+
+func TestFailedForwardPort(t *testing.T) {
+	t.Parallel()
+
+	for lines, want := range map[string]int{
+		"bind [127.0.0.1]:8080: Address already in use\nchannel_setup_fwd_listener_tcpip: cannot listen to port: 8080\nCould not request local forwarding.": 8080,
+		"bind [::1]:5432: Address already in use": 5432,
+		"Permission denied (publickey).":          0,
+	} {
+		port, ok := failedForwardPort(strings.Split(lines, "\n"))
+		if port != want || ok != (want != 0) {
+			t.Errorf("%q: %d %v", lines, port, ok)
+		}
+	}
+}
+
+func TestMoveForward(t *testing.T) {
+	orig := localPortFree
+	defer func() { localPortFree = orig }()
+	busy := map[int]bool{8080: true, 8081: true}
+	localPortFree = func(p int) bool { return !busy[p] }
+
+	opts := launchOptions{localForward: "8080:localhost:80", forwards: []string{"8082:localhost:9100", "5432:db:5432"}}
+	notes := freeForwardPorts(&opts)
+	if len(notes) != 1 || notes[0] != "local port 8080 is in use; using 8083" {
+		t.Fatalf("notes %v", notes)
+	}
+	if opts.localForward != "8083:localhost:80" || opts.forwards[0] != "8082:localhost:9100" {
+		t.Fatalf("opts %+v", opts)
+	}
+
+	busy[5432] = true
+	if to, ok := opts.moveForward(5432); !ok || to != 5433 || opts.forwards[1] != "5433:db:5432" {
+		t.Fatalf("moveForward: %d %v %+v", to, ok, opts)
+	}
+	if _, ok := opts.moveForward(9999); ok {
+		t.Fatal("moved a port no forward uses")
+	}
+	got := describeForwards(sshHost{Alias: "db1"}, opts)
+	if strings.Join(got, "; ") != "localhost:8083 → localhost:80 on db1; localhost:8082 → localhost:9100 on db1; localhost:5433 → db:5432 on db1" {
+		t.Fatalf("describeForwards %v", got)
+	}
+}
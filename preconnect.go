@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// connectPrompt interrupts a connection with a warning the user must confirm.
+// Enter connects anyway, Esc cancels, and each action runs a command (with the
+// TUI suspended) before the checks are re-run.
+type connectPrompt struct {
+	id      string // identifies the check so "connect anyway" skips only it
+	host    sshHost
+	message string
+	actions []promptAction
+	// pending is set while the check's lookup (a card listing, a network
+	// probe) runs in the background; its precheckMsg re-runs the checks.
+	pending tea.Cmd
+}
+
+type promptAction struct {
+	key   string
+	label string
+	cmd   func() *exec.Cmd
+}
+
+type promptActionMsg struct{ err error }
+
+// precheckMsg carries the result of a slow pre-connect lookup, kept in
+// model.prechecked under key until the connection goes ahead or is
+// cancelled.
+type precheckMsg struct {
+	key    string
+	result any
+}
+
+// precheck is a check's result for key, or, when it isn't known yet, a
+// prompt that runs lookup off the UI goroutine.
+func precheck(m model, key string, p *connectPrompt, lookup func() any) (any, *connectPrompt) {
+	if r, ok := m.prechecked[key]; ok {
+		return r, nil
+	}
+	p.pending = func() tea.Msg { return precheckMsg{key: key, result: lookup()} }
+	return nil, p
+}
+
+// preconnectChecks run in order when a host is picked; the first one that
+// returns a prompt stops the connection until it's answered.
+var preconnectChecks = []func(model, sshHost) *connectPrompt{
+	maintenanceCheck,
+	tagConfirmCheck,
+	networkCheck,
+	hostKeyCheck,
+	agentCheck,
+	pkcs11Check,
+	kerberosCheck,
+}
+
+// beginConnect runs the pre-connect checks for h and either shows a prompt
+// or marks h as chosen and quits the TUI.
+func (m model) beginConnect(h sshHost) (tea.Model, tea.Cmd) {
+	for _, check := range preconnectChecks {
+		p := check(m, h)
+		if p == nil || m.acknowledged[p.id] {
+			continue
+		}
+		p.host = h
+		m.prompt = p
+		return m, p.pending
+	}
+	m.prompt = nil
+	m.acknowledged = nil
+	m.prechecked = nil
+	m.chosen = true
+	m.selectedHost = h
+	return m, tea.Quit
+}
+
+func (m model) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.prompt
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q":
+		m.prompt = nil
+		m.acknowledged = nil
+		m.prechecked = nil
+		return m, nil
+	case "enter":
+		if m.acknowledged == nil {
+			m.acknowledged = map[string]bool{}
+		}
+		m.acknowledged[p.id] = true
+		return m.beginConnect(p.host)
+	}
+	for _, a := range p.actions {
+		if msg.String() == a.key {
+			return m, tea.ExecProcess(a.cmd(), func(err error) tea.Msg { return promptActionMsg{err: err} })
+		}
+	}
+	return m, nil
+}
+
+func (m model) renderPrompt(b *strings.Builder) {
+	p := m.prompt
+	if p.pending != nil {
+		fmt.Fprintln(b, m.styles.help.Render(p.message))
+		fmt.Fprintln(b, m.styles.help.Render("Enter connect without waiting • Esc cancel"))
+		return
+	}
+	fmt.Fprintln(b, m.styles.error.Render(p.message))
+	keys := make([]string, 0, len(p.actions)+2)
+	for _, a := range p.actions {
+		keys = append(keys, a.key+" "+a.label)
+	}
+	keys = append(keys, "Enter connect anyway", "Esc cancel")
+	fmt.Fprintln(b, m.styles.help.Render(strings.Join(keys, " • ")))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestKerberosPromptBeforeConnect(t *testing.T) {
+	orig := hasKerberosTicket
+	defer func() { hasKerberosTicket = orig }()
+	hasKerberosTicket = func() (bool, bool) { return false, true }
+
+	krb := sshHost{Alias: "krb", Options: map[string]string{"gssapiauthentication": "yes"}}
+	m := initialModel([]sshHost{krb}, "", "")
+
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if m.chosen || m.prompt == nil || m.prompt.id != "kerberos" {
+		t.Fatalf("expected kerberos prompt, got chosen=%v prompt=%#v", m.chosen, m.prompt)
+	}
+
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = next.(model)
+	if m.prompt != nil || m.chosen {
+		t.Fatalf("esc should cancel the connection")
+	}
+
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if !m.chosen || m.selectedHost.Alias != "krb" || cmd == nil {
+		t.Fatalf("connect anyway should choose the host")
+	}
+
+	hasKerberosTicket = func() (bool, bool) { return true, true }
+	if p := kerberosCheck(m, krb); p != nil {
+		t.Fatalf("valid ticket should not prompt")
+	}
+}
+
+// settle runs check, answering its background lookups in place, until it
+// gives its final answer.
+func settle(t *testing.T, m model, check func(model, sshHost) *connectPrompt, h sshHost) *connectPrompt {
+	t.Helper()
+	m.prechecked = map[string]any{}
+	for i := 0; i < 10; i++ {
+		p := check(m, h)
+		if p == nil || p.pending == nil {
+			return p
+		}
+		msg := p.pending().(precheckMsg)
+		m.prechecked[msg.key] = msg.result
+	}
+	t.Fatalf("check for %s never settled", h.Alias)
+	return nil
+}
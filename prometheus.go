@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// promTargetGroup is one entry of a Prometheus file_sd JSON file.
+type promTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// promTargetsResponse is the subset of /api/v1/targets we care about.
+type promTargetsResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ActiveTargets []struct {
+			Labels           map[string]string `json:"labels"`
+			DiscoveredLabels map[string]string `json:"discoveredLabels"`
+		} `json:"activeTargets"`
+	} `json:"data"`
+}
+
+// loadPrometheusHosts turns Prometheus scrape targets into hosts. src is either
+// a Prometheus server URL (the targets API is queried through proxy, like
+// other providers' APIs) or a file_sd JSON file.
+func loadPrometheusHosts(src, proxy string) ([]sshHost, error) {
+	var (
+		groups []promTargetGroup
+		err    error
+	)
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		groups, err = fetchPrometheusTargets(src, proxy)
+	} else {
+		groups, err = readPrometheusFileSD(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return promGroupsToHosts(groups, src), nil
+}
+
+func fetchPrometheusTargets(base, proxy string) ([]promTargetGroup, error) {
+	url := strings.TrimRight(base, "/")
+	if !strings.HasSuffix(url, "/api/v1/targets") {
+		url += "/api/v1/targets"
+	}
+	p, err := parseProviderProxy(proxy)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := p.transport()
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 5 * time.Second, Transport: transport}
+	resp, err := client.Get(url + "?state=active")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prometheus targets: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return nil, err
+	}
+	var tr promTargetsResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, fmt.Errorf("prometheus targets: %w", err)
+	}
+	if tr.Status != "" && tr.Status != "success" {
+		return nil, fmt.Errorf("prometheus targets: status %q", tr.Status)
+	}
+	groups := make([]promTargetGroup, 0, len(tr.Data.ActiveTargets))
+	for _, t := range tr.Data.ActiveTargets {
+		addr := t.DiscoveredLabels["__address__"]
+		if addr == "" {
+			addr = t.Labels["instance"]
+		}
+		if addr == "" {
+			continue
+		}
+		groups = append(groups, promTargetGroup{Targets: []string{addr}, Labels: t.Labels})
+	}
+	return groups, nil
+}
+
+func readPrometheusFileSD(path string) ([]promTargetGroup, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var groups []promTargetGroup
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return groups, nil
+}
+
+// promGroupsToHosts creates one host per distinct target hostname; the scrape
+// port is dropped since it's not the ssh port. Job labels become notes.
+func promGroupsToHosts(groups []promTargetGroup, src string) []sshHost {
+	var hosts []sshHost
+	seen := map[string]int{}
+	for _, g := range groups {
+		for _, target := range g.Targets {
+			name := promTargetHost(target)
+			if name == "" {
+				continue
+			}
+			note := ""
+			if job := g.Labels["job"]; job != "" {
+				note = "prometheus job: " + job
+			}
+			if i, ok := seen[name]; ok {
+				if note != "" && !containsString(hosts[i].Notes, note) {
+					hosts[i].Notes = append(hosts[i].Notes, note)
+				}
+				continue
+			}
+			h := sshHost{
+				Alias:      name,
+				Hostname:   name,
+				SourcePath: src,
+				Source:     "prometheus",
+			}
+			if note != "" {
+				h.Notes = []string{note}
+			}
+			h.IP = literalIP(name)
+			seen[name] = len(hosts)
+			hosts = append(hosts, h)
+		}
+	}
+	sort.SliceStable(hosts, func(i, j int) bool { return hosts[i].Alias < hosts[j].Alias })
+	return hosts
+}
+
+// promTargetHost strips scheme, path and port from a scrape target address.
+func promTargetHost(target string) string {
+	target = strings.TrimSpace(target)
+	if i := strings.Index(target, "://"); i >= 0 {
+		target = target[i+3:]
+	}
+	if i := strings.IndexByte(target, '/'); i >= 0 {
+		target = target[:i]
+	}
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		return host
+	}
+	return strings.Trim(target, "[]")
+}
+
+// mergeHosts appends extra hosts that aren't already listed. A host matching
+// an existing alias, hostname or IP is the same machine seen by another
+// source: it's folded into the existing row (config entries always win),
+// contributing its source name, notes, tags and any annotations the row
+// doesn't set, but never connection settings. Hostnames are resolved
+// first, so two names for one address are still one machine. A hostname or
+// IP match also needs the same ssh port (sameSSHPort), so containers behind
+// one address on different ports stay apart.
+func mergeHosts(hosts, extra []sshHost) []sshHost {
+	if len(extra) > 0 {
+		resolveHostIPs(hosts)
+		resolveHostIPs(extra)
+	}
+	index := map[string][]int{}
+	remember := func(h sshHost, i int) {
+		for _, key := range []string{h.Alias, h.Hostname, h.IP} {
+			if key != "" && !containsInt(index[key], i) {
+				index[key] = append(index[key], i)
+			}
+		}
+	}
+	for i, h := range hosts {
+		remember(h, i)
+	}
+	for _, h := range extra {
+		i, dup := -1, false
+	find:
+		for _, key := range []string{h.Alias, h.Hostname, h.IP} {
+			for _, j := range index[key] {
+				if key != "" && (hosts[j].Alias == h.Alias || sameSSHPort(hosts[j], h)) {
+					i, dup = j, true
+					break find
+				}
+			}
+		}
+		if !dup {
+			hosts = append(hosts, h)
+			remember(h, len(hosts)-1)
+			continue
+		}
+		hosts[i] = foldDuplicate(hosts[i], h)
+	}
+	return hosts
+}
+
+// sameSSHPort reports whether a and b can be the same sshd. A Prometheus
+// target has no ssh port (its scrape port was dropped), so it matches any.
+func sameSSHPort(a, b sshHost) bool {
+	port := func(h sshHost) string {
+		if h.Port == "" {
+			return "22"
+		}
+		return h.Port
+	}
+	return a.Source == "prometheus" || b.Source == "prometheus" || port(a) == port(b)
+}
+
+// foldDuplicate merges dup's descriptive fields into h.
+func foldDuplicate(h, dup sshHost) sshHost {
+	src := hostSource(dup)
+	if src != hostSource(h) && !containsString(h.AlsoSources, src) {
+		h.AlsoSources = append(append([]string(nil), h.AlsoSources...), src)
+	}
+	for _, note := range dup.Notes {
+		if !containsString(h.Notes, note) {
+			h.Notes = append(append([]string(nil), h.Notes...), note)
+		}
+	}
+	if len(dup.Annotations) > 0 {
+		ann := copyStringMap(h.Annotations)
+		if ann == nil {
+			ann = map[string]string{}
+		}
+		for k, v := range dup.Annotations {
+			if _, ok := ann[k]; !ok {
+				ann[k] = v
+			}
+		}
+		tags := h.tags()
+		for _, t := range dup.tags() {
+			if !h.hasTag(t) {
+				tags = append(tags, t)
+			}
+		}
+		if len(tags) > 0 {
+			ann["tags"] = strings.Join(tags, ",")
+		}
+		h.Annotations = ann
+	}
+	return h
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
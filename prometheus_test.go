@@ -0,0 +1,137 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPrometheusHosts_FileSD(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	sd := filepath.Join(dir, "targets.json")
+	content := `[
+  {"targets": ["10.0.0.7:9100", "db1.example.invalid:9100"], "labels": {"job": "node"}},
+  {"targets": ["10.0.0.7:9187"], "labels": {"job": "postgres"}},
+  {"targets": ["http://[::1]:8080/metrics"]}
+]`
+	if err := os.WriteFile(sd, []byte(content), 0o600); err != nil {
+		t.Fatalf("write file_sd: %v", err)
+	}
+
+	hosts, err := loadPrometheusHosts(sd, "")
+	if err != nil {
+		t.Fatalf("loadPrometheusHosts: %v", err)
+	}
+	if len(hosts) != 3 {
+		t.Fatalf("expected 3 hosts, got %#v", hosts)
+	}
+
+	byAlias := map[string]sshHost{}
+	for _, h := range hosts {
+		byAlias[h.Alias] = h
+	}
+	h, ok := byAlias["10.0.0.7"]
+	if !ok {
+		t.Fatalf("missing 10.0.0.7 in %#v", hosts)
+	}
+	if h.Source != "prometheus" || h.IP != "10.0.0.7" {
+		t.Fatalf("unexpected host %#v", h)
+	}
+	if len(h.Notes) != 2 {
+		t.Fatalf("expected job notes merged, got %#v", h.Notes)
+	}
+	if _, ok := byAlias["::1"]; !ok {
+		t.Fatalf("expected scheme/path/port stripped, got %#v", hosts)
+	}
+
+	merged := mergeHosts([]sshHost{{Alias: "db", Hostname: "db1.example.invalid"}}, hosts)
+	if len(merged) != 3 {
+		t.Fatalf("expected duplicate hostname to be dropped, got %#v", merged)
+	}
+	if db := merged[0]; db.Alias != "db" || len(db.AlsoSources) != 1 || db.AlsoSources[0] != "prometheus" || len(db.Notes) != 1 {
+		t.Fatalf("expected the prometheus entry folded into db, got %#v", db)
+	}
+}
+
+func TestMergeHosts_FoldsDuplicatesByResolvedIP(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(dnsAnswer(query, [4]byte{10, 1, 2, 3}))
+	}))
+	defer srv.Close()
+	orig := ipResolver
+	defer func() { ipResolver = orig }()
+	ipResolver = dohResolver(srv.URL, srv.Client())
+
+	// loaders leave the IP of a name empty; merging must still see that
+	// both rows are 10.1.2.3
+	hosts := []sshHost{{Alias: "web1", Hostname: "web1.corp.example", Source: "config"}}
+	extra := []sshHost{{Alias: "10.1.2.3", Hostname: "10.1.2.3", IP: "10.1.2.3", Source: "prometheus"}}
+	merged := mergeHosts(hosts, extra)
+	if len(merged) != 1 || merged[0].IP != "10.1.2.3" || len(merged[0].AlsoSources) != 1 {
+		t.Fatalf("expected the target folded into web1, got %#v", merged)
+	}
+}
+
+func TestMergeHosts_FoldsDuplicatesByIP(t *testing.T) {
+	t.Parallel()
+
+	hosts := []sshHost{{Alias: "web1", Hostname: "web1.corp", IP: "10.0.0.1", User: "deploy", Source: "config",
+		Annotations: map[string]string{"tags": "prod"}}}
+	extra := []sshHost{
+		{Alias: "ip-10-0-0-1", Hostname: "ip-10-0-0-1.ec2.internal", IP: "10.0.0.1", User: "ec2-user", Source: "aws",
+			Notes: []string{"t3.large"}, Annotations: map[string]string{"tags": "web", "aws-region": "eu-west-1"}},
+		{Alias: "web2", Hostname: "10.0.0.2", IP: "10.0.0.2", Source: "aws"},
+	}
+	orig := hosts[0].Annotations
+	merged := mergeHosts(hosts, extra)
+	if len(merged) != 2 {
+		t.Fatalf("expected the duplicate folded, got %#v", merged)
+	}
+	h := merged[0]
+	if h.User != "deploy" || h.Source != "config" {
+		t.Fatalf("connection settings must come from the config entry: %#v", h)
+	}
+	if len(h.AlsoSources) != 1 || h.AlsoSources[0] != "aws" || !h.hasTag("web") || !h.hasTag("prod") ||
+		h.Annotations["aws-region"] != "eu-west-1" || len(h.Notes) != 1 {
+		t.Fatalf("unexpected merged host %#v", h)
+	}
+	if orig["aws-region"] != "" {
+		t.Fatalf("merging must not mutate the input's annotation map")
+	}
+}
+
+func TestMergeHosts_KeepsHostsOnOtherPorts(t *testing.T) {
+	t.Parallel()
+
+	hosts := []sshHost{
+		{Alias: "local", Hostname: "127.0.0.1", IP: "127.0.0.1", Source: "config"},
+		{Alias: "c1", Hostname: "127.0.0.1", IP: "127.0.0.1", Port: "2201", Source: "config"},
+	}
+	extra := []sshHost{
+		{Alias: "c2", Hostname: "127.0.0.1", IP: "127.0.0.1", Port: "2202", Source: "inventory"},
+		{Alias: "c1-again", Hostname: "127.0.0.1", IP: "127.0.0.1", Port: "2201", Source: "inventory"},
+		{Alias: "ssh", Hostname: "127.0.0.1", IP: "127.0.0.1", Port: "22", Source: "inventory"},
+		{Alias: "127.0.0.1", Hostname: "127.0.0.1", IP: "127.0.0.1", Source: "prometheus"},
+	}
+	merged := mergeHosts(hosts, extra)
+	var aliases []string
+	for _, h := range merged {
+		aliases = append(aliases, h.Alias)
+	}
+	if len(merged) != 3 || aliases[2] != "c2" {
+		t.Fatalf("expected only c2 kept apart, got %v", aliases)
+	}
+	if got := merged[0].AlsoSources; len(got) != 2 || got[0] != "inventory" || got[1] != "prometheus" {
+		t.Fatalf("port 22 and the prometheus target belong to local, got %v", got)
+	}
+	if got := merged[1].AlsoSources; len(got) != 1 {
+		t.Fatalf("the second :2201 entry belongs to c1, got %v", got)
+	}
+}
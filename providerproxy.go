@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tunnelStartTimeout bounds how long a provider waits for its ssh -D tunnel
+// to start listening.
+const tunnelStartTimeout = 15 * time.Second
+
+// providerProxy is how a provider reaches its API: directly, through a
+// SOCKS or HTTP proxy, or through a SOCKS tunnel sshpick opens with ssh -D
+// to a bastion, for cloud APIs only reachable from inside the network.
+type providerProxy struct {
+	url    *url.URL // socks5://, socks5h://, http:// or https://
+	tunnel string   // host alias to open ssh -D through
+}
+
+// parseProviderProxy reads a "proxy" setting: a proxy URL, ssh:<alias> for
+// a tunnel, or "" / "direct" to connect directly.
+func parseProviderProxy(spec string) (providerProxy, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "direct" {
+		return providerProxy{}, nil
+	}
+	if alias, ok := strings.CutPrefix(spec, "ssh:"); ok {
+		alias = strings.TrimPrefix(alias, "//")
+		if alias == "" || strings.ContainsAny(alias, " \t") {
+			return providerProxy{}, fmt.Errorf("proxy %q: want ssh:<host alias>", spec)
+		}
+		return providerProxy{tunnel: alias}, nil
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return providerProxy{}, fmt.Errorf("proxy %q: %w", spec, err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http", "https":
+	default:
+		return providerProxy{}, fmt.Errorf("proxy %q: want socks5://, http://, https:// or ssh:<alias>", spec)
+	}
+	if u.Host == "" {
+		return providerProxy{}, fmt.Errorf("proxy %q: no host", spec)
+	}
+	return providerProxy{url: u}, nil
+}
+
+// resolve is the proxy URL to use, opening the tunnel if p needs one; nil
+// means connect directly.
+func (p providerProxy) resolve() (*url.URL, error) {
+	if p.tunnel != "" {
+		return openTunnel(p.tunnel)
+	}
+	return p.url, nil
+}
+
+// transport is the HTTP transport for p; nil is http.DefaultTransport,
+// which still honors $HTTPS_PROXY.
+func (p providerProxy) transport() (http.RoundTripper, error) {
+	u, err := p.resolve()
+	if err != nil || u == nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyURL(u)
+	return t, nil
+}
+
+// env is the environment a command provider runs with so the CLIs it calls
+// (curl, cloud SDKs) go through p.
+func (p providerProxy) env() ([]string, error) {
+	u, err := p.resolve()
+	if err != nil || u == nil {
+		return nil, err
+	}
+	var env []string
+	for _, name := range []string{"ALL_PROXY", "HTTPS_PROXY", "HTTP_PROXY"} {
+		env = append(env, name+"="+u.String(), strings.ToLower(name)+"="+u.String())
+	}
+	return env, nil
+}
+
+// sshTunnel is a running ssh -D to a bastion.
+type sshTunnel struct {
+	cmd *exec.Cmd
+	url *url.URL
+}
+
+// tunnels are the provider tunnels open right now, by alias, shared by
+// every provider going through the same bastion.
+var tunnels = struct {
+	sync.Mutex
+	open map[string]*sshTunnel
+}{open: map[string]*sshTunnel{}}
+
+// startTunnel opens a SOCKS tunnel through alias. A variable so tests don't
+// need a bastion.
+var startTunnel = func(alias string) (*sshTunnel, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// BatchMode: the tunnel may start inside the daemon or before the
+	// picker draws, where nobody can answer a prompt.
+	args := append([]string{"-N", "-D", addr, "-o", "BatchMode=yes", "-o", "ExitOnForwardFailure=yes", "-o", "ConnectTimeout=10"}, hostKeyArgs(false)...)
+	args = append(args, configFileArgs()...)
+	cmd := exec.Command("ssh", append(args, alias)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	deadline := time.Now().Add(tunnelStartTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-exited:
+			if msg := lastLine(stderr.String()); msg != "" {
+				err = fmt.Errorf("%v: %s", err, msg)
+			}
+			return nil, fmt.Errorf("tunnel through %s: %w", alias, err)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+			conn.Close()
+			return &sshTunnel{cmd: cmd, url: &url.URL{Scheme: "socks5h", Host: addr}}, nil
+		}
+	}
+	cmd.Process.Kill()
+	return nil, fmt.Errorf("tunnel through %s: not listening after %s", alias, tunnelStartTimeout)
+}
+
+// openTunnel returns the SOCKS URL of the tunnel through alias, starting it
+// on first use.
+func openTunnel(alias string) (*url.URL, error) {
+	tunnels.Lock()
+	defer tunnels.Unlock()
+	if t := tunnels.open[alias]; t != nil {
+		return t.url, nil
+	}
+	t, err := startTunnel(alias)
+	if err != nil {
+		return nil, err
+	}
+	tunnels.open[alias] = t
+	return t.url, nil
+}
+
+// closeTunnels stops every provider tunnel. Called once the providers are
+// loaded: sshpick execs ssh afterwards, and a tunnel left running would
+// outlive it.
+func closeTunnels() {
+	tunnels.Lock()
+	defer tunnels.Unlock()
+	for alias, t := range tunnels.open {
+		if t.cmd != nil && t.cmd.Process != nil {
+			t.cmd.Process.Kill()
+		}
+		delete(tunnels.open, alias)
+	}
+}
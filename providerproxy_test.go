@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseProviderProxy(t *testing.T) {
+	t.Parallel()
+	for spec, want := range map[string]providerProxy{
+		"":                        {},
+		"direct":                  {},
+		"ssh:bastion":             {tunnel: "bastion"},
+		"ssh://bastion":           {tunnel: "bastion"},
+		"socks5://127.0.0.1:1080": {url: &url.URL{Scheme: "socks5", Host: "127.0.0.1:1080"}},
+	} {
+		got, err := parseProviderProxy(spec)
+		if err != nil {
+			t.Errorf("%q: %v", spec, err)
+			continue
+		}
+		if got.tunnel != want.tunnel || fmt.Sprint(got.url) != fmt.Sprint(want.url) {
+			t.Errorf("%q: %+v, want %+v", spec, got, want)
+		}
+	}
+	for _, spec := range []string{"ssh:", "ftp://proxy:21", "socks5://", "proxy:3128"} {
+		if _, err := parseProviderProxy(spec); err == nil {
+			t.Errorf("%q: no error", spec)
+		}
+	}
+	if _, err := (appConfig{ProviderProxy: "gopher://x"}).hostProviders(); err != nil {
+		t.Errorf("unused provider_proxy rejected: %v", err)
+	}
+	if _, err := (appConfig{ProviderProxy: "gopher://x", Inventories: []inventoryConfig{{URL: "https://inv"}}}).hostProviders(); err == nil {
+		t.Error("bad provider_proxy accepted for an inventory")
+	}
+}
+
+func TestInventoryThroughProxy(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// an HTTP proxy sees the absolute URL of the origin
+		requested = r.URL.String()
+		fmt.Fprint(w, `{"hosts":[{"alias":"vm1","hostname":"10.1.0.4"}]}`)
+	}))
+	defer proxy.Close()
+
+	ic := inventoryConfig{Name: "cloud", URL: "http://inventory.internal/hosts.json"}
+	providers, err := appConfig{ProviderProxy: proxy.URL, Inventories: []inventoryConfig{ic}}.hostProviders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, _, err := providers[0].hosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requested != ic.URL || len(hosts) != 1 || hosts[0].Alias != "vm1" {
+		t.Fatalf("requested %q, hosts %+v", requested, hosts)
+	}
+}
+
+func TestCommandProviderTunnel(t *testing.T) {
+	started := 0
+	orig := startTunnel
+	defer func() { startTunnel = orig; closeTunnels() }()
+	startTunnel = func(alias string) (*sshTunnel, error) {
+		started++
+		return &sshTunnel{url: &url.URL{Scheme: "socks5h", Host: "127.0.0.1:4242"}}, nil
+	}
+
+	pc := providerConfig{Type: "command", Command: `printf '{"hosts":[{"alias":"%s"}]}' "$HTTPS_PROXY"`}
+	providers, err := appConfig{ProviderProxy: "ssh:bastion", Providers: []providerConfig{pc, pc}}.hostProviders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range providers {
+		hosts, _, err := p.hosts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hosts) != 1 || hosts[0].Alias != "socks5h://127.0.0.1:4242" {
+			t.Fatalf("hosts %+v", hosts)
+		}
+	}
+	if started != 1 {
+		t.Fatalf("tunnel started %d times, want once per bastion", started)
+	}
+	closeTunnels()
+	if len(tunnels.open) != 0 {
+		t.Fatal("tunnel left open")
+	}
+
+	if _, err := (providerConfig{Type: "known_hosts", Proxy: "ssh:bastion"}).provider(); err == nil || !strings.Contains(err.Error(), "proxy") {
+		t.Fatalf("known_hosts with a proxy: %v", err)
+	}
+}
+
+func TestPrometheusThroughProxy(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		fmt.Fprint(w, `{"status":"success","data":{"activeTargets":[{"labels":{"job":"node"},"discoveredLabels":{"__address__":"10.1.0.7:9100"}}]}}`)
+	}))
+	defer proxy.Close()
+
+	hosts, err := loadPrometheusHosts("http://prometheus.internal:9090", proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requested != "http://prometheus.internal:9090/api/v1/targets?state=active" || len(hosts) != 1 || hosts[0].Alias != "10.1.0.7" {
+		t.Fatalf("requested %q, hosts %+v", requested, hosts)
+	}
+	if _, err := loadPrometheusHosts("http://prometheus.internal:9090", "ftp://nope"); err == nil {
+		t.Fatal("a bad provider_proxy should be an error")
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// hostProvider is a source of hosts besides the ssh config. Its hosts are
+// merged into the list after the config's, so a machine the config already
+// has becomes a badge on that row rather than a second one.
+type hostProvider interface {
+	sourceName() string
+	// hosts returns the source's hosts. A warning means they may be stale
+	// or incomplete but are still usable.
+	hosts() (hosts []sshHost, warning string, err error)
+}
+
+// providerCommandTimeout bounds a command provider; the list waits for it.
+const providerCommandTimeout = 10 * time.Second
+
+// providerConfig is one entry of the "providers" settings list, e.g.
+//
+//	{"type": "known_hosts"}
+//	{"type": "command", "name": "tailscale", "command": "tailscale-hosts.sh"}
+//	{"type": "ansible", "inventory": "~/infra/hosts.ini"}
+//
+// A command runs with sh -c and prints the inventory format (see
+// inventoryFile) on stdout.
+type providerConfig struct {
+	Type      string `json:"type"`                // "known_hosts", "command" or "ansible"
+	Name      string `json:"name,omitempty"`      // source name shown in the UI, default the type
+	Command   string `json:"command,omitempty"`   // for "command"
+	Inventory string `json:"inventory,omitempty"` // for "ansible": ansible-inventory -i
+	Proxy     string `json:"proxy,omitempty"`     // for "command": exported as ALL_PROXY/HTTPS_PROXY/HTTP_PROXY
+}
+
+func (pc providerConfig) provider() (hostProvider, error) {
+	name := pc.Name
+	if name == "" {
+		name = pc.Type
+	}
+	switch pc.Type {
+	case "known_hosts":
+		if pc.Proxy != "" {
+			return nil, fmt.Errorf("provider %s: known_hosts is local, proxy doesn't apply", name)
+		}
+		return knownHostsProvider{name: name}, nil
+	case "command":
+		if strings.TrimSpace(pc.Command) == "" {
+			return nil, fmt.Errorf("provider %s: command is empty", name)
+		}
+		proxy, err := parseProviderProxy(pc.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", name, err)
+		}
+		return commandProvider{name: name, command: pc.Command, proxy: proxy}, nil
+	case "ansible":
+		if pc.Proxy != "" {
+			return nil, fmt.Errorf("provider %s: proxy doesn't apply to ansible", name)
+		}
+		return ansibleProvider{name: name, inventory: pc.Inventory}, nil
+	}
+	return nil, fmt.Errorf("provider type %q: want known_hosts, command or ansible", pc.Type)
+}
+
+// hostProviders are the configured sources in order: HTTP inventories,
+// then the "providers" list. Sources without a proxy of their own use
+// provider_proxy.
+func (c appConfig) hostProviders() ([]hostProvider, error) {
+	var out []hostProvider
+	for _, ic := range c.Inventories {
+		if ic.Proxy == "" {
+			ic.Proxy = c.ProviderProxy
+		}
+		if _, err := parseProviderProxy(ic.Proxy); err != nil {
+			return nil, fmt.Errorf("inventory %s: %w", ic.sourceName(), err)
+		}
+		out = append(out, ic)
+	}
+	for _, pc := range c.Providers {
+		if pc.Proxy == "" && pc.Type == "command" {
+			pc.Proxy = c.ProviderProxy
+		}
+		p, err := pc.provider()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func (ic inventoryConfig) hosts() ([]sshHost, string, error) {
+	return loadInventoryHosts(ic)
+}
+
+// knownHostsProvider lists the machines in the user's known_hosts files:
+// everywhere ssh has connected to, whether the config names it or not.
+type knownHostsProvider struct {
+	name string
+}
+
+func (p knownHostsProvider) sourceName() string { return p.name }
+
+func (p knownHostsProvider) hosts() ([]sshHost, string, error) {
+	var entries []knownHostEntry
+	for _, path := range userKnownHostsFiles(sshHost{}) {
+		es, err := loadKnownHosts(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// known_hosts2 is rarely there, and a fresh ~/.ssh has neither
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", p.name, err)
+		}
+		entries = append(entries, es...)
+	}
+	return knownHostsToHosts(entries, p.name), "", nil
+}
+
+// knownHostsToHosts makes a host of each known_hosts line: the first name
+// is the alias and hostname, an IP among the names fills the IP column.
+// Hashed, wildcard and negated names say nothing usable and are skipped,
+// as are CA and revoked lines. Lines for the same name (one per key type)
+// become one host.
+func knownHostsToHosts(entries []knownHostEntry, source string) []sshHost {
+	var hosts []sshHost
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if e.Marker != "" {
+			continue
+		}
+		var h sshHost
+		for _, p := range e.Hosts {
+			if strings.HasPrefix(p, "|") || strings.HasPrefix(p, "!") || strings.ContainsAny(p, "*?") {
+				continue
+			}
+			name, port := knownHostName(p), ""
+			if _, pt, err := net.SplitHostPort(p); err == nil && strings.HasPrefix(p, "[") {
+				port = pt
+			}
+			if h.Alias == "" {
+				h = sshHost{Alias: name, Hostname: name, Port: port, SourcePath: e.Path, SourceLine: e.Line, Source: source}
+			}
+			if h.IP == "" {
+				h.IP = literalIP(name)
+			}
+		}
+		key := h.Alias + ":" + h.Port
+		if h.Alias == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if h.Port != "" {
+			h.Alias = h.Alias + ":" + h.Port
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// commandProvider runs a user command that prints hosts in the inventory
+// format, e.g. a wrapper around `tailscale status --json` or
+// `ansible-inventory --list`.
+type commandProvider struct {
+	name    string
+	command string
+	proxy   providerProxy
+}
+
+func (p commandProvider) sourceName() string { return p.name }
+
+func (p commandProvider) hosts() ([]sshHost, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), providerCommandTimeout)
+	defer cancel()
+	env, err := p.proxy.env()
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", p.name, err)
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", p.command)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, "", fmt.Errorf("%s: %w", p.name, err)
+	}
+	inv, err := parseInventory(out)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: command output: %w", p.name, err)
+	}
+	return inventoryToHosts(inv, p.name, p.command), "", nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKnownHostsProvider(t *testing.T) {
+	t.Parallel()
+	entries := parseKnownHostLines(`db1.example.com,10.0.0.5 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAAA
+db1.example.com,10.0.0.5 ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAA
+[git.example.com]:2222 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBBB
+|1|c2FsdA==|aGFzaA== ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAICCC
+@cert-authority *.example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDDD
+`)
+	hosts := knownHostsToHosts(entries, "known_hosts")
+	if len(hosts) != 2 {
+		t.Fatalf("hosts %+v", hosts)
+	}
+	if h := hosts[0]; h.Hostname != "db1.example.com" || h.IP != "10.0.0.5" || h.Source != "known_hosts" {
+		t.Fatalf("db1: %+v", h)
+	}
+	if h := hosts[1]; h.Alias != "git.example.com:2222" || h.Hostname != "git.example.com" || h.Port != "2222" {
+		t.Fatalf("git: %+v", h)
+	}
+
+	// the config's db1 absorbs the known_hosts row as a source badge
+	merged := mergeHosts([]sshHost{{Alias: "db1", Hostname: "db1.example.com", Source: "config"}}, hosts)
+	if len(merged) != 2 || strings.Join(merged[0].AlsoSources, ",") != "known_hosts" {
+		t.Fatalf("merged %+v", merged)
+	}
+}
+
+func TestKnownHostsProviderReadsFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SSHPICK_SSH_DIR", dir)
+	line := "web1.example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAAA\n"
+	if err := os.WriteFile(filepath.Join(dir, "known_hosts"), []byte(line), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// no known_hosts2, as on most machines
+	hosts, _, err := knownHostsProvider{name: "known_hosts"}.hosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].Hostname != "web1.example.com" {
+		t.Fatalf("hosts %+v", hosts)
+	}
+}
+
+func TestCommandProvider(t *testing.T) {
+	t.Parallel()
+	p, err := providerConfig{Type: "command", Name: "tailscale", Command: `printf '{"hosts":[{"alias":"pi","hostname":"100.64.0.7","tags":["home"]}]}'`}.provider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, _, err := p.hosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].Source != "tailscale" || hosts[0].IP != "100.64.0.7" || !hosts[0].hasTag("home") {
+		t.Fatalf("hosts %+v", hosts)
+	}
+
+	failing, _ := providerConfig{Type: "command", Command: "echo nope >&2; exit 3"}.provider()
+	if _, _, err := failing.hosts(); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Fatalf("error %v", err)
+	}
+	for _, bad := range []providerConfig{{Type: "command"}, {Type: "consul"}} {
+		if _, err := bad.provider(); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// proxyPresets are ProxyCommand templates for common access brokers; a
+// provider's proxy_command may name one of these instead of spelling it out.
+var proxyPresets = map[string]string{
+	"ssm":         "aws ssm start-session --target {{.Hostname}} --document-name AWS-StartSSHSession --parameters portNumber=%p{{with attr \"aws-region\"}} --region {{.}}{{end}}",
+	"iap":         "gcloud compute start-iap-tunnel {{.Alias}} %p --listen-on-stdin{{with attr \"zone\"}} --zone={{.}}{{end}}{{with attr \"project\"}} --project={{.}}{{end}}",
+	"cloudflared": "cloudflared access ssh --hostname %h",
+}
+
+// proxyTemplateHost is what a ProxyCommand template sees of a host.
+type proxyTemplateHost struct {
+	Alias, Hostname, User, Port string
+}
+
+// proxyValue quotes an inventory-supplied value for a ProxyCommand: ssh runs
+// it through sh -c after expanding its own % tokens, so the value is
+// shell-quoted and its % doubled. Empty stays empty, so {{with}} still works.
+func proxyValue(v string) string {
+	if v == "" {
+		return ""
+	}
+	return strings.ReplaceAll(shellJoin([]string{v}), "%", "%%")
+}
+
+// expandProxyCommand renders a ProxyCommand template (or preset name) for h.
+// Templates see the host's fields (.Alias, .Hostname, .User, .Port) and its
+// annotations through attr, each quoted with proxyValue; ssh's own %h/%p
+// tokens pass through untouched.
+func expandProxyCommand(tmpl string, h sshHost) (string, error) {
+	if preset, ok := proxyPresets[strings.ToLower(tmpl)]; ok {
+		tmpl = preset
+	}
+	t, err := template.New("proxy_command").Option("missingkey=zero").Funcs(template.FuncMap{
+		"attr": func(key string) string { return proxyValue(h.Annotations[key]) },
+	}).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("proxy_command: %w", err)
+	}
+	view := proxyTemplateHost{Alias: proxyValue(h.Alias), Hostname: proxyValue(h.Hostname), User: proxyValue(h.User), Port: proxyValue(h.Port)}
+	var b strings.Builder
+	if err := t.Execute(&b, view); err != nil {
+		return "", fmt.Errorf("proxy_command for %s: %w", h.Alias, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// applyProxyTemplate sets the ProxyCommand of every host a provider emitted.
+func applyProxyTemplate(hosts []sshHost, tmpl string) error {
+	for i := range hosts {
+		cmd, err := expandProxyCommand(tmpl, hosts[i])
+		if err != nil {
+			return err
+		}
+		opts := copyStringMap(hosts[i].Options)
+		if opts == nil {
+			opts = map[string]string{}
+		}
+		opts["proxycommand"] = cmd
+		hosts[i].Options = opts
+	}
+	return nil
+}
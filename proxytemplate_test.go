@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandProxyCommand(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Alias: "web1", Hostname: "i-0abc", Annotations: map[string]string{"aws-region": "eu-west-1"}}
+	tests := []struct {
+		tmpl, want string
+	}{
+		{"ssm", "aws ssm start-session --target i-0abc --document-name AWS-StartSSHSession --parameters portNumber=%p --region eu-west-1"},
+		{"iap", "gcloud compute start-iap-tunnel web1 %p --listen-on-stdin"},
+		{"cloudflared", "cloudflared access ssh --hostname %h"},
+		{"nc -x proxy:1080 {{.Hostname}} %p", "nc -x proxy:1080 i-0abc %p"},
+	}
+	for _, tt := range tests {
+		got, err := expandProxyCommand(tt.tmpl, h)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.tmpl, err)
+		}
+		if got != tt.want {
+			t.Fatalf("%s: got %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+	if _, err := expandProxyCommand("{{.Nope", h); err == nil {
+		t.Fatalf("expected a parse error")
+	}
+}
+
+func TestExpandProxyCommandQuotesValues(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Alias: "a b", Hostname: "x;rm -rf ~", Annotations: map[string]string{"aws-region": "$(id)", "zone": "it's%h"}}
+	tests := []struct {
+		tmpl, want string
+	}{
+		{"ssm", "aws ssm start-session --target 'x;rm -rf ~' --document-name AWS-StartSSHSession --parameters portNumber=%p --region '$(id)'"},
+		{"iap", "gcloud compute start-iap-tunnel 'a b' %p --listen-on-stdin --zone='it'\\''s%%h'"},
+		{"nc {{.Hostname}} %p", "nc 'x;rm -rf ~' %p"},
+	}
+	for _, tt := range tests {
+		got, err := expandProxyCommand(tt.tmpl, h)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.tmpl, err)
+		}
+		if got != tt.want {
+			t.Fatalf("%s: got %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+	if got, _ := expandProxyCommand("nc {{.Hostname}} %p", sshHost{Hostname: "web%p.example"}); got != "nc web%%p.example %p" {
+		t.Fatalf("%% not escaped: %q", got)
+	}
+}
+
+func TestInventoryProxyTemplate(t *testing.T) {
+	t.Parallel()
+
+	inv := inventoryFile{Hosts: []inventoryHost{{Alias: "db1", Hostname: "i-0db", ProxyJump: "bastion", Attrs: map[string]string{"Zone": "b"}}}}
+	hosts := inventoryToHosts(inv, "aws", "https://inv.example")
+	if err := applyProxyTemplate(hosts, "ssm"); err != nil {
+		t.Fatal(err)
+	}
+	if hosts[0].Annotations["zone"] != "b" {
+		t.Fatalf("attrs should become annotations: %#v", hosts[0].Annotations)
+	}
+	args := strings.Join(hostArgs(hosts[0]), " ")
+	if !strings.Contains(args, "-o ProxyCommand=aws ssm start-session --target i-0db") || strings.Contains(args, "-J") {
+		t.Fatalf("hostArgs = %q", args)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A filter query selects hosts by field instead of by text, e.g.
+//
+//	tag:prod user:ubuntu port:!22 latency<50ms
+//
+// Terms are ANDed. field:value matches case-insensitively, with * as a
+// wildcard; field:!value and -field:value negate. port and latency also
+// compare with <, <=, > and >=. Any other ssh_config keyword matches its
+// directive values as a regex, the way a "keyword:regex" filter does. The
+// query fields win over keywords of the same name: "user:ubu*" and
+// "port:22" are globs over the host's User and Port, not directive regexes.
+type hostQuery []queryTerm
+
+type queryTerm struct {
+	field   string
+	op      string // ":", "<", "<=", ">", ">="
+	value   string
+	negate  bool
+	num     float64        // the value of a comparison, in ms for latency
+	re      *regexp.Regexp // the value as a pattern
+	keyword bool           // field is an ssh_config keyword, matched by directive
+}
+
+// queryFields are the fields a query can name besides ssh_config keywords.
+var queryFields = map[string]bool{
+	"alias": true, "host": true, "ip": true, "user": true, "port": true, "tag": true,
+	"source": true, "jump": true, "note": true, "latency": true, "status": true,
+}
+
+var queryTermRE = regexp.MustCompile(`^(-?)([A-Za-z]+)(<=|>=|<|>|:)(.*)$`)
+
+// scopedQuery is parseQuery under the filter scope. Queries name their
+// fields, so they only apply with the "all fields" scope; a narrower scope
+// keeps the filter to its own fields.
+func scopedQuery(s string, scope filterScope) (q hostQuery, ok bool, err error) {
+	if scope != scopeAll {
+		return nil, false, nil
+	}
+	return parseQuery(s)
+}
+
+// parseQuery reads s as a query. ok is false when s isn't one, meaning it is
+// a fuzzy or regex filter, which includes a lone "keyword:regex" directive
+// filter; err is set when s is a query with a bad term.
+func parseQuery(s string) (q hostQuery, ok bool, err error) {
+	fields := strings.Fields(s)
+	named := false
+	for _, f := range fields {
+		m := queryTermRE.FindStringSubmatch(f)
+		if m == nil {
+			return nil, false, nil
+		}
+		t := queryTerm{field: strings.ToLower(m[2]), op: m[3], value: m[4], negate: m[1] == "-"}
+		_, keyword := knownKeywords[t.field]
+		switch {
+		case queryFields[t.field]:
+			named = true
+		case keyword && t.op == ":" && !t.negate:
+		default:
+			return nil, false, nil
+		}
+		if t.op == ":" && strings.HasPrefix(t.value, "!") {
+			t.negate, t.value = !t.negate, t.value[1:]
+		}
+		q = append(q, t)
+	}
+	if !named {
+		return nil, false, nil
+	}
+	for i := range q {
+		if err := q[i].compile(); err != nil {
+			return nil, true, err
+		}
+	}
+	return q, true, nil
+}
+
+func (t *queryTerm) compile() error {
+	if queryFields[t.field] {
+		if t.op == ":" {
+			if t.field == "latency" {
+				return fmt.Errorf("latency:%s: compare it, e.g. latency<50ms", t.value)
+			}
+			// a glob: * is the only special character
+			t.re = regexp.MustCompile("(?i)^" + strings.ReplaceAll(regexp.QuoteMeta(t.value), `\*`, ".*") + "$")
+			return nil
+		}
+		if t.negate {
+			return fmt.Errorf("-%s%s%s: flip the comparison instead", t.field, t.op, t.value)
+		}
+		switch t.field {
+		case "port":
+			n, err := strconv.Atoi(t.value)
+			if err != nil {
+				return fmt.Errorf("port%s%s: not a number", t.op, t.value)
+			}
+			t.num = float64(n)
+		case "latency":
+			v := t.value
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				v += "ms"
+			}
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("latency%s%s: want a duration like 50ms", t.op, t.value)
+			}
+			t.num = float64(d) / float64(time.Millisecond)
+		default:
+			return fmt.Errorf("%s%s%s: only port and latency compare", t.field, t.op, t.value)
+		}
+		return nil
+	}
+	re, err := regexp.Compile(t.value)
+	if err != nil {
+		return fmt.Errorf("%s:%s: %w", t.field, t.value, err)
+	}
+	t.re, t.keyword = re, true
+	return nil
+}
+
+// hostLatency is the latest latency measured to h: a reachability probe,
+// else the b key's measurement.
+func (m model) hostLatency(h sshHost) (time.Duration, bool) {
+	if r, ok := m.reach[h.Alias]; ok {
+		return r.Latency, r.Err == nil
+	}
+	for _, r := range m.latencyResults {
+		if r.Host.Alias == h.Alias {
+			return r.Latency, r.Err == nil
+		}
+	}
+	return 0, false
+}
+
+// hostStatus is "up", "down" or "unknown" from the checks run so far.
+func (m model) hostStatus(h sshHost) string {
+	if r, ok := m.reach[h.Alias]; ok {
+		if r.Err != nil {
+			return "down"
+		}
+		return "up"
+	}
+	for _, r := range m.latencyResults {
+		if r.Host.Alias == h.Alias {
+			if r.Err != nil {
+				return "down"
+			}
+			return "up"
+		}
+	}
+	return "unknown"
+}
+
+// values are h's values for a query field.
+func (m model) queryValues(h sshHost, field string) []string {
+	switch field {
+	case "alias":
+		return []string{h.Alias}
+	case "host":
+		return []string{h.Hostname}
+	case "ip":
+		return []string{h.IP}
+	case "user":
+		return []string{h.User}
+	case "port":
+		if h.Port == "" {
+			return []string{"22"}
+		}
+		return []string{h.Port}
+	case "tag":
+		return h.tags()
+	case "source":
+		return append([]string{hostSource(h)}, h.AlsoSources...)
+	case "jump":
+		return h.JumpChain
+	case "note":
+		return h.Notes
+	case "status":
+		return []string{m.hostStatus(h)}
+	}
+	return nil
+}
+
+func (m model) matchTerm(h sshHost, t queryTerm) bool {
+	if t.keyword {
+		for _, v := range h.directiveValues(t.field) {
+			if t.re.MatchString(v) {
+				return true
+			}
+		}
+		return false
+	}
+	if t.op == ":" {
+		for _, v := range m.queryValues(h, t.field) {
+			if t.re.MatchString(v) {
+				return true
+			}
+		}
+		return false
+	}
+	var n float64
+	switch t.field {
+	case "port":
+		p, err := strconv.Atoi(m.queryValues(h, "port")[0])
+		if err != nil {
+			return false
+		}
+		n = float64(p)
+	case "latency":
+		d, ok := m.hostLatency(h)
+		if !ok {
+			// unmeasured or unreachable: neither fast nor slow
+			return false
+		}
+		n = float64(d) / float64(time.Millisecond)
+	}
+	switch t.op {
+	case "<":
+		return n < t.num
+	case "<=":
+		return n <= t.num
+	case ">":
+		return n > t.num
+	}
+	return n >= t.num
+}
+
+// matches reports whether h satisfies every term of q.
+func (m model) matches(h sshHost, q hostQuery) bool {
+	for _, t := range q {
+		if m.matchTerm(h, t) == t.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// usesChecks reports whether q depends on check results, so it must be
+// re-evaluated as they arrive.
+func (q hostQuery) usesChecks() bool {
+	for _, t := range q {
+		if t.field == "latency" || t.field == "status" {
+			return true
+		}
+	}
+	return false
+}
+
+func (m model) filterHostsQuery(all []sshHost, q hostQuery) []sshHost {
+	out := make([]sshHost, 0, len(all))
+	for _, h := range all {
+		if m.matches(h, q) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParseQuery(t *testing.T) {
+	t.Parallel()
+	for s, want := range map[string]bool{
+		"tag:prod user:ubuntu port:!22 latency<50ms": true,
+		"-tag:staging":               true,
+		"tag:prod proxyjump:bastion": true,
+		"proxyjump:bastion-eu":       false, // a directive filter
+		"user:ubu*":                  true,  // query fields win over the User keyword
+		"hostname:^web":              false, // other keywords stay directive filters
+		"web prod":                   false,
+		"tag:prod web":               false,
+		"nosuchfield:x":              false,
+		"":                           false,
+	} {
+		if _, ok, err := parseQuery(s); ok != want || err != nil {
+			t.Errorf("%q: query %v (err %v), want %v", s, ok, err, want)
+		}
+	}
+	if _, ok, _ := scopedQuery("tag:prod", scopeAlias); ok {
+		t.Error("a query under the alias scope")
+	}
+	for _, s := range []string{"latency<fast", "port>x", "tag<3", "latency:50ms", "-port<22", "tag:prod proxyjump:("} {
+		if _, ok, err := parseQuery(s); !ok || err == nil {
+			t.Errorf("%q: want an invalid query, got ok=%v err=%v", s, ok, err)
+		}
+	}
+}
+
+func TestFilterHostsQuery(t *testing.T) {
+	t.Parallel()
+	tagged := func(tags string) map[string]string { return map[string]string{"tags": tags} }
+	hosts := []sshHost{
+		{Alias: "db1", User: "postgres", Annotations: tagged("prod,db"), Directives: []string{"user postgres", "proxyjump bastion"}, JumpChain: []string{"bastion"}},
+		{Alias: "web1", User: "ubuntu", Port: "2222", Annotations: tagged("prod,web")},
+		{Alias: "web2", User: "ubuntu", Annotations: tagged("prod,web")},
+		{Alias: "dev1", User: "ubuntu", Annotations: tagged("staging")},
+	}
+	m := model{reach: map[string]reachability{
+		"web1": {Latency: 20 * time.Millisecond},
+		"web2": {Latency: 120 * time.Millisecond},
+		"dev1": {Err: errors.New("timeout")},
+	}}
+	for query, want := range map[string]string{
+		"tag:prod":                 "db1 web1 web2",
+		"tag:prod user:ubuntu":     "web1 web2",
+		"tag:prod port:!22":        "web1",
+		"port>1024":                "web1",
+		"latency<50ms":             "web1",
+		"latency>=100":             "web2",
+		"status:down":              "dev1",
+		"-tag:prod":                "dev1",
+		"alias:web*":               "web1 web2",
+		"TAG:PROD jump:bastion":    "db1",
+		"tag:prod proxyjump:^bast": "db1",
+	} {
+		q, ok, err := parseQuery(query)
+		if !ok || err != nil {
+			t.Fatalf("%q: ok=%v err=%v", query, ok, err)
+		}
+		var got []string
+		for _, h := range m.filterHostsQuery(hosts, q) {
+			got = append(got, h.Alias)
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("%q: %q, want %q", query, strings.Join(got, " "), want)
+		}
+	}
+}
+
+func TestViewMenu(t *testing.T) {
+	t.Parallel()
+	m := initialModel([]sshHost{
+		{Alias: "web1", Annotations: map[string]string{"tags": "prod"}},
+		{Alias: "dev1"},
+	}, "", "")
+	m.appConfig.Views = map[string]viewSpec{"shared-prod": {Filter: "tag:prod", filterOnly: true}}
+	m.lastValidRegex = "dev"
+
+	m = m.openViewMenu()
+	press := func(keys ...tea.KeyMsg) {
+		for _, k := range keys {
+			next, _ := m.updateViewMenu(k)
+			m = next.(model)
+		}
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")}, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("mine")}, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.views["mine"].Filter != "dev" || m.viewMenu.cursor != 1 {
+		t.Fatalf("saved views %v, cursor %d", m.views, m.viewMenu.cursor)
+	}
+	// shared views can't be deleted from the menu
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("k")}, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if len(m.savedViews()) != 2 {
+		t.Fatalf("views %+v", m.savedViews())
+	}
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.viewMenu != nil || m.lastValidRegex != "tag:prod" || len(m.hosts) != 1 || m.hosts[0].Alias != "web1" {
+		t.Fatalf("applied: filter %q, hosts %+v", m.lastValidRegex, m.hosts)
+	}
+	if st := m.snapshotState(); st.Views["mine"].Filter != "dev" {
+		t.Fatalf("views not saved in state: %+v", st.Views)
+	}
+}
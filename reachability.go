@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	probeTimeout  = 2 * time.Second
+	probeInterval = 30 * time.Second
+	// probeSlow is the latency from which a reachable host shows as slow.
+	probeSlow = 150 * time.Millisecond
+	// maxProbes caps the dials in flight, so a long list doesn't open
+	// hundreds of sockets at once.
+	maxProbes = 32
+)
+
+var probeSlots = make(chan struct{}, maxProbes)
+
+// reachability is the last TCP probe of a host's ssh port.
+type reachability struct {
+	Latency time.Duration
+	Err     error
+}
+
+type reachMsg struct {
+	alias  string
+	result reachability
+}
+
+// probeTickMsg asks for the next round of probes. gen tells rounds of an
+// earlier toggle apart, so switching probes off and on doesn't double them.
+type probeTickMsg struct{ gen int }
+
+func probeCmd(h sshHost) tea.Cmd {
+	return func() tea.Msg {
+		probeSlots <- struct{}{}
+		defer func() { <-probeSlots }()
+		d, err := measureLatency(dialAddress(h), probeTimeout)
+		return reachMsg{alias: h.Alias, result: reachability{Latency: d, Err: err}}
+	}
+}
+
+func probeTick(gen int) tea.Cmd {
+	return tea.Tick(probeInterval, func(time.Time) tea.Msg { return probeTickMsg{gen: gen} })
+}
+
+// startProbes dials every visible host not already being probed. Earlier
+// results stay on screen until the new ones arrive. Hosts that opt out of
+// batch probes are skipped, and so are hosts behind a jump host, whose
+// address means nothing from here.
+func (m *model) startProbes() tea.Cmd {
+	if m.reach == nil {
+		m.reach = map[string]reachability{}
+	}
+	if m.reachPending == nil {
+		m.reachPending = map[string]bool{}
+	}
+	var cmds []tea.Cmd
+	for _, h := range m.hosts {
+		if skipsBatchProbes(h) || behindProxy(h) || m.reachPending[h.Alias] {
+			continue
+		}
+		m.reachPending[h.Alias] = true
+		cmds = append(cmds, probeCmd(h))
+	}
+	return tea.Batch(cmds...)
+}
+
+// toggleProbes switches the reachability column on or off. Turning it on
+// probes right away and then every probeInterval.
+func (m model) toggleProbes() (model, tea.Cmd) {
+	m.showReach = !m.showReach
+	m.probeGen++
+	if !m.showReach {
+		return m, nil
+	}
+	return m, tea.Batch(m.startProbes(), probeTick(m.probeGen))
+}
+
+func (m model) updateProbeTick(msg probeTickMsg) (model, tea.Cmd) {
+	if !m.showReach || msg.gen != m.probeGen {
+		return m, nil
+	}
+	return m, tea.Batch(m.startProbes(), probeTick(m.probeGen))
+}
+
+// reachCell renders the status dot and latency for a host row: green when
+// it answered quickly, yellow when slowly, red when it didn't. Hosts behind
+// a jump host say so instead.
+func (m model) reachCell(h sshHost) string {
+	if skipsBatchProbes(h) {
+		return ""
+	}
+	if behindProxy(h) {
+		return m.styles.help.Render("↪ via jump")
+	}
+	r, ok := m.reach[h.Alias]
+	if !ok {
+		if m.reachPending[h.Alias] {
+			return "○ …"
+		}
+		return ""
+	}
+	level, text := statusOK, fmt.Sprintf("%dms", r.Latency.Milliseconds())
+	switch {
+	case r.Err != nil:
+		level, text = statusBad, "down"
+	case r.Latency >= probeSlow:
+		level = statusWarn
+	}
+	if palette.shapes {
+		// the shape takes the place of the dot
+		return palette.render(level, text)
+	}
+	return palette.style(level).Render("● " + text)
+}
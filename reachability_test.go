@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestReachabilityProbes(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	// a port that was free a moment ago, so nothing answers there
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	_, upPort, _ := net.SplitHostPort(ln.Addr().String())
+	hosts := []sshHost{
+		{Alias: "up", Hostname: "127.0.0.1", Port: upPort},
+		{Alias: "down", Hostname: "127.0.0.1", Port: strconv.Itoa(closedPort)},
+		{Alias: "quiet", Hostname: "127.0.0.1", Annotations: map[string]string{"noprobe": "yes"}},
+		{Alias: "inner", Hostname: "127.0.0.1", Port: strconv.Itoa(closedPort), Options: map[string]string{"proxyjump": "bastion"}},
+	}
+	m := initialModel(hosts, "", "")
+	m.ready = true
+	m, cmd := m.toggleProbes()
+	if !m.showReach || cmd == nil {
+		t.Fatal("P should turn probes on")
+	}
+	if !strings.Contains(m.reachCell(hosts[0]), "…") || m.reachPending["quiet"] || m.reachPending["inner"] {
+		t.Fatalf("probes should be pending except for noprobe and jump hosts: %v", m.reachPending)
+	}
+	m.reachPending = nil
+
+	var run func(cmd tea.Cmd)
+	run = func(cmd tea.Cmd) {
+		msg := cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for _, c := range batch {
+				run(c)
+			}
+			return
+		}
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	run(m.startProbes())
+	if r := m.reach["up"]; r.Err != nil || !strings.Contains(m.reachCell(hosts[0]), "ms") {
+		t.Fatalf("up: %+v %q", r, m.reachCell(hosts[0]))
+	}
+	if !strings.Contains(m.reachCell(hosts[1]), "down") {
+		t.Fatalf("down: %q", m.reachCell(hosts[1]))
+	}
+	if m.reachCell(hosts[2]) != "" {
+		t.Fatal("noprobe hosts shouldn't show a status")
+	}
+	if _, probed := m.reach["inner"]; probed || !strings.Contains(m.reachCell(hosts[3]), "via jump") {
+		t.Fatalf("a host behind a jump host isn't down: %q", m.reachCell(hosts[3]))
+	}
+	if !strings.Contains(m.View(), "down") {
+		t.Fatal("the status should be on the host rows")
+	}
+
+	// a tick from before the last toggle doesn't start another round
+	stale := m.probeGen
+	m, _ = m.toggleProbes()
+	m, _ = m.toggleProbes()
+	if _, cmd := m.updateProbeTick(probeTickMsg{gen: stale}); cmd != nil {
+		t.Fatal("a stale tick should be dropped")
+	}
+	if _, cmd := m.updateProbeTick(probeTickMsg{gen: m.probeGen}); cmd == nil {
+		t.Fatal("the current tick should probe again")
+	}
+}
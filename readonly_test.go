@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestReadOnlyBlocksEditing(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel([]sshHost{{Alias: "web1"}}, "", "/tmp/ssh_config")
+	m.readOnly = true
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	m = next.(model)
+	if cmd != nil || m.err != errReadOnly {
+		t.Fatalf("expected editing to be refused, got err=%v", m.err)
+	}
+	for _, a := range m.hostActions(m.hosts[0]) {
+		if a.key == "e" || a.key == "M" {
+			t.Fatalf("action menu offers %q in read-only mode", a.label)
+		}
+	}
+
+	m.appConfig.MaintenanceFile = filepath.Join(t.TempDir(), "maintenance.json")
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("M")})
+	m = next.(model)
+	if m.err != errReadOnly || len(m.maintenance) != 0 || fileExists(m.appConfig.MaintenanceFile) {
+		t.Fatalf("maintenance toggled in read-only mode, err=%v", m.err)
+	}
+
+	settings := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(settings, []byte(`{"read_only": true}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if code := runWorkspace([]string{"save", "-settings", settings, "oncall", "web1"}, &out); code != 1 {
+		t.Fatalf("workspace save in read-only mode exited %d", code)
+	}
+}
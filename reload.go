@@ -0,0 +1,61 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// reloadConfig re-reads the ssh config after the e key's editor exits so
+// edits show up without restarting. Hosts from other sources (prometheus,
+// inventories, pasted ones) are kept as they were; config hosts are
+// replaced and get their metadata and known IPs back. The returned command
+// resolves hostnames that are new.
+func (m model) reloadConfig() (model, tea.Cmd) {
+	if m.configPath == "" {
+		return m, nil
+	}
+	parsed, warnings, err := parseSSHConfigWarnings(m.configPath)
+	if err != nil {
+		m.err = trErr("err.reload", err)
+		return m, nil
+	}
+	known := map[string]string{}
+	var others []sshHost
+	for _, h := range m.allHosts {
+		if hostSource(h) != "config" {
+			others = append(others, h)
+			continue
+		}
+		if h.IP != "" {
+			known[h.Hostname] = h.IP
+		}
+	}
+	for i := range parsed {
+		if parsed[i].IP == "" {
+			parsed[i].IP = known[parsed[i].Hostname]
+		}
+	}
+	if m.restrict != nil {
+		parsed = restrictHosts(parsed, m.restrict)
+	}
+	// Local metadata only: the team copy was fetched at startup.
+	meta, _ := hostMetadata(m.appConfig, false)
+	m.allHosts = favoritesFirst(applyMetadata(append(parsed, others...), meta))
+	m.warnings = warnings
+	m.applyFilter(m.lastValidRegex)
+
+	names := unresolvedHostnames(parsed)
+	for name := range names {
+		if m.resolving[name] {
+			delete(names, name)
+			continue
+		}
+		if m.resolving == nil {
+			m.resolving = map[string]bool{}
+		}
+		m.resolving[name] = true
+	}
+	if len(names) == 0 {
+		return m, nil
+	}
+	return m, resolveHostsCmd(names)
+}
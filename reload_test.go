@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReloadConfigAfterEdit(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(cfg, []byte("Host db1\n  HostName db1.example.com\nHost web\n"), 0o600)
+	hosts, _, err := parseSSHConfigWarnings(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts[0].IP = "10.0.0.5"
+	hosts = append(hosts, sshHost{Alias: "node7", Source: "prometheus"})
+	m := initialModel(hosts, "", cfg)
+	m.cursor = 1 // web
+
+	os.WriteFile(cfg, []byte("Host db1\n  HostName db1.example.com\n  User admin\nHost cache\nHost web\n  Port 2222\n"), 0o600)
+	next, _ := m.Update(editorFinishedMsg{})
+	m = next.(model)
+
+	var aliases []string
+	for _, h := range m.allHosts {
+		aliases = append(aliases, h.Alias)
+	}
+	if got := strings.Join(aliases, " "); !strings.Contains(got, "cache") || !strings.Contains(got, "node7") {
+		t.Fatalf("hosts after reload: %s", got)
+	}
+	if m.allHosts[0].User != "admin" || m.allHosts[0].IP != "10.0.0.5" {
+		t.Fatalf("db1 should pick up the edit and keep its IP: %+v", m.allHosts[0])
+	}
+	if h := m.hosts[m.cursor]; h.Alias != "web" || h.Port != "2222" {
+		t.Fatalf("cursor should stay on the edited web host, got %+v", h)
+	}
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+
+	os.Remove(cfg)
+	next, _ = m.Update(editorFinishedMsg{})
+	if m = next.(model); m.err == nil || len(m.allHosts) == 0 {
+		t.Fatal("a config that can't be read should keep the old list and say why")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runRemote runs a command on h over a non-interactive ssh session and
+// returns its stdout. On failure the last line of stderr is folded into the
+// error, since that's where ssh explains what went wrong.
+func runRemote(h sshHost, command string, timeout time.Duration) (string, error) {
+	return remoteOutput(remoteArgs(h, command, false), timeout)
+}
+
+// runProbe is runRemote for checks nobody asked for just now (stats, who,
+// health checks): a security key is never offered, so no key blinks for
+// them.
+func runProbe(h sshHost, command string, timeout time.Duration) (string, error) {
+	return remoteOutput(remoteArgs(h, command, true), timeout)
+}
+
+// remoteArgs is the ssh command line running command on h; probe leaves
+// security keys out (probeKeyArgs).
+func remoteArgs(h sshHost, command string, probe bool) []string {
+	args := append([]string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=5"}, hostKeyArgs(false)...)
+	if probe {
+		args = append(args, probeKeyArgs(h)...)
+	}
+	args = append(args, hostArgs(h)...)
+	return append(args, command)
+}
+
+func remoteOutput(args []string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			return string(out), fmt.Errorf("%w: %s", err, msg)
+		}
+		return string(out), err
+	}
+	return string(out), nil
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// forEachHost runs fn for every host, at most concurrency at a time.
+func forEachHost(hosts []sshHost, concurrency int, fn func(i int, h sshHost)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h sshHost) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			fn(i, h)
+		}(i, h)
+	}
+	wg.Wait()
+}
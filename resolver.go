@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// resolverConfig picks the DNS server used for the IP column, for split-DNS
+// setups where the system resolver gives the wrong answers. At most one of
+// the fields should be set.
+type resolverConfig struct {
+	Server     string `json:"server,omitempty"`      // "10.0.0.53" or "10.0.0.53:53"
+	DoH        string `json:"doh,omitempty"`         // DNS-over-HTTPS endpoint (RFC 8484), e.g. https://dns.corp/dns-query
+	ResolvConf string `json:"resolv_conf,omitempty"` // use the first nameserver in this resolv.conf-style file
+}
+
+// ipResolver resolves host names for display; replaced from the settings.
+var ipResolver = net.DefaultResolver
+
+// resolveTimeout bounds each lookup; -resolve-timeout 0 turns lookups off
+// and only IP literals fill the IP column.
+var resolveTimeout = 3 * time.Second
+
+// newResolver builds the resolver described by rc; an empty config is the
+// system resolver.
+func newResolver(rc resolverConfig) (*net.Resolver, error) {
+	server := rc.Server
+	if rc.ResolvConf != "" {
+		ns, err := firstNameserver(rc.ResolvConf)
+		if err != nil {
+			return nil, err
+		}
+		server = ns
+	}
+	switch {
+	case rc.DoH != "":
+		if !strings.HasPrefix(rc.DoH, "https://") {
+			return nil, fmt.Errorf("resolver doh: %q is not an https URL", rc.DoH)
+		}
+		return dohResolver(rc.DoH, &http.Client{Timeout: resolveTimeout}), nil
+	case server != "":
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		}}, nil
+	}
+	return net.DefaultResolver, nil
+}
+
+// dohResolver sends every query to a DNS-over-HTTPS endpoint.
+func dohResolver(url string, client *http.Client) *net.Resolver {
+	return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return &dohConn{ctx: ctx, url: url, client: client}, nil
+	}}
+}
+
+func firstNameserver(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return fields[1], nil
+		}
+	}
+	return "", fmt.Errorf("%s: no nameserver line", path)
+}
+
+// dohConn carries the Go resolver's TCP-framed DNS queries over HTTPS.
+// Because it isn't a PacketConn, the resolver writes each query with a
+// two-byte length prefix and reads the answer framed the same way.
+type dohConn struct {
+	ctx    context.Context
+	url    string
+	client *http.Client
+	out    bytes.Buffer // queries written by the resolver
+	in     bytes.Buffer // framed answers waiting to be read
+}
+
+func (c *dohConn) Write(p []byte) (int, error) {
+	c.out.Write(p)
+	for c.out.Len() >= 2 {
+		n := int(binary.BigEndian.Uint16(c.out.Bytes()))
+		if c.out.Len() < 2+n {
+			break
+		}
+		query := append([]byte(nil), c.out.Next(2 + n)[2:]...)
+		answer, err := c.exchange(query)
+		if err != nil {
+			return 0, err
+		}
+		var size [2]byte
+		binary.BigEndian.PutUint16(size[:], uint16(len(answer)))
+		c.in.Write(size[:])
+		c.in.Write(answer)
+	}
+	return len(p), nil
+}
+
+func (c *dohConn) exchange(query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh: %s", resp.Status)
+	}
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return nil, err
+	}
+	if len(answer) == 0 {
+		return nil, errors.New("doh: empty answer")
+	}
+	return answer, nil
+}
+
+func (c *dohConn) Read(p []byte) (int, error) {
+	if c.in.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.in.Read(p)
+}
+
+func (c *dohConn) Close() error                     { return nil }
+func (c *dohConn) LocalAddr() net.Addr              { return dohAddr{} }
+func (c *dohConn) RemoteAddr() net.Addr             { return dohAddr{} }
+func (c *dohConn) SetDeadline(time.Time) error      { return nil }
+func (c *dohConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(time.Time) error { return nil }
+
+type dohAddr struct{}
+
+func (dohAddr) Network() string { return "https" }
+func (dohAddr) String() string  { return "doh" }
+
+// maxLookups caps the picker's concurrent DNS lookups.
+const maxLookups = 16
+
+// lookupSlots is the worker pool the picker's lookups wait for.
+var lookupSlots = make(chan struct{}, maxLookups)
+
+// ipResolvedMsg is one background lookup's answer; ip is "" when the name
+// didn't resolve.
+type ipResolvedMsg struct {
+	hostname string
+	ip       string
+}
+
+// unresolvedHostnames are the hostnames still missing an IP, or nil when
+// lookups are off.
+func unresolvedHostnames(hosts []sshHost) map[string]bool {
+	if resolveTimeout <= 0 {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, h := range hosts {
+		if h.IP == "" && h.Hostname != "" {
+			names[h.Hostname] = true
+		}
+	}
+	return names
+}
+
+// resolveHostsCmd looks up every name in the background, at most
+// maxLookups at a time, sending an ipResolvedMsg for each as it finishes.
+func resolveHostsCmd(names map[string]bool) tea.Cmd {
+	cmds := make([]tea.Cmd, 0, len(names))
+	for name := range names {
+		cmds = append(cmds, func() tea.Msg {
+			lookupSlots <- struct{}{}
+			defer func() { <-lookupSlots }()
+			return ipResolvedMsg{hostname: name, ip: resolveIP(name)}
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+// resolveHostIPs fills in the missing IPs of hosts, waiting for the
+// lookups (at most maxLookups at a time). For the paths without a picker
+// to show "resolving…": merging sources, -list and -json, hooks, open.
+func resolveHostIPs(hosts []sshHost) {
+	names := unresolvedHostnames(hosts)
+	if len(names) == 0 {
+		return
+	}
+	ips := make(map[string]string, len(names))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lookupSlots <- struct{}{}
+			ip := resolveIP(name)
+			<-lookupSlots
+			mu.Lock()
+			ips[name] = ip
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	for i := range hosts {
+		if hosts[i].IP == "" {
+			hosts[i].IP = ips[hosts[i].Hostname]
+		}
+	}
+}
+
+// applyResolved fills in the IP of every row for the looked-up hostname.
+func (m *model) applyResolved(msg ipResolvedMsg) {
+	delete(m.resolving, msg.hostname)
+	if msg.ip == "" {
+		return
+	}
+	for _, list := range [][]sshHost{m.allHosts, m.hosts} {
+		for i := range list {
+			if list[i].Hostname == msg.hostname && list[i].IP == "" {
+				list[i].IP = msg.ip
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// dnsAnswer answers an A query with ip and anything else with no records.
+func dnsAnswer(query []byte, ip [4]byte) []byte {
+	end := 12
+	for query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	question := query[12 : end+5]
+	qtype := binary.BigEndian.Uint16(query[end+1:])
+
+	msg := append([]byte(nil), query[:2]...)
+	msg = append(msg, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0)
+	msg = append(msg, question...)
+	if qtype == 1 {
+		msg[7] = 1 // ANCOUNT
+		msg = append(msg, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+		msg = append(msg, ip[:]...)
+	}
+	return msg
+}
+
+func TestDoHResolver(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad content type", http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(dnsAnswer(query, [4]byte{10, 1, 2, 3}))
+	}))
+	defer srv.Close()
+
+	r := dohResolver(srv.URL, srv.Client())
+	ips, err := r.LookupIP(context.Background(), "ip4", "db1.corp.example.")
+	if err != nil {
+		t.Fatalf("LookupIP: %v", err)
+	}
+	if len(ips) != 1 || ips[0].String() != "10.1.2.3" {
+		t.Fatalf("ips = %v", ips)
+	}
+}
+
+func TestNewResolverConfig(t *testing.T) {
+	t.Parallel()
+
+	conf := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(conf, []byte("# corp\nsearch corp.example\nnameserver 10.0.0.53\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if ns, err := firstNameserver(conf); err != nil || ns != "10.0.0.53" {
+		t.Fatalf("firstNameserver = %q, %v", ns, err)
+	}
+	if _, err := newResolver(resolverConfig{ResolvConf: conf}); err != nil {
+		t.Fatalf("newResolver: %v", err)
+	}
+	if _, err := newResolver(resolverConfig{DoH: "http://dns.example/dns-query"}); err == nil {
+		t.Fatalf("expected plain-http DoH to be rejected")
+	}
+}
+
+func TestBackgroundResolve(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(dnsAnswer(query, [4]byte{10, 1, 2, 3}))
+	}))
+	defer srv.Close()
+	orig := ipResolver
+	defer func() { ipResolver = orig }()
+	ipResolver = dohResolver(srv.URL, srv.Client())
+
+	hosts := []sshHost{
+		{Alias: "db1", Hostname: "db1.corp.example"},
+		{Alias: "db1-admin", Hostname: "db1.corp.example", User: "admin"},
+		{Alias: "lit", Hostname: "10.0.0.9", IP: "10.0.0.9"},
+	}
+	m := initialModel(hosts, "", "")
+	m.ready = true
+	m.resolving = unresolvedHostnames(hosts)
+	if len(m.resolving) != 1 || !m.resolving["db1.corp.example"] {
+		t.Fatalf("unresolved %v", m.resolving)
+	}
+	if !strings.Contains(m.View(), "IP: resolving…") {
+		t.Fatal("pending lookups should show as resolving")
+	}
+
+	var run func(cmd tea.Cmd)
+	run = func(cmd tea.Cmd) {
+		msg := cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for _, c := range batch {
+				run(c)
+			}
+			return
+		}
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	cmd := m.Init()
+	if cmd == nil {
+		t.Fatal("Init should start the lookups")
+	}
+	run(cmd)
+	if len(m.resolving) != 0 || m.hosts[0].IP != "10.1.2.3" || m.hosts[1].IP != "10.1.2.3" || m.allHosts[2].IP != "10.0.0.9" {
+		t.Fatalf("resolved %+v pending %v", m.allHosts, m.resolving)
+	}
+
+	timeout := resolveTimeout
+	defer func() { resolveTimeout = timeout }()
+	resolveTimeout = 0
+	if unresolvedHostnames(hosts) != nil || resolveIP("db1.corp.example") != "" {
+		t.Fatal("-resolve-timeout 0 should turn lookups off")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+)
+
+// restrictConfig is the admin-provided allowlist for -restrict, which turns
+// sshpick into a plain menu on a shared bastion (typically run as the
+// account's ForceCommand so users can't drop the flag).
+type restrictConfig struct {
+	Hosts   []string `json:"hosts,omitempty"`   // alias globs, e.g. "web*"
+	Tags    []string `json:"tags,omitempty"`    // hosts with any of these tags
+	Options []string `json:"options,omitempty"` // overrides users may still use: "L" (-L forwards), "verbose" (-vvv retry)
+}
+
+// loadRestrict reads the allowlist. Unlike the settings file, a missing or
+// unreadable allowlist is an error: restricted mode fails closed.
+func loadRestrict(p string) (*restrictConfig, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var r restrictConfig
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return &r, nil
+}
+
+func (r *restrictConfig) allows(h sshHost) bool {
+	for _, pattern := range r.Hosts {
+		if ok, _ := path.Match(pattern, h.Alias); ok {
+			return true
+		}
+	}
+	for _, tag := range r.Tags {
+		if h.hasTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *restrictConfig) allowsOption(name string) bool {
+	return containsString(r.Options, name)
+}
+
+// restrictHosts keeps only the hosts the allowlist permits.
+func restrictHosts(hosts []sshHost, r *restrictConfig) []sshHost {
+	var out []sshHost
+	for _, h := range hosts {
+		if r.allows(h) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// restrictedKeys are the list-view keys that still work in restricted mode:
+// moving, filtering, notes, details and connecting.
+var restrictedKeys = map[string]bool{
+	"ctrl+c": true, "q": true, "esc": true,
+	"j": true, "l": true, "down": true, "k": true, "h": true, "up": true,
+	"enter": true, "/": true, "f": true, "backspace": true, "n": true, "i": true,
+}
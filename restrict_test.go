@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestRestrictHosts(t *testing.T) {
+	t.Parallel()
+
+	p := filepath.Join(t.TempDir(), "allow.json")
+	if err := os.WriteFile(p, []byte(`{"hosts": ["web*"], "tags": ["support"], "options": ["L"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := loadRestrict(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := []sshHost{
+		{Alias: "web1"},
+		{Alias: "db1"},
+		{Alias: "db2", Annotations: map[string]string{"tags": "support"}},
+	}
+	got := restrictHosts(hosts, r)
+	if len(got) != 2 || got[0].Alias != "web1" || got[1].Alias != "db2" {
+		t.Fatalf("restrictHosts = %#v", got)
+	}
+	if !r.allowsOption("L") || r.allowsOption("verbose") {
+		t.Fatalf("unexpected option allowlist %#v", r.Options)
+	}
+	if _, err := loadRestrict(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatalf("a missing allowlist must be an error")
+	}
+}
+
+func TestRestrictedKeys(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel([]sshHost{{Alias: "web1"}, {Alias: "web2"}}, "", "/tmp/ssh_config")
+	m.restrict = &restrictConfig{Hosts: []string{"*"}}
+	for _, key := range []string{"e", "d", "a", "o", "M"} {
+		next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		got := next.(model)
+		if cmd != nil || got.actions != nil || got.dual != nil || got.err != nil {
+			t.Fatalf("key %q did something in restricted mode", key)
+		}
+	}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	if next.(model).cursor != 1 {
+		t.Fatalf("navigation should still work")
+	}
+}
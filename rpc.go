@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// The daemon's control API is JSON-RPC 2.0 over HTTP POST /rpc, with status
+// changes streamed as JSON-RPC notifications over server-sent events on
+// GET /events, so an editor extension needs nothing but fetch.
+//
+// Methods:
+//
+//	hosts.list     {"filter": regex, "tag": tag}  -> [hostInfo]
+//	hosts.get      {"alias": alias}               -> hostInfo
+//	hosts.command  {"alias": alias}               -> {"argv": ["ssh", ...]}
+//	hosts.connect  {"alias": alias}               -> {"window": name}  (tmux only)
+//	daemon.refresh {}                             -> {"queued": true}
+
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// hostInfo is a host as the API reports it.
+type hostInfo struct {
+	Alias     string            `json:"alias"`
+	Hostname  string            `json:"hostname,omitempty"`
+	User      string            `json:"user,omitempty"`
+	Port      string            `json:"port,omitempty"`
+	Source    string            `json:"source"`
+	Tags      []string          `json:"tags,omitempty"`
+	Notes     []string          `json:"notes,omitempty"`
+	Favorite  bool              `json:"favorite,omitempty"`
+	Fields    map[string]string `json:"annotations,omitempty"`
+	Up        *bool             `json:"up,omitempty"` // nil when not checked (mfa/noprobe, or no check yet)
+	ConnectMS float64           `json:"connect_ms,omitempty"`
+}
+
+// statusChange is sent to subscribers when a host's reachability flips.
+type statusChange struct {
+	Alias string `json:"alias"`
+	Up    bool   `json:"up"`
+	Error string `json:"error,omitempty"`
+}
+
+// tmuxNewWindow opens argv in a new tmux window; a var so tests don't need tmux.
+var tmuxNewWindow = func(name string, argv []string) error {
+	args := append([]string{"new-window", "-n", name, "--"}, argv...)
+	if out, err := exec.Command("tmux", args...).CombinedOutput(); err != nil {
+		if msg := lastLine(string(out)); msg != "" {
+			return fmt.Errorf("tmux: %w: %s", err, msg)
+		}
+		return fmt.Errorf("tmux: %w", err)
+	}
+	return nil
+}
+
+// subscribe registers a channel for status changes; the returned func
+// unregisters it.
+func (d *daemonState) subscribe() (<-chan statusChange, func()) {
+	ch := make(chan statusChange, 64)
+	d.mu.Lock()
+	if d.subscribers == nil {
+		d.subscribers = map[chan statusChange]bool{}
+	}
+	d.subscribers[ch] = true
+	d.mu.Unlock()
+	return ch, func() {
+		d.mu.Lock()
+		delete(d.subscribers, ch)
+		d.mu.Unlock()
+	}
+}
+
+// statusChanges compares two rounds of checks. Hosts seen for the first
+// time count as a change, so subscribers learn the initial state.
+func statusChanges(prev, next []latencyResult) []statusChange {
+	before := map[string]bool{}
+	for _, c := range prev {
+		if !errors.Is(c.Err, errProbeSkipped) {
+			before[c.Host.Alias] = c.Err == nil
+		}
+	}
+	var out []statusChange
+	for _, c := range next {
+		if errors.Is(c.Err, errProbeSkipped) {
+			continue
+		}
+		up := c.Err == nil
+		if was, ok := before[c.Host.Alias]; ok && was == up {
+			continue
+		}
+		ch := statusChange{Alias: c.Host.Alias, Up: up}
+		if c.Err != nil {
+			ch.Error = c.Err.Error()
+		}
+		out = append(out, ch)
+	}
+	return out
+}
+
+// publish sends changes to every subscriber, dropping them for a
+// subscriber that isn't keeping up rather than stalling the refresh.
+// The caller holds d.mu.
+func (d *daemonState) publish(changes []statusChange) {
+	for ch := range d.subscribers {
+		for _, c := range changes {
+			select {
+			case ch <- c:
+			default:
+			}
+		}
+	}
+}
+
+func (d *daemonState) hostInfo(h sshHost) hostInfo {
+	info := hostInfo{
+		Alias:    h.Alias,
+		Hostname: h.Hostname,
+		User:     h.User,
+		Port:     h.Port,
+		Source:   hostSource(h),
+		Tags:     h.tags(),
+		Notes:    h.Notes,
+		Favorite: h.annotationBool("favorite"),
+		Fields:   h.Annotations,
+	}
+	for _, c := range d.checks {
+		if c.Host.Alias != h.Alias || errors.Is(c.Err, errProbeSkipped) {
+			continue
+		}
+		up := c.Err == nil
+		info.Up = &up
+		if up {
+			info.ConnectMS = float64(c.Latency.Microseconds()) / 1000
+		}
+	}
+	return info
+}
+
+func (d *daemonState) lookup(alias string) (sshHost, error) {
+	for _, h := range d.hosts {
+		if h.Alias == alias {
+			return h, nil
+		}
+	}
+	return sshHost{}, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("no host %q", alias)}
+}
+
+// call runs one method.
+func (d *daemonState) call(method string, params json.RawMessage) (any, error) {
+	var p struct {
+		Alias  string `json:"alias"`
+		Filter string `json:"filter"`
+		Tag    string `json:"tag"`
+	}
+	if len(params) > 0 && string(params) != "null" {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+	}
+
+	switch method {
+	case "daemon.refresh":
+		select {
+		case d.refreshNow <- struct{}{}:
+		default:
+		}
+		return map[string]bool{"queued": true}, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch method {
+	case "hosts.list":
+		hosts, err := selectHosts(d.hosts, p.Filter, p.Tag)
+		if err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		out := make([]hostInfo, 0, len(hosts))
+		for _, h := range hosts {
+			out = append(out, d.hostInfo(h))
+		}
+		return out, nil
+	case "hosts.get":
+		h, err := d.lookup(p.Alias)
+		if err != nil {
+			return nil, err
+		}
+		return d.hostInfo(h), nil
+	case "hosts.command":
+		h, err := d.lookup(p.Alias)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]string{"argv": append([]string{"ssh"}, sshArgs(h, d.launch)...)}, nil
+	case "hosts.connect":
+		h, err := d.lookup(p.Alias)
+		if err != nil {
+			return nil, err
+		}
+		if os.Getenv("TMUX") == "" {
+			return nil, &rpcError{Code: rpcServerError, Message: "the daemon isn't running inside tmux; run the argv from hosts.command instead"}
+		}
+		if err := tmuxNewWindow(h.Alias, append([]string{"ssh"}, sshArgs(h, d.launch)...)); err != nil {
+			return nil, &rpcError{Code: rpcServerError, Message: err.Error()}
+		}
+		appendHistory(historyPath(), historyEntry{Alias: h.Alias, Source: h.Source, Start: time.Now()})
+		return map[string]string{"window": h.Alias}, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + method}
+}
+
+// localOnly refuses requests a web page could forge: anything carrying an
+// Origin header, and POSTs that aren't application/json (which a browser
+// can't send cross-origin without a preflight the daemon never answers).
+func localOnly(r *http.Request) bool {
+	if r.Header.Get("Origin") != "" {
+		return false
+	}
+	if r.Method == http.MethodPost {
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		return mt == "application/json"
+	}
+	return true
+}
+
+func (d *daemonState) serveRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a JSON-RPC 2.0 request", http.StatusMethodNotAllowed)
+		return
+	}
+	if !localOnly(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	var req rpcRequest
+	resp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		resp.Error = &rpcError{Code: rpcParseError, Message: err.Error()}
+	} else if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{Code: rpcInvalidRequest, Message: `expected {"jsonrpc": "2.0", "method": ...}`}
+	} else {
+		if len(req.ID) > 0 {
+			resp.ID = req.ID
+		}
+		result, err := d.call(req.Method, req.Params)
+		var rerr *rpcError
+		switch {
+		case errors.As(err, &rerr):
+			resp.Error = rerr
+		case err != nil:
+			resp.Error = &rpcError{Code: rpcServerError, Message: err.Error()}
+		default:
+			resp.Result = result
+		}
+		if len(req.ID) == 0 {
+			// a notification: no response body
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveEvents streams status changes as server-sent events, each a
+// JSON-RPC "status.changed" notification.
+func (d *daemonState) serveEvents(w http.ResponseWriter, r *http.Request) {
+	if !localOnly(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch, cancel := d.subscribe()
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case c := <-ch:
+			data, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": "status.changed", "params": c})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rpcCall(t *testing.T, srv *httptest.Server, body string) rpcResponse {
+	t.Helper()
+	resp, err := srv.Client().Post(srv.URL+"/rpc", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("%s: %v", body, err)
+	}
+	return out
+}
+
+func TestDaemonRPC(t *testing.T) {
+	t.Parallel()
+
+	state := newDaemonState(daemonOptions{})
+	state.hosts = []sshHost{
+		{Alias: "web1", Hostname: "10.0.0.1", Annotations: map[string]string{"tags": "prod"}},
+		{Alias: "db1", Hostname: "10.0.0.2"},
+	}
+	state.checks = []latencyResult{{Host: state.hosts[0], Latency: 2 * time.Millisecond}, {Host: state.hosts[1], Err: errors.New("refused")}}
+	srv := httptest.NewServer(state.handler())
+	defer srv.Close()
+
+	resp := rpcCall(t, srv, `{"jsonrpc": "2.0", "id": 1, "method": "hosts.list", "params": {"tag": "prod"}}`)
+	var hosts []hostInfo
+	data, _ := json.Marshal(resp.Result)
+	json.Unmarshal(data, &hosts)
+	if resp.Error != nil || len(hosts) != 1 || hosts[0].Alias != "web1" || hosts[0].Up == nil || !*hosts[0].Up || hosts[0].ConnectMS != 2 {
+		t.Fatalf("hosts.list: %+v %s", resp.Error, data)
+	}
+	if string(resp.ID) != "1" {
+		t.Errorf("id %s", resp.ID)
+	}
+
+	resp = rpcCall(t, srv, `{"jsonrpc": "2.0", "id": "a", "method": "hosts.command", "params": {"alias": "db1"}}`)
+	if data, _ := json.Marshal(resp.Result); string(data) != `{"argv":["ssh","--","db1"]}` {
+		t.Errorf("hosts.command: %s %+v", data, resp.Error)
+	}
+	for body, code := range map[string]int{
+		`{"jsonrpc": "2.0", "id": 2, "method": "hosts.get", "params": {"alias": "nope"}}`: rpcInvalidParams,
+		`{"jsonrpc": "2.0", "id": 3, "method": "hosts.delete"}`:                           rpcMethodNotFound,
+		`{"id": 4, "method": "hosts.list"}`:                                               rpcInvalidRequest,
+		`{"jsonrpc": `:                                                                    rpcParseError,
+	} {
+		if resp := rpcCall(t, srv, body); resp.Error == nil || resp.Error.Code != code {
+			t.Errorf("%s: %+v", body, resp.Error)
+		}
+	}
+
+	for _, headers := range []map[string]string{
+		{"Content-Type": "text/plain"},
+		{"Content-Type": "application/json", "Origin": "https://evil.example.com"},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/rpc", strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "hosts.list"}`))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		r, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+		if r.StatusCode != http.StatusForbidden {
+			t.Errorf("%v: got %s, want 403", headers, r.Status)
+		}
+	}
+}
+
+func TestDaemonEvents(t *testing.T) {
+	t.Parallel()
+
+	state := newDaemonState(daemonOptions{})
+	srv := httptest.NewServer(state.handler())
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	web := sshHost{Alias: "web1"}
+	state.mu.Lock()
+	state.publish(statusChanges(nil, []latencyResult{{Host: web}, {Host: sshHost{Alias: "vault"}, Err: errProbeSkipped}}))
+	state.publish(statusChanges([]latencyResult{{Host: web}}, []latencyResult{{Host: web, Err: errors.New("timeout")}}))
+	state.mu.Unlock()
+
+	sc := bufio.NewScanner(resp.Body)
+	var events []string
+	for len(events) < 2 && sc.Scan() {
+		if line := sc.Text(); strings.HasPrefix(line, "data: ") {
+			events = append(events, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	want := []string{
+		`{"jsonrpc":"2.0","method":"status.changed","params":{"alias":"web1","up":true}}`,
+		`{"jsonrpc":"2.0","method":"status.changed","params":{"alias":"web1","up":false,"error":"timeout"}}`,
+	}
+	if strings.Join(events, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events:\n%s", strings.Join(events, "\n"))
+	}
+}
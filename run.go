@@ -0,0 +1,566 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+)
+
+// runResult is one host's outcome of `sshpick run`.
+type runResult struct {
+	Alias    string
+	Exit     int // the command's exit code; 255 when ssh itself failed
+	Duration time.Duration
+	Stdout   []byte
+	Stderr   []byte
+	Err      string // why it didn't run to completion, e.g. a timeout
+	NotRun   bool   // not started: too many hosts had failed already
+}
+
+func (r runResult) failed() bool { return r.Exit != 0 && !r.NotRun }
+
+// runJob is what `sshpick run` runs on each host.
+type runJob struct {
+	Command string
+	Timeout time.Duration
+	// SudoPassword, when set, is written to the command's stdin for
+	// sudo -S and masked in its output.
+	SudoPassword string
+	// Script is a local file copied to each host, run with Args instead of
+	// Command and removed again.
+	Script string
+	Args   []string
+	// Stream, when set, prints output lines as they arrive.
+	Stream *runStream
+	// Prompts, when set, relays prompts the command stalls on to the user
+	// and feeds back the answers.
+	Prompts *promptRelay
+	// Context stops the run when done, e.g. Ctrl+C on the run screen; nil
+	// runs until the timeout.
+	Context context.Context
+}
+
+// context is the run's context, Background when none was given.
+func (job runJob) context() context.Context {
+	if job.Context != nil {
+		return job.Context
+	}
+	return context.Background()
+}
+
+// runStream prints every host's output lines as they arrive, prefixed with
+// the host.
+type runStream struct {
+	mu     sync.Mutex
+	w      io.Writer
+	width  int    // of the alias column
+	secret string // masked in every line
+}
+
+func newRunStream(w io.Writer, hosts []sshHost, secret string) *runStream {
+	s := &runStream{w: w, secret: secret}
+	for _, h := range hosts {
+		s.width = max(s.width, len(h.Alias))
+	}
+	return s
+}
+
+func (s *runStream) line(alias string, line []byte) {
+	if s.secret != "" {
+		line = bytes.ReplaceAll(line, []byte(s.secret), []byte("********"))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, "%-*s | %s\n", s.width, alias, line)
+}
+
+// lineWriter passes whole lines written to it on to a runStream; one per
+// output, so stdout and stderr lines don't mix mid-line.
+type lineWriter struct {
+	stream  *runStream
+	alias   string
+	partial []byte
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.partial = append(w.partial, b...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.stream.line(w.alias, w.partial[:i])
+		w.partial = w.partial[i+1:]
+	}
+	return len(b), nil
+}
+
+// flush prints a last line that had no newline.
+func (w *lineWriter) flush() {
+	if len(w.partial) > 0 {
+		w.stream.line(w.alias, w.partial)
+		w.partial = nil
+	}
+}
+
+// sudoCommand wraps command so sudo reads the password from stdin without
+// printing a prompt into the output. -k makes sudo read it even when the
+// remote user has cached credentials; otherwise the password line would be
+// left for the command's own stdin.
+func sudoCommand(command string) string {
+	return shellJoin([]string{"sudo", "-k", "-S", "-p", "", "sh", "-c", command})
+}
+
+// masked hides secret wherever it shows up in r's output, e.g. echoed by a
+// command that read stdin itself.
+func (r runResult) masked(secret string) runResult {
+	if secret == "" {
+		return r
+	}
+	r.Stdout = bytes.ReplaceAll(r.Stdout, []byte(secret), []byte("********"))
+	r.Stderr = bytes.ReplaceAll(r.Stderr, []byte(secret), []byte("********"))
+	return r
+}
+
+// readPassword prompts on the terminal with echo off. Echo comes back on
+// even when the user presses Ctrl+C at the prompt. A variable so tests
+// don't need a terminal.
+var readPassword = func(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal to ask for the password: %w", err)
+	}
+	defer tty.Close()
+	state, err := term.GetState(tty.Fd())
+	if err != nil {
+		return "", fmt.Errorf("reading the terminal state: %w", err)
+	}
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	defer func() {
+		signal.Stop(interrupted)
+		close(done)
+	}()
+	go func() {
+		select {
+		case <-interrupted:
+			term.Restore(tty.Fd(), state)
+			fmt.Fprintln(tty)
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+	fmt.Fprint(tty, prompt)
+	password, err := term.ReadPassword(tty.Fd())
+	fmt.Fprintln(tty)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// runOnHost runs job on h non-interactively, keeping both outputs. A
+// variable so tests don't need ssh.
+var runOnHost = func(h sshHost, job runJob) runResult {
+	ctx, cancel := context.WithTimeout(job.context(), job.Timeout)
+	defer cancel()
+	args := append([]string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=5"}, hostKeyArgs(false)...)
+	args = append(args, hostArgs(h)...)
+	command := job.Command
+	if job.SudoPassword != "" {
+		command = sudoCommand(command)
+	}
+	cmd := exec.CommandContext(ctx, "ssh", append(args, command)...)
+	if job.SudoPassword != "" {
+		// the password never appears on a command line, only on stdin
+		cmd.Stdin = strings.NewReader(job.SudoPassword + "\n")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if job.Stream != nil {
+		out, errOut := &lineWriter{stream: job.Stream, alias: h.Alias}, &lineWriter{stream: job.Stream, alias: h.Alias}
+		cmd.Stdout, cmd.Stderr = io.MultiWriter(&stdout, out), io.MultiWriter(&stderr, errOut)
+		defer out.flush()
+		defer errOut.flush()
+	}
+	var stdin io.WriteCloser
+	var watches []*promptWatch
+	if job.Prompts != nil {
+		watches = []*promptWatch{{}, {}}
+		cmd.Stdout, cmd.Stderr = io.MultiWriter(cmd.Stdout, watches[0]), io.MultiWriter(cmd.Stderr, watches[1])
+		cmd.Stdin = nil
+		stdin, _ = cmd.StdinPipe()
+	}
+	start := time.Now()
+	err := cmd.Start()
+	if err == nil {
+		if stdin != nil {
+			if job.SudoPassword != "" {
+				io.WriteString(stdin, job.SudoPassword+"\n")
+			}
+			done := make(chan struct{})
+			go relayPrompts(job.Prompts, h.Alias, stdin, done, watches...)
+			err = cmd.Wait()
+			close(done)
+		} else {
+			err = cmd.Wait()
+		}
+	}
+	r := runResult{Alias: h.Alias, Duration: time.Since(start), Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	var exit *exec.ExitError
+	switch {
+	case job.context().Err() != nil:
+		r.Exit, r.Err = -1, "interrupted"
+	case ctx.Err() != nil:
+		r.Exit, r.Err = -1, fmt.Sprintf("timed out after %s", job.Timeout)
+	case errors.As(err, &exit):
+		r.Exit = exit.ExitCode()
+		if r.Exit == 255 {
+			r.Err = lastLine(stderr.String())
+		}
+	case err != nil:
+		r.Exit, r.Err = -1, err.Error()
+	}
+	return r
+}
+
+// scriptName is the file name of script, reduced to characters that need no
+// quoting on either side of scp.
+func scriptName(script string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, filepath.Base(script))
+}
+
+// remoteScriptPath is a fresh path in the remote /tmp for script.
+func remoteScriptPath(script string) string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "/tmp/sshpick-" + hex.EncodeToString(b) + "-" + scriptName(script)
+}
+
+// scriptCommand runs the uploaded script with args, then removes it,
+// exiting with the script's exit code.
+func scriptCommand(path string, args []string) string {
+	p := shellJoin([]string{path})
+	return "chmod 700 " + p + " && " + shellJoin(append([]string{path}, args...)) + "; rc=$?; rm -f " + p + "; exit $rc"
+}
+
+// uploadScript copies local to remote on h with scp. A variable so tests
+// don't need scp.
+var uploadScript = func(h sshHost, local, remote string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	args := append([]string{"-q", "-o", "BatchMode=yes", "-o", "ConnectTimeout=5"}, hostKeyArgs(false)...)
+	args = append(args, transferRouteArgs(h)...)
+	out, err := exec.CommandContext(ctx, "scp", append(args, "--", local, sshTarget(h)+":"+remote)...).CombinedOutput()
+	if err != nil {
+		if msg := lastLine(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// runHost runs job on h, uploading its script first if it has one.
+func runHost(h sshHost, job runJob) runResult {
+	if job.Script == "" {
+		return runOnHost(h, job)
+	}
+	start := time.Now()
+	remote := remoteScriptPath(job.Script)
+	if err := uploadScript(h, job.Script, remote, job.Timeout); err != nil {
+		return runResult{Alias: h.Alias, Exit: -1, Duration: time.Since(start), Err: "upload: " + err.Error()}
+	}
+	job.Command = scriptCommand(remote, job.Args)
+	r := runOnHost(h, job)
+	r.Duration = time.Since(start)
+	return r
+}
+
+// collectRuns runs command on every host, at most concurrency at a time,
+// keeping the hosts' order. Hosts start in order, so it rolls through the
+// fleet; once maxFailures hosts failed (0 means no limit) or the job's
+// context is done no more start, the running ones finish and the rest are
+// marked NotRun.
+func collectRuns(hosts []sshHost, concurrency, maxFailures int, job runJob) []runResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]runResult, len(hosts))
+	slots := make(chan struct{}, concurrency)
+	var mu sync.Mutex
+	failures := 0
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		slots <- struct{}{}
+		mu.Lock()
+		stop := maxFailures > 0 && failures >= maxFailures
+		mu.Unlock()
+		if stop {
+			<-slots
+			results[i] = runResult{Alias: h.Alias, NotRun: true}
+			continue
+		}
+		if job.context().Err() != nil {
+			<-slots
+			results[i] = runResult{Alias: h.Alias, NotRun: true, Err: "interrupted"}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, h sshHost) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			r := runHost(h, job).masked(job.SudoPassword)
+			mu.Lock()
+			if r.failed() {
+				failures++
+			}
+			mu.Unlock()
+			results[i] = r
+		}(i, h)
+	}
+	wg.Wait()
+	return results
+}
+
+// runInteractive is collectRuns under the run screen (-interactive):
+// prompts are answered there, output scrolls above it, and Ctrl+C stops
+// the run. results is nil when the user left before the hosts were done.
+func runInteractive(hosts []sshHost, concurrency, maxFailures int, job runJob, stdout io.Writer) (results []runResult, interrupted bool, err error) {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil, false, fmt.Errorf("-interactive needs a terminal: %w", err)
+	}
+	defer tty.Close()
+	ctx, cancel := context.WithCancel(job.context())
+	defer cancel()
+	var p *tea.Program
+	relay := newPromptRelay(func(msg tea.Msg) { p.Send(msg) })
+	p = tea.NewProgram(newRunScreen(relay, len(hosts), cancel), tea.WithInput(tty), tea.WithOutput(stdout))
+	job.Prompts, job.Context = relay, ctx
+	if job.Stream != nil {
+		job.Stream.w = printWriter{p}
+	}
+	done := make(chan []runResult, 1)
+	go func() {
+		done <- collectRuns(hosts, concurrency, maxFailures, job)
+		p.Send(runFinishedMsg{})
+	}()
+	final, err := p.Run()
+	if err != nil {
+		return nil, false, err
+	}
+	screen := final.(runScreen)
+	if screen.abandoned {
+		return nil, true, nil
+	}
+	return <-done, screen.interrupted, nil
+}
+
+// runFileName is alias made safe as a file name: separators and control
+// characters become "_", as do leading dots, so ".." or ".ssh" can't name
+// anything outside the output directory or hide the file.
+func runFileName(alias string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, alias)
+	if trimmed := strings.TrimLeft(name, "."); trimmed != name {
+		name = strings.Repeat("_", len(name)-len(trimmed)) + trimmed
+	}
+	if name == "" {
+		name = "_"
+	}
+	return name
+}
+
+// saveRunOutputs writes each host's stdout and stderr to <alias>.stdout and
+// <alias>.stderr in a new directory under base named after now, and returns
+// that directory. Aliases that come out as the same file name (db/1 and
+// db:1, or Web and web on a case-insensitive disk) get -2, -3, ... appended.
+func saveRunOutputs(base string, results []runResult, now time.Time) (string, error) {
+	dir := filepath.Join(expandHome(base), now.Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	used := map[string]bool{}
+	for _, r := range results {
+		if r.NotRun {
+			continue
+		}
+		name := runFileName(r.Alias)
+		for i := 2; used[strings.ToLower(name)]; i++ {
+			name = fmt.Sprintf("%s-%d", runFileName(r.Alias), i)
+		}
+		used[strings.ToLower(name)] = true
+		if err := os.WriteFile(filepath.Join(dir, name+".stdout"), r.Stdout, 0o600); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".stderr"), r.Stderr, 0o600); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// writeRunSummary is the table of exit codes that ends `sshpick run`.
+func writeRunSummary(w io.Writer, results []runResult) {
+	failed, notRun, interrupted := 0, 0, 0
+	for _, r := range results {
+		status := "ok"
+		switch {
+		case r.NotRun && r.Err != "":
+			interrupted++
+			status = "not run: " + r.Err
+		case r.NotRun:
+			notRun++
+			status = "not run"
+		case r.Exit != 0:
+			failed++
+			status = fmt.Sprintf("exit %d", r.Exit)
+			if r.Err != "" {
+				status += ": " + r.Err
+			}
+		}
+		fmt.Fprintf(w, "%-20s %8s  %s\n", r.Alias, r.Duration.Round(10*time.Millisecond), status)
+	}
+	fmt.Fprintf(w, "%d hosts, %d failed", len(results), failed)
+	if notRun > 0 {
+		fmt.Fprintf(w, ", %d not run after too many failures", notRun)
+	}
+	if interrupted > 0 {
+		fmt.Fprintf(w, ", %d not run after Ctrl+C", interrupted)
+	}
+	fmt.Fprintln(w)
+}
+
+// runRun implements `sshpick run`, a command or an uploaded script across
+// the selected hosts in parallel, stopping early with -max-failures. Output
+// streams to the terminal, or with -out goes to files in a timestamped
+// directory. It exits 1 when any host's command failed or didn't run.
+func runRun(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	settingsPath := fs.String("settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
+	all := fs.Bool("all", false, "Run on every host")
+	filter := fs.String("filter", "", "Only hosts whose alias matches this regex")
+	tag := fs.String("tag", "", "Only hosts with this tag")
+	concurrency := fs.Int("concurrency", 16, "Hosts contacted at once")
+	maxFailures := fs.Int("max-failures", 0, "Start no more hosts once this many failed (0: no limit)")
+	timeout := fs.Duration("timeout", time.Minute, "How long the command may run on each host")
+	out := fs.String("out", "", "Write each host's stdout and stderr to files in a timestamped directory under this one")
+	sudo := fs.Bool("sudo", false, "Ask once for a sudo password and run the command with sudo -S on every host")
+	script := fs.String("script", "", "Local script to copy to each host, run with the remaining arguments and remove")
+	interactive := fs.Bool("interactive", false, "Show prompts hosts' commands wait at on a run screen, to answer per host or for all")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	groups, rest := splitGroupArgs(fs.Args())
+	command := strings.Join(rest, " ")
+	if (command == "" && *script == "") || (!*all && *filter == "" && *tag == "" && len(groups) == 0) {
+		fmt.Fprintln(os.Stderr, "usage: sshpick run -all | -filter re | -tag t | @group... [-concurrency n] [-max-failures m] [-sudo] [-interactive] [-out dir] [--] command... | -script file [args...]")
+		return 2
+	}
+	if *script != "" {
+		if info, err := os.Stat(*script); err != nil || info.IsDir() {
+			fmt.Fprintf(os.Stderr, "-script %s: not a readable file\n", *script)
+			return 2
+		}
+	}
+	settings, err := loadHostKeyPolicy(*settingsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+		return 2
+	}
+	if *cfgPath == "" {
+		*cfgPath = defaultConfigPath()
+	}
+	hosts, loads := loadDaemonHosts(daemonOptions{cfgPath: *cfgPath, settings: settings})
+	for _, l := range loads {
+		if l.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", l.Name, l.Err)
+		}
+	}
+	if len(groups) > 0 {
+		if hosts, err = groupMembers(hosts, groups); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if hosts, err = selectHosts(hosts, *filter, *tag); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	job := runJob{Command: command, Timeout: *timeout}
+	if *script != "" {
+		job.Command, job.Script, job.Args = "", *script, rest
+	}
+	if *sudo && len(hosts) > 0 {
+		if job.SudoPassword, err = readPassword("sudo password for the remote hosts: "); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if *out == "" {
+		job.Stream = newRunStream(stdout, hosts, job.SudoPassword)
+	}
+	start := time.Now()
+	var results []runResult
+	interrupted := false
+	if *interactive {
+		if results, interrupted, err = runInteractive(hosts, *concurrency, *maxFailures, job, stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if results == nil {
+			fmt.Fprintln(os.Stderr, "interrupted")
+			return 130
+		}
+	} else {
+		results = collectRuns(hosts, *concurrency, *maxFailures, job)
+	}
+	if *out != "" {
+		dir, err := saveRunOutputs(*out, results, start)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		fmt.Fprintln(stdout, "output in", tildePath(dir))
+	}
+	writeRunSummary(stdout, results)
+	if interrupted {
+		return 130
+	}
+	for _, r := range results {
+		if r.Exit != 0 || r.NotRun {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunOutputs(t *testing.T) {
+	results := []runResult{
+		{Alias: "web", Stdout: []byte("up 3 days\n"), Duration: 120 * time.Millisecond},
+		{Alias: "db/primary", Exit: 1, Stdout: []byte("partial\n"), Stderr: []byte("disk full\n")},
+		{Alias: "gone", Exit: 255, Err: "Connection refused"},
+	}
+
+	dir, err := saveRunOutputs(t.TempDir(), results, time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(dir) != "20261016-093000" {
+		t.Fatalf("directory %s", dir)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "db_primary.stderr")); string(data) != "disk full\n" {
+		t.Fatalf("stderr file %q", data)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "gone.stdout")); err != nil || len(data) != 0 {
+		t.Fatalf("hosts without output still get their files: %q %v", data, err)
+	}
+
+	// Aliases that would leave the directory, or share a file name, each
+	// get their own file inside it.
+	clash := []runResult{
+		{Alias: "..", Stdout: []byte("dots")},
+		{Alias: "db/1", Stdout: []byte("slash")},
+		{Alias: "db:1", Stdout: []byte("colon")},
+		{Alias: "DB_1", Stdout: []byte("upper")},
+		{Alias: ".ssh", Stdout: []byte("hidden")},
+	}
+	base := t.TempDir()
+	dir, err = saveRunOutputs(base, clash, time.Date(2026, 10, 16, 9, 31, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{"__": "dots", "db_1": "slash", "db_1-2": "colon", "DB_1-3": "upper", "_ssh": "hidden"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, file+".stdout")); string(data) != want {
+			t.Errorf("%s.stdout: %q, want %q", file, data, want)
+		}
+	}
+	if entries, _ := os.ReadDir(base); len(entries) != 1 {
+		t.Fatalf("files written outside the run directory: %v", entries)
+	}
+
+	var buf bytes.Buffer
+	writeRunSummary(&buf, results)
+	for _, want := range []string{"exit 1\n", "exit 255: Connection refused\n", "3 hosts, 2 failed\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("summary missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestCollectRuns(t *testing.T) {
+	orig := runOnHost
+	defer func() { runOnHost = orig }()
+	runOnHost = func(h sshHost, job runJob) runResult {
+		return runResult{Alias: h.Alias, Stdout: []byte(job.Command + " on " + h.Alias)}
+	}
+
+	results := collectRuns([]sshHost{{Alias: "a"}, {Alias: "b"}, {Alias: "c"}}, 2, 0, runJob{Command: "uptime", Timeout: time.Second})
+	if len(results) != 3 || results[2].Alias != "c" || string(results[1].Stdout) != "uptime on b" {
+		t.Fatalf("results %+v", results)
+	}
+}
+
+func TestCollectRunsMaxFailures(t *testing.T) {
+	orig := runOnHost
+	defer func() { runOnHost = orig }()
+	var mu sync.Mutex
+	var ran []string
+	runOnHost = func(h sshHost, _ runJob) runResult {
+		mu.Lock()
+		ran = append(ran, h.Alias)
+		mu.Unlock()
+		if strings.HasPrefix(h.Alias, "bad") {
+			return runResult{Alias: h.Alias, Exit: 1}
+		}
+		return runResult{Alias: h.Alias}
+	}
+
+	hosts := []sshHost{{Alias: "ok1"}, {Alias: "bad1"}, {Alias: "bad2"}, {Alias: "ok2"}, {Alias: "ok3"}}
+	results := collectRuns(hosts, 1, 2, runJob{Command: "deploy", Timeout: time.Second})
+	if len(ran) != 3 {
+		t.Fatalf("one at a time, the run should stop after the second failure; ran %v", ran)
+	}
+	if !results[3].NotRun || !results[4].NotRun || results[2].NotRun || !results[2].failed() {
+		t.Fatalf("results %+v", results)
+	}
+	var buf bytes.Buffer
+	writeRunSummary(&buf, results)
+	if !strings.Contains(buf.String(), "5 hosts, 2 failed, 2 not run after too many failures\n") {
+		t.Fatalf("summary:\n%s", buf.String())
+	}
+}
+
+func TestRunSudo(t *testing.T) {
+	if got := sudoCommand("apt-get update && echo 'done'"); got != `sudo -k -S -p '' sh -c 'apt-get update && echo '\''done'\'''` {
+		t.Fatalf("sudoCommand = %s", got)
+	}
+
+	orig := runOnHost
+	defer func() { runOnHost = orig }()
+	runOnHost = func(h sshHost, job runJob) runResult {
+		// a command that echoes what it read from stdin
+		return runResult{Alias: h.Alias, Stdout: []byte("read " + job.SudoPassword + "\n"), Stderr: []byte(job.SudoPassword)}
+	}
+	results := collectRuns([]sshHost{{Alias: "web"}}, 1, 0, runJob{Command: "id", Timeout: time.Second, SudoPassword: "hunter2"})
+	if string(results[0].Stdout) != "read ********\n" || string(results[0].Stderr) != "********" {
+		t.Fatalf("the password should be masked: %q %q", results[0].Stdout, results[0].Stderr)
+	}
+}
+
+func TestRunStream(t *testing.T) {
+	var buf bytes.Buffer
+	s := newRunStream(&buf, []sshHost{{Alias: "web"}, {Alias: "db/primary"}}, "hunter2")
+	w := &lineWriter{stream: s, alias: "web"}
+	w.Write([]byte("up 3 "))
+	if buf.Len() != 0 {
+		t.Fatalf("a partial line should wait for its newline: %q", buf.String())
+	}
+	w.Write([]byte("days\npassword hunter2\nno newline"))
+	w.flush()
+	want := "web        | up 3 days\nweb        | password ********\nweb        | no newline\n"
+	if buf.String() != want {
+		t.Fatalf("stream %q, want %q", buf.String(), want)
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	origRun, origUpload := runOnHost, uploadScript
+	defer func() { runOnHost, uploadScript = origRun, origUpload }()
+	var mu sync.Mutex
+	uploaded := map[string]string{}
+	uploadScript = func(h sshHost, local, remote string, _ time.Duration) error {
+		if h.Alias == "ro" {
+			return errors.New("scp: /tmp: Read-only file system")
+		}
+		mu.Lock()
+		uploaded[h.Alias] = remote
+		mu.Unlock()
+		return nil
+	}
+	runOnHost = func(h sshHost, job runJob) runResult {
+		return runResult{Alias: h.Alias, Stdout: []byte(job.Command)}
+	}
+
+	results := collectRuns([]sshHost{{Alias: "web"}, {Alias: "ro"}}, 2, 0, runJob{Script: "/home/me/fix disk.sh", Args: []string{"--yes", "a b"}, Timeout: time.Second})
+	remote := uploaded["web"]
+	if !strings.HasPrefix(remote, "/tmp/sshpick-") || !strings.HasSuffix(remote, "-fix_disk.sh") {
+		t.Fatalf("remote path %q", remote)
+	}
+	want := "chmod 700 " + remote + " && " + remote + " --yes 'a b'; rc=$?; rm -f " + remote + "; exit $rc"
+	if string(results[0].Stdout) != want {
+		t.Fatalf("command %q, want %q", results[0].Stdout, want)
+	}
+	if results[1].Exit != -1 || !strings.Contains(results[1].Err, "upload: scp: /tmp: Read-only") {
+		t.Fatalf("a failed upload should fail the host: %+v", results[1])
+	}
+	if remoteScriptPath("x.sh") == remoteScriptPath("x.sh") {
+		t.Fatal("every run should get its own path")
+	}
+}
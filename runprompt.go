@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxPromptTail bounds the unfinished line kept per output.
+const maxPromptTail = 256
+
+// promptIdle is how long output must stall on an unfinished line before
+// sshpick run -interactive takes it for a prompt. A variable so tests
+// don't wait as long.
+var promptIdle = time.Second
+
+// promptEnding matches the end of a line waiting for input: "[y/N]",
+// "Continue?", "Password:" and the like.
+var promptEnding = regexp.MustCompile(`[:?\])>]\s*$`)
+
+var passwordPrompt = regexp.MustCompile(`(?i)pass(word|phrase)`)
+
+// promptWatch tracks the unfinished last line of one output of a host's
+// command, to notice it waiting for input.
+type promptWatch struct {
+	mu    sync.Mutex
+	tail  []byte
+	at    time.Time // of the last write
+	asked bool      // the current tail was already relayed
+}
+
+func (w *promptWatch) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if i := bytes.LastIndexByte(b, '\n'); i >= 0 {
+		w.tail = append(w.tail[:0], b[i+1:]...)
+	} else {
+		w.tail = append(w.tail, b...)
+	}
+	if len(w.tail) > maxPromptTail {
+		w.tail = w.tail[len(w.tail)-maxPromptTail:]
+	}
+	w.at, w.asked = time.Now(), false
+	return len(b), nil
+}
+
+// wroteSince reports whether output arrived after t.
+func (w *promptWatch) wroteSince(t time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.at.After(t)
+}
+
+// pending is the prompt the output has been stalled on for idle, once.
+func (w *promptWatch) pending(idle time.Duration, now time.Time) (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	prompt := strings.TrimSpace(string(w.tail))
+	if prompt == "" || w.asked || now.Sub(w.at) < idle || !promptEnding.MatchString(prompt) {
+		return "", false
+	}
+	w.asked = true
+	return prompt, true
+}
+
+// promptAsk is one host's command waiting at a prompt, shown on the run
+// screen until it's answered or the host moves on.
+type promptAsk struct {
+	id      int
+	alias   string
+	prompt  string
+	secret  bool                // a password prompt: masked, never answered for all hosts
+	answers chan<- promptAnswer // back to the host's relayPrompts
+}
+
+// promptAnswer is the user's answer to the ask with id.
+type promptAnswer struct {
+	id   int
+	text string
+}
+
+// reply sends the answer to the host, unless it's gone meanwhile.
+func (a promptAsk) reply(text string) {
+	select {
+	case a.answers <- promptAnswer{id: a.id, text: text}:
+	default:
+	}
+}
+
+// promptGoneMsg takes an ask off the run screen: its host finished, or
+// printed more, before anyone answered.
+type promptGoneMsg struct{ id int }
+
+// promptRelay passes the prompts hosts stall on to the run screen through
+// send (the program's Send), and remembers answers given for all hosts.
+type promptRelay struct {
+	mu   sync.Mutex
+	next int
+	all  map[string]string // prompt → answer for every host
+	send func(tea.Msg)
+}
+
+func newPromptRelay(send func(tea.Msg)) *promptRelay {
+	return &promptRelay{all: map[string]string{}, send: send}
+}
+
+// answerFor is the answer given for every host showing prompt, if any.
+func (p *promptRelay) answerFor(prompt string) (string, bool) {
+	if passwordPrompt.MatchString(prompt) {
+		return "", false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	answer, ok := p.all[prompt]
+	return answer, ok
+}
+
+// answerAll remembers answer for every host that shows prompt from now on.
+func (p *promptRelay) answerAll(prompt, answer string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.all[prompt] = answer
+}
+
+func (p *promptRelay) newAsk(alias, prompt string, answers chan<- promptAnswer) promptAsk {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next++
+	return promptAsk{id: p.next, alias: alias, prompt: prompt, secret: passwordPrompt.MatchString(prompt), answers: answers}
+}
+
+// relayPrompts watches a running command's outputs and writes the user's
+// answers to its stdin until done is closed. Nothing here waits for the
+// user: an ask stays on the run screen until answered, and is taken off
+// again when the host finishes or prints more first.
+func relayPrompts(relay *promptRelay, alias string, stdin io.Writer, done <-chan struct{}, watches ...*promptWatch) {
+	tick := time.NewTicker(promptIdle / 4)
+	defer tick.Stop()
+	answers := make(chan promptAnswer, len(watches))
+	pending := make([]*promptAsk, len(watches))
+	since := make([]time.Time, len(watches))
+	drop := func(i int) {
+		relay.send(promptGoneMsg{id: pending[i].id})
+		pending[i] = nil
+	}
+	for {
+		select {
+		case <-done:
+			for i := range pending {
+				if pending[i] != nil {
+					drop(i)
+				}
+			}
+			return
+		case a := <-answers:
+			for i := range pending {
+				if pending[i] != nil && pending[i].id == a.id {
+					pending[i] = nil
+					io.WriteString(stdin, a.text+"\n")
+				}
+			}
+		case now := <-tick.C:
+			for i, w := range watches {
+				if pending[i] != nil && w.wroteSince(since[i]) {
+					drop(i)
+				}
+				prompt, ok := w.pending(promptIdle, now)
+				if !ok {
+					continue
+				}
+				if answer, ok := relay.answerFor(prompt); ok {
+					io.WriteString(stdin, answer+"\n")
+					continue
+				}
+				ask := relay.newAsk(alias, prompt, answers)
+				pending[i], since[i] = &ask, now
+				relay.send(ask)
+			}
+		}
+	}
+}
+
+// runFinishedMsg ends the run screen: every host is done.
+type runFinishedMsg struct{}
+
+// runScreen is `sshpick run -interactive`: output lines scroll above it
+// (printed through the program), and below it the prompts hosts wait at,
+// answered one host at a time or for every host showing the same prompt.
+type runScreen struct {
+	relay  *promptRelay
+	hosts  int
+	asks   []promptAsk
+	cursor int
+	input  string
+	// stop cancels the run on Ctrl+C; the screen stays up while the hosts
+	// wind down, and a second Ctrl+C leaves at once.
+	stop        func()
+	interrupted bool
+	abandoned   bool
+	styles      styles
+}
+
+func newRunScreen(relay *promptRelay, hosts int, stop func()) runScreen {
+	return runScreen{relay: relay, hosts: hosts, stop: stop, styles: defaultStyles()}
+}
+
+func (m runScreen) Init() tea.Cmd { return nil }
+
+func (m runScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case runFinishedMsg:
+		return m, tea.Quit
+	case promptAsk:
+		// answered for all hosts while this one was on its way
+		if answer, ok := m.relay.answerFor(msg.prompt); ok {
+			msg.reply(answer)
+			return m, nil
+		}
+		m.asks = append(m.asks, msg)
+	case promptGoneMsg:
+		for i, a := range m.asks {
+			if a.id == msg.id {
+				m.remove(i)
+				break
+			}
+		}
+	case tea.KeyMsg:
+		return m.updateKey(msg)
+	}
+	return m, nil
+}
+
+// remove takes ask i off the screen.
+func (m *runScreen) remove(i int) {
+	m.asks = append(m.asks[:i:i], m.asks[i+1:]...)
+	if m.cursor >= len(m.asks) {
+		m.cursor = max(len(m.asks)-1, 0)
+	}
+}
+
+func (m runScreen) updateKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		if m.interrupted {
+			m.abandoned = true
+			return m, tea.Quit
+		}
+		m.interrupted = true
+		m.stop()
+		return m, nil
+	}
+	if len(m.asks) == 0 || m.interrupted {
+		return m, nil
+	}
+	switch msg.String() {
+	case "up", "shift+tab":
+		m.cursor = (m.cursor - 1 + len(m.asks)) % len(m.asks)
+	case "down", "tab":
+		m.cursor = (m.cursor + 1) % len(m.asks)
+	case "enter":
+		m.asks[m.cursor].reply(m.input)
+		m.remove(m.cursor)
+		m.input = ""
+	case "ctrl+a":
+		ask := m.asks[m.cursor]
+		if ask.secret {
+			// passwords are answered host by host
+			m.asks[m.cursor].reply(m.input)
+			m.remove(m.cursor)
+			m.input = ""
+			break
+		}
+		m.relay.answerAll(ask.prompt, m.input)
+		for i := len(m.asks) - 1; i >= 0; i-- {
+			if m.asks[i].prompt == ask.prompt {
+				m.asks[i].reply(m.input)
+				m.remove(i)
+			}
+		}
+		m.input = ""
+	case "ctrl+u":
+		m.input = ""
+	case "backspace":
+		if m.input != "" {
+			_, n := utf8.DecodeLastRuneInString(m.input)
+			m.input = m.input[:len(m.input)-n]
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			m.input += string(msg.Runes)
+		}
+	}
+	return m, nil
+}
+
+func (m runScreen) View() string {
+	var b strings.Builder
+	if m.interrupted {
+		fmt.Fprintln(&b, m.styles.help.Render("Stopping the run… Ctrl+C again to leave now."))
+		return b.String()
+	}
+	if len(m.asks) == 0 {
+		fmt.Fprintln(&b, m.styles.help.Render(fmt.Sprintf("Running on %d hosts; prompts show up here. Ctrl+C stops the run.", m.hosts)))
+		return b.String()
+	}
+	fmt.Fprintln(&b, m.styles.title.Render(fmt.Sprintf("Waiting for input (%d)", len(m.asks))))
+	for i, a := range m.asks {
+		line := fmt.Sprintf("%-20s %s", a.alias, a.prompt)
+		if i == m.cursor {
+			fmt.Fprintln(&b, m.styles.selected.Render("> "+line))
+		} else {
+			fmt.Fprintln(&b, m.styles.item.Render("  "+line))
+		}
+	}
+	input := m.input
+	if m.asks[m.cursor].secret {
+		input = strings.Repeat("*", utf8.RuneCountInString(input))
+	}
+	fmt.Fprintln(&b, "answer: ["+input+"▏]")
+	fmt.Fprintln(&b, m.styles.help.Render(tr("help.runprompt")))
+	return b.String()
+}
+
+// printWriter prints what the run streams above the run screen, a line
+// per Println so it doesn't tear the screen. The screen stays up until
+// every host is done, so nothing is printed after it's gone.
+type printWriter struct{ p *tea.Program }
+
+func (w printWriter) Write(b []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		w.p.Println(line)
+	}
+	return len(b), nil
+}
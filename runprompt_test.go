@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPromptWatch(t *testing.T) {
+	t.Parallel()
+
+	var w promptWatch
+	w.Write([]byte("Reading package lists...\nDo you want to continue? [Y/n] "))
+	start := w.at
+	if _, ok := w.pending(time.Second, start.Add(500*time.Millisecond)); ok {
+		t.Fatal("output that just arrived is no prompt yet")
+	}
+	prompt, ok := w.pending(time.Second, start.Add(2*time.Second))
+	if !ok || prompt != "Do you want to continue? [Y/n]" {
+		t.Fatalf("pending = %q %v", prompt, ok)
+	}
+	if _, ok := w.pending(time.Second, start.Add(3*time.Second)); ok {
+		t.Fatal("a prompt should be relayed once")
+	}
+
+	w.Write([]byte("\n50% done"))
+	if _, ok := w.pending(time.Second, w.at.Add(2*time.Second)); ok {
+		t.Fatal("a stalled progress line is no prompt")
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe to write from relayPrompts.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestRelayPrompts(t *testing.T) {
+	idle := promptIdle
+	defer func() { promptIdle = idle }()
+	promptIdle = 20 * time.Millisecond
+
+	msgs := make(chan tea.Msg, 8)
+	relay := newPromptRelay(func(msg tea.Msg) { msgs <- msg })
+	next := func() tea.Msg {
+		select {
+		case msg := <-msgs:
+			return msg
+		case <-time.After(2 * time.Second):
+			t.Fatal("nothing sent to the run screen")
+			return nil
+		}
+	}
+	var stdin syncBuffer
+	var w promptWatch
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		relayPrompts(relay, "web1", &stdin, done, &w)
+		close(finished)
+	}()
+
+	// answered on the run screen
+	w.Write([]byte("Continue? [y/N] "))
+	ask, ok := next().(promptAsk)
+	if !ok || ask.alias != "web1" || ask.prompt != "Continue? [y/N]" {
+		t.Fatalf("ask %+v", ask)
+	}
+	ask.reply("y")
+	for deadline := time.Now().Add(2 * time.Second); stdin.String() != "y\n"; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("stdin %q", stdin.String())
+		}
+	}
+
+	// the host answers itself (more output): the ask is withdrawn
+	w.Write([]byte("\nRestart? [y/N] "))
+	ask = next().(promptAsk)
+	w.Write([]byte("auto-restarting\n"))
+	if gone, ok := next().(promptGoneMsg); !ok || gone.id != ask.id {
+		t.Fatalf("want the stale ask %d withdrawn, got %#v", ask.id, gone)
+	}
+
+	// the host exits with a prompt still pending
+	w.Write([]byte("Really? [y/N] "))
+	ask = next().(promptAsk)
+	close(done)
+	if gone, ok := next().(promptGoneMsg); !ok || gone.id != ask.id {
+		t.Fatalf("want the pending ask %d withdrawn, got %#v", ask.id, gone)
+	}
+	<-finished
+	ask.reply("late") // must not block or reach the exited host
+	if stdin.String() != "y\n" {
+		t.Fatalf("stdin %q", stdin.String())
+	}
+}
+
+func TestRunScreen(t *testing.T) {
+	t.Parallel()
+
+	relay := newPromptRelay(func(tea.Msg) {})
+	stopped := false
+	var m tea.Model = newRunScreen(relay, 3, func() { stopped = true })
+	answers := make(chan promptAnswer, 8)
+	for _, a := range []struct{ alias, prompt string }{
+		{"web1", "Continue? [y/N]"},
+		{"web2", "Continue? [y/N]"},
+		{"web3", "Continue? [y/N]"},
+		{"db1", "[sudo] password for ops:"},
+	} {
+		m, _ = m.Update(relay.newAsk(a.alias, a.prompt, answers))
+	}
+	keys := func(ks ...tea.KeyMsg) {
+		for _, k := range ks {
+			m, _ = m.Update(k)
+		}
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	// web1 only
+	keys(runes("n"), tea.KeyMsg{Type: tea.KeyEnter})
+	if a := <-answers; a.text != "n" || len(m.(runScreen).asks) != 3 {
+		t.Fatalf("answer %+v, asks %+v", a, m.(runScreen).asks)
+	}
+	// every host asking the same, including ones asking later
+	keys(runes("y"), tea.KeyMsg{Type: tea.KeyCtrlA})
+	if a, b := <-answers, <-answers; a.text != "y" || b.text != "y" {
+		t.Fatalf("answers %+v %+v", a, b)
+	}
+	if got, ok := relay.answerFor("Continue? [y/N]"); !ok || got != "y" {
+		t.Fatalf("answer for all %q %v", got, ok)
+	}
+	// the password is masked and answered for db1 alone
+	keys(runes("hunter2"))
+	if v := m.View(); !strings.Contains(v, "*******") || strings.Contains(v, "hunter2") {
+		t.Fatalf("password shown:\n%s", v)
+	}
+	keys(tea.KeyMsg{Type: tea.KeyCtrlA})
+	if a := <-answers; a.text != "hunter2" || len(m.(runScreen).asks) != 0 {
+		t.Fatalf("answer %+v", a)
+	}
+	if _, ok := relay.answerFor("[sudo] password for ops:"); ok {
+		t.Fatal("a password must not be reused for other hosts")
+	}
+
+	// a withdrawn ask leaves the screen
+	ask := relay.newAsk("web4", "Proceed? [y/N]", answers)
+	m, _ = m.Update(ask)
+	m, _ = m.Update(promptGoneMsg{id: ask.id})
+	if len(m.(runScreen).asks) != 0 {
+		t.Fatalf("asks %+v", m.(runScreen).asks)
+	}
+
+	keys(tea.KeyMsg{Type: tea.KeyCtrlC})
+	if !stopped || !m.(runScreen).interrupted {
+		t.Fatal("Ctrl+C should stop the run")
+	}
+}
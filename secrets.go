@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Secret values in the settings file may be references instead of plaintext:
+//
+//	keychain:<service>[/<account>]  macOS Keychain, the freedesktop secret service
+//	                                or the Windows Credential Manager
+//	age:<path>                      a file encrypted with age
+//	age:-----BEGIN AGE ENCRYPTED FILE-----...  inline armored age ciphertext
+//
+// Anything else is used as is.
+
+// runSecretTool runs a helper binary and returns its trimmed stdout. It's a
+// variable so tests can stand in for age and the keychain tools.
+var runSecretTool = func(name string, args []string, stdin string) (string, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// resolveSecret decrypts or looks up a secret reference.
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "keychain:"):
+		return keychainSecret(strings.TrimPrefix(value, "keychain:"))
+	case strings.HasPrefix(value, "age:"):
+		return ageSecret(strings.TrimPrefix(value, "age:"))
+	default:
+		return value, nil
+	}
+}
+
+// keychainOS picks the keychain tools; a variable so tests can build every
+// platform's commands.
+var keychainOS = runtime.GOOS
+
+// credReadScript prints the password of a Windows generic credential,
+// through CredReadW since cmdkey can store but not show one. The offsets
+// are those of CREDENTIALW's CredentialBlobSize and CredentialBlob.
+const credReadScript = `$ErrorActionPreference = 'Stop'
+Add-Type -Namespace SSHPick -Name Cred -MemberDefinition '
+[DllImport("advapi32.dll", CharSet = CharSet.Unicode, SetLastError = true)]
+public static extern bool CredReadW(string target, int type, int flags, out IntPtr cred);
+[DllImport("advapi32.dll")]
+public static extern void CredFree(IntPtr cred);'
+$p = [IntPtr]::Zero
+if (-not [SSHPick.Cred]::CredReadW($args[0], 1, 0, [ref]$p)) { throw "no credential named $($args[0])" }
+$off = 8 + 2 * [IntPtr]::Size + 8
+$size = [Runtime.InteropServices.Marshal]::ReadInt32($p, $off)
+$blob = [Runtime.InteropServices.Marshal]::ReadIntPtr($p, $off + [IntPtr]::Size)
+[Console]::Out.Write([Runtime.InteropServices.Marshal]::PtrToStringUni($blob, $size / 2))
+[SSHPick.Cred]::CredFree($p)`
+
+func keychainSecret(ref string) (string, error) {
+	service, account, _ := strings.Cut(ref, "/")
+	if service == "" {
+		return "", errors.New("keychain reference needs a service name")
+	}
+	if keychainOS == "windows" {
+		// The credential's target name is the reference itself, as
+		// sshpick auth stores it.
+		script := "& {" + credReadScript + "} " + powershellQuote(ref)
+		return runSecretTool("powershell", []string{"-NoProfile", "-NonInteractive", "-Command", script}, "")
+	}
+	if keychainOS == "darwin" {
+		args := []string{"find-generic-password", "-s", service, "-w"}
+		if account != "" {
+			args = append(args, "-a", account)
+		}
+		return runSecretTool("security", args, "")
+	}
+	args := []string{"lookup", "service", service}
+	if account != "" {
+		args = append(args, "account", account)
+	}
+	return runSecretTool("secret-tool", args, "")
+}
+
+// powershellQuote is s as a single-quoted PowerShell string.
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func ageSecret(ref string) (string, error) {
+	identity := ageIdentityPath()
+	if identity == "" {
+		return "", errors.New("no age identity: set SSHPICK_AGE_IDENTITY")
+	}
+	args := []string{"--decrypt", "-i", identity}
+	if strings.HasPrefix(strings.TrimSpace(ref), "-----BEGIN AGE ENCRYPTED FILE-----") {
+		return runSecretTool("age", args, ref)
+	}
+	return runSecretTool("age", append(args, expandHome(ref)), "")
+}
+
+// ageIdentityPath is $SSHPICK_AGE_IDENTITY, else age.key in the config
+// directory, else the user's ed25519 ssh key (age accepts ssh keys).
+func ageIdentityPath() string {
+	if p := os.Getenv("SSHPICK_AGE_IDENTITY"); p != "" {
+		return expandHome(p)
+	}
+	if dir := configDir(); dir != "" {
+		p := filepath.Join(dir, "age.key")
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	if dir := defaultSSHDir(); dir != "" {
+		p := filepath.Join(dir, "id_ed25519")
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
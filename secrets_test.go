@@ -0,0 +1,55 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("SSHPICK_AGE_IDENTITY", "/keys/age.txt")
+
+	var calls []string
+	orig := runSecretTool
+	defer func() { runSecretTool = orig }()
+	runSecretTool = func(name string, args []string, stdin string) (string, error) {
+		calls = append(calls, name+" "+strings.Join(args, " ")+"|"+stdin)
+		return "s3cret", nil
+	}
+
+	for _, value := range []string{"plain-token", "keychain:sshpick/inventory", "age:/etc/sshpick/token.age", "age:-----BEGIN AGE ENCRYPTED FILE-----\nYWdl\n-----END AGE ENCRYPTED FILE-----"} {
+		got, err := resolveSecret(value)
+		if err != nil {
+			t.Fatalf("resolveSecret(%q): %v", value, err)
+		}
+		want := "s3cret"
+		if value == "plain-token" {
+			want = value
+		}
+		if got != want {
+			t.Fatalf("resolveSecret(%q) = %q, want %q", value, got, want)
+		}
+	}
+
+	keychain := "secret-tool lookup service sshpick account inventory|"
+	if runtime.GOOS == "darwin" {
+		keychain = "security find-generic-password -s sshpick -w -a inventory|"
+	}
+	want := []string{
+		keychain,
+		"age --decrypt -i /keys/age.txt /etc/sshpick/token.age|",
+		"age --decrypt -i /keys/age.txt|-----BEGIN AGE ENCRYPTED FILE-----\nYWdl\n-----END AGE ENCRYPTED FILE-----",
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %q", calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("call %d = %q, want %q", i, calls[i], want[i])
+		}
+	}
+
+	if _, err := resolveSecret("keychain:"); err == nil {
+		t.Fatalf("expected an error for an empty keychain reference")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// skProbeArgs keep ssh from offering FIDO2 keys: every attempt makes the
+// key blink until touched, which background probes must never cause.
+// PubkeyAcceptedAlgorithms needs OpenSSH 8.5; older clients reject the
+// option and the probe fails instead of blinking.
+var skProbeArgs = []string{"-o", "PubkeyAcceptedAlgorithms=-sk-*"}
+
+// securityKeyCache remembers isSecurityKey per path, and defaultKeyCache
+// the default keys present per ssh directory: rows are drawn every frame
+// and keys don't change while sshpick runs.
+var securityKeyCache, defaultKeyCache sync.Map
+
+// isSecurityKey reports whether the identity at path is a FIDO2 key
+// (sk-ssh-ed25519, sk-ecdsa-sha2-nistp256). The public key says so;
+// without one the ssh-keygen default names (id_ed25519_sk) do.
+func isSecurityKey(path string) bool {
+	if v, ok := securityKeyCache.Load(path); ok {
+		return v.(bool)
+	}
+	sk := strings.HasSuffix(strings.TrimSuffix(filepath.Base(path), ".pub"), "_sk")
+	if data, err := os.ReadFile(strings.TrimSuffix(path, ".pub") + ".pub"); err == nil {
+		fields := strings.Fields(string(data))
+		sk = len(fields) > 0 && strings.HasPrefix(fields[0], "sk-")
+	}
+	securityKeyCache.Store(path, sk)
+	return sk
+}
+
+// hostIdentities are the identity files ssh offers h: its effective
+// IdentityFiles, else the default keys that exist.
+func hostIdentities(h sshHost) []string {
+	var ids []string
+	for _, o := range h.Origins {
+		if o.Key == "identityfile" {
+			ids = append(ids, expandHome(strings.Trim(o.Value, `"`)))
+		}
+	}
+	if len(ids) == 0 {
+		ids = h.IdentityFiles
+	}
+	if len(ids) > 0 {
+		return ids
+	}
+	dir := defaultSSHDir()
+	if dir == "" {
+		return nil
+	}
+	if v, ok := defaultKeyCache.Load(dir); ok {
+		return v.([]string)
+	}
+	for _, name := range defaultIdentities {
+		if path := filepath.Join(dir, name); fileExists(path) {
+			ids = append(ids, path)
+		}
+	}
+	defaultKeyCache.Store(dir, ids)
+	return ids
+}
+
+// securityKeys lists h's identities that are FIDO2 keys.
+func securityKeys(h sshHost) []string {
+	var out []string
+	for _, id := range hostIdentities(h) {
+		if !strings.Contains(id, "%") && isSecurityKey(id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// needsTouch reports whether connecting to h may wait for a security key
+// touch.
+func needsTouch(h sshHost) bool {
+	return len(securityKeys(h)) > 0
+}
+
+// probeKeyArgs are the extra ssh options for a background session to h.
+func probeKeyArgs(h sshHost) []string {
+	if needsTouch(h) {
+		return skProbeArgs
+	}
+	return nil
+}
+
+// securityKeySummary is the detail pane's security key line.
+func securityKeySummary(h sshHost) string {
+	keys := securityKeys(h)
+	if len(keys) == 0 {
+		return ""
+	}
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = filepath.Base(k)
+	}
+	return strings.Join(names, ", ") + " (touch required; probes don't use it)"
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecurityKeys(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o600)
+		return path
+	}
+	yubi := write("yubikey", "private")
+	write("yubikey.pub", "sk-ssh-ed25519@openssh.com AAAAGnNrLXNzaC1lZDI1NTE5QG9wZW5zc2guY29t me@laptop\n")
+	plain := write("work", "private")
+	write("work.pub", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAAA me@laptop\n")
+	named := write("id_ecdsa_sk", "private") // no .pub: the name tells
+
+	h := sshHost{Alias: "prod", Origins: []optionOrigin{{Key: "identityfile", Value: yubi}, {Key: "identityfile", Value: plain}}}
+	if got := securityKeys(h); len(got) != 1 || got[0] != yubi {
+		t.Fatalf("security keys %v", got)
+	}
+	if !strings.Contains(securityKeySummary(h), "yubikey (touch required") {
+		t.Fatalf("summary %q", securityKeySummary(h))
+	}
+	if got := strings.Join(probeKeyArgs(h), " "); got != "-o PubkeyAcceptedAlgorithms=-sk-*" {
+		t.Fatalf("probe args %q", got)
+	}
+	if !isSecurityKey(named) {
+		t.Fatal("id_ecdsa_sk without a public key")
+	}
+
+	// only background probes leave the key out; what the user starts keeps it
+	if got := strings.Join(remoteArgs(h, "true", true), " "); !strings.Contains(got, "PubkeyAcceptedAlgorithms=-sk-*") {
+		t.Fatalf("probe %q", got)
+	}
+	for _, args := range [][]string{remoteArgs(h, "true", false), copyIDArgs(h, yubi+".pub")} {
+		if got := strings.Join(args, " "); strings.Contains(got, "PubkeyAcceptedAlgorithms") {
+			t.Fatalf("user command without the security key: %q", got)
+		}
+	}
+
+	lab := sshHost{Alias: "lab", IdentityFiles: []string{plain}}
+	if needsTouch(lab) || probeKeyArgs(lab) != nil {
+		t.Fatal("plain keys need no touch")
+	}
+}
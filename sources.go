@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sourceCount is one row of the source toggle panel.
+type sourceCount struct {
+	Name  string
+	Count int
+}
+
+// hostSource returns the source name used for grouping; hosts without an
+// explicit source come from the ssh config.
+func hostSource(h sshHost) string {
+	if h.Source == "" {
+		return "config"
+	}
+	return h.Source
+}
+
+// sourceCounts lists every source in order of first appearance with its host count.
+func sourceCounts(hosts []sshHost) []sourceCount {
+	var out []sourceCount
+	idx := map[string]int{}
+	for _, h := range hosts {
+		name := hostSource(h)
+		if i, ok := idx[name]; ok {
+			out[i].Count++
+			continue
+		}
+		idx[name] = len(out)
+		out = append(out, sourceCount{Name: name, Count: 1})
+	}
+	return out
+}
+
+// visibleSources narrows sourceCounts to the panel's search query.
+func (m model) visibleSources() []sourceCount {
+	all := sourceCounts(m.allHosts)
+	q := strings.ToLower(strings.TrimSpace(m.sourceQuery))
+	if q == "" {
+		return all
+	}
+	out := make([]sourceCount, 0, len(all))
+	for _, s := range all {
+		if strings.Contains(strings.ToLower(s.Name), q) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// hostsInShownSources drops hosts whose source was hidden in the panel.
+func (m model) hostsInShownSources() []sshHost {
+	if len(m.hiddenSources) == 0 {
+		return m.allHosts
+	}
+	out := make([]sshHost, 0, len(m.allHosts))
+	for _, h := range m.allHosts {
+		if !m.hiddenSources[hostSource(h)] {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+func (m model) updateSourcePanel(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	sources := m.visibleSources()
+	if m.sourceSearch {
+		switch msg.String() {
+		case "enter", "esc":
+			m.sourceSearch = false
+			if msg.String() == "esc" {
+				m.sourceQuery = ""
+			}
+		case "ctrl+c":
+			return m, tea.Quit
+		case "backspace":
+			if m.sourceQuery != "" {
+				_, n := utf8.DecodeLastRuneInString(m.sourceQuery)
+				m.sourceQuery = m.sourceQuery[:len(m.sourceQuery)-n]
+			}
+		default:
+			if msg.Type == tea.KeyRunes && len(m.sourceQuery) < 64 {
+				m.sourceQuery += string(msg.Runes)
+			}
+		}
+		m.sourceCursor = 0
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "p", "q":
+		m.sourcePanel = false
+		m.sourceQuery = ""
+	case "j", "down":
+		if len(sources) > 0 {
+			m.sourceCursor = (m.sourceCursor + 1) % len(sources)
+		}
+	case "k", "up":
+		if len(sources) > 0 {
+			m.sourceCursor = (m.sourceCursor - 1 + len(sources)) % len(sources)
+		}
+	case "/":
+		m.sourceSearch = true
+	case " ", "enter", "x":
+		if m.sourceCursor < len(sources) {
+			name := sources[m.sourceCursor].Name
+			if m.hiddenSources == nil {
+				m.hiddenSources = map[string]bool{}
+			}
+			if m.hiddenSources[name] {
+				delete(m.hiddenSources, name)
+			} else {
+				m.hiddenSources[name] = true
+			}
+			m.applyFilter(m.lastValidRegex)
+		}
+	}
+	return m, nil
+}
+
+func (m model) renderSourcePanel(b *strings.Builder) {
+	fmt.Fprintln(b, m.styles.title.Render("Host sources"))
+	fmt.Fprintln(b, m.styles.help.Render(tr("help.sources")))
+	if m.sourceSearch || m.sourceQuery != "" {
+		fmt.Fprintln(b, m.styles.help.Render("/ "+m.sourceQuery))
+	}
+	fmt.Fprintln(b, "")
+
+	sources := m.visibleSources()
+	if len(sources) == 0 {
+		fmt.Fprintln(b, m.styles.error.Render("No sources match"))
+		return
+	}
+	for i, s := range sources {
+		box := "[x]"
+		if m.hiddenSources[s.Name] {
+			box = "[ ]"
+		}
+		line := fmt.Sprintf("%s %-15s %d hosts", box, s.Name, s.Count)
+		if i == m.sourceCursor {
+			fmt.Fprintln(b, m.styles.selected.Render("> "+line))
+		} else {
+			fmt.Fprintln(b, m.styles.item.Render("  "+line))
+		}
+	}
+}
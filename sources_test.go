@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSourceCounts(t *testing.T) {
+	t.Parallel()
+
+	got := sourceCounts([]sshHost{{Alias: "a"}, {Alias: "b", Source: "prometheus"}, {Alias: "c"}})
+	want := []sourceCount{{Name: "config", Count: 2}, {Name: "prometheus", Count: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestSourcePanelHidesSource(t *testing.T) {
+	t.Parallel()
+
+	keys := func(m model, ks ...string) model {
+		for _, k := range ks {
+			msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+			switch k {
+			case "esc":
+				msg = tea.KeyMsg{Type: tea.KeyEsc}
+			case "enter":
+				msg = tea.KeyMsg{Type: tea.KeyEnter}
+			}
+			next, _ := m.Update(msg)
+			m = next.(model)
+		}
+		return m
+	}
+	aliases := func(m model) []string {
+		var out []string
+		for _, h := range m.hosts {
+			out = append(out, h.Alias)
+		}
+		return out
+	}
+
+	m := initialModel([]sshHost{{Alias: "web1"}, {Alias: "node1", Source: "prometheus"}, {Alias: "vm1", Source: "aws"}}, "", "")
+	m = keys(m, "p", "j", "x")
+	if !m.sourcePanel || !m.hiddenSources["prometheus"] {
+		t.Fatalf("panel %v, hidden %v", m.sourcePanel, m.hiddenSources)
+	}
+	if got := aliases(m); !reflect.DeepEqual(got, []string{"web1", "vm1"}) {
+		t.Fatalf("shown %v", got)
+	}
+
+	// Searching narrows the panel, and the cursor toggles within the matches.
+	m = keys(m, "/", "a", "w", "enter", "x")
+	if got := m.visibleSources(); len(got) != 1 || got[0].Name != "aws" || !m.hiddenSources["aws"] {
+		t.Fatalf("visible %+v, hidden %v", got, m.hiddenSources)
+	}
+	if got := aliases(m); !reflect.DeepEqual(got, []string{"web1"}) {
+		t.Fatalf("shown %v", got)
+	}
+
+	m = keys(m, "x", "esc")
+	if m.sourcePanel || m.sourceQuery != "" || m.hiddenSources["aws"] {
+		t.Fatalf("panel %v, query %q, hidden %v", m.sourcePanel, m.sourceQuery, m.hiddenSources)
+	}
+	if got := aliases(m); !reflect.DeepEqual(got, []string{"web1", "vm1"}) {
+		t.Fatalf("shown %v", got)
+	}
+}
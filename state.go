@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// uiState is what sshpick remembers between runs.
+type uiState struct {
+	CursorAlias   string              `json:"cursor_alias,omitempty"`
+	Filter        string              `json:"filter,omitempty"`
+	ShowNotes     bool                `json:"show_notes,omitempty"`
+	ShowDetail    bool                `json:"show_detail,omitempty"`
+	HiddenSources []string            `json:"hidden_sources,omitempty"`
+	FilterHistory []string            `json:"filter_history,omitempty"`
+	FilterFields  string              `json:"filter_fields,omitempty"`
+	FilterMode    string              `json:"filter_mode,omitempty"` // "fuzzy" or "regex"; filters saved before fuzzy are regexes
+	FlipForwards  bool                `json:"flip_forwards,omitempty"`
+	Order         string              `json:"order,omitempty"` // "recent" or "frequent"; empty is config order
+	Group         string              `json:"group,omitempty"` // "source" or "tag"; empty is ungrouped
+	Compact       bool                `json:"compact,omitempty"`
+	Views         map[string]viewSpec `json:"views,omitempty"` // saved views from the v menu, by name
+}
+
+// stateDir is $XDG_STATE_HOME/sshpick, falling back to ~/.local/state/sshpick.
+func stateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "sshpick")
+	}
+	home := homeDir()
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".local", "state", "sshpick")
+}
+
+func statePath() string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "state.json")
+}
+
+// loadState reads the state file; a missing or corrupt file yields an empty state.
+func loadState(path string) uiState {
+	var st uiState
+	if path == "" {
+		return st
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return st
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return uiState{}
+	}
+	return st
+}
+
+// saveState writes the state file atomically (temp file + rename).
+func saveState(path string, st uiState) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".state-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// snapshotState captures the parts of the model worth restoring next time.
+func (m model) snapshotState() uiState {
+	st := uiState{
+		Filter:        m.lastValidRegex,
+		ShowNotes:     m.showNotes,
+		ShowDetail:    m.showDetail,
+		FilterHistory: m.filterHistory,
+		FilterFields:  m.filterScope.String(),
+		FilterMode:    m.filterMode(),
+		FlipForwards:  m.flipForwards,
+		Compact:       m.compact,
+		Views:         m.views,
+	}
+	if m.order != orderConfig {
+		st.Order = m.order.String()
+	}
+	if m.grouping != groupNone {
+		st.Group = m.grouping.String()
+	}
+	if m.cursor < len(m.hosts) {
+		st.CursorAlias = m.hosts[m.cursor].Alias
+	}
+	for name, hidden := range m.hiddenSources {
+		if hidden {
+			st.HiddenSources = append(st.HiddenSources, name)
+		}
+	}
+	sort.Strings(st.HiddenSources)
+	return st
+}
+
+// restoreState applies a saved state. An invalid saved filter is dropped
+// rather than leaving the list empty.
+func (m *model) restoreState(st uiState) {
+	m.showNotes = st.ShowNotes
+	m.showDetail = st.ShowDetail
+	m.flipForwards = st.FlipForwards
+	m.compact = st.Compact
+	m.views = st.Views
+	m.order = parseHostOrder(st.Order)
+	m.grouping = parseHostGrouping(st.Group)
+	m.filterRegex = st.FilterMode == "regex" || (st.FilterMode == "" && st.Filter != "")
+	if scope, err := parseFilterScope(st.FilterFields); err == nil {
+		m.filterScope = scope
+	}
+	for i := len(st.FilterHistory) - 1; i >= 0; i-- {
+		m.rememberFilter(st.FilterHistory[i])
+	}
+	for _, name := range st.HiddenSources {
+		if m.hiddenSources == nil {
+			m.hiddenSources = map[string]bool{}
+		}
+		m.hiddenSources[name] = true
+	}
+	m.applyFilter(st.Filter)
+	if m.filterErr != nil {
+		m.filterErr = nil
+		m.applyFilter("")
+	} else {
+		m.lastValidRegex = st.Filter
+		m.filterQuery = st.Filter
+	}
+	for i, h := range m.hosts {
+		if h.Alias == st.CursorAlias {
+			m.cursor = i
+			break
+		}
+	}
+}
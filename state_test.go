@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	hosts := []sshHost{{Alias: "prod"}, {Alias: "stage"}, {Alias: "db"}}
+
+	m := initialModel(hosts, "", "")
+	m.filterRegex = true
+	m.applyFilter("^(stage|db)$")
+	m.lastValidRegex = "^(stage|db)$"
+	m.cursor = 1
+	m.showNotes = true
+	m.compact = true
+	if err := saveState(path, m.snapshotState()); err != nil {
+		t.Fatalf("saveState: %v", err)
+	}
+
+	restored := initialModel(hosts, "", "")
+	restored.restoreState(loadState(path))
+	if restored.lastValidRegex != "^(stage|db)$" || len(restored.hosts) != 2 {
+		t.Fatalf("filter not restored: %q %#v", restored.lastValidRegex, restored.hosts)
+	}
+	if got := restored.hosts[restored.cursor].Alias; got != "db" {
+		t.Fatalf("cursor: expected db, got %s", got)
+	}
+	if !restored.showNotes || !restored.compact {
+		t.Fatalf("showNotes/compact not restored")
+	}
+
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if st := loadState(path); st.Filter != "" || st.CursorAlias != "" {
+		t.Fatalf("corrupt state should load empty, got %#v", st)
+	}
+
+	bad := initialModel(hosts, "", "")
+	bad.restoreState(uiState{Filter: "("})
+	if bad.lastValidRegex != "" || len(bad.hosts) != 3 || bad.filterErr != nil {
+		t.Fatalf("invalid saved filter should be dropped")
+	}
+}
+
+func TestCompactRows(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel([]sshHost{{Alias: "web", Hostname: "web.example.com", User: "deploy"}}, "", "")
+	m.ready = true
+	if !strings.Contains(m.View(), "Hostname: web.example.com") {
+		t.Fatalf("rows start detailed:\n%s", m.View())
+	}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("C")})
+	m = next.(model)
+	if view := m.View(); !strings.Contains(view, "> web") || strings.Contains(view, "web.example.com") || strings.Contains(view, "User:") {
+		t.Fatalf("C should show the alias only:\n%s", view)
+	}
+}
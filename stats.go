@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	statsTimeout     = 10 * time.Second
+	statsConcurrency = 8
+)
+
+// statsCommand prints the 1-minute load, CPU count and root filesystem usage.
+// It falls back to uptime where /proc is missing (macOS, BSD).
+const statsCommand = "cat /proc/loadavg 2>/dev/null || uptime; getconf _NPROCESSORS_ONLN 2>/dev/null || echo 0; df -P / | tail -n 1"
+
+// hostStats is a quick health snapshot of a remote host.
+type hostStats struct {
+	Load1   float64
+	CPUs    int
+	DiskPct int
+	Err     error
+}
+
+type statsMsg struct {
+	alias string
+	stats hostStats
+}
+
+// statsSlots bounds how many stats ssh sessions run at once.
+var statsSlots = make(chan struct{}, statsConcurrency)
+
+// fetchStatsCmd runs the stats command on h over a non-interactive ssh.
+func fetchStatsCmd(h sshHost) tea.Cmd {
+	return func() tea.Msg {
+		statsSlots <- struct{}{}
+		defer func() { <-statsSlots }()
+
+		out, err := runProbe(h, statsCommand, statsTimeout)
+		if err != nil {
+			return statsMsg{alias: h.Alias, stats: hostStats{Err: err}}
+		}
+		st, err := parseStats(out)
+		st.Err = err
+		return statsMsg{alias: h.Alias, stats: st}
+	}
+}
+
+// parseStats reads the three lines printed by statsCommand.
+func parseStats(out string) (hostStats, error) {
+	var st hostStats
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 3 {
+		return st, errors.New("unexpected stats output")
+	}
+
+	loadLine := lines[0]
+	if i := strings.LastIndex(loadLine, "load average"); i >= 0 {
+		loadLine = loadLine[i:]
+		if j := strings.IndexByte(loadLine, ':'); j >= 0 {
+			loadLine = loadLine[j+1:]
+		}
+	}
+	fields := strings.FieldsFunc(loadLine, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(fields) == 0 {
+		return st, errors.New("no load average in stats output")
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return st, fmt.Errorf("load average %q: %w", fields[0], err)
+	}
+	st.Load1 = load
+	st.CPUs, _ = strconv.Atoi(strings.TrimSpace(lines[1]))
+
+	df := strings.Fields(lines[len(lines)-1])
+	if len(df) < 5 {
+		return st, errors.New("unexpected df output")
+	}
+	pct, err := strconv.Atoi(strings.TrimSuffix(df[4], "%"))
+	if err != nil {
+		return st, fmt.Errorf("disk usage %q: %w", df[4], err)
+	}
+	st.DiskPct = pct
+	return st, nil
+}
+
+// statsLevel rates a host by the worse of disk usage and per-CPU load.
+func statsLevel(st hostStats) statusLevel {
+	load := st.Load1
+	if st.CPUs > 0 {
+		load /= float64(st.CPUs)
+	}
+	switch {
+	case st.DiskPct >= 90 || load >= 1.0:
+		return statusBad
+	case st.DiskPct >= 75 || load >= 0.7:
+		return statusWarn
+	default:
+		return statusOK
+	}
+}
+
+// statsColor is the theme's color for the host's stats level.
+func statsColor(st hostStats) lipgloss.Color {
+	return palette.color(statsLevel(st))
+}
+
+// startStats requests stats for every visible host not already fetched or in
+// flight. Hosts that opt out of batch probes are skipped.
+func (m *model) startStats() tea.Cmd {
+	if m.stats == nil {
+		m.stats = map[string]hostStats{}
+	}
+	if m.statsPending == nil {
+		m.statsPending = map[string]bool{}
+	}
+	var cmds []tea.Cmd
+	for _, h := range m.hosts {
+		if skipsBatchProbes(h) || m.statsPending[h.Alias] {
+			continue
+		}
+		if _, done := m.stats[h.Alias]; done {
+			continue
+		}
+		m.statsPending[h.Alias] = true
+		cmds = append(cmds, fetchStatsCmd(h))
+	}
+	return tea.Batch(cmds...)
+}
+
+// statsCell renders the stats column for a host row.
+func (m model) statsCell(h sshHost) string {
+	if skipsBatchProbes(h) {
+		return "stats: skipped"
+	}
+	if m.statsPending[h.Alias] {
+		return "stats: …"
+	}
+	st, ok := m.stats[h.Alias]
+	if !ok {
+		return ""
+	}
+	if st.Err != nil {
+		return "stats: n/a"
+	}
+	text := fmt.Sprintf("load %.2f disk %d%%", st.Load1, st.DiskPct)
+	return palette.render(statsLevel(st), text)
+}
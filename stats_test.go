@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestParseStats(t *testing.T) {
+	linux := "0.52 0.58 0.59 1/123 4567\n4\nFilesystem 1024-blocks Used Available Capacity Mounted on\n/dev/sda1 100 81 19 81% /\n"
+	st, err := parseStats(linux)
+	if err != nil {
+		t.Fatalf("parseStats: %v", err)
+	}
+	if st.Load1 != 0.52 || st.CPUs != 4 || st.DiskPct != 81 {
+		t.Fatalf("unexpected stats %#v", st)
+	}
+	if c := statsColor(st); c != "11" {
+		t.Fatalf("81%% disk should be yellow, got %s", c)
+	}
+
+	mac := " 9:41  up 3 days, 2 users, load averages: 3.10 2.50 2.00\n2\n/dev/disk1s1 100 20 80 20% /\n"
+	st, err = parseStats(mac)
+	if err != nil {
+		t.Fatalf("parseStats (uptime): %v", err)
+	}
+	if st.Load1 != 3.10 || st.DiskPct != 20 {
+		t.Fatalf("unexpected stats %#v", st)
+	}
+	if c := statsColor(st); c != "9" {
+		t.Fatalf("load 1.55 per cpu should be red, got %s", c)
+	}
+
+	if _, err := parseStats("garbage"); err == nil {
+		t.Fatalf("expected error for short output")
+	}
+}
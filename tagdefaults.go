@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// tagDefaults are launch settings applied to every host carrying a tag.
+type tagDefaults struct {
+	Options  map[string]string `json:"options,omitempty"`  // ssh -o options, e.g. {"ForwardAgent": "no"}
+	Confirm  bool              `json:"confirm,omitempty"`  // ask before connecting
+	Priority int               `json:"priority,omitempty"` // higher wins when tags set the same option
+}
+
+// matchingTagDefaults returns the tag names of h with configured defaults,
+// highest priority first. Tags of equal priority never disagree on an
+// option (validTagDefaults), so sorting them by name only keeps the order
+// stable.
+func matchingTagDefaults(h sshHost, defaults map[string]tagDefaults) []string {
+	var tags []string
+	for tag := range defaults {
+		if h.hasTag(tag) {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if pi, pj := defaults[tags[i]].Priority, defaults[tags[j]].Priority; pi != pj {
+			return pi > pj
+		}
+		return tags[i] < tags[j]
+	})
+	return tags
+}
+
+// validTagDefaults refuses two tags of the same priority that set one
+// option to different values: which applies to a host carrying both must
+// be chosen in the settings, not by the tags' names.
+func validTagDefaults(defaults map[string]tagDefaults) error {
+	tags := make([]string, 0, len(defaults))
+	for tag := range defaults {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for i, a := range tags {
+		for _, b := range tags[i+1:] {
+			if defaults[a].Priority != defaults[b].Priority {
+				continue
+			}
+			for ka, va := range defaults[a].Options {
+				for kb, vb := range defaults[b].Options {
+					if strings.EqualFold(ka, kb) && !strings.EqualFold(va, vb) {
+						return fmt.Errorf("tag_defaults: %q and %q both set %s (%s, %s); give one a higher priority", a, b, ka, va, vb)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// tagOptionArgs turns the tag defaults for h into -o arguments. An option
+// the host sets itself in the ssh config is left alone, as is one a tag of
+// higher priority already set.
+func tagOptionArgs(h sshHost, defaults map[string]tagDefaults) []string {
+	var args []string
+	seen := map[string]bool{}
+	for _, tag := range matchingTagDefaults(h, defaults) {
+		opts := defaults[tag].Options
+		keys := make([]string, 0, len(opts))
+		for k := range opts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			lk := strings.ToLower(k)
+			if seen[lk] || h.option(lk) != "" {
+				continue
+			}
+			seen[lk] = true
+			args = append(args, "-o", k+"="+opts[k])
+		}
+	}
+	return args
+}
+
+// tagConfirmCheck asks before connecting to hosts whose tag requires it.
+func tagConfirmCheck(m model, h sshHost) *connectPrompt {
+	for _, tag := range matchingTagDefaults(h, m.appConfig.TagDefaults) {
+		if m.appConfig.TagDefaults[tag].Confirm {
+			return &connectPrompt{
+				id:      "tag-confirm",
+				message: fmt.Sprintf("%s is tagged %s. Connect?", h.Alias, tag),
+			}
+		}
+	}
+	return nil
+}
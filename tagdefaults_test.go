@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTagOptionArgs(t *testing.T) {
+	t.Parallel()
+
+	defaults := map[string]tagDefaults{
+		"prod": {Options: map[string]string{"ForwardAgent": "no", "LogLevel": "ERROR"}, Confirm: true, Priority: 10},
+		"lab":  {Options: map[string]string{"StrictHostKeyChecking": "accept-new", "ForwardAgent": "yes"}},
+	}
+	h := sshHost{Alias: "web1",
+		Annotations: map[string]string{"tags": "prod,lab"},
+		Options:     map[string]string{"loglevel": "DEBUG"}}
+	got := strings.Join(tagOptionArgs(h, defaults), " ")
+	// prod has the higher priority, so its ForwardAgent wins even though lab
+	// sorts first; LogLevel is set by the host itself.
+	want := "-o ForwardAgent=no -o StrictHostKeyChecking=accept-new"
+	if got != want {
+		t.Fatalf("tagOptionArgs = %q, want %q", got, want)
+	}
+	if args := tagOptionArgs(sshHost{Alias: "dev"}, defaults); len(args) != 0 {
+		t.Fatalf("untagged host got %q", args)
+	}
+}
+
+func TestTagConfirmCheck(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel(nil, "", "")
+	m.appConfig.TagDefaults = map[string]tagDefaults{"prod": {Confirm: true}}
+	if p := tagConfirmCheck(m, sshHost{Alias: "web1", Annotations: map[string]string{"tags": "prod"}}); p == nil || p.id != "tag-confirm" {
+		t.Fatalf("expected a confirmation prompt, got %#v", p)
+	}
+	if p := tagConfirmCheck(m, sshHost{Alias: "dev1"}); p != nil {
+		t.Fatalf("untagged host prompted: %#v", p)
+	}
+}
+
+func TestValidTagDefaults(t *testing.T) {
+	t.Parallel()
+
+	conflict := map[string]tagDefaults{
+		"prod": {Options: map[string]string{"ForwardAgent": "no"}},
+		"lab":  {Options: map[string]string{"forwardagent": "yes"}},
+	}
+	if err := validTagDefaults(conflict); err == nil || !strings.Contains(err.Error(), `"lab" and "prod"`) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	conflict["prod"] = tagDefaults{Options: conflict["prod"].Options, Priority: 1}
+	if err := validTagDefaults(conflict); err != nil {
+		t.Fatalf("a priority settles it: %v", err)
+	}
+	same := map[string]tagDefaults{
+		"prod": {Options: map[string]string{"ForwardAgent": "no"}},
+		"db":   {Options: map[string]string{"ForwardAgent": "No"}},
+	}
+	if err := validTagDefaults(same); err != nil {
+		t.Fatalf("agreeing tags: %v", err)
+	}
+
+	settings := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(settings, []byte(`{"tag_defaults": {"prod": {"options": {"ForwardAgent": "no"}}, "lab": {"options": {"ForwardAgent": "yes"}}}}`), 0o600)
+	if _, err := loadAppConfig(settings); err == nil {
+		t.Fatal("conflicting tag defaults should fail to load")
+	}
+}
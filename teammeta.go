@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// metadataSyncConfig points at a team-wide hosts.yaml, shared through a
+// URL (e.g. a raw gist) or a git repository. The local hosts.yaml is
+// layered on top of it.
+type metadataSyncConfig struct {
+	URL      string `json:"url,omitempty"`       // HTTPS URL of the shared file
+	Token    string `json:"token,omitempty"`     // bearer token for URL, or a keychain:/age: reference
+	TokenEnv string `json:"token_env,omitempty"` // environment variable holding the bearer token
+	Repo     string `json:"repo,omitempty"`      // git repository cloned into the cache directory
+	Path     string `json:"path,omitempty"`      // file within Repo (default hosts.yaml)
+}
+
+const gitSyncTimeout = 15 * time.Second
+
+// gitCommand builds the git invocations used to sync a metadata repo.
+var gitCommand = func(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "git", args...)
+}
+
+func (c metadataSyncConfig) configured() bool {
+	return c.URL != "" || c.Repo != ""
+}
+
+// repoDir is where Repo is cloned, keyed by its URL.
+func (c metadataSyncConfig) repoDir() string {
+	dir := cacheDir()
+	if dir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(c.Repo))
+	return filepath.Join(dir, "metadata-"+hex.EncodeToString(sum[:8]))
+}
+
+// loadTeamMetadata returns the shared layer. With refresh it is fetched
+// first (the URL revalidated with its ETag, the repo cloned or pulled); if
+// that fails the cached copy is used and a warning returned, as for
+// inventories. Without refresh only the cached copy is read.
+func loadTeamMetadata(c metadataSyncConfig, refresh bool) (meta map[string]hostMeta, warning string, err error) {
+	switch {
+	case c.URL != "":
+		return loadTeamMetadataURL(c, refresh)
+	case c.Repo != "":
+		return loadTeamMetadataRepo(c, refresh)
+	}
+	return map[string]hostMeta{}, "", nil
+}
+
+func loadTeamMetadataURL(c metadataSyncConfig, refresh bool) (map[string]hostMeta, string, error) {
+	ic := inventoryConfig{Name: "team metadata", URL: c.URL, Token: c.Token, TokenEnv: c.TokenEnv}
+	cachePath := inventoryCachePath(c.URL)
+	cached := readInventoryCache(cachePath)
+	fresh, warning := cached, ""
+	if refresh {
+		var err error
+		if fresh, err = fetchInventory(ic, cached); err != nil {
+			if cached == nil {
+				return map[string]hostMeta{}, "", fmt.Errorf("%s: %w", ic.sourceName(), err)
+			}
+			warning = fmt.Sprintf("%s: using cached copy: %v", ic.sourceName(), err)
+			fresh = cached
+		}
+	}
+	if fresh == nil {
+		return map[string]hostMeta{}, "", nil
+	}
+	meta, err := parseMetadata(fresh.Body)
+	if err != nil {
+		return map[string]hostMeta{}, warning, fmt.Errorf("%s: %w", ic.sourceName(), err)
+	}
+	if fresh != cached {
+		writeInventoryCache(cachePath, fresh)
+	}
+	return meta, warning, nil
+}
+
+func loadTeamMetadataRepo(c metadataSyncConfig, refresh bool) (map[string]hostMeta, string, error) {
+	name := c.Path
+	if name == "" {
+		name = "hosts.yaml"
+	}
+	if !filepath.IsLocal(name) {
+		return map[string]hostMeta{}, "", fmt.Errorf("team metadata: path %q must be inside the repository", c.Path)
+	}
+	dir := c.repoDir()
+	if dir == "" {
+		return map[string]hostMeta{}, "", errors.New("team metadata: no cache directory")
+	}
+	warning := ""
+	if refresh {
+		if err := syncRepo(c.Repo, dir); err != nil {
+			if !fileExists(filepath.Join(dir, name)) {
+				return map[string]hostMeta{}, "", fmt.Errorf("team metadata: %w", err)
+			}
+			warning = fmt.Sprintf("team metadata: using cached copy: %v", err)
+		}
+	}
+	meta, err := loadMetadata(filepath.Join(dir, name))
+	if err != nil {
+		return map[string]hostMeta{}, warning, fmt.Errorf("team metadata: %w", err)
+	}
+	return meta, warning, nil
+}
+
+// syncRepo makes a shallow clone of repo in dir, or fast-forwards it.
+func syncRepo(repo, dir string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), gitSyncTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if fileExists(filepath.Join(dir, ".git")) {
+		cmd = gitCommand(ctx, "-C", dir, "pull", "--ff-only", "--quiet")
+	} else {
+		if err := os.MkdirAll(filepath.Dir(dir), 0o700); err != nil {
+			return err
+		}
+		cmd = gitCommand(ctx, "clone", "--depth", "1", "--quiet", "--", repo, dir)
+	}
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := lastLine(string(out)); msg != "" {
+			return fmt.Errorf("git: %w: %s", err, msg)
+		}
+		return fmt.Errorf("git: %w", err)
+	}
+	return nil
+}
+
+// mergeMetadata layers the local store over the team's: tags and notes are
+// combined, local color and fields win, and favorites only ever come from
+// the local store, since they're personal.
+func mergeMetadata(team, local map[string]hostMeta) map[string]hostMeta {
+	out := make(map[string]hostMeta, len(team)+len(local))
+	for alias, t := range team {
+		t.Favorite = false
+		t.Tags = append([]string(nil), t.Tags...)
+		t.Notes = append([]string(nil), t.Notes...)
+		t.Fields = copyStringMap(t.Fields)
+		out[alias] = t
+	}
+	for alias, l := range local {
+		m := out[alias]
+		for _, tag := range l.Tags {
+			if !containsString(m.Tags, tag) {
+				m.Tags = append(m.Tags, tag)
+			}
+		}
+		for _, n := range l.Notes {
+			if !containsString(m.Notes, n) {
+				m.Notes = append(m.Notes, n)
+			}
+		}
+		if l.Color != "" {
+			m.Color = l.Color
+		}
+		m.Favorite = l.Favorite
+		for k, v := range l.Fields {
+			if m.Fields == nil {
+				m.Fields = map[string]string{}
+			}
+			m.Fields[k] = v
+		}
+		out[alias] = m
+	}
+	return out
+}
+
+// hostMetadata is the merged store for startup and subcommands. Problems
+// with either layer are returned as warnings; whatever loaded is still used.
+func hostMetadata(settings appConfig, refresh bool) (map[string]hostMeta, []string) {
+	var warnings []string
+	local, err := loadMetadata(metadataPath())
+	if err != nil {
+		warnings = append(warnings, "could not read host metadata: "+err.Error())
+	}
+	if !settings.MetadataSync.configured() {
+		return local, warnings
+	}
+	team, warning, err := loadTeamMetadata(settings.MetadataSync, refresh)
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+	if err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	return mergeMetadata(team, local), warnings
+}
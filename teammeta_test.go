@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeMetadata(t *testing.T) {
+	t.Parallel()
+
+	team := map[string]hostMeta{
+		"db1":  {Tags: []string{"prod"}, Notes: []string{"primary"}, Color: "red", Favorite: true, Fields: map[string]string{"owner": "team-data"}},
+		"web1": {Favorite: true},
+	}
+	local := map[string]hostMeta{
+		"db1":   {Tags: []string{"prod", "mine"}, Color: "green", Fields: map[string]string{"owner": "me"}},
+		"web1":  {Favorite: true},
+		"stage": {Favorite: true},
+	}
+	got := mergeMetadata(team, local)
+	want := map[string]hostMeta{
+		"db1":   {Tags: []string{"prod", "mine"}, Notes: []string{"primary"}, Color: "green", Fields: map[string]string{"owner": "me"}},
+		"web1":  {Favorite: true},
+		"stage": {Favorite: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v", got)
+	}
+	if len(team["db1"].Tags) != 1 || team["db1"].Fields["owner"] != "team-data" {
+		t.Fatal("team layer was modified")
+	}
+}
+
+func TestTeamMetadataURL(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	online := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !online {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("db1:\n  tags: [prod]\n"))
+	}))
+	defer srv.Close()
+
+	cfg := metadataSyncConfig{URL: srv.URL}
+	if meta, _, err := loadTeamMetadata(cfg, false); err != nil || len(meta) != 0 {
+		t.Fatalf("nothing cached yet: %v %v", meta, err)
+	}
+	meta, warning, err := loadTeamMetadata(cfg, true)
+	if err != nil || warning != "" || !containsString(meta["db1"].Tags, "prod") {
+		t.Fatalf("fetch: %v %q %v", meta, warning, err)
+	}
+	online = false
+	meta, warning, err = loadTeamMetadata(cfg, true)
+	if err != nil || !strings.Contains(warning, "cached copy") || len(meta) != 1 {
+		t.Fatalf("offline: %v %q %v", meta, warning, err)
+	}
+	if meta, _, err = loadTeamMetadata(cfg, false); err != nil || len(meta) != 1 {
+		t.Fatalf("cache only: %v %v", meta, err)
+	}
+}
+
+func TestTeamMetadataRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	commit := func(content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(repo, "ssh"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, "ssh", "hosts.yaml"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "-A")
+		git("commit", "-qm", "update")
+	}
+	git("init", "-q")
+	commit("db1:\n  notes:\n    - primary\n")
+
+	cfg := metadataSyncConfig{Repo: repo, Path: "ssh/hosts.yaml"}
+	meta, warning, err := loadTeamMetadata(cfg, true)
+	if err != nil || warning != "" || !containsString(meta["db1"].Notes, "primary") {
+		t.Fatalf("clone: %v %q %v", meta, warning, err)
+	}
+	commit("db1:\n  notes:\n    - primary, moved to rack 4\n")
+	meta, _, err = loadTeamMetadata(cfg, true)
+	if err != nil || !containsString(meta["db1"].Notes, "primary, moved to rack 4") {
+		t.Fatalf("pull: %v %v", meta, err)
+	}
+
+	cfg.Path = "../hosts.yaml"
+	if _, _, err := loadTeamMetadata(cfg, false); err == nil {
+		t.Fatal("path outside the repository accepted")
+	}
+}
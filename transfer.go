@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxRecentTransfers is how many transfers are remembered per host.
+const maxRecentTransfers = 5
+
+// transferEntry is one copy to or from a host, remembered so the next one
+// to the same place is a keypress.
+type transferEntry struct {
+	Alias  string `json:"alias"`
+	Push   bool   `json:"push"` // local → remote; false pulls
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+}
+
+// transferPicker is the t overlay: pick a direction (or a recent transfer),
+// then the local and remote paths, and sshpick hands over to scp.
+type transferPicker struct {
+	host   sshHost
+	push   bool
+	step   int       // 0 direction, 1 source path, 2 destination path
+	paths  [2]string // local, remote
+	recent []transferEntry
+}
+
+// field is the index into paths typed at step: the source first, then the
+// destination.
+func (t transferPicker) field(step int) int {
+	if (step == 1) == t.push {
+		return 0
+	}
+	return 1
+}
+
+func (t transferPicker) entry() transferEntry {
+	return transferEntry{Alias: t.host.Alias, Push: t.push, Local: t.paths[0], Remote: t.paths[1]}
+}
+
+func transfersPath() string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "transfers.json")
+}
+
+// loadTransfers reads the remembered transfers, newest first. A missing or
+// corrupt file is an empty list.
+func loadTransfers(path string) []transferEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entries []transferEntry
+	if json.Unmarshal(data, &entries) != nil {
+		return nil
+	}
+	return entries
+}
+
+// recentTransfers are alias's remembered transfers, newest first.
+func recentTransfers(entries []transferEntry, alias string) []transferEntry {
+	var out []transferEntry
+	for _, e := range entries {
+		if e.Alias == alias {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// rememberTransfer moves e to the front of the list at path, dropping its
+// duplicate and the host's oldest beyond maxRecentTransfers.
+func rememberTransfer(path string, e transferEntry) error {
+	if path == "" {
+		return nil
+	}
+	entries := []transferEntry{e}
+	perHost := 1
+	for _, old := range loadTransfers(path) {
+		if old == e {
+			continue
+		}
+		if old.Alias == e.Alias {
+			if perHost == maxRecentTransfers {
+				continue
+			}
+			perHost++
+		}
+		entries = append(entries, old)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".transfers-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// scpArgs builds the scp arguments for e. -r copies directories too; for
+// a file it is a no-op.
+func scpArgs(h sshHost, e transferEntry) []string {
+	args := append(transferHostArgs(h), "-r", "--")
+	remote := sshTarget(h) + ":" + e.Remote
+	if e.Push {
+		return append(args, e.Local, remote)
+	}
+	local := e.Local
+	if local == "" {
+		local = "."
+	}
+	return append(args, remote, local)
+}
+
+func (m model) openTransfer() model {
+	if len(m.hosts) == 0 {
+		m.err = trErr("err.no_hosts_select")
+		return m
+	}
+	h := m.hosts[m.cursor]
+	m.err = nil
+	m.transfer = &transferPicker{host: h, push: true, recent: recentTransfers(loadTransfers(transfersPath()), h.Alias)}
+	return m
+}
+
+// startTransfer quits the picker so main hands the terminal to scp.
+func (m model) startTransfer(e transferEntry) (tea.Model, tea.Cmd) {
+	m.transferTool = "scp"
+	m.transferArgs = scpArgs(m.transfer.host, e)
+	m.transferDone = &e
+	m.transfer = nil
+	return m, tea.Quit
+}
+
+func (m model) updateTransfer(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	t := *m.transfer
+	if msg.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+	if t.step > 0 {
+		i := t.field(t.step)
+		switch msg.String() {
+		case "esc":
+			t.step--
+		case "enter":
+			// the source is required; the destination defaults to the
+			// remote home or the current directory
+			if t.step == 1 {
+				if strings.TrimSpace(t.paths[i]) == "" {
+					break
+				}
+				t.step = 2
+			} else {
+				m.transfer = &t
+				return m.startTransfer(t.entry())
+			}
+		case "backspace":
+			if t.paths[i] != "" {
+				_, n := utf8.DecodeLastRuneInString(t.paths[i])
+				t.paths[i] = t.paths[i][:len(t.paths[i])-n]
+			}
+		default:
+			if msg.Type == tea.KeyRunes && len(t.paths[i]) < 1024 {
+				t.paths[i] += string(msg.Runes)
+			}
+		}
+		m.transfer = &t
+		return m, nil
+	}
+
+	switch key := msg.String(); key {
+	case "esc", "q":
+		m.transfer = nil
+		return m, nil
+	case "tab", "left", "right", "h", "l":
+		t.push = !t.push
+	case "enter":
+		t.step = 1
+	case "s":
+		m.transferTool = "sftp"
+		m.transferArgs = sftpArgs(t.host)
+		m.transfer = nil
+		return m, tea.Quit
+	default:
+		if len(key) == 1 && key[0] >= '1' && key[0] <= '9' {
+			if n := int(key[0] - '1'); n < len(t.recent) {
+				return m.startTransfer(t.recent[n])
+			}
+		}
+	}
+	m.transfer = &t
+	return m, nil
+}
+
+// transferLine describes e for the recent list, e.g. "./app.tar.gz → web1:/tmp/".
+func transferLine(e transferEntry) string {
+	remote := e.Alias + ":" + e.Remote
+	local := e.Local
+	if local == "" {
+		local = "."
+	}
+	if e.Push {
+		return local + " → " + remote
+	}
+	return remote + " → " + local
+}
+
+func (m model) renderTransfer(b *strings.Builder) {
+	t := m.transfer
+	fmt.Fprintln(b, m.styles.title.Render("Transfer files with "+t.host.Alias))
+	fmt.Fprintln(b, m.styles.help.Render(tr("help.transfer")))
+	fmt.Fprintln(b, "")
+
+	push, pull := "  push local → "+t.host.Alias, "  pull "+t.host.Alias+" → local"
+	if t.push {
+		fmt.Fprintln(b, m.styles.selected.Render("> "+push[2:]))
+		fmt.Fprintln(b, m.styles.item.Render(pull))
+	} else {
+		fmt.Fprintln(b, m.styles.item.Render(push))
+		fmt.Fprintln(b, m.styles.selected.Render("> "+pull[2:]))
+	}
+	if t.step == 0 && len(t.recent) > 0 {
+		fmt.Fprintln(b, "")
+		fmt.Fprintln(b, m.styles.title.Render("Recent"))
+		for i, e := range t.recent {
+			fmt.Fprintln(b, m.styles.item.Render(fmt.Sprintf("  %d %s", i+1, transferLine(e))))
+		}
+	}
+	if t.step == 0 {
+		return
+	}
+
+	fmt.Fprintln(b, "")
+	labels := [2]string{"Local path", "Path on " + t.host.Alias}
+	for step := 1; step <= t.step; step++ {
+		i := t.field(step)
+		label := labels[i]
+		if step == 2 {
+			label += [2]string{" (empty for .)", " (empty for home)"}[i]
+		}
+		fmt.Fprintln(b, m.styles.help.Render(label+": "+t.paths[i]))
+	}
+	if t.step == 2 {
+		fmt.Fprintln(b, m.styles.help.Render("scp "+shellJoin(scpArgs(t.host, t.entry()))))
+	}
+}
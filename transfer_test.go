@@ -0,0 +1,81 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestScpArgs(t *testing.T) {
+	t.Parallel()
+	h := sshHost{Alias: "web", Hostname: "10.0.0.5", User: "deploy", Port: "2222", Source: "inventory", IdentityFiles: []string{"/k/id"}}
+	push := scpArgs(h, transferEntry{Alias: "web", Push: true, Local: "app.tar", Remote: "/tmp/"})
+	want := append(hostKeyArgs(true), "-P", "2222", "-i", "/k/id", "-r", "--", "app.tar", "deploy@10.0.0.5:/tmp/")
+	if !reflect.DeepEqual(push, want) {
+		t.Fatalf("push %q, want %q", push, want)
+	}
+	pull := scpArgs(sshHost{Alias: "db"}, transferEntry{Alias: "db", Remote: "/var/log/syslog"})
+	want = append(hostKeyArgs(true), "-r", "--", "db:/var/log/syslog", ".")
+	if !reflect.DeepEqual(pull, want) {
+		t.Fatalf("pull %q, want %q", pull, want)
+	}
+}
+
+func TestRememberTransfer(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "transfers.json")
+	for i := 0; i < maxRecentTransfers+2; i++ {
+		if err := rememberTransfer(path, transferEntry{Alias: "web", Push: true, Local: string(rune('a' + i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rememberTransfer(path, transferEntry{Alias: "db", Remote: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	// repeating a transfer moves it to the front instead of duplicating it
+	if err := rememberTransfer(path, transferEntry{Alias: "web", Push: true, Local: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	web := recentTransfers(loadTransfers(path), "web")
+	var locals []string
+	for _, e := range web {
+		locals = append(locals, e.Local)
+	}
+	if want := []string{"d", "g", "f", "e", "c"}; !reflect.DeepEqual(locals, want) {
+		t.Fatalf("web recents %q, want %q", locals, want)
+	}
+	if db := recentTransfers(loadTransfers(path), "db"); len(db) != 1 {
+		t.Fatalf("db recents %+v", db)
+	}
+}
+
+func TestTransferPicker(t *testing.T) {
+	t.Parallel()
+	m := model{hosts: []sshHost{{Alias: "web"}}}
+	m.transfer = &transferPicker{host: m.hosts[0], push: true}
+	keys := []tea.KeyMsg{
+		{Type: tea.KeyTab}, // pull
+		{Type: tea.KeyEnter},
+		{Type: tea.KeyRunes, Runes: []rune("/etc/hosts")},
+		{Type: tea.KeyEnter},
+		{Type: tea.KeyRunes, Runes: []rune("out")},
+	}
+	for _, k := range keys {
+		next, _ := m.updateTransfer(k)
+		m = next.(model)
+	}
+	if m.transfer.paths != [2]string{"out", "/etc/hosts"} {
+		t.Fatalf("paths %q", m.transfer.paths)
+	}
+	next, cmd := m.updateTransfer(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if cmd == nil || m.transfer != nil || m.transferDone == nil {
+		t.Fatal("enter on the destination should start the transfer")
+	}
+	want := append(hostKeyArgs(true), "-r", "--", "web:/etc/hosts", "out")
+	if m.transferTool != "scp" || !reflect.DeepEqual(m.transferArgs, want) {
+		t.Fatalf("%s %q, want %q", m.transferTool, m.transferArgs, want)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sshURL is a parsed ssh:// URL (draft-ietf-secsh-scp-sftp-ssh-uri):
+// ssh://[user[;fingerprint=SHA256:...]@]host[:port][/]
+type sshURL struct {
+	User        string
+	Host        string
+	Port        string
+	Fingerprint string // "SHA256:..." host key fingerprint, when given
+}
+
+var (
+	// urlHostname and urlUser keep URL fields from reaching ssh as options
+	// (a host of "-oProxyCommand=..." must never be passed through).
+	urlHostname = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._:%-]*$`)
+	urlUser     = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]*$`)
+)
+
+func parseSSHURL(raw string) (sshURL, error) {
+	var u sshURL
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return u, err
+	}
+	if !strings.EqualFold(parsed.Scheme, "ssh") {
+		return u, fmt.Errorf("%q is not an ssh:// URL", raw)
+	}
+	if parsed.Path != "" && parsed.Path != "/" || parsed.RawQuery != "" || parsed.Opaque != "" {
+		return u, errors.New("ssh URLs can't have a path or query")
+	}
+	if parsed.User != nil {
+		name, params, _ := strings.Cut(parsed.User.Username(), ";")
+		if _, hasPassword := parsed.User.Password(); hasPassword {
+			return u, errors.New("passwords in ssh URLs are not supported")
+		}
+		if name != "" && !urlUser.MatchString(name) {
+			return u, fmt.Errorf("invalid user %q", name)
+		}
+		u.User = name
+		for _, p := range strings.Split(params, ",") {
+			k, v, _ := strings.Cut(p, "=")
+			switch strings.ToLower(k) {
+			case "":
+			case "fingerprint":
+				// The draft writes SHA256-<base64>; accept the ssh-keygen form too.
+				fp := strings.Replace(v, "SHA256-", "SHA256:", 1)
+				if !strings.HasPrefix(fp, "SHA256:") {
+					return u, fmt.Errorf("unsupported fingerprint %q (only SHA256)", v)
+				}
+				u.Fingerprint = fp
+			default:
+				return u, fmt.Errorf("unsupported connection parameter %q", k)
+			}
+		}
+	}
+	u.Host = parsed.Hostname()
+	if u.Host == "" || !urlHostname.MatchString(u.Host) {
+		return u, fmt.Errorf("invalid host %q", u.Host)
+	}
+	if u.Port = parsed.Port(); u.Port != "" {
+		if n, err := strconv.Atoi(u.Port); err != nil || n < 1 || n > 65535 {
+			return u, fmt.Errorf("invalid port %q", u.Port)
+		}
+	}
+	return u, nil
+}
+
+// matchURLHost finds the configured host a URL refers to: one whose alias,
+// hostname or IP is the URL's host, on the same port, with the same user
+// when the URL names one.
+func matchURLHost(hosts []sshHost, u sshURL) (sshHost, bool) {
+	port := func(p string) string {
+		if p == "" {
+			return "22"
+		}
+		return p
+	}
+	for _, h := range hosts {
+		if h.Alias != u.Host && !strings.EqualFold(h.Hostname, u.Host) && h.IP != u.Host {
+			continue
+		}
+		if port(h.Port) != port(u.Port) || (u.User != "" && h.User != u.User) {
+			continue
+		}
+		return h, true
+	}
+	return sshHost{}, false
+}
+
+// urlHost is an ad-hoc host for a URL nothing in the config matches.
+func urlHost(u sshURL) sshHost {
+	return sshHost{Alias: defaultAlias(u.Host), Hostname: u.Host, User: u.User, Port: u.Port, Source: "url"}
+}
+
+// verifyURLFingerprint checks the URL's fingerprint against the host's
+// scanned keys and returns the matching known_hosts line.
+func verifyURLFingerprint(h sshHost, fingerprint string) (string, error) {
+	scan, err := scanHostKeys(h)
+	keys := parseKnownHostLines(scan)
+	if err != nil || len(keys) == 0 {
+		return "", fmt.Errorf("could not scan %s's host keys to check the URL's fingerprint", h.Hostname)
+	}
+	for _, k := range keys {
+		if k.fingerprint() == fingerprint {
+			return strings.Join([]string{knownHostsKey(h), k.KeyType, k.Key}, " ") + "\n", nil
+		}
+	}
+	return "", fmt.Errorf("%s's host key does not match the URL's fingerprint %s", h.Hostname, fingerprint)
+}
+
+// runOpen implements `sshpick open ssh://...`, so sshpick can be the
+// system's ssh:// handler. A URL matching a configured host connects with
+// that host's settings; anything else connects ad hoc, and -save adds it
+// to the ssh config first.
+func runOpen(args []string) int {
+	fs := flag.NewFlagSet("open", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	settingsPath := fs.String("settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
+	save := fs.Bool("save", false, "Add a host the config doesn't have yet")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: sshpick open [-save] ssh://[user@]host[:port]")
+		return 2
+	}
+	u, err := parseSSHURL(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	settings, err := loadHostKeyPolicy(*settingsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+		return 2
+	}
+	if *cfgPath == "" {
+		*cfgPath = defaultConfigPath()
+	}
+	hosts, _ := loadDaemonHosts(daemonOptions{cfgPath: *cfgPath, settings: settings})
+	if literalIP(u.Host) != "" {
+		// an address can only match a host by its resolved IP
+		resolveHostIPs(hosts)
+	}
+
+	h, ok := matchURLHost(hosts, u)
+	if !ok {
+		h = urlHost(u)
+		if *save {
+			if settings.ReadOnly {
+				fmt.Fprintln(os.Stderr, errReadOnly)
+				return 1
+			}
+			for _, existing := range hosts {
+				if existing.Alias == h.Alias {
+					fmt.Fprintf(os.Stderr, "not saving: alias %q is already taken\n", h.Alias)
+					return 1
+				}
+			}
+			if err := appendHostBlock(*cfgPath, hostBlock{Alias: h.Alias, HostName: h.Hostname, User: h.User, Port: h.Port}); err != nil {
+				fmt.Fprintln(os.Stderr, "error saving host:", err)
+				return 1
+			}
+			h.Source = ""
+			fmt.Fprintf(os.Stderr, "added %s to %s\n", h.Alias, tildePath(*cfgPath))
+		}
+	}
+	if u.Fingerprint != "" {
+		if h.option("proxyjump") != "" || h.option("proxycommand") != "" {
+			fmt.Fprintf(os.Stderr, "%s is reached through a proxy; can't check the URL's fingerprint\n", h.Alias)
+			return 1
+		}
+		line, err := verifyURLFingerprint(h, u.Fingerprint)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if !hostKeyKnown(loadHostKnownHosts(h), knownHostsKey(h)) {
+			if err := appendKnownHosts(knownHostsWritePath(h), line); err != nil {
+				fmt.Fprintln(os.Stderr, "warning: could not record the host key:", err)
+			}
+		}
+	}
+
+	opts := launchOptions{hooks: settings.Hooks, notify: settings.Notify, tagDefaults: settings.TagDefaults}
+	if f := connectHost(h, opts); f != nil {
+		for _, line := range f.lines {
+			fmt.Fprintln(os.Stderr, line)
+		}
+		return f.code
+	}
+	return 0
+}
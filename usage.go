@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func (m model) updateUsage(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q", "H":
+		m.usage, m.usageEntries = nil, nil
+	case "v":
+		m.usageHeatmap = !m.usageHeatmap
+	}
+	return m, nil
+}
+
+func (m model) renderUsage(b *strings.Builder) {
+	if m.usageHeatmap {
+		m.renderHeatmap(b)
+		return
+	}
+	fmt.Fprintln(b, m.styles.title.Render("Connection history"))
+	fmt.Fprintln(b, m.styles.help.Render(tr("help.usage")))
+	fmt.Fprintln(b, "")
+	if len(m.usage) == 0 {
+		fmt.Fprintln(b, m.styles.help.Render("No connections recorded yet."))
+		return
+	}
+	var total time.Duration
+	sessions := 0
+	for _, u := range m.usage {
+		total += u.Total
+		sessions += u.Sessions
+	}
+	fmt.Fprintln(b, m.styles.item.Render(fmt.Sprintf("%d sessions to %d hosts, %s connected in total", sessions, len(m.usage), formatUsageDuration(total))))
+	fmt.Fprintln(b, "")
+	fmt.Fprintln(b, m.styles.help.Render(fmt.Sprintf("%-20s %8s %7s %8s %10s  %s", "HOST", "SESSIONS", "7 DAYS", "PER WEEK", "TIME", "LAST")))
+	for _, u := range m.usage {
+		fmt.Fprintln(b, m.styles.item.Render(fmt.Sprintf("%-20s %8d %7d %8.1f %10s  %s",
+			u.Alias, u.Sessions, u.LastWeek, u.PerWeek, formatUsageDuration(u.Total), u.Last.Local().Format("2006-01-02 15:04"))))
+	}
+}
+
+func formatUsageDuration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	if d < time.Hour {
+		return d.Round(time.Second).String()
+	}
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// verboseLogPath is where a -vvv retry of h writes its debug log.
+func verboseLogPath(h sshHost) string {
+	dir := stateDir()
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == os.PathSeparator || r == ' ' {
+			return '_'
+		}
+		return r
+	}, h.Alias)
+	return filepath.Join(dir, "logs", name+"-"+time.Now().Format("20060102-150405")+".log")
+}
+
+// relevantDebug are fragments of ssh -vvv output worth showing after a
+// failure; the rest is protocol noise.
+var relevantDebug = []string{
+	"Connecting to",
+	"connect to address",
+	"Connection established",
+	"Authenticating to",
+	"Server host key",
+	"Host key verification",
+	"Authentications that can continue",
+	"Offering public key",
+	"Server accepts key",
+	"Trying private key",
+	"No such identity",
+	"send_pubkey_test",
+	"Next authentication method",
+	"Permission denied",
+	"Connection closed",
+	"Connection refused",
+	"Connection timed out",
+	"Could not resolve",
+	"UNPROTECTED",
+	"bad permissions",
+	"kex_exchange_identification",
+	"no matching",
+}
+
+// relevantLogLines returns the last n interesting lines of an ssh debug log.
+func relevantLogLines(path string, n int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		for _, frag := range relevantDebug {
+			if strings.Contains(line, frag) {
+				lines = append(lines, line)
+				break
+			}
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
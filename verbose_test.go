@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestRelevantLogLines(t *testing.T) {
+	t.Parallel()
+
+	log := filepath.Join(t.TempDir(), "ssh.log")
+	content := strings.Join([]string{
+		"OpenSSH_9.6p1, OpenSSL 3.0.13 30 Jan 2024",
+		"debug1: Connecting to web1 [10.0.0.1] port 22.",
+		"debug3: set_sock_tos: set socket 3 IP_TOS 0x10",
+		"debug1: Connection established.",
+		"debug1: Authentications that can continue: publickey",
+		"debug1: Offering public key: /home/me/.ssh/id_ed25519 ED25519 SHA256:abc agent",
+		"debug3: receive packet: type 51",
+		"me@web1: Permission denied (publickey).",
+	}, "\n")
+	if err := os.WriteFile(log, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got := relevantLogLines(log, 3)
+	if len(got) != 3 || !strings.Contains(got[0], "Authentications that can continue") || !strings.Contains(got[2], "Permission denied") {
+		t.Fatalf("relevantLogLines = %q", got)
+	}
+}
+
+func TestFailurePanel_VerboseRetry(t *testing.T) {
+	t.Parallel()
+
+	h := sshHost{Alias: "web1"}
+	m := initialModel([]sshHost{h}, "", "").resumeAfter(&sessionFailure{host: h, code: 255})
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")})
+	m = next.(model)
+	if !m.chosen || !m.verboseRetry {
+		t.Fatalf("expected a verbose retry, got chosen=%v verbose=%v", m.chosen, m.verboseRetry)
+	}
+	args := sshArgs(h, launchOptions{verboseLog: "/tmp/web1.log"})
+	if strings.Join(args, " ") != "-vvv -E /tmp/web1.log -- web1" {
+		t.Fatalf("sshArgs = %q", args)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// versionRow is one host in the `sshpick versions` report.
+type versionRow struct {
+	Alias    string `json:"alias"`
+	Address  string `json:"address"`
+	Version  string `json:"version,omitempty"`
+	Outdated bool   `json:"outdated,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// collectVersions fetches the server version of every host, at most
+// concurrency at a time, keeping the hosts' order. Probe-averse hosts are
+// reported as skipped rather than contacted.
+func collectVersions(hosts []sshHost, concurrency int, fetch func(sshHost) hostBanner) []versionRow {
+	rows := make([]versionRow, len(hosts))
+	forEachHost(hosts, concurrency, func(i int, h sshHost) {
+		rows[i] = versionRow{Alias: h.Alias, Address: dialAddress(h)}
+		if skipsBatchProbes(h) {
+			rows[i].Error = errProbeSkipped.Error()
+			return
+		}
+		b := fetch(h)
+		rows[i].Version = b.Version
+		rows[i].Outdated = outdatedOpenSSH(b.Version)
+		rows[i].Error = b.Err
+	})
+	return rows
+}
+
+func writeVersionReport(w io.Writer, rows []versionRow, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"alias", "address", "version", "outdated", "error"})
+		for _, r := range rows {
+			cw.Write([]string{r.Alias, r.Address, r.Version, fmt.Sprint(r.Outdated), r.Error})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "table", "":
+		for _, r := range rows {
+			status := ""
+			switch {
+			case r.Version == "":
+				status = "unreachable (" + r.Error + ")"
+			case r.Outdated:
+				status = "OUTDATED"
+			}
+			fmt.Fprintf(w, "%-20s %-30s %-40s %s\n", r.Alias, r.Address, r.Version, status)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q (table, csv, json)", format)
+	}
+}
+
+// runVersions implements `sshpick versions`, a fleet audit of server
+// versions. It exits 1 when any reachable host runs an outdated OpenSSH.
+func runVersions(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("versions", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	settingsPath := fs.String("settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
+	filter := fs.String("filter", "", "Only hosts whose alias matches this regex")
+	tag := fs.String("tag", "", "Only hosts with this tag")
+	format := fs.String("format", "table", "Output format: table, csv or json")
+	concurrency := fs.Int("concurrency", 16, "Hosts contacted at once")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	settings, err := loadHostKeyPolicy(*settingsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+		return 2
+	}
+	if *cfgPath == "" {
+		*cfgPath = defaultConfigPath()
+	}
+	hosts, err := parseSSHConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading config:", err)
+		return 2
+	}
+	meta, _ := hostMetadata(settings, false)
+	hosts = applyMetadata(hosts, meta)
+	selected, err := selectHosts(hosts, *filter, *tag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	rows := collectVersions(selected, *concurrency, fetchBanner)
+	if err := writeVersionReport(stdout, rows, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, r := range rows {
+		if r.Outdated {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCollectVersions(t *testing.T) {
+	t.Parallel()
+
+	hosts := []sshHost{
+		{Alias: "old", Hostname: "10.0.0.1"},
+		{Alias: "new", Hostname: "10.0.0.2"},
+		{Alias: "down", Hostname: "10.0.0.3"},
+		{Alias: "mfa", Hostname: "10.0.0.4", Annotations: map[string]string{"mfa": "true"}},
+	}
+	fetch := func(h sshHost) hostBanner {
+		switch h.Alias {
+		case "old":
+			return hostBanner{Version: "OpenSSH_6.6.1p1"}
+		case "new":
+			return hostBanner{Version: "OpenSSH_9.6p1"}
+		case "mfa":
+			t.Error("probe-averse host was contacted")
+		}
+		return hostBanner{Err: "Connection refused"}
+	}
+	rows := collectVersions(hosts, 2, fetch)
+	if len(rows) != 4 || rows[0].Alias != "old" || !rows[0].Outdated || rows[1].Outdated {
+		t.Fatalf("rows %+v", rows)
+	}
+	if rows[2].Version != "" || rows[2].Error != "Connection refused" || rows[3].Error == "" {
+		t.Fatalf("rows %+v", rows)
+	}
+
+	var buf bytes.Buffer
+	if err := writeVersionReport(&buf, rows, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "old,10.0.0.1:22,OpenSSH_6.6.1p1,true,\n") {
+		t.Fatalf("csv %q", buf.String())
+	}
+	if err := writeVersionReport(&buf, rows, "xml"); err == nil {
+		t.Fatal("unknown format should fail")
+	}
+}
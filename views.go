@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// viewSpec is a saved view: a filter (usually a query like "tag:prod
+// latency<50ms") and how the list is sorted, grouped and which columns it
+// shows. In the settings file a plain string is a view with just a filter,
+// which leaves the rest as it is.
+type viewSpec struct {
+	Filter  string   `json:"filter,omitempty"`
+	Order   string   `json:"order,omitempty"`   // "recent" or "frequent"; empty is config order
+	Group   string   `json:"group,omitempty"`   // "source" or "tag"
+	Columns []string `json:"columns,omitempty"` // of viewColumns
+
+	filterOnly bool
+}
+
+// viewColumns are the optional row columns a view can turn on.
+var viewColumns = []string{"notes", "stats", "reach"}
+
+func (v *viewSpec) UnmarshalJSON(data []byte) error {
+	var filter string
+	if json.Unmarshal(data, &filter) == nil {
+		*v = viewSpec{Filter: filter, filterOnly: true}
+		return nil
+	}
+	type plain viewSpec
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*v = viewSpec(p)
+	return nil
+}
+
+func (v viewSpec) MarshalJSON() ([]byte, error) {
+	if v.filterOnly {
+		return json.Marshal(v.Filter)
+	}
+	type plain viewSpec
+	return json.Marshal(plain(v))
+}
+
+// describe is the view's line in the menu, e.g.
+// "tag:prod · recent first · by tag · notes, stats".
+func (v viewSpec) describe() string {
+	parts := []string{v.Filter}
+	if v.Filter == "" {
+		parts[0] = "all hosts"
+	}
+	if v.filterOnly {
+		return parts[0]
+	}
+	if v.Order != "" {
+		parts = append(parts, v.Order+" first")
+	}
+	if v.Group != "" {
+		parts = append(parts, "by "+v.Group)
+	}
+	if len(v.Columns) > 0 {
+		parts = append(parts, strings.Join(v.Columns, ", "))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// currentView captures the list as it is now.
+func (m model) currentView() viewSpec {
+	v := viewSpec{Filter: m.lastValidRegex}
+	if m.order != orderConfig {
+		v.Order = m.order.String()
+	}
+	if m.grouping != groupNone {
+		v.Group = m.grouping.String()
+	}
+	for _, c := range viewColumns {
+		if m.columnShown(c) {
+			v.Columns = append(v.Columns, c)
+		}
+	}
+	return v
+}
+
+func (m model) columnShown(column string) bool {
+	switch column {
+	case "notes":
+		return m.showNotes
+	case "stats":
+		return m.showStats
+	case "reach":
+		return m.showReach
+	}
+	return false
+}
+
+// savedView is a view in the v menu.
+type savedView struct {
+	Name   string
+	Spec   viewSpec
+	Shared bool // from the settings file, not saved from the menu
+}
+
+// savedViews are the settings file's views, then the user's, by name.
+func (m model) savedViews() []savedView {
+	var out []savedView
+	for _, views := range []struct {
+		byName map[string]viewSpec
+		shared bool
+	}{{m.appConfig.Views, true}, {m.views, false}} {
+		names := make([]string, 0, len(views.byName))
+		for name := range views.byName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			out = append(out, savedView{Name: name, Spec: views.byName[name], Shared: views.shared})
+		}
+	}
+	return out
+}
+
+// findView looks a view up by name, the user's own first.
+func (m model) findView(name string) (viewSpec, bool) {
+	if v, ok := m.views[name]; ok {
+		return v, true
+	}
+	v, ok := m.appConfig.Views[name]
+	return v, ok
+}
+
+// viewMenu is the v overlay listing saved views.
+type viewMenu struct {
+	cursor int
+	naming bool   // typing a name to save the current filter under
+	name   string // the name typed so far
+}
+
+func (m model) openViewMenu() model {
+	m.err = nil
+	m.viewMenu = &viewMenu{}
+	return m
+}
+
+// applyView switches the list to v: its filter, as if typed and confirmed,
+// and unless v is just a filter, its order, grouping and columns. The
+// command starts the checks behind columns it turns on.
+func (m model) applyView(v viewSpec) (model, tea.Cmd) {
+	var cmds []tea.Cmd
+	if !v.filterOnly {
+		m.order = parseHostOrder(v.Order)
+		m.grouping = parseHostGrouping(v.Group)
+		m.showNotes = containsString(v.Columns, "notes")
+		if stats := containsString(v.Columns, "stats"); stats != m.showStats {
+			m.showStats = stats
+			if stats {
+				cmds = append(cmds, m.startStats())
+			}
+		}
+		if containsString(v.Columns, "reach") != m.showReach {
+			var cmd tea.Cmd
+			m, cmd = m.toggleProbes()
+			cmds = append(cmds, cmd)
+		}
+	}
+	m.applyFilter(v.Filter)
+	if m.filterErr != nil {
+		m.err = m.filterErr
+		m.filterErr = nil
+		m.applyFilter(m.lastValidRegex)
+		return m, tea.Batch(cmds...)
+	}
+	m.filterQuery, m.lastValidRegex = v.Filter, v.Filter
+	if v.Filter != "" {
+		m.rememberFilter(v.Filter)
+	}
+	return m, tea.Batch(append(cmds, m.refreshDetail())...)
+}
+
+func (m model) updateViewMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	v := *m.viewMenu
+	if msg.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+	if v.naming {
+		switch msg.String() {
+		case "esc":
+			v.naming, v.name = false, ""
+		case "enter":
+			name := strings.TrimSpace(v.name)
+			if name == "" {
+				break
+			}
+			if m.views == nil {
+				m.views = map[string]viewSpec{}
+			}
+			m.views[name] = m.currentView()
+			v.naming, v.name = false, ""
+			for i, sv := range m.savedViews() {
+				if !sv.Shared && sv.Name == name {
+					v.cursor = i
+				}
+			}
+		case "backspace":
+			if v.name != "" {
+				_, n := utf8.DecodeLastRuneInString(v.name)
+				v.name = v.name[:len(v.name)-n]
+			}
+		default:
+			if (msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace) && len(v.name) < 64 {
+				v.name += string(msg.Runes)
+			}
+		}
+		m.viewMenu = &v
+		return m, nil
+	}
+
+	views := m.savedViews()
+	switch msg.String() {
+	case "esc", "q", "v":
+		m.viewMenu = nil
+		return m, nil
+	case "j", "down":
+		if len(views) > 0 {
+			v.cursor = (v.cursor + 1) % len(views)
+		}
+	case "k", "up":
+		if len(views) > 0 {
+			v.cursor = (v.cursor - 1 + len(views)) % len(views)
+		}
+	case "enter":
+		if v.cursor < len(views) {
+			m.viewMenu = nil
+			return m.applyView(views[v.cursor].Spec)
+		}
+	case "a":
+		m.err = nil
+		v.naming = true
+	case "x":
+		if v.cursor < len(views) && !views[v.cursor].Shared {
+			delete(m.views, views[v.cursor].Name)
+			if v.cursor > 0 && v.cursor >= len(views)-1 {
+				v.cursor--
+			}
+		}
+	}
+	m.viewMenu = &v
+	return m, nil
+}
+
+func (m model) renderViewMenu(b *strings.Builder) {
+	v := m.viewMenu
+	fmt.Fprintln(b, m.styles.title.Render("Saved views"))
+	fmt.Fprintln(b, m.styles.help.Render(tr("help.views")))
+	fmt.Fprintln(b, "")
+	views := m.savedViews()
+	if len(views) == 0 {
+		fmt.Fprintln(b, m.styles.item.Render("  No saved views. Filter (e.g. / tag:prod latency<50ms), sort and group the list, then press a here."))
+	}
+	width := 0
+	for _, sv := range views {
+		width = max(width, len(sv.Name))
+	}
+	for i, sv := range views {
+		line := fmt.Sprintf("%-*s  %s", width, sv.Name, sv.Spec.describe())
+		if sv.Shared {
+			line += "  (shared)"
+		}
+		if i == v.cursor {
+			fmt.Fprintln(b, m.styles.selected.Render("> "+line))
+		} else {
+			fmt.Fprintln(b, m.styles.item.Render("  "+line))
+		}
+	}
+	if v.naming {
+		fmt.Fprintln(b, "")
+		fmt.Fprintln(b, m.styles.help.Render("Save "+m.currentView().describe()+" as: "+v.name))
+	}
+	if m.err != nil {
+		fmt.Fprintln(b, m.styles.error.Render(m.err.Error()))
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseWarning records a config line sshpick could not make sense of.
+type parseWarning struct {
+	Path string
+	Line int
+	Msg  string
+}
+
+func (w parseWarning) String() string {
+	return fmt.Sprintf("%s:%d: %s", w.Path, w.Line, w.Msg)
+}
+
+// sshKeywords is the set of ssh_config(5) keywords, in their canonical case.
+var sshKeywords = []string{
+	"AddKeysToAgent", "AddressFamily", "BatchMode", "BindAddress", "BindInterface",
+	"CanonicalDomains", "CanonicalizeFallbackLocal", "CanonicalizeHostname",
+	"CanonicalizeMaxDots", "CanonicalizePermittedCNAMEs", "CASignatureAlgorithms",
+	"CertificateFile", "ChannelTimeout", "CheckHostIP", "Ciphers", "ClearAllForwardings",
+	"Compression", "ConnectionAttempts", "ConnectTimeout", "ControlMaster", "ControlPath",
+	"ControlPersist", "DynamicForward", "EnableEscapeCommandline", "EnableSSHKeysign",
+	"EscapeChar", "ExitOnForwardFailure", "FingerprintHash", "ForkAfterAuthentication",
+	"ForwardAgent", "ForwardX11", "ForwardX11Timeout", "ForwardX11Trusted",
+	"GatewayPorts", "GlobalKnownHostsFile", "GSSAPIAuthentication",
+	"GSSAPIDelegateCredentials", "GSSAPIKexAlgorithms", "GSSAPIKeyExchange",
+	"GSSAPIRenewalForcesRekey", "GSSAPIServerIdentity", "GSSAPITrustDns",
+	"HashKnownHosts", "Host", "HostbasedAcceptedAlgorithms", "HostbasedAuthentication",
+	"HostKeyAlgorithms", "HostKeyAlias", "Hostname", "IdentitiesOnly", "IdentityAgent",
+	"IdentityFile", "IgnoreUnknown", "Include", "IPQoS", "KbdInteractiveAuthentication",
+	"KbdInteractiveDevices", "KexAlgorithms", "KnownHostsCommand", "LocalCommand",
+	"LocalForward", "LogLevel", "LogVerbose", "MACs", "Match", "NoHostAuthenticationForLocalhost",
+	"NumberOfPasswordPrompts", "ObscureKeystrokeTiming", "PasswordAuthentication",
+	"PermitLocalCommand", "PermitRemoteOpen", "PKCS11Provider", "Port",
+	"PreferredAuthentications", "ProxyCommand", "ProxyJump", "ProxyUseFdpass",
+	"PubkeyAcceptedAlgorithms", "PubkeyAuthentication", "RekeyLimit", "RemoteCommand",
+	"RemoteForward", "RequestTTY", "RequiredRSASize", "RevokedHostKeys",
+	"SecurityKeyProvider", "SendEnv", "ServerAliveCountMax", "ServerAliveInterval",
+	"SessionType", "SetEnv", "StdinNull", "StreamLocalBindMask", "StreamLocalBindUnlink",
+	"StrictHostKeyChecking", "SyslogFacility", "Tag", "TCPKeepAlive", "Tunnel",
+	"TunnelDevice", "UpdateHostKeys", "UseKeychain", "User", "UserKnownHostsFile",
+	"VerifyHostKeyDNS", "VisualHostKey", "XAuthLocation",
+}
+
+var knownKeywords = func() map[string]string {
+	m := make(map[string]string, len(sshKeywords))
+	for _, k := range sshKeywords {
+		m[strings.ToLower(k)] = k
+	}
+	return m
+}()
+
+// directiveWarning checks a single directive and returns a reason or "".
+func directiveWarning(name, value string) string {
+	key := strings.ToLower(name)
+	if _, ok := knownKeywords[key]; !ok {
+		if s := suggestKeyword(key); s != "" {
+			return fmt.Sprintf("unknown directive %q (did you mean %q?)", name, s)
+		}
+		return fmt.Sprintf("unknown directive %q", name)
+	}
+	if value == "" {
+		return fmt.Sprintf("%s has no value", knownKeywords[key])
+	}
+	if key == "port" {
+		if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+			return fmt.Sprintf("invalid port %q", value)
+		}
+	}
+	return ""
+}
+
+// suggestKeyword returns the closest known keyword within a small edit distance.
+func suggestKeyword(key string) string {
+	best, bestDist := "", 3
+	for lower, canonical := range knownKeywords {
+		if d := editDistance(key, lower); d < bestDist || (d == bestDist && best != "" && canonical < best) {
+			best, bestDist = canonical, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// runLint implements `sshpick lint` and returns the process exit code.
+func runLint(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *cfgPath == "" {
+		*cfgPath = defaultConfigPath()
+	}
+	_, warnings, err := parseSSHConfigWarnings(*cfgPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading config:", err)
+		return 2
+	}
+	for _, w := range warnings {
+		fmt.Fprintln(stdout, w)
+	}
+	if len(warnings) > 0 {
+		return 1
+	}
+	return 0
+}
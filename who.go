@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	whoTimeout  = 10 * time.Second
+	whoCacheTTL = 2 * time.Minute
+)
+
+// whoResult is the cached `who` output for a host.
+type whoResult struct {
+	Lines   []string
+	Err     error
+	Fetched time.Time
+}
+
+type whoMsg struct {
+	alias  string
+	result whoResult
+}
+
+func fetchWhoCmd(h sshHost) tea.Cmd {
+	return func() tea.Msg {
+		out, err := runProbe(h, "who", whoTimeout)
+		res := whoResult{Err: err, Fetched: time.Now()}
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				res.Lines = append(res.Lines, line)
+			}
+		}
+		return whoMsg{alias: h.Alias, result: res}
+	}
+}
+
+// refreshWho fetches `who` for h unless a fresh result is cached or a fetch is
+// already running.
+func (m *model) refreshWho(h sshHost) tea.Cmd {
+	if skipsBatchProbes(h) || m.whoPending[h.Alias] {
+		return nil
+	}
+	if res, ok := m.who[h.Alias]; ok && time.Since(res.Fetched) < whoCacheTTL {
+		return nil
+	}
+	m.whoPending[h.Alias] = true
+	return fetchWhoCmd(h)
+}
+
+// whoLines renders the logged-in users section of the detail pane.
+func (m model) whoLines(h sshHost) []string {
+	if skipsBatchProbes(h) {
+		return []string{"Logged in:     (skipped for mfa/noprobe host)"}
+	}
+	if m.whoPending[h.Alias] {
+		return []string{"Logged in:     checking…"}
+	}
+	res, ok := m.who[h.Alias]
+	switch {
+	case !ok:
+		return nil
+	case res.Err != nil:
+		return []string{"Logged in:     unavailable (" + res.Err.Error() + ")"}
+	case len(res.Lines) == 0:
+		return []string{"Logged in:     nobody"}
+	}
+	out := []string{"Logged in:     (as of " + res.Fetched.Format("15:04:05") + ")"}
+	for _, l := range res.Lines {
+		out = append(out, "  "+l)
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestWhoToggleLeavesDetailPane(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel([]sshHost{{Alias: "db1"}}, "", "")
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = next.(model)
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	m = next.(model)
+	if !m.showWho || m.showDetail || cmd == nil || !m.whoPending["db1"] {
+		t.Fatalf("showWho %v, showDetail %v, cmd %v, pending %v", m.showWho, m.showDetail, cmd != nil, m.whoPending)
+	}
+	next, _ = m.Update(whoMsg{alias: "db1", result: whoResult{Lines: []string{"alice pts/0"}, Fetched: time.Now()}})
+	m = next.(model)
+	view := m.View()
+	if !strings.Contains(view, "alice pts/0") || strings.Contains(view, hostKeyLine(m.hosts[0])) {
+		t.Fatalf("expected only the who panel:\n%s", view)
+	}
+
+	next, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	m = next.(model)
+	if m.showWho || m.showDetail || cmd != nil || strings.Contains(m.View(), "alice pts/0") {
+		t.Fatalf("second u: showWho %v, showDetail %v", m.showWho, m.showDetail)
+	}
+
+	// With the detail pane open, u still only toggles who; the cached
+	// result is shown without another fetch.
+	m.showDetail = true
+	next, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	m = next.(model)
+	if !m.showWho || !m.showDetail || cmd != nil || !strings.Contains(m.View(), "alice pts/0") {
+		t.Fatalf("showWho %v, showDetail %v, cmd %v", m.showWho, m.showDetail, cmd != nil)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	if m = next.(model); m.showWho || !m.showDetail {
+		t.Fatalf("showWho %v, showDetail %v", m.showWho, m.showDetail)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// workspaceConfig is a named set of hosts brought up together in one tmux
+// session, one pane per host.
+type workspaceConfig struct {
+	Hosts    []string            `json:"hosts"`
+	Layout   string              `json:"layout,omitempty"`   // tmux layout, default "tiled"
+	Forwards map[string][]string `json:"forwards,omitempty"` // alias -> LocalForward specs for that host's pane
+}
+
+func (ws workspaceConfig) layout() string {
+	if ws.Layout == "" {
+		return "tiled"
+	}
+	return ws.Layout
+}
+
+// stringsFlag collects a repeatable flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string     { return strings.Join(*s, ",") }
+func (s *stringsFlag) Set(v string) error { *s = append(*s, v); return nil }
+
+// runWorkspace implements `sshpick workspace list|save|up|down`.
+func runWorkspace(args []string, stdout io.Writer) int {
+	usage := "usage: sshpick workspace list | save [-layout L] [-L alias=spec]... <name> <host>... | up <name> | down <name>"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	fs := flag.NewFlagSet("workspace", flag.ContinueOnError)
+	cfgPath := fs.String("config", "", "Path to ssh config (default: ~/.ssh/config)")
+	fs.StringVar(&sshDirOverride, "ssh-dir", "", "Directory with the ssh config and keys (default: ~/.ssh)")
+	settingsPath := fs.String("settings", defaultAppConfigPath(), "Path to sshpick's own config.json")
+	readOnly := fs.Bool("read-only", false, "Refuse to modify the settings file")
+	layout := fs.String("layout", "", "tmux layout for save (tiled, even-horizontal, main-vertical, ...)")
+	var forwards stringsFlag
+	fs.Var(&forwards, "L", "Local forward for save as alias=spec (repeatable)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	settings, err := loadAppConfig(*settingsPath)
+	if err == nil {
+		err = validHostKeyPolicy(settings.HostKeyPolicy)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading sshpick config:", err)
+		return 2
+	}
+	hostKeyPolicy = settings.HostKeyPolicy
+
+	switch args[0] {
+	case "list":
+		names := make([]string, 0, len(settings.Workspaces))
+		for name := range settings.Workspaces {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			ws := settings.Workspaces[name]
+			fmt.Fprintf(stdout, "%-16s %s (%s)\n", name, strings.Join(ws.Hosts, " "), ws.layout())
+		}
+		return 0
+	case "save":
+		if *readOnly || settings.ReadOnly {
+			fmt.Fprintln(os.Stderr, errReadOnly)
+			return 1
+		}
+		if fs.NArg() < 2 {
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+		ws := workspaceConfig{Hosts: fs.Args()[1:], Layout: *layout}
+		for _, f := range forwards {
+			alias, spec, ok := strings.Cut(f, "=")
+			if !ok || alias == "" || spec == "" {
+				fmt.Fprintf(os.Stderr, "-L %q: want alias=spec\n", f)
+				return 2
+			}
+			if ws.Forwards == nil {
+				ws.Forwards = map[string][]string{}
+			}
+			ws.Forwards[alias] = append(ws.Forwards[alias], spec)
+		}
+		if settings.Workspaces == nil {
+			settings.Workspaces = map[string]workspaceConfig{}
+		}
+		settings.Workspaces[fs.Arg(0)] = ws
+		if err := updateSettingsFile(*settingsPath, "workspaces", settings.Workspaces); err != nil {
+			fmt.Fprintln(os.Stderr, "error saving workspace:", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Saved workspace %s (%d hosts).\n", fs.Arg(0), len(ws.Hosts))
+		return 0
+	case "up", "down":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+		name := fs.Arg(0)
+		ws, ok := settings.Workspaces[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown workspace %q\n", name)
+			return 2
+		}
+		if args[0] == "down" {
+			if err := exec.Command("tmux", "kill-session", "-t", "="+name).Run(); err != nil {
+				fmt.Fprintln(os.Stderr, "tmux:", err)
+				return 1
+			}
+			return 0
+		}
+		if *cfgPath == "" {
+			*cfgPath = defaultConfigPath()
+		}
+		hosts, err := parseSSHConfig(*cfgPath)
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, "error reading config:", err)
+			return 2
+		}
+		if err := workspaceUp(name, ws, hosts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, usage)
+	return 2
+}
+
+// workspacePlan lists the tmux invocations that build the workspace session.
+// Hosts not in the ssh config are passed to ssh by name.
+func workspacePlan(name string, ws workspaceConfig, hosts []sshHost) ([][]string, error) {
+	if len(ws.Hosts) == 0 {
+		return nil, fmt.Errorf("workspace %s has no hosts", name)
+	}
+	byAlias := map[string]sshHost{}
+	for _, h := range hosts {
+		byAlias[h.Alias] = h
+	}
+	var plan [][]string
+	for i, alias := range ws.Hosts {
+		h, ok := byAlias[alias]
+		if !ok {
+			h = sshHost{Alias: alias}
+		}
+		argv := append([]string{"ssh"}, hostKeyArgs(true)...)
+		for _, spec := range ws.Forwards[alias] {
+			argv = append(argv, "-L", spec)
+		}
+		command := shellJoin(append(argv, hostArgs(h)...))
+		if i == 0 {
+			plan = append(plan, []string{"new-session", "-d", "-s", name, "-n", name, command})
+			continue
+		}
+		// Re-apply the layout after each split so panes never run out of room.
+		plan = append(plan,
+			[]string{"split-window", "-t", "=" + name, command},
+			[]string{"select-layout", "-t", "=" + name, ws.layout()})
+	}
+	return plan, nil
+}
+
+// workspaceUp creates the session (unless it's already running) and attaches.
+func workspaceUp(name string, ws workspaceConfig, hosts []sshHost) error {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return errors.New("workspaces need tmux")
+	}
+	if exec.Command("tmux", "has-session", "-t", "="+name).Run() != nil {
+		plan, err := workspacePlan(name, ws, hosts)
+		if err != nil {
+			return err
+		}
+		for _, args := range plan {
+			if out, err := exec.Command("tmux", args...).CombinedOutput(); err != nil {
+				return fmt.Errorf("tmux %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+			}
+		}
+	}
+	if os.Getenv("TMUX") != "" {
+		return exec.Command("tmux", "switch-client", "-t", "="+name).Run()
+	}
+	return execTool("tmux", []string{"attach-session", "-t", "=" + name})
+}
+
+// shellJoin quotes argv for sh, leaving plain words bare.
+func shellJoin(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, a := range argv {
+		if a != "" && strings.Trim(a, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:@=,+%") == "" {
+			quoted[i] = a
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// updateSettingsFile replaces one top-level key of the settings file,
+// keeping every other key as written.
+func updateSettingsFile(path, key string, value any) error {
+	if path == "" {
+		return errors.New("no settings file location")
+	}
+	doc := map[string]json.RawMessage{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	doc[key] = raw
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(out, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
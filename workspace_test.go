@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorkspacePlan(t *testing.T) {
+	t.Parallel()
+
+	ws := workspaceConfig{
+		Hosts:    []string{"web1", "db1"},
+		Forwards: map[string][]string{"db1": {"5432:localhost:5432"}},
+	}
+	hosts := []sshHost{{Alias: "web1"}, {Alias: "db1", Hostname: "10.0.0.5", User: "pg", Source: "team"}}
+	plan, err := workspacePlan("oncall", ws, hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, args := range plan {
+		got = append(got, strings.Join(args, " "))
+	}
+	want := []string{
+		"new-session -d -s oncall -n oncall ssh -- web1",
+		"split-window -t =oncall ssh -L 5432:localhost:5432 -- pg@10.0.0.5",
+		"select-layout -t =oncall tiled",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("plan =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if _, err := workspacePlan("empty", workspaceConfig{}, nil); err == nil {
+		t.Fatalf("expected an error for a workspace without hosts")
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	t.Parallel()
+
+	got := shellJoin([]string{"ssh", "-o", "ProxyCommand=nc %h %p", "it's"})
+	if got != `ssh -o 'ProxyCommand=nc %h %p' 'it'\''s'` {
+		t.Fatalf("shellJoin = %s", got)
+	}
+}
+
+func TestWorkspaceSaveKeepsOtherSettings(t *testing.T) {
+	t.Parallel()
+
+	settings := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(settings, []byte(`{"maintenance_file": "/srv/maint.json"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if code := runWorkspace([]string{"save", "-settings", settings, "-layout", "even-horizontal", "-L", "db1=5432:localhost:5432", "oncall", "web1", "db1"}, &out); code != 0 {
+		t.Fatalf("save exited %d", code)
+	}
+	cfg, err := loadAppConfig(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := cfg.Workspaces["oncall"]
+	if cfg.MaintenanceFile != "/srv/maint.json" || len(ws.Hosts) != 2 || ws.Layout != "even-horizontal" || ws.Forwards["db1"][0] != "5432:localhost:5432" {
+		t.Fatalf("unexpected settings after save: %#v", cfg)
+	}
+}